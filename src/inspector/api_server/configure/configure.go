@@ -41,6 +41,24 @@ type ApiServerGlobalConfigure struct {
 
 	CollectorTimeout int
 	StoreTimeout     int
+	InstantLookback  int // instant query向前查找数据的时间范围(秒)
+	QueryTimeout     int // 单个查询请求的全局超时时间(秒)，0表示不限制
+	QueryConcurrency int // 单个查询请求中并发查询的实例数
+	QueryCacheSize   int // 历史数据查询缓存的大小(MB)，0表示关闭缓存
+
+	// 单个查询的限制，0表示不限制
+	MaxQuerySeries     int // 最大曲线数(实例数*metric数)
+	MaxQueryPoints     int // 最大数据点数
+	MaxQueryInstances  int // 最大实例数
+	MaxQueryRange      int // 最大时间范围(秒)
+	SlowQueryThreshold int // 耗时超过该值(毫秒)的查询记录慢查询日志，0表示不记录
+
+	RemoteWriteDelay int // remote_write的存储单元结束后等待迟到数据的时间(秒)，之后落盘
+	RecordLookback   int // recording rule每次执行时重新计算的点数，用于修正迟到的数据
+
+	AlertWebhook        string // 告警发送的webhook地址，多个以","分隔
+	AlertInterval       int    // 告警规则的执行间隔(秒)，0表示关闭告警
+	AlertResendInterval int    // firing状态的告警重复发送的间隔(秒)
 
 	LocalConfigCache map[string]map[string]interface{}
 }
@@ -0,0 +1,117 @@
+/*
+// =====================================================================================
+//
+//       Filename:  bucketSamplingFilter.go
+//
+//    Description:  分桶聚合采样过滤器：平均值、最小值、最大值、最后一个值
+//                  每个桶输出一个点，时间点为桶的起始位置，与固定点采样对齐
+//
+//        Version:  1.0
+//        Created:  10/19/2026 03:12:26 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package filter
+
+import (
+	"inspector/util"
+	"math"
+)
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  bucketSamplingFilter
+//  Description:  reduce的输入为桶内的非空数据，桶内全部为空时输出空值
+// =====================================================================================
+*/
+func bucketSamplingFilter(input []int64, dataOutput []int64, indexOutput []int, reduce func([]int64) int64) error {
+	if err := checkBuffer(input, dataOutput, indexOutput); err != nil {
+		return err
+	}
+
+	var inputLen = len(input)
+	var outputLen = len(dataOutput)
+	var bucket = make([]int64, 0, inputLen/outputLen+1)
+	for i := 0; i < outputLen; i++ {
+		var l, r = bucketRange(i, inputLen, outputLen)
+		bucket = bucket[:0]
+		for _, it := range input[l:r] {
+			if it != util.NullData {
+				bucket = append(bucket, it)
+			}
+		}
+		indexOutput[i] = l
+		if len(bucket) == 0 {
+			dataOutput[i] = util.NullData
+		} else {
+			dataOutput[i] = reduce(bucket)
+		}
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  AvgSamplingFilter
+//  Description:  平均值采样过滤，四舍五入
+// =====================================================================================
+*/
+func AvgSamplingFilter(input []int64, dataOutput []int64, indexOutput []int) error {
+	return bucketSamplingFilter(input, dataOutput, indexOutput, func(bucket []int64) int64 {
+		var sum float64
+		for _, it := range bucket {
+			sum += float64(it)
+		}
+		return int64(math.Round(sum / float64(len(bucket))))
+	})
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  MinSamplingFilter
+//  Description:  最小值采样过滤
+// =====================================================================================
+*/
+func MinSamplingFilter(input []int64, dataOutput []int64, indexOutput []int) error {
+	return bucketSamplingFilter(input, dataOutput, indexOutput, func(bucket []int64) int64 {
+		var result = bucket[0]
+		for _, it := range bucket[1:] {
+			if it < result {
+				result = it
+			}
+		}
+		return result
+	})
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  MaxSamplingFilter
+//  Description:  最大值采样过滤
+// =====================================================================================
+*/
+func MaxSamplingFilter(input []int64, dataOutput []int64, indexOutput []int) error {
+	return bucketSamplingFilter(input, dataOutput, indexOutput, func(bucket []int64) int64 {
+		var result = bucket[0]
+		for _, it := range bucket[1:] {
+			if it > result {
+				result = it
+			}
+		}
+		return result
+	})
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  LastSamplingFilter
+//  Description:  最后一个值采样过滤，适用于counter等只关心最新值的数据
+// =====================================================================================
+*/
+func LastSamplingFilter(input []int64, dataOutput []int64, indexOutput []int) error {
+	return bucketSamplingFilter(input, dataOutput, indexOutput, func(bucket []int64) int64 {
+		return bucket[len(bucket)-1]
+	})
+}
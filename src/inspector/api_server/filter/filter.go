@@ -0,0 +1,102 @@
+/*
+// =====================================================================================
+//
+//       Filename:  filter.go
+//
+//    Description:  采样过滤器列表，查询时按照名称选择
+//
+//        Version:  1.0
+//        Created:  10/19/2026 03:05:52 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+)
+
+// 没有指定过滤器时使用的过滤器
+const DefaultFilterName = "fix"
+
+// =====================================================================================
+//         Type:  Filter
+//  Description:  将input采样为len(dataOutput)个点，indexOutput为每个点对应的input下标
+//                input长度不能小于dataOutput长度
+// =====================================================================================
+type Filter func(input []int64, dataOutput []int64, indexOutput []int) error
+
+var filterMap = map[string]Filter{
+	"fix":  FixedPointSamplingFilter,
+	"peak": PeakSamplingFilterAvg,
+	"lttb": LTTBSamplingFilter,
+	"avg":  AvgSamplingFilter,
+	"min":  MinSamplingFilter,
+	"max":  MaxSamplingFilter,
+	"last": LastSamplingFilter,
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  GetFilter
+//  Description:  按照名称返回过滤器，名称为空时返回默认过滤器
+// =====================================================================================
+*/
+func GetFilter(name string) (Filter, bool) {
+	if len(name) == 0 {
+		name = DefaultFilterName
+	}
+	var f, ok = filterMap[name]
+	return f, ok
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  FilterNameList
+//  Description:  返回所有过滤器的名称，按字典序排列
+// =====================================================================================
+*/
+func FilterNameList() []string {
+	var result = make([]string, 0, len(filterMap))
+	for name := range filterMap {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  checkBuffer
+//  Description:  检查输入输出buffer
+// =====================================================================================
+*/
+func checkBuffer(input []int64, dataOutput []int64, indexOutput []int) error {
+	if dataOutput == nil || len(indexOutput) < len(dataOutput) {
+		var errStr = fmt.Sprintf("filter dataOutput or indexOutput buffer is invalid")
+		glog.Errorf(errStr)
+		return errors.New(errStr)
+	}
+	if len(input) < len(dataOutput) {
+		var errStr = fmt.Sprintf("input len should be larger than dataOutput len")
+		glog.Errorf(errStr)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  bucketRange
+//  Description:  将长度为inputLen的输入平均分为outputLen个桶，返回第i个桶的[l, r)
+// =====================================================================================
+*/
+func bucketRange(i, inputLen, outputLen int) (int, int) {
+	return i * inputLen / outputLen, (i + 1) * inputLen / outputLen
+}
@@ -362,3 +362,150 @@ func TestPeakSamplingFilterAvg(t *testing.T) {
 
 	check(true, "test")
 }
+
+func TestBucketSamplingFilter(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	arrayEqualInt64 := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var input = []int64{1, 5, 3, null, 2, 8, null, null, null, 4}
+	var indexOutput = make([]int, 4)
+	var dataOutput = make([]int64, 4)
+
+	// case 1: 桶为[0,2) [2,5) [5,7) [7,10)
+	{
+		var err = AvgSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{3, 3, 8, 4}), "test")
+		check(arrayEqual(indexOutput, []int{0, 2, 5, 7}), "test")
+
+		err = MinSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{1, 2, 8, 4}), "test")
+
+		err = MaxSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{5, 3, 8, 4}), "test")
+
+		err = LastSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{5, 2, 8, 4}), "test")
+	}
+
+	// case 2: 桶内全部为空
+	{
+		var err = MaxSamplingFilter([]int64{1, null, null, 2}, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{1, null, null, 2}), "test")
+	}
+
+	// case 3: buffer错误
+	{
+		var err = AvgSamplingFilter([]int64{1, 2}, dataOutput, indexOutput)
+		check(err != nil, "test")
+		err = AvgSamplingFilter(input, dataOutput, make([]int, 2))
+		check(err != nil, "test")
+	}
+
+	// case 4: 按名称选择
+	{
+		var f, ok = GetFilter("")
+		check(ok && f != nil, "test")
+		_, ok = GetFilter("lttb")
+		check(ok, "test")
+		_, ok = GetFilter("unknown")
+		check(!ok, "test")
+		check(arrayEqualStr(FilterNameList(), []string{"avg", "fix", "last", "lttb", "max", "min", "peak"}), "test")
+	}
+}
+
+func TestLTTBSamplingFilter(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqualInt64 := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var indexOutput = make([]int, 4)
+	var dataOutput = make([]int64, 4)
+
+	// case 1: 保留首尾点和尖峰
+	{
+		var input = []int64{1, 1, 1, 9, 1, 1, 1, 0, 1, 2, 1, 7}
+		var err = LTTBSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{1, 9, 0, 7}), "test")
+	}
+
+	// case 2: 空值不参与计算
+	{
+		var input = []int64{null, 3, 0, null, null, null, 5, null}
+		var err = LTTBSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, []int64{3, 0, null, 5}), "test")
+	}
+
+	// case 3: 输出点数与输入相同
+	{
+		var input = []int64{4, 3, 2, 1}
+		var err = LTTBSamplingFilter(input, dataOutput, indexOutput)
+		check(err == nil, "test")
+		check(arrayEqualInt64(dataOutput, input), "test")
+	}
+}
+
+func arrayEqualStr(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := 0; i < len(x); i++ {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+/*
+// =====================================================================================
+//
+//       Filename:  lttbSamplingFilter.go
+//
+//    Description:  Largest-Triangle-Three-Buckets采样过滤器
+//                  每个桶选择与前一个选中点、下一个桶的平均点组成的三角形面积最大的点，
+//                  在点数很少时仍然能保留曲线的形状
+//
+//        Version:  1.0
+//        Created:  10/19/2026 03:20:41 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package filter
+
+import (
+	"inspector/util"
+	"math"
+)
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  LTTBSamplingFilter
+//  Description:  第一个有数据的桶选择第一个点，最后一个有数据的桶选择最后一个点，
+//                空值不参与计算，桶内全部为空时输出空值
+//                与PeakSamplingFilterAvg相同，indexOutput为桶的起始位置而不是选中点的位置，
+//                保证输出的时间点均匀
+// =====================================================================================
+*/
+func LTTBSamplingFilter(input []int64, dataOutput []int64, indexOutput []int) error {
+	if err := checkBuffer(input, dataOutput, indexOutput); err != nil {
+		return err
+	}
+
+	var inputLen = len(input)
+	var outputLen = len(dataOutput)
+
+	// 每个桶的平均点，next[i]为i之后第一个有数据的桶，不存在时为-1
+	var avgX = make([]float64, outputLen)
+	var avgY = make([]float64, outputLen)
+	var next = make([]int, outputLen)
+	var following = -1
+	for i := outputLen - 1; i >= 0; i-- {
+		next[i] = following
+		var l, r = bucketRange(i, inputLen, outputLen)
+		var n int
+		for k := l; k < r; k++ {
+			if input[k] == util.NullData {
+				continue
+			}
+			avgX[i] += float64(k)
+			avgY[i] += float64(input[k])
+			n++
+		}
+		if n > 0 {
+			avgX[i] /= float64(n)
+			avgY[i] /= float64(n)
+			following = i
+		}
+	}
+
+	var prevIndex = -1
+	for i := 0; i < outputLen; i++ {
+		var l, r = bucketRange(i, inputLen, outputLen)
+		indexOutput[i] = l
+
+		var selected = -1
+		switch {
+		case prevIndex < 0:
+			for k := l; k < r && selected < 0; k++ {
+				if input[k] != util.NullData {
+					selected = k
+				}
+			}
+		case next[i] < 0:
+			for k := r - 1; k >= l && selected < 0; k-- {
+				if input[k] != util.NullData {
+					selected = k
+				}
+			}
+		default:
+			var ax, ay = float64(prevIndex), float64(input[prevIndex])
+			var cx, cy = avgX[next[i]], avgY[next[i]]
+			var maxArea = -1.0
+			for k := l; k < r; k++ {
+				if input[k] == util.NullData {
+					continue
+				}
+				// 三角形面积的2倍
+				var area = math.Abs((ax-cx)*(float64(input[k])-ay) - (ax-float64(k))*(cy-ay))
+				if area > maxArea {
+					maxArea = area
+					selected = k
+				}
+			}
+		}
+
+		if selected < 0 {
+			dataOutput[i] = util.NullData
+			continue
+		}
+		dataOutput[i] = input[selected]
+		prevIndex = selected
+	}
+	return nil
+}
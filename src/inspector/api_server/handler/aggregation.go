@@ -0,0 +1,131 @@
+/*
+// =====================================================================================
+//
+//       Filename:  aggregation.go
+//
+//    Description:  跨实例聚合：按label分组后调用syntax.AggregateArray
+//
+//        Version:  1.0
+//        Created:  10/18/2026 06:47:03 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"inspector/api_server/syntax"
+	"inspector/util"
+	"sort"
+	"strings"
+)
+
+// 实例相关的label，聚合时按照by/without决定是否保留
+var instanceLabels = map[string]bool{
+	syntax.LabelHid:  true,
+	syntax.LabelPid:  true,
+	syntax.LabelHost: true,
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  groupLabels
+ *  Description:  返回聚合后保留的label，metric名称以及service总是保留，
+ *                不同的metric不会被聚合到一起
+ * =====================================================================================
+ */
+func (h *ApiHandler) groupLabels(agg *syntax.Aggregation, metric map[string]string) map[string]string {
+	var grouping = make(map[string]bool, len(agg.Grouping))
+	for _, it := range agg.Grouping {
+		grouping[it] = true
+	}
+
+	var labels = make(map[string]string, len(metric))
+	for key, value := range metric {
+		if instanceLabels[key] && grouping[key] == agg.Without {
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  labelsKey
+ *  Description:  将label集合转换为唯一的字符串，用于分组
+ * =====================================================================================
+ */
+func (h *ApiHandler) labelsKey(labels map[string]string) string {
+	var keyList = make([]string, 0, len(labels))
+	for key := range labels {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+
+	var key = make([]string, 0, len(keyList))
+	for _, it := range keyList {
+		key = append(key, it+"="+labels[it])
+	}
+	return strings.Join(key, ",")
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  aggregateSeries
+ *  Description:  metricLabelList与dataList一一对应，分组按照首次出现的顺序返回
+ *                topk/bottomk保留原始label，并丢弃全部为空值的曲线
+ * =====================================================================================
+ */
+func (h *ApiHandler) aggregateSeries(agg *syntax.Aggregation,
+	metricLabelList []map[string]string,
+	dataList [][]int64) ([]map[string]string, [][]int64) {
+
+	var groupOrder []string
+	var groupLabelMap = make(map[string]map[string]string)
+	var groupIndexMap = make(map[string][]int)
+	for i, metric := range metricLabelList {
+		if i >= len(dataList) {
+			break
+		}
+		var labels = h.groupLabels(agg, metric)
+		var key = h.labelsKey(labels)
+		if _, ok := groupIndexMap[key]; !ok {
+			groupOrder = append(groupOrder, key)
+			groupLabelMap[key] = labels
+		}
+		groupIndexMap[key] = append(groupIndexMap[key], i)
+	}
+
+	var labelResult []map[string]string
+	var dataResult [][]int64
+	for _, key := range groupOrder {
+		var indexList = groupIndexMap[key]
+		var group = make([][]int64, 0, len(indexList))
+		for _, i := range indexList {
+			group = append(group, dataList[i])
+		}
+
+		var result = syntax.AggregateArray(agg, group)
+		if !agg.KeepSeries() {
+			labelResult = append(labelResult, groupLabelMap[key])
+			dataResult = append(dataResult, result[0])
+			continue
+		}
+		for j, it := range result {
+			var empty = true
+			for _, value := range it {
+				if value != util.NullData {
+					empty = false
+					break
+				}
+			}
+			if !empty {
+				labelResult = append(labelResult, metricLabelList[indexList[j]])
+				dataResult = append(dataResult, it)
+			}
+		}
+	}
+	return labelResult, dataResult
+}
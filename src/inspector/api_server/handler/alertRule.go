@@ -0,0 +1,709 @@
+/*
+// =====================================================================================
+//
+//       Filename:  alertRule.go
+//
+//    Description:  告警规则，定期执行配置的查询语句，与阈值比较后产生告警，
+//                  告警经过pending、firing、resolved状态后以alertmanager的格式发送给webhook
+//                  规则保存在config server的alertRule中，与collector相同选举一个leader执行
+//
+//        Version:  1.0
+//        Created:  10/19/2026 02:41:15 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/heartbeat"
+	"inspector/util"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// leader在alertRule中的key，以InnerLeadingMark开头，不会被当作规则
+var alertLeaderName = string(util.InnerLeadingMark) + "elect_leader"
+
+// 发送webhook的超时时间
+const alertWebhookTimeout = 10 * time.Second
+
+// alertRule中的字段
+const (
+	alertExprName        = "expr"        // 查询语句，与query的query参数相同
+	alertOpName          = "op"          // 比较运算符，默认为">"
+	alertThresholdName   = "threshold"   // 阈值
+	alertForName         = "for"         // 持续时间，秒或者"5m"形式，默认为0
+	alertLabelsName      = "labels"      // 附加到告警上的label
+	alertAnnotationsName = "annotations" // 告警的说明，支持{{ $value }}与{{ $labels.host }}
+)
+
+// 告警状态
+const (
+	alertStatePending  = "pending"
+	alertStateFiring   = "firing"
+	alertStateResolved = "resolved"
+)
+
+// 告警固定带有的label
+const alertLabelName = "alertname"
+
+// 比较运算符
+var alertOpMap = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+	"==": func(value, threshold float64) bool { return value == threshold },
+	"!=": func(value, threshold float64) bool { return value != threshold },
+}
+
+var alertTemplateRegexp = regexp.MustCompile(`\{\{\s*\$(value|labels\.([a-zA-Z_][a-zA-Z0-9_]*))\s*\}\}`)
+
+// =====================================================================================
+//       Struct:  alertRule
+//  Description:  一条告警规则，查询结果中满足 value op threshold 的曲线持续for秒后告警
+// =====================================================================================
+type alertRule struct {
+	name        string
+	expr        string
+	op          string
+	threshold   float64
+	duration    int // for，秒
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// =====================================================================================
+//       Struct:  alertSample
+//  Description:  一条曲线在执行时刻的最新值
+// =====================================================================================
+type alertSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// =====================================================================================
+//       Struct:  alertInstance
+//  Description:  一条曲线产生的告警，lastSent记录每个webhook上次发送的时间，
+//                没有记录时需要发送给该webhook
+// =====================================================================================
+type alertInstance struct {
+	labels      map[string]string
+	annotations map[string]string
+	state       string
+	value       float64
+	activeAt    time.Time // 进入pending的时间
+	firedAt     time.Time
+	resolvedAt  time.Time
+	lastSent    map[string]time.Time // webhook -> 上次发送时间
+}
+
+// =====================================================================================
+//       Struct:  alertManager
+//  Description:  leader上所有规则的告警，alerts为规则名称 -> label key -> 告警
+// =====================================================================================
+type alertManager struct {
+	lock    sync.Mutex
+	alerts  map[string]map[string]*alertInstance
+	running bool
+}
+
+// =====================================================================================
+//       Struct:  webhookAlert
+//  Description:  alertmanager的/api/v2/alerts格式，status方便普通webhook区分告警与恢复
+// =====================================================================================
+type webhookAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Status      string            `json:"status"`
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  StartAlertRules
+ *  Description:  启动告警规则的执行，每个api_server都启动，由选举出的leader实际执行
+ * =====================================================================================
+ */
+func StartAlertRules() {
+	var interval = configure.Options.AlertInterval
+	if interval <= 0 {
+		glog.Infof("[AlertRule] alerting is disabled")
+		return
+	}
+	var manager = &alertManager{alerts: make(map[string]map[string]*alertInstance)}
+	go func() {
+		for now := range time.Tick(time.Duration(interval) * time.Second) {
+			new(ApiHandler).runAlertRules(manager, now)
+		}
+	}()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  runAlertRules
+ *  Description:  执行所有规则并发送告警，上一轮还没有结束时跳过
+ *                不是leader时清空告警状态，由新的leader重新计算；
+ *                读取leader出错时无法判断是否还是leader，跳过本轮并保留状态
+ * =====================================================================================
+ */
+func (h *ApiHandler) runAlertRules(manager *alertManager, now time.Time) {
+	manager.lock.Lock()
+	if manager.running {
+		manager.lock.Unlock()
+		glog.Warningf("[AlertRule] last evaluation is still running")
+		return
+	}
+	manager.running = true
+	manager.lock.Unlock()
+	defer func() {
+		manager.lock.Lock()
+		manager.running = false
+		manager.lock.Unlock()
+	}()
+
+	if leader, err := h.quorumLeader(util.AlertRuleCollection, alertLeaderName); err != nil {
+		glog.Errorf("[AlertRule] check leader error, skip this round: %s", err.Error())
+		return
+	} else if !leader {
+		manager.alerts = make(map[string]map[string]*alertInstance)
+		return
+	}
+
+	var ruleList, errList = h.loadAlertRules()
+	for _, it := range errList {
+		glog.Errorf("[AlertRule] %s", it.Error())
+	}
+
+	// 删除的规则不再告警，也不发送恢复
+	var ruleMap = make(map[string]bool, len(ruleList))
+	for _, rule := range ruleList {
+		ruleMap[rule.name] = true
+	}
+	for name := range manager.alerts {
+		if !ruleMap[name] {
+			delete(manager.alerts, name)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var sampleList = make([][]alertSample, len(ruleList))
+	var evalErrList = make([]error, len(ruleList))
+	for i, rule := range ruleList {
+		wg.Add(1)
+		go func(i int, rule *alertRule) {
+			defer wg.Done()
+			evalErrList[i] = h.runSafe(func() error {
+				var err error
+				sampleList[i], err = new(ApiHandler).evaluateAlertRule(rule, now)
+				return err
+			})
+		}(i, rule)
+	}
+	wg.Wait()
+
+	for i, rule := range ruleList {
+		if evalErrList[i] != nil {
+			// 查询出错时保持原有状态，避免误报恢复
+			glog.Errorf("[AlertRule] evaluate rule[%s] error: %s", rule.name, evalErrList[i].Error())
+			continue
+		}
+		var alerts, ok = manager.alerts[rule.name]
+		if !ok {
+			alerts = make(map[string]*alertInstance)
+			manager.alerts[rule.name] = alerts
+		}
+		h.updateAlerts(rule, alerts, sampleList[i], now)
+	}
+	h.notifyAlerts(manager.alerts, now)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  notifyAlerts
+ *  Description:  分别发送给每个webhook，失败的webhook下一轮只重发给自己，
+ *                恢复发送给所有webhook之后不再保留
+ * =====================================================================================
+ */
+func (h *ApiHandler) notifyAlerts(alertMap map[string]map[string]*alertInstance, now time.Time) {
+	var urlList = h.alertWebhooks()
+	for _, url := range urlList {
+		var noticeList []*alertInstance
+		for _, alerts := range alertMap {
+			noticeList = append(noticeList, h.pickNotices(alerts, url, now)...)
+		}
+		if len(noticeList) == 0 {
+			continue
+		}
+		if err := h.sendAlerts(url, noticeList, now); err != nil {
+			glog.Errorf("[AlertRule] send %d alerts to webhook[%s] error: %s", len(noticeList), url, err.Error())
+			continue
+		}
+		for _, it := range noticeList {
+			it.lastSent[url] = now
+		}
+	}
+
+	for _, alerts := range alertMap {
+		for key, it := range alerts {
+			if it.state != alertStateResolved {
+				continue
+			}
+			var done = true
+			for _, url := range urlList {
+				if _, ok := it.lastSent[url]; !ok {
+					done = false
+					break
+				}
+			}
+			if done {
+				delete(alerts, key)
+			}
+		}
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  quorumLeader
+ *  Description:  与collector的QuorumLeader相同：leader记录在collectionName的keyName中，
+ *                leader不存活时加锁后double check，再将自己设置为leader。
+ *                读取leader出错时返回错误；读到的leader不是自己时已经不是leader，
+ *                之后抢占失败只返回false
+ * =====================================================================================
+ */
+func (h *ApiHandler) quorumLeader(collectionName, keyName string) (bool, error) {
+	var cs = configure.Options.ConfigServer
+	var hb = configure.Options.HeartbeatServer
+	var current = hb.Conf.Service
+
+	var leader, err = cs.GetString(collectionName, keyName)
+	if err != nil {
+		if !util.IsNotFound(err) {
+			return false, fmt.Errorf("get leader from config server error: %s", err.Error())
+		}
+		leader = ""
+	}
+	if leader == current {
+		return true, nil
+	}
+	if len(leader) > 0 {
+		if status := hb.IsAlive(heartbeat.ModuleApi, leader); status == heartbeat.ServiceAlive || status == heartbeat.ServiceUnknown {
+			return false, nil
+		}
+	}
+	glog.Infof("[AlertRule] leader[%s] is dead, current[%s] try to quorum", leader, current)
+
+	if err = cs.Lock(collectionName, ""); err != nil {
+		glog.Infof("[AlertRule] lock %s error: %s", collectionName, err.Error())
+		return false, nil
+	}
+	defer cs.Unlock(collectionName, "")
+
+	// double check
+	var leader2 string
+	if leader2, err = cs.GetString(collectionName, keyName); err != nil {
+		if !util.IsNotFound(err) {
+			glog.Warningf("[AlertRule] double check get leader error: %s", err.Error())
+			return false, nil
+		}
+		leader2 = ""
+	}
+	if leader != leader2 {
+		glog.Warningf("[AlertRule] double check fail, leader[%s] leader2[%s]", leader, leader2)
+		return false, nil
+	}
+
+	if err = cs.SetItem(collectionName, keyName, current); err != nil {
+		glog.Errorf("[AlertRule] set leader[%s] error: %s", current, err.Error())
+		return false, nil
+	}
+	glog.Infof("[AlertRule] current[%s] quorum successfully", current)
+	return true, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  loadAlertRules
+ *  Description:  从config server读取所有规则，按照名称排序，配置错误的规则跳过并返回错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) loadAlertRules() ([]*alertRule, []error) {
+	var cs = configure.Options.ConfigServer
+	var nameList, err = cs.GetKeyList(util.AlertRuleCollection)
+	if err != nil {
+		if util.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("get rule list error: %s", err.Error())}
+	}
+	sort.Strings(nameList)
+
+	var ruleList []*alertRule
+	var errList []error
+	for _, name := range nameList {
+		var doc map[string]interface{}
+		if doc, err = cs.GetMap(util.AlertRuleCollection, name); err != nil {
+			errList = append(errList, fmt.Errorf("get rule[%s] error: %s", name, err.Error()))
+			continue
+		}
+		var rule *alertRule
+		if rule, err = h.newAlertRule(name, doc); err != nil {
+			errList = append(errList, fmt.Errorf("rule[%s]: %s", name, err.Error()))
+			continue
+		}
+		ruleList = append(ruleList, rule)
+	}
+	return ruleList, errList
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  newAlertRule
+ *  Description:  由config server中的文档创建规则，数字可能是mongo中的任意数字类型
+ * =====================================================================================
+ */
+func (h *ApiHandler) newAlertRule(name string, doc map[string]interface{}) (*alertRule, error) {
+	var rule = &alertRule{
+		name:        name,
+		op:          ">",
+		labels:      make(map[string]string),
+		annotations: make(map[string]string),
+	}
+	var ok bool
+	if rule.expr, ok = doc[alertExprName].(string); !ok || len(rule.expr) == 0 {
+		return nil, fmt.Errorf("%s is not configured", alertExprName)
+	}
+	if op, exist := doc[alertOpName]; exist {
+		if rule.op, ok = op.(string); !ok || alertOpMap[rule.op] == nil {
+			return nil, fmt.Errorf("%s[%v] is invalid, expected one of >, >=, <, <=, == or !=", alertOpName, op)
+		}
+	}
+	if rule.threshold, ok = alertNumber(doc[alertThresholdName]); !ok {
+		return nil, fmt.Errorf("%s[%v] is not a number", alertThresholdName, doc[alertThresholdName])
+	}
+	switch duration := doc[alertForName].(type) {
+	case nil:
+	case string:
+		var seconds, err = parseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%s] is invalid: %s", alertForName, duration, err.Error())
+		}
+		rule.duration = seconds
+	default:
+		var seconds, ok = alertNumber(duration)
+		if !ok || seconds < 0 {
+			return nil, fmt.Errorf("%s[%v] is invalid", alertForName, duration)
+		}
+		rule.duration = int(seconds)
+	}
+	for _, it := range []struct {
+		field  string
+		output map[string]string
+	}{{alertLabelsName, rule.labels}, {alertAnnotationsName, rule.annotations}} {
+		if doc[it.field] == nil {
+			continue
+		}
+		var m, ok = doc[it.field].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s should be a map", it.field)
+		}
+		for key, value := range m {
+			it.output[key] = fmt.Sprint(value)
+		}
+	}
+	rule.labels[alertLabelName] = name
+	return rule, nil
+}
+
+func alertNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		var f, err = strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  evaluateAlertRule
+ *  Description:  查询每条曲线最近的值，与query接口相同同时查询collector与store，
+ *                只向前查找一个执行周期，曲线没有新数据时视为不满足条件
+ * =====================================================================================
+ */
+func (h *ApiHandler) evaluateAlertRule(rule *alertRule, now time.Time) ([]alertSample, error) {
+	var stmt, err = h.parseQueryStatement(rule.expr)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s[%s] error: %s", alertExprName, rule.expr, err.Error())
+	}
+	var instanceList []map[string]string
+	if instanceList, err = h.selectInstances(stmt); err != nil {
+		return nil, err
+	}
+	var dataStep int
+	if dataStep, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, stmt.service, util.IntervalName); err != nil || dataStep <= 0 {
+		dataStep = 1
+	}
+
+	var lookback = uint32(configure.Options.AlertInterval + dataStep)
+	var evalTime = uint32(now.Unix())
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Duration(configure.Options.AlertInterval)*time.Second)
+	defer cancel()
+	var virtualStartTime, metricLabelList, _, dataList, warnings, queryErr = h.doQueryStatement(ctx, stmt, instanceList, evalTime-lookback, evalTime+uint32(dataStep), dataStep)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	for _, it := range warnings {
+		glog.Warningf("[AlertRule] rule[%s]: %s", rule.name, it)
+	}
+
+	var sampleList []alertSample
+	for i, metric := range metricLabelList {
+		if i >= len(dataList) {
+			break
+		}
+		var _, value, ok = h.pickInstantValue(dataList[i], virtualStartTime, uint32(dataStep), evalTime)
+		if !ok {
+			continue
+		}
+		sampleList = append(sampleList, alertSample{
+			labels: h.alertSeriesLabels(metric),
+			value:  float64(value) / util.FloatMultiple,
+		})
+	}
+	return sampleList, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  alertSeriesLabels
+ *  Description:  将曲线的label转换为告警的label，field1...fieldN合并为metric
+ * =====================================================================================
+ */
+func (h *ApiHandler) alertSeriesLabels(metric map[string]string) map[string]string {
+	var labels = make(map[string]string, len(metric))
+	var fieldList []string
+	for i := 1; ; i++ {
+		var value, ok = metric[fmt.Sprintf("field%d", i)]
+		if !ok {
+			break
+		}
+		fieldList = append(fieldList, value)
+	}
+	for key, value := range metric {
+		if key == "name" || strings.HasPrefix(key, "field") {
+			continue
+		}
+		labels[key] = value
+	}
+	if len(fieldList) > 0 {
+		labels["metric"] = strings.Join(fieldList, "|")
+	}
+	return labels
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  updateAlerts
+ *  Description:  根据本次的执行结果更新规则的告警状态：
+ *                满足条件的曲线进入pending，持续for秒后进入firing，
+ *                不再满足条件时pending直接删除，firing进入resolved
+ * =====================================================================================
+ */
+func (h *ApiHandler) updateAlerts(rule *alertRule, alerts map[string]*alertInstance, sampleList []alertSample, now time.Time) {
+	var compare = alertOpMap[rule.op]
+	var active = make(map[string]bool)
+	for _, sample := range sampleList {
+		if !compare(sample.value, rule.threshold) {
+			continue
+		}
+		// 规则的label优先
+		var labels = make(map[string]string, len(sample.labels)+len(rule.labels))
+		for key, value := range sample.labels {
+			labels[key] = value
+		}
+		for key, value := range rule.labels {
+			labels[key] = value
+		}
+		var key = h.labelsKey(labels)
+		active[key] = true
+
+		var alert, ok = alerts[key]
+		if !ok || alert.state == alertStateResolved {
+			alert = &alertInstance{
+				labels:   labels,
+				state:    alertStatePending,
+				activeAt: now,
+				lastSent: make(map[string]time.Time),
+			}
+			alerts[key] = alert
+		}
+		alert.value = sample.value
+		alert.annotations = h.expandAnnotations(rule.annotations, labels, sample.value)
+		if alert.state == alertStatePending && now.Sub(alert.activeAt) >= time.Duration(rule.duration)*time.Second {
+			alert.state = alertStateFiring
+			alert.firedAt = now
+			alert.lastSent = make(map[string]time.Time)
+		}
+	}
+
+	for key, alert := range alerts {
+		if active[key] {
+			continue
+		}
+		switch alert.state {
+		case alertStatePending:
+			delete(alerts, key)
+		case alertStateFiring:
+			alert.state = alertStateResolved
+			alert.resolvedAt = now
+			alert.lastSent = make(map[string]time.Time)
+		}
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pickNotices
+ *  Description:  返回需要发送给url的告警：新的firing与resolved，以及超过重发间隔的firing
+ *                firing按照重发间隔发送，alertmanager依靠重发判断告警仍然存在
+ * =====================================================================================
+ */
+func (h *ApiHandler) pickNotices(alerts map[string]*alertInstance, url string, now time.Time) []*alertInstance {
+	var resend = time.Duration(configure.Options.AlertResendInterval) * time.Second
+	var result []*alertInstance
+	for _, alert := range alerts {
+		switch alert.state {
+		case alertStateFiring:
+			if lastSent, ok := alert.lastSent[url]; !ok || now.Sub(lastSent) >= resend {
+				result = append(result, alert)
+			}
+		case alertStateResolved:
+			if _, ok := alert.lastSent[url]; !ok {
+				result = append(result, alert)
+			}
+		}
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  expandAnnotations
+ *  Description:  替换annotation中的{{ $value }}与{{ $labels.name }}
+ * =====================================================================================
+ */
+func (h *ApiHandler) expandAnnotations(annotations map[string]string, labels map[string]string, value float64) map[string]string {
+	var result = make(map[string]string, len(annotations))
+	for key, text := range annotations {
+		result[key] = alertTemplateRegexp.ReplaceAllStringFunc(text, func(s string) string {
+			var match = alertTemplateRegexp.FindStringSubmatch(s)
+			if match[1] == "value" {
+				return strconv.FormatFloat(value, 'f', -1, 64)
+			}
+			return labels[match[2]]
+		})
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  alerts2webhook
+ *  Description:  firing的endsAt为4个重发间隔之后，leader切换或者退出后告警会自动恢复
+ * =====================================================================================
+ */
+func (h *ApiHandler) alerts2webhook(alertList []*alertInstance, now time.Time) []*webhookAlert {
+	var resend = time.Duration(configure.Options.AlertResendInterval) * time.Second
+	var result = make([]*webhookAlert, 0, len(alertList))
+	for _, alert := range alertList {
+		var it = &webhookAlert{
+			Labels:      alert.labels,
+			Annotations: alert.annotations,
+			StartsAt:    alert.firedAt,
+			EndsAt:      now.Add(4 * resend),
+			Status:      alertStateFiring,
+		}
+		if alert.state == alertStateResolved {
+			it.EndsAt = alert.resolvedAt
+			it.Status = alertStateResolved
+		}
+		result = append(result, it)
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  sendAlerts
+ *  Description:  发送给一个webhook，url为空表示没有配置webhook，直接丢弃
+ * =====================================================================================
+ */
+func (h *ApiHandler) sendAlerts(url string, alertList []*alertInstance, now time.Time) error {
+	var body, err = json.Marshal(h.alerts2webhook(alertList, now))
+	if err != nil {
+		return err
+	}
+	if len(url) == 0 {
+		glog.Warningf("[AlertRule] no webhook configured, drop alerts: %s", body)
+		return nil
+	}
+
+	var client = &http.Client{Timeout: alertWebhookTimeout}
+	var res *http.Response
+	if res, err = client.Post(url, "application/json", bytes.NewReader(body)); err != nil {
+		return err
+	}
+	var content, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returns %d: %s", res.StatusCode, content)
+	}
+	return nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  alertWebhooks
+ *  Description:  逗号分隔的webhook列表，没有配置时返回一个空字符串，告警只记录日志
+ * =====================================================================================
+ */
+func (h *ApiHandler) alertWebhooks() []string {
+	var urlList []string
+	for _, it := range strings.Split(configure.Options.AlertWebhook, ",") {
+		if it = strings.TrimSpace(it); len(it) > 0 {
+			urlList = append(urlList, it)
+		}
+	}
+	if len(urlList) == 0 {
+		return []string{""}
+	}
+	return urlList
+}
@@ -0,0 +1,237 @@
+/*
+// =====================================================================================
+//
+//       Filename:  apiError.go
+//
+//    Description:  prometheus格式的错误返回、参数解析以及panic恢复
+//
+//        Version:  1.0
+//        Created:  10/18/2026 09:26:14 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inspector/util"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// 单条曲线允许返回的最大点数，与prometheus相同
+const maxPointsPerSeries = 11000
+
+// =====================================================================================
+//         Type:  errorType
+//  Description:  与prometheus HTTP API的errorType取值相同
+// =====================================================================================
+type errorType string
+
+const (
+	errorBadData     errorType = "bad_data"
+	errorExecution   errorType = "execution"
+	errorInternal    errorType = "internal"
+	errorTimeout     errorType = "timeout"
+	errorCanceled    errorType = "canceled"
+	errorUnavailable errorType = "unavailable"
+	errorNotFound    errorType = "not_found"
+)
+
+// 各类错误对应的http状态码
+var errorStatusMap = map[errorType]int{
+	errorBadData:     http.StatusBadRequest,
+	errorExecution:   http.StatusUnprocessableEntity,
+	errorInternal:    http.StatusInternalServerError,
+	errorTimeout:     http.StatusServiceUnavailable,
+	errorCanceled:    499, // client closed request
+	errorUnavailable: http.StatusServiceUnavailable,
+	errorNotFound:    http.StatusNotFound,
+}
+
+// =====================================================================================
+//       Struct:  apiError
+//  Description:  带有错误类型的错误，不是apiError的错误按照internal处理
+// =====================================================================================
+type apiError struct {
+	typ errorType
+	err error
+}
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func newApiError(typ errorType, format string, args ...interface{}) *apiError {
+	return &apiError{typ: typ, err: fmt.Errorf(format, args...)}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  metaError
+ *  Description:  读取service的meta信息出错，不存在时认为是请求错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) metaError(service, field string, err error) *apiError {
+	if util.IsNotFound(err) {
+		return newApiError(errorBadData, "%s of service[%s] not found", field, service)
+	}
+	return newApiError(errorInternal, "get %s of service[%s] error: %s", field, service, err.Error())
+}
+
+/*
+// =====================================================================================
+// grafana data model
+// =====================================================================================
+*/
+type PrometheusErrorModel struct {
+	Status    string       `json:"status"` // error
+	ErrorType string       `json:"errorType"`
+	Error     string       `json:"error"`
+	Trace     *traceResult `json:"trace,omitempty"`
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  writeError
+ *  Description:  按照prometheus格式返回错误，并设置对应的http状态码
+ * =====================================================================================
+ */
+func (h *ApiHandler) writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	var ok bool
+	if apiErr, ok = err.(*apiError); !ok {
+		apiErr = &apiError{typ: errorInternal, err: err}
+	}
+	var code, exist = errorStatusMap[apiErr.typ]
+	if !exist {
+		code = http.StatusInternalServerError
+	}
+	if code >= http.StatusInternalServerError {
+		glog.Errorf("response error[%s]: %s", apiErr.typ, apiErr.Error())
+	} else {
+		glog.Warningf("response error[%s]: %s", apiErr.typ, apiErr.Error())
+	}
+
+	var resultBytes, _ = json.Marshal(&PrometheusErrorModel{
+		Status:    "error",
+		ErrorType: string(apiErr.typ),
+		Error:     apiErr.Error(),
+		Trace:     h.trace.result(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, string(resultBytes))
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  Recover
+ *  Description:  捕获单个请求中的panic，返回internal错误，不影响其他请求
+ * =====================================================================================
+ */
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if e := recover(); e != nil {
+				glog.Errorf("panic in request[%v]: %v", r.URL, e)
+				util.PrintStack()
+				new(ApiHandler).writeError(w, newApiError(errorInternal, "unexpected error: %v", e))
+			}
+		}()
+		next(w, r)
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  runSafe
+ *  Description:  在goroutine中执行f，panic时转换为internal错误，Recover无法捕获其他goroutine的panic
+ * =====================================================================================
+ */
+func (h *ApiHandler) runSafe(f func() error) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			glog.Errorf("panic in goroutine: %v", e)
+			util.PrintStack()
+			err = newApiError(errorInternal, "unexpected error: %v", e)
+		}
+	}()
+	return f()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  contextError
+ *  Description:  请求超时或者客户端断开连接
+ * =====================================================================================
+ */
+func (h *ApiHandler) contextError(err error) *apiError {
+	if err == context.DeadlineExceeded {
+		return newApiError(errorTimeout, "query timed out")
+	}
+	return newApiError(errorCanceled, "query canceled: %s", err.Error())
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseForm
+ *  Description:  合并url中的参数与POST表单中的参数
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseForm(r *http.Request) (url.Values, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, newApiError(errorBadData, "invalid parameter: %s", err.Error())
+	}
+	return r.Form, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseDuration
+ *  Description:  解析prometheus格式的step参数，支持秒数(可带小数)以及"15s"、"1m"等格式
+ *                不足1秒时按1秒处理
+ * =====================================================================================
+ */
+func parseDuration(input string) (int, error) {
+	var seconds float64
+	if d, err := strconv.ParseFloat(input, 64); err == nil {
+		seconds = d
+	} else if d, err := time.ParseDuration(input); err == nil {
+		seconds = d.Seconds()
+	} else {
+		return 0, fmt.Errorf("cannot parse %q to a valid duration", input)
+	}
+	if math.IsNaN(seconds) || seconds <= 0 {
+		return 0, errors.New("zero or negative duration is not accepted")
+	}
+	if seconds > math.MaxInt32 {
+		return 0, errors.New("duration out of range")
+	}
+	return int(math.Max(1, math.Ceil(seconds))), nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  requireParam
+ *  Description:  返回必填参数，参数不存在或者为空时返回bad_data错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) requireParam(params url.Values, name string) (string, error) {
+	var value = strings.TrimSpace(params.Get(name))
+	if len(value) == 0 {
+		return "", newApiError(errorBadData, "parameter %q is required", name)
+	}
+	return value, nil
+}
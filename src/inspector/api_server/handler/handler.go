@@ -28,6 +28,7 @@ type ApiHandler struct {
 	timeTickerCount     int
 	perfTimeConsumeList []perfTimeConsume
 	allTimeConsume      time.Duration
+	trace               *queryTrace // queryContext中创建
 }
 
 /*
@@ -16,13 +16,36 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
 	"inspector/compress"
+	"inspector/config"
+	"inspector/heartbeat"
+	"inspector/proto/core"
+	"inspector/proto/prometheus"
+	"inspector/proto/store"
 	"inspector/util"
+	"inspector/util/grpc2"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestParsePanelQuery(t *testing.T) {
@@ -513,6 +536,1551 @@ func TestMergeDataMap(t *testing.T) {
 	check(true, "test")
 }
 
+func TestTrimLookback(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: 数据足够时截掉lookback个点
+	{
+		var dataList = [][]int64{{1, 2, 3, 4}, {5, 6, 7, 8}}
+		check(h.trimLookback(dataList, 2) == 2, "test")
+		check(reflect.DeepEqual(dataList, [][]int64{{3, 4}, {7, 8}}), fmt.Sprint(dataList))
+	}
+
+	// case 2: 数据不足时只截掉实际有的点数
+	{
+		var dataList = [][]int64{{1, 2}, {3, 4}}
+		check(h.trimLookback(dataList, 5) == 2, "test")
+		check(len(dataList[0]) == 0 && len(dataList[1]) == 0, fmt.Sprint(dataList))
+		check(h.trimLookback(nil, 5) == 5, "test")
+	}
+
+	check(true, "test")
+}
+
+func TestPickInstantValue(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: 取at之前最近的有效值
+	{
+		var data = []int64{1, 2, 3, util.NullData, util.NullData}
+		timestamp, value, ok := h.pickInstantValue(data, 100, 1, 104)
+		check(ok, "test")
+		check(timestamp == 102, "test")
+		check(value == 3, "test")
+	}
+
+	// case 2: at之后的数据不可见，virtual step需要还原为真实时间
+	{
+		var data = []int64{1, 2, 3, 4, 5}
+		timestamp, value, ok := h.pickInstantValue(data, 50, 2, 105)
+		check(ok, "test")
+		check(timestamp == 104, "test")
+		check(value == 3, "test")
+	}
+
+	// case 3: 没有有效数据
+	{
+		var data = []int64{util.NullData, util.NullData}
+		_, _, ok := h.pickInstantValue(data, 100, 1, 200)
+		check(!ok, "test")
+	}
+
+	// case 4: 向前查找的起始时间不会回绕
+	{
+		check(h.lookbackStart(1000, 300) == 700, "test")
+		check(h.lookbackStart(100, 300) == 0, "test")
+		check(h.lookbackStart(300, 300) == 0, "test")
+	}
+
+	// case 5: vector中所有元素的时间都是执行时刻
+	{
+		var labelList = []map[string]string{{"host": "a"}, {"host": "b"}, {"host": "c"}}
+		var dataList = [][]int64{
+			{1000, 2000, 3000, util.NullData},
+			{4000, util.NullData, util.NullData, util.NullData},
+			{util.NullData, util.NullData, util.NullData, util.NullData},
+		}
+		var result PrometheusQueryModel
+		check(json.Unmarshal([]byte(h.array2vector(labelList, 100, 1, 103, dataList, nil)), &result) == nil, "test")
+		check(len(result.Data.Result) == 2, fmt.Sprint(result.Data.Result))
+		check(fmt.Sprint(result.Data.Result[0].Value) == "[103 3]", fmt.Sprint(result.Data.Result[0].Value))
+		check(fmt.Sprint(result.Data.Result[1].Value) == "[103 4]", fmt.Sprint(result.Data.Result[1].Value))
+	}
+
+	check(true, "test")
+}
+
+func TestParseTimestamp(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var ts uint32
+	var err error
+
+	ts, err = parseTimestamp("1539590400")
+	check(err == nil && ts == 1539590400, "test")
+	ts, err = parseTimestamp("1539590400.781")
+	check(err == nil && ts == 1539590400, "test")
+	ts, err = parseTimestamp("2018-10-15T08:00:00Z")
+	check(err == nil && ts == 1539590400, "test")
+	_, err = parseTimestamp("yesterday")
+	check(err != nil, "test")
+
+	check(true, "test")
+}
+
+func TestParseRangeParams(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+	var params = func(query string) url.Values {
+		var v, _ = url.ParseQuery(query)
+		return v
+	}
+	var errType = func(err error) errorType {
+		if e, ok := err.(*apiError); ok {
+			return e.typ
+		}
+		return ""
+	}
+
+	// case 1
+	{
+		var query, start, end, step, err = h.parseRangeParams(params("query=redis|qps&start=1539590400&end=1539594000.5&step=1m"))
+		check(err == nil, "test")
+		check(query == "redis|qps" && start == 1539590400 && end == 1539594000 && step == 60, "test")
+
+		_, _, _, step, err = h.parseRangeParams(params("query=redis|qps&start=1539590400&end=1539594000&step=0.5"))
+		check(err == nil && step == 1, "test")
+	}
+
+	// case 2: 参数缺失或者非法
+	{
+		var _, _, _, _, err = h.parseRangeParams(params("start=1&end=2&step=1"))
+		check(errType(err) == errorBadData, "test")
+		_, _, _, _, err = h.parseRangeParams(params("query=redis|qps&start=1&end=2"))
+		check(errType(err) == errorBadData, "test")
+		_, _, _, _, err = h.parseRangeParams(params("query=redis|qps&start=now&end=2&step=1"))
+		check(errType(err) == errorBadData, "test")
+		_, _, _, _, err = h.parseRangeParams(params("query=redis|qps&start=3&end=2&step=1"))
+		check(errType(err) == errorBadData, "test")
+		_, _, _, _, err = h.parseRangeParams(params("query=redis|qps&start=1&end=2&step=-1"))
+		check(errType(err) == errorBadData, "test")
+		_, _, _, _, err = h.parseRangeParams(params("query=redis|qps&start=0&end=86400&step=1"))
+		check(errType(err) == errorBadData, "test")
+	}
+
+	check(true, "test")
+}
+
+func TestWriteError(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1
+	{
+		var w = httptest.NewRecorder()
+		h.writeError(w, newApiError(errorBadData, "parameter %q is required", "query"))
+		check(w.Code == http.StatusBadRequest, "test")
+		var result PrometheusErrorModel
+		check(json.Unmarshal(w.Body.Bytes(), &result) == nil, "test")
+		check(result.Status == "error" && result.ErrorType == "bad_data", "test")
+		check(result.Error == `parameter "query" is required`, result.Error)
+	}
+
+	// case 2: 普通error按照internal处理
+	{
+		var w = httptest.NewRecorder()
+		h.writeError(w, errors.New("oops"))
+		check(w.Code == http.StatusInternalServerError, "test")
+	}
+
+	// case 3: panic恢复
+	{
+		var w = httptest.NewRecorder()
+		Recover(func(w http.ResponseWriter, r *http.Request) {
+			var m map[string]string
+			m["a"] = "b"
+		})(w, httptest.NewRequest("GET", "/api/v1/query", nil))
+		check(w.Code == http.StatusInternalServerError, "test")
+		check(bytes.Contains(w.Body.Bytes(), []byte(`"errorType":"internal"`)), w.Body.String())
+	}
+
+	check(true, "test")
+}
+
+func TestDoQueryStatement(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+	var stmt = &queryStatement{service: "redis", metricList: []string{"qps"}}
+	var instanceList = []map[string]string{
+		{"hid": "1", "pid": "0", "host": "10.1.1.1:3001"},
+		{"hid": "2", "pid": "0", "host": "10.1.1.2:3001"},
+	}
+
+	// case 1: timeout参数只能缩短deadline
+	{
+		var r = httptest.NewRequest("GET", "/api/v1/query_range?timeout=2s", nil)
+		var ctx, cancel, err = h.queryContext(r, r.URL.Query())
+		check(err == nil, "test")
+		var deadline, ok = ctx.Deadline()
+		check(ok && time.Until(deadline) <= 2*time.Second, "test")
+		cancel()
+
+		_, _, err = h.queryContext(r, url.Values{"timeout": {"abc"}})
+		check(err != nil && err.(*apiError).typ == errorBadData, "test")
+	}
+
+	// case 2: 请求被取消
+	{
+		var ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		var _, _, _, _, _, err = h.doQueryStatement(ctx, stmt, instanceList, 0, 60, 1)
+		check(err != nil && err.(*apiError).typ == errorCanceled, fmt.Sprint(err))
+	}
+
+	// case 3: 实例查询中的panic被转换为错误，每个实例一条warning
+	{
+		var _, _, _, data, warnings, err = h.doQueryStatement(context.Background(), stmt, instanceList, 0, 60, 1)
+		check(err != nil && err.(*apiError).typ == errorInternal, fmt.Sprint(err))
+		check(data == nil && len(warnings) == 2, fmt.Sprint(warnings))
+	}
+
+	check(true, "test")
+}
+
+func TestParseQueryStatement(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: 新语法
+	{
+		var stmt, err = h.parseQueryStatement(`mongo|c1|cpu, mongo|m1|memory [arrayAdd($1, $2)] {hid="4931065", host=~"11\.218\..*"}`)
+		check(err == nil, "test")
+		check(!stmt.legacy, "test")
+		check(stmt.service == "mongo", "test")
+		check(len(stmt.metricList) == 2, "test")
+		check(stmt.metricList[0] == "c1|cpu", "test")
+		check(stmt.metricList[1] == "m1|memory", "test")
+		check(stmt.opExpression == "arrayAdd($1, $2)", "test")
+		check(len(stmt.matchers) == 2, "test")
+	}
+
+	// case 2: 旧语法兼容
+	{
+		var stmt, err = h.parseQueryStatement("mongo|c1|c2|c3|cpu [arrayDiff($1)] {hostId=dds-2ze2ae2045feb3e4{hid=4931065}, host=11.218.80.161:3079-P}")
+		check(err == nil, "test")
+		check(stmt.legacy, "test")
+		check(stmt.service == "mongo", "test")
+		check(stmt.metricList[0] == "c1|c2|c3|cpu", "test")
+		check(stmt.opExpression == "arrayDiff($1)", "test")
+		check(stmt.instanceSelector["hid"] == "4931065", "test")
+		check(stmt.instanceSelector["host"] == "11.218.80.161:3079", "test")
+	}
+
+	// case 3: 新语法错误不会回退到旧语法
+	{
+		var _, err = h.parseQueryStatement(`mongo|cpu{hid="1", hostId="x"}`)
+		check(err != nil, "test")
+
+		// 不符合旧语法形式的语句直接返回语法错误
+		for _, query := range []string{
+			"rate(redis|qps[1m]",
+			"sum by (hid) (redis|qps",
+			"redis|qps {hid=~1}",
+			"redis|qps {hid=1} offset",
+		} {
+			_, err = h.parseQueryStatement(query)
+			var _, ok = err.(*syntax.ParseError)
+			check(err != nil && ok, fmt.Sprintf("query[%s] err[%v]", query, err))
+		}
+		check(h.isLegacyStatement("redis|qps {hostId=h1; host=1.1.1.1:3079-P}"), "test")
+		check(h.isLegacyStatement("reg(redis|q.*) {hid=1}"), "test")
+	}
+
+	// case 4: 过滤器，查询函数优先于请求参数
+	{
+		var stmt, err = h.parseQueryStatement(`downsample(mongo|cpu {hid="1"}, "lttb")`)
+		check(err == nil && stmt.downsample == "lttb", "test")
+		err = h.parseDownsample(url.Values{"downsample": {"max"}}, stmt)
+		check(err == nil && stmt.downsample == "lttb", "test")
+
+		stmt, err = h.parseQueryStatement(`mongo|cpu {hid="1"}`)
+		check(err == nil && stmt.downsample == "", "test")
+		err = h.parseDownsample(url.Values{"downsample": {"max"}}, stmt)
+		check(err == nil && stmt.downsample == "max", "test")
+		err = h.parseDownsample(url.Values{"downsample": {"median"}}, stmt)
+		check(err != nil && err.(*apiError).typ == errorBadData, "test")
+
+		_, err = h.parseQueryStatement(`downsample(mongo|cpu, "median")`)
+		check(err != nil, "test")
+
+		stmt, err = h.parseQueryStatement("mongo|cpu {hid=1, filter=peak}")
+		check(err == nil && stmt.legacy && stmt.downsample == "peak", "test")
+	}
+
+	// case 5: offset，选择器的offset叠加到每个metric上
+	{
+		var stmt, err = h.parseQueryStatement(`redis|qps, redis|qps offset 1d [arrayDiv($1, $2)] {hid="1"} offset 1h`)
+		check(err == nil, fmt.Sprint(err))
+		check(len(stmt.offsetList) == 2 && stmt.offsetList[0] == 3600 && stmt.offsetList[1] == 90000, "test")
+		var nameList = h.seriesNameList(stmt)
+		check(nameList[0] == "qps offset 1h" && nameList[1] == "qps offset 25h", fmt.Sprint(nameList))
+
+		stmt, err = h.parseQueryStatement(`redis|qps, redis|cpu offset 1d {__name__=~"redis\\|cpu"}`)
+		check(err == nil && len(stmt.metricList) == 1 && stmt.metricList[0] == "cpu", fmt.Sprint(err))
+		check(len(stmt.offsetList) == 1 && stmt.offsetList[0] == 86400, "test")
+
+		stmt, err = h.parseQueryStatement(`redis|qps {hid="1"}`)
+		check(err == nil && h.seriesNameList(stmt)[0] == "qps", "test")
+	}
+
+	check(true, "test")
+}
+
+func TestMatchInstances(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	var distribute = map[string]interface{}{
+		"~key_md5":      3,
+		"10_1_1_1:3001": map[string]interface{}{"host": "10.1.1.1:3001", "hid": 2, "pid": 100},
+		"10_1_1_2:3001": map[string]interface{}{"host": "10.1.1.2:3001", "hid": 1, "pid": 100},
+		"10_1_1_3:3001": map[string]interface{}{"host": "10.1.1.3:3001", "hid": 10, "pid": 200},
+		"10_1_1_4:3001": map[string]interface{}{"host": "10.1.1.4:3001"},
+	}
+
+	matcher := func(t syntax.MatchType, name, value string) *syntax.LabelMatcher {
+		var m, _ = syntax.NewLabelMatcher(t, name, value)
+		return m
+	}
+
+	// case 1: 全部实例，按hid排序
+	{
+		var list = h.matchInstances(distribute, nil)
+		check(len(list) == 3, "test")
+		check(list[0]["hid"] == "1" && list[1]["hid"] == "2" && list[2]["hid"] == "10", "test")
+		check(list[2]["pid"] == "200", "test")
+		check(list[0]["host"] == "10.1.1.2:3001", "test")
+	}
+
+	// case 2
+	{
+		var list = h.matchInstances(distribute, []*syntax.LabelMatcher{
+			matcher(syntax.MatchEqual, "pid", "100"),
+			matcher(syntax.MatchNotRegexp, "host", "10\\.1\\.1\\.1:.*"),
+		})
+		check(len(list) == 1, "test")
+		check(list[0]["hid"] == "1", "test")
+	}
+
+	check(true, "test")
+}
+
+func TestAggregateSeries(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+	var stmt = &queryStatement{service: "redis"}
+
+	var instanceList = []map[string]string{
+		{"hid": "1", "pid": "100", "host": "10.1.1.1:3001"},
+		{"hid": "2", "pid": "100", "host": "10.1.1.2:3001"},
+		{"hid": "3", "pid": "200", "host": "10.1.1.3:3001"},
+	}
+	var metricLabelList []map[string]string
+	var dataList [][]int64
+	for i, it := range instanceList {
+		metricLabelList = append(metricLabelList, h.seriesMetric(stmt, "qps", it))
+		dataList = append(dataList, []int64{int64(i + 1), int64(10 * (i + 1))})
+	}
+
+	// case 1: sum by (pid)
+	{
+		var labels, data = h.aggregateSeries(&syntax.Aggregation{Op: syntax.AggregateSum, Grouping: []string{"pid"}},
+			metricLabelList, dataList)
+		check(len(labels) == 2 && len(data) == 2, "test")
+		check(labels[0]["pid"] == "100" && labels[0]["name"] == "qps" && labels[0]["service"] == "redis", "test")
+		_, ok := labels[0]["hid"]
+		check(!ok, "test")
+		check(data[0][0] == 3 && data[0][1] == 30, "test")
+		check(labels[1]["pid"] == "200" && data[1][0] == 3, "test")
+	}
+
+	// case 2: without (hid, host)等价于by (pid)
+	{
+		var labels, data = h.aggregateSeries(&syntax.Aggregation{Op: syntax.AggregateMax, Grouping: []string{"hid", "host"}, Without: true},
+			metricLabelList, dataList)
+		check(len(labels) == 2, "test")
+		check(data[0][1] == 20, "test")
+	}
+
+	// case 3: 不分组
+	{
+		var labels, data = h.aggregateSeries(&syntax.Aggregation{Op: syntax.AggregateAvg}, metricLabelList, dataList)
+		check(len(labels) == 1 && data[0][0] == 2 && data[0][1] == 20, "test")
+	}
+
+	// case 4: topk保留原始label
+	{
+		var labels, data = h.aggregateSeries(&syntax.Aggregation{Op: syntax.AggregateTopk, Param: 1}, metricLabelList, dataList)
+		check(len(labels) == 1, "test")
+		check(labels[0]["hid"] == "3" && data[0][1] == 30, "test")
+	}
+
+	check(true, "test")
+}
+
+func TestLabelValues(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: taskDistribute中被分配的实例
+	{
+		var distribute = map[string]interface{}{
+			"~key_md5": 3,
+			"10.0.0.1:8080": []interface{}{
+				map[string]interface{}{"host": "10.1.1.1:3001", "hid": 2, "pid": 100},
+				map[string]interface{}{"hid": 3.0},
+			},
+			"10.0.0.2:8080": []interface{}{
+				map[string]interface{}{"host": "10.1.1.2:3001"},
+			},
+		}
+		var distributed = h.distributedInstances(distribute)
+		check(len(distributed) == 2, fmt.Sprint(distributed))
+		check(distributed[h.instanceKey("2", "100", "10.1.1.1:3001")], "test")
+		check(distributed[h.instanceKey("3", "0", "")], "test")
+	}
+
+	// case 2: label取值去重并排序
+	{
+		var seriesList = []*seriesSet{
+			{
+				service:    "redis",
+				metricList: []string{"qps", "cpu"},
+				instanceList: []map[string]string{
+					{"hid": "2", "pid": "100", "host": "10.1.1.2:3001"},
+					{"hid": "10", "pid": "100", "host": "10.1.1.1:3001"},
+				},
+			},
+			{
+				service:      "mongo",
+				metricList:   []string{"qps"},
+				instanceList: []map[string]string{{"hid": "2", "pid": "0", "host": "10.1.1.3:3001"}},
+			},
+		}
+		var values = h.labelValues(syntax.LabelName, seriesList)
+		check(fmt.Sprint(values) == "[mongo|qps redis|cpu redis|qps]", fmt.Sprint(values))
+		values = h.labelValues(syntax.LabelService, seriesList)
+		check(fmt.Sprint(values) == "[mongo redis]", fmt.Sprint(values))
+		values = h.labelValues(syntax.LabelHid, seriesList)
+		check(fmt.Sprint(values) == "[10 2]", fmt.Sprint(values))
+		values = h.labelValues(syntax.LabelPid, seriesList)
+		check(fmt.Sprint(values) == "[0 100]", fmt.Sprint(values))
+		values = h.labelValues(syntax.LabelHost, nil)
+		check(values != nil && len(values) == 0, "test")
+	}
+
+	// case 3: 读取taskList失败时返回unavailable，而不是空列表
+	{
+		var origin = configure.Options.ConfigServer
+		configure.Options.ConfigServer = &keyListErrorConfig{ConfigInterface: origin}
+		defer func() { configure.Options.ConfigServer = origin }()
+
+		var _, err = h.findSeries(nil, 0, 100, false)
+		check(err != nil && err.(*apiError).typ == errorUnavailable, fmt.Sprint(err))
+		var w = httptest.NewRecorder()
+		h.LabelsHandler(w, httptest.NewRequest("GET", "/api/v1/labels", nil))
+		check(w.Code == http.StatusServiceUnavailable, w.Body.String())
+		w = httptest.NewRecorder()
+		h.LabelValuesHandler(w, httptest.NewRequest("GET", "/api/v1/label/host/values", nil))
+		check(w.Code == http.StatusServiceUnavailable, w.Body.String())
+	}
+
+	check(true, "test")
+}
+
+// GetKeyList总是返回错误的config server
+type keyListErrorConfig struct {
+	config.ConfigInterface
+}
+
+func (c *keyListErrorConfig) GetKeyList(section string) ([]string, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestQueryCache(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var nu = util.NullData
+
+	// case 1: 命中统计
+	{
+		var cache = newQueryCache(1 << 20)
+		var _, ok = cache.get("a")
+		check(!ok, "test")
+		cache.set("a", []int64{1, 2, 3})
+		cache.set("b", nil)
+		var data []int64
+		data, ok = cache.get("a")
+		check(ok && len(data) == 3 && data[2] == 3, "test")
+		data, ok = cache.get("b")
+		check(ok && data == nil, "test")
+
+		var stats = cache.stats()
+		check(stats.Hits == 2 && stats.Misses == 1 && stats.Entries == 2, fmt.Sprintf("%v", stats))
+		check(stats.HitRatio > 0.66 && stats.HitRatio < 0.67, fmt.Sprintf("%v", stats))
+		check(stats.Bytes == queryCacheItemSize("a", make([]int64, 3))+queryCacheItemSize("b", nil), fmt.Sprintf("%v", stats))
+	}
+
+	// case 2: 按照LRU淘汰
+	{
+		var itemSize = queryCacheItemSize("a", make([]int64, 10))
+		var cache = newQueryCache(itemSize * 2)
+		cache.set("a", make([]int64, 10))
+		cache.set("b", make([]int64, 10))
+		cache.get("a")
+		cache.set("c", make([]int64, 10))
+		var _, ok = cache.get("b")
+		check(!ok, "test")
+		_, ok = cache.get("a")
+		check(ok, "test")
+		_, ok = cache.get("c")
+		check(ok, "test")
+		var stats = cache.stats()
+		check(stats.Evictions == 1 && stats.Entries == 2 && stats.Bytes == itemSize*2, fmt.Sprintf("%v", stats))
+
+		// 超过容量的单项不缓存
+		cache.set("d", make([]int64, 100))
+		_, ok = cache.get("d")
+		check(!ok, "test")
+		check(cache.stats().Entries == 2, "test")
+	}
+
+	// case 3: 不可变数据的边界按照count对齐，并保留collector环形缓存与sender重发的范围
+	{
+		var h = new(ApiHandler)
+		check(h.immutableBoundary(time.Unix(10000, 0), 1, 60) == 9360, "test")
+		check(h.immutableBoundary(time.Unix(10000, 0), 5, 60) == 1800, "test")
+		check(h.immutableBoundary(time.Unix(100, 0), 1, 60) == 0, "test")
+	}
+
+	// case 4: 历史数据与实时数据拼接
+	{
+		var h = new(ApiHandler)
+		var history = map[string][]int64{
+			"a": {1, 2, 3, 4},
+			"b": {5, 6, 7, 8},
+		}
+		var tail = map[string][]int64{
+			"a": {9, nu},
+			"c": {nu, 10},
+			"d": nil,
+		}
+		var result = h.concatDataMap(history, 100, tail, 104, 102, 106)
+		check(len(result) == 3, fmt.Sprintf("%v", result))
+		check(reflect.DeepEqual(result["a"], []int64{3, 4, 9, nu}), fmt.Sprintf("%v", result["a"]))
+		check(reflect.DeepEqual(result["b"], []int64{7, 8, nu, nu}), fmt.Sprintf("%v", result["b"]))
+		check(reflect.DeepEqual(result["c"], []int64{nu, nu, nu, 10}), fmt.Sprintf("%v", result["c"]))
+	}
+
+	// case 5: 全部为空值的存储单元不保存数据
+	{
+		var h = new(ApiHandler)
+		check(h.compactBlock([]int64{nu, nu}) == nil, "test")
+		var input = []int64{nu, 1}
+		var block = h.compactBlock(input)
+		input[1] = 2
+		check(reflect.DeepEqual(block, []int64{nu, 1}), "test")
+	}
+
+	check(true, "test")
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  newTestConfig
+ *  Description:  使用本地配置文件模拟config server，返回恢复原配置的函数
+ * =====================================================================================
+ */
+func newTestConfig(t *testing.T, content string) func() {
+	var file, err = ioutil.TempFile("", "api_server_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(content)
+	file.Close()
+
+	var factory = config.ConfigFactory{Name: config.LocalConfigName}
+	var cs config.ConfigInterface
+	if cs, err = factory.Create(file.Name(), "", "", "", 0); err != nil {
+		os.Remove(file.Name())
+		t.Fatal(err)
+	}
+	var origin = configure.Options.ConfigServer
+	configure.Options.ConfigServer = cs
+	return func() {
+		configure.Options.ConfigServer = origin
+		os.Remove(file.Name())
+	}
+}
+
+func TestCheckQueryLimits(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var restore = newTestConfig(t, "[meta]\nmongo = {\"interval\":5, \"count\":60}\nredis = {\"count\":60}\n")
+	defer restore()
+	var maxSeries, maxPoints = configure.Options.MaxQuerySeries, configure.Options.MaxQueryPoints
+	var maxInstances, maxRange = configure.Options.MaxQueryInstances, configure.Options.MaxQueryRange
+	defer func() {
+		configure.Options.MaxQuerySeries = maxSeries
+		configure.Options.MaxQueryPoints = maxPoints
+		configure.Options.MaxQueryInstances = maxInstances
+		configure.Options.MaxQueryRange = maxRange
+	}()
+
+	var h *ApiHandler = new(ApiHandler)
+	var stmt = &queryStatement{
+		service:      "mongo",
+		metricList:   []string{"a", "b", "c"},
+		opExpression: "rate($0, 1m)",
+	}
+	var instanceList = []map[string]string{{"hid": "1"}, {"hid": "2"}}
+	var isLimited = func(err error) bool {
+		var apiErr, ok = err.(*apiError)
+		return ok && apiErr.typ == errorExecution
+	}
+
+	// case 1: 不限制
+	configure.Options.MaxQuerySeries = 0
+	configure.Options.MaxQueryPoints = 0
+	configure.Options.MaxQueryInstances = 0
+	configure.Options.MaxQueryRange = 0
+	{
+		var cost, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(err == nil, fmt.Sprintf("%v", err))
+		check(cost.timeRange == 600, fmt.Sprintf("%v", cost))
+		check(cost.instances == 2 && cost.series == 6, fmt.Sprintf("%v", cost))
+		check(cost.points == 6*121, fmt.Sprintf("%v", cost))
+	}
+
+	// case 2: 时间范围包含窗口函数向前多取的部分
+	configure.Options.MaxQueryRange = 599
+	{
+		var _, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(isLimited(err), fmt.Sprintf("%v", err))
+		configure.Options.MaxQueryRange = 600
+		_, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(err == nil, fmt.Sprintf("%v", err))
+	}
+
+	// case 3: 实例数
+	configure.Options.MaxQueryInstances = 1
+	{
+		var _, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(isLimited(err), fmt.Sprintf("%v", err))
+		configure.Options.MaxQueryInstances = 2
+	}
+
+	// case 4: 曲线数
+	configure.Options.MaxQuerySeries = 5
+	{
+		var _, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(isLimited(err), fmt.Sprintf("%v", err))
+		configure.Options.MaxQuerySeries = 6
+	}
+
+	// case 5: 数据点数
+	configure.Options.MaxQueryPoints = 6*121 - 1
+	{
+		var _, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(isLimited(err), fmt.Sprintf("%v", err))
+		configure.Options.MaxQueryPoints = 6 * 121
+		_, err = h.checkQueryLimits("q", stmt, instanceList, 1000, 1540)
+		check(err == nil, fmt.Sprintf("%v", err))
+	}
+
+	// case 6: service没有interval
+	{
+		var _, err = h.checkQueryLimits("q", &queryStatement{service: "redis"}, instanceList, 1000, 1540)
+		var apiErr, ok = err.(*apiError)
+		check(ok && apiErr.typ == errorBadData, fmt.Sprintf("%v", err))
+	}
+
+	// case 7: 实际返回的数据量
+	{
+		var cost = new(queryCost)
+		cost.setResult([][]int64{{1, 2}, {3}}, []string{"w"})
+		check(cost.resultSeries == 2 && cost.resultPoints == 3 && cost.warnings == 1, fmt.Sprintf("%v", cost))
+	}
+
+	check(true, "test")
+}
+
+func TestRemoteRead(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: snappy压缩的protobuf请求
+	{
+		var req = &prometheus.ReadRequest{
+			Queries: []*prometheus.Query{
+				{
+					StartTimestampMs: 1000,
+					EndTimestampMs:   2000,
+					Matchers: []*prometheus.LabelMatcher{
+						{Type: prometheus.LabelMatcher_EQ, Name: "__name__", Value: "mongo|a|b"},
+					},
+				},
+			},
+		}
+		var data, err = proto.Marshal(req)
+		check(err == nil, "test")
+		var r = httptest.NewRequest("POST", "/api/v1/read", bytes.NewReader(snappy.Encode(nil, data)))
+		var result *prometheus.ReadRequest
+		result, err = h.parseRemoteReadRequest(r)
+		check(err == nil, fmt.Sprintf("%v", err))
+		check(proto.Equal(req, result), result.String())
+
+		// 未压缩的请求
+		r = httptest.NewRequest("POST", "/api/v1/read", bytes.NewReader(data))
+		_, err = h.parseRemoteReadRequest(r)
+		var apiErr, ok = err.(*apiError)
+		check(ok && apiErr.typ == errorBadData, fmt.Sprintf("%v", err))
+	}
+
+	// case 2: matcher转换
+	{
+		var selector, hit, err = h.remoteSelector([]*prometheus.LabelMatcher{
+			{Type: prometheus.LabelMatcher_EQ, Name: "__name__", Value: "mongo|a|b"},
+			{Type: prometheus.LabelMatcher_RE, Name: "hid", Value: "1|2"},
+			{Type: prometheus.LabelMatcher_NEQ, Name: "service", Value: "redis"},
+			{Type: prometheus.LabelMatcher_EQ, Name: "job", Value: ""},
+			{Type: prometheus.LabelMatcher_NRE, Name: "instance", Value: ".+"},
+		})
+		check(err == nil && hit, fmt.Sprintf("%v", err))
+		check(reflect.DeepEqual(selector.Metrics, []string{"mongo|a|b"}), fmt.Sprintf("%v", selector.Metrics))
+		check(len(selector.Matchers) == 3, fmt.Sprintf("%v", selector.Matchers))
+		check(selector.Matchers[1].Type == syntax.MatchRegexp && selector.Matchers[1].Matches("2"), "test")
+		check(selector.Matchers[2].Type == syntax.MatchNotEqual, "test")
+	}
+
+	// case 3: 不存在的label不能匹配空字符串时不选中任何曲线
+	{
+		var _, hit, err = h.remoteSelector([]*prometheus.LabelMatcher{
+			{Type: prometheus.LabelMatcher_EQ, Name: "job", Value: "mongo"},
+		})
+		check(err == nil && !hit, "test")
+
+		var result *prometheus.QueryResult
+		result, err = h.doRemoteQuery(context.Background(), &prometheus.Query{
+			StartTimestampMs: 1000,
+			EndTimestampMs:   2000,
+			Matchers: []*prometheus.LabelMatcher{
+				{Type: prometheus.LabelMatcher_EQ, Name: "job", Value: "mongo"},
+			},
+		})
+		check(err == nil && result != nil && len(result.Timeseries) == 0, fmt.Sprintf("%v", err))
+	}
+
+	// case 4: 非法的正则表达式
+	{
+		var _, _, err = h.remoteSelector([]*prometheus.LabelMatcher{
+			{Type: prometheus.LabelMatcher_RE, Name: "host", Value: "("},
+		})
+		var apiErr, ok = err.(*apiError)
+		check(ok && apiErr.typ == errorBadData, fmt.Sprintf("%v", err))
+	}
+
+	// case 5: 返回的label按照字典序排列
+	{
+		var stmt = &queryStatement{service: "mongo"}
+		var metric = h.seriesMetric(stmt, "opcounters|insert", map[string]string{"hid": "1", "pid": "0", "host": "10.1.1.1:3001"})
+		var labels = h.remoteLabels("mongo", metric)
+		var expect = []*prometheus.Label{
+			{Name: "__name__", Value: "mongo|opcounters|insert"},
+			{Name: "hid", Value: "1"},
+			{Name: "host", Value: "10.1.1.1:3001"},
+			{Name: "pid", Value: "0"},
+			{Name: "service", Value: "mongo"},
+		}
+		check(len(labels) == len(expect), fmt.Sprintf("%v", labels))
+		for i := range expect {
+			check(i < len(labels) && proto.Equal(labels[i], expect[i]), fmt.Sprintf("%v", labels))
+		}
+	}
+
+	check(true, "test")
+}
+
+func TestRemoteWrite(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: label映射，service/host缺失时使用job/instance，其余label排序后拼接为metric名称
+	{
+		var target, err = h.pushTarget([]*prometheus.Label{
+			{Name: "__name__", Value: "http_requests_total"},
+			{Name: "job", Value: "web"},
+			{Name: "instance", Value: "10.1.1.1:8080"},
+			{Name: "method", Value: "GET"},
+			{Name: "code", Value: "200"},
+			{Name: "path", Value: "/a.b|c"},
+			{Name: "empty", Value: ""},
+		})
+		check(err == nil, fmt.Sprintf("%v", err))
+		check(target.service == "web" && target.host == "10.1.1.1:8080" && target.pid == 0, fmt.Sprintf("%v", target))
+		check(target.key == "http_requests_total|code:200|method:GET|path:/a_b_c", target.key)
+		check(target.hid >= 0, fmt.Sprintf("%v", target.hid))
+
+		// hid由host计算得到，同一个host总是相同
+		var other *pushTarget
+		other, err = h.pushTarget([]*prometheus.Label{
+			{Name: "__name__", Value: "up"},
+			{Name: "job", Value: "web"},
+			{Name: "instance", Value: "10.1.1.1:8080"},
+		})
+		check(err == nil && other.hid == target.hid && other.key == "up", fmt.Sprintf("%v %v", err, other))
+	}
+
+	// case 2: service/host/hid/pid label优先，remote read返回的__name__去掉service前缀
+	{
+		var target, err = h.pushTarget([]*prometheus.Label{
+			{Name: "__name__", Value: "mongo|opcounters|insert"},
+			{Name: "service", Value: "mongo"},
+			{Name: "job", Value: "prometheus"},
+			{Name: "host", Value: "10.1.1.1:3001"},
+			{Name: "instance", Value: "10.1.1.1:9100"},
+			{Name: "hid", Value: "12"},
+			{Name: "pid", Value: "3"},
+		})
+		check(err == nil, fmt.Sprintf("%v", err))
+		check(target.service == "mongo" && target.host == "10.1.1.1:3001", fmt.Sprintf("%v", target))
+		check(target.hid == 12 && target.pid == 3, fmt.Sprintf("%v", target))
+		check(target.key == "opcounters|insert", target.key)
+	}
+
+	// case 3: 非法的label
+	{
+		var labelsList = [][]*prometheus.Label{
+			{{Name: "__name__", Value: "up"}, {Name: "instance", Value: "a"}},
+			{{Name: "__name__", Value: "up"}, {Name: "job", Value: "web"}},
+			{{Name: "job", Value: "web"}, {Name: "instance", Value: "a"}},
+			{{Name: "__name__", Value: "up"}, {Name: "job", Value: "w.b"}, {Name: "instance", Value: "a"}},
+			{{Name: "__name__", Value: "up"}, {Name: "job", Value: "web"}, {Name: "instance", Value: "a"}, {Name: "hid", Value: "x"}},
+		}
+		for _, labels := range labelsList {
+			var _, err = h.pushTarget(labels)
+			check(err != nil, fmt.Sprintf("%v", labels))
+		}
+	}
+
+	// case 4: 按照存储单元缓存，已经落盘的与超前的数据返回错误
+	{
+		var origin = configure.Options.RemoteWriteDelay
+		configure.Options.RemoteWriteDelay = 30
+		defer func() {
+			configure.Options.RemoteWriteDelay = origin
+		}()
+
+		var buffer = newPushBuffer()
+		var now = time.Unix(1200, 0)
+		var target = &pushTarget{service: "web", hid: 1, host: "a", key: "up"}
+		// interval为5，count为12，每个存储单元60秒
+		check(buffer.add(target, 1145, 1, 5, 12, now) == nil, "test")
+		// 同一个点写入多次时以最后一次为准
+		check(buffer.add(target, 1150, 2, 5, 12, now) == nil, "test")
+		check(buffer.add(target, 1151, 3, 5, 12, now) == nil, "test")
+		check(buffer.add(target, 1200, 4, 5, 12, now) == nil, "test")
+		check(buffer.add(target, 1139, 5, 5, 12, now) != nil, "test")
+		check(buffer.add(target, 1261, 6, 5, 12, now) != nil, "test")
+		check(len(buffer.blocks) == 2, fmt.Sprintf("%v", buffer.blocks))
+
+		check(len(buffer.popDue(time.Unix(1229, 0))) == 0, "test")
+		var blockList = buffer.popDue(time.Unix(1230, 0))
+		check(len(blockList) == 1 && len(buffer.blocks) == 1, fmt.Sprintf("%v", blockList))
+		var block = blockList[0]
+		check(block.start == 228 && block.count == 12 && block.step == 5, fmt.Sprintf("%v", block))
+		var expect = []int64{util.NullData, 1, 3, util.NullData, util.NullData, util.NullData,
+			util.NullData, util.NullData, util.NullData, util.NullData, util.NullData, util.NullData}
+		check(reflect.DeepEqual(block.data["up"], expect), fmt.Sprintf("%v", block.data["up"]))
+
+		// 落盘失败时放回，超过expire后丢弃
+		buffer.retry(blockList, time.Unix(1230, 0), "test")
+		check(len(buffer.blocks) == 2, "test")
+		blockList = buffer.popDue(time.Unix(1290, 0))
+		check(len(blockList) == 2, "test")
+		buffer.retry(blockList, time.Unix(1290, 0), "test")
+		check(len(buffer.blocks) == 1, "test")
+	}
+
+	// case 5: 压缩后可以解压，并且不修改输入
+	{
+		var dataList = [][]int64{
+			{3, 3, 3, 3},
+			{util.NullData, 1000, 3000, -2000},
+			{0, util.NullData, 0, 0},
+		}
+		for _, data := range dataList {
+			var origin = make([]int64, len(data))
+			copy(origin, data)
+			var value, err = h.compressBlockData(data)
+			check(err == nil, fmt.Sprintf("%v", err))
+			check(reflect.DeepEqual(data, origin), fmt.Sprintf("%v", data))
+			var output []int64
+			output, err = compress.Decompress(value, nil)
+			check(err == nil && reflect.DeepEqual(output, origin), fmt.Sprintf("%v %v", err, output))
+		}
+	}
+
+	// case 6: taskList与pushList中的实例合并，推送的实例带有source标记
+	{
+		var distribute = map[string]interface{}{
+			"10_1_1_1:3001": map[string]interface{}{"hid": 1, "pid": 0, "host": "10.1.1.1:3001"},
+		}
+		var pushed = map[string]interface{}{
+			"10_1_1_1:3001": map[string]interface{}{"hid": 1, "pid": 0, "host": "10.1.1.1:3001", "source": "push"},
+			"10_1_1_2:3001": map[string]interface{}{"hid": 2, "pid": 0, "host": "10.1.1.2:3001", "source": "push"},
+		}
+		var instanceList = h.matchInstances(h.mergeDistribute(distribute, pushed), nil)
+		var expect = []map[string]string{
+			{"hid": "1", "pid": "0", "host": "10.1.1.1:3001"},
+			{"hid": "2", "pid": "0", "host": "10.1.1.2:3001", "source": "push"},
+		}
+		check(reflect.DeepEqual(instanceList, expect), fmt.Sprintf("%v", instanceList))
+		check(len(h.mergeDistribute(nil, pushed)) == 2, "test")
+	}
+
+	// case 7: 只重试store返回失败的存储单元
+	{
+		var blockList = []*pushBlock{{start: 60}, {start: 120}, {start: 180}}
+		var infoList = make([]*core.Info, len(blockList))
+		for i, block := range blockList {
+			infoList[i] = &core.Info{
+				Header:    &core.Header{Service: "node", Hid: 1, Host: "10.1.1.1:3001"},
+				Timestamp: block.start,
+			}
+		}
+		var res = &store.StoreSaveResponse{Error: &core.Error{Errno: 0}}
+		check(len(h.failedPushBlocks(blockList, infoList, res)) == 0, "test")
+
+		res.Error.Errno = 255
+		check(len(h.failedPushBlocks(blockList, infoList, res)) == 3, "test")
+
+		res.SuccessList = []*core.ResponseItem{{Header: infoList[0].Header, Timestamp: 60}}
+		res.FailureList = []*core.ResponseItem{
+			{Header: infoList[1].Header, Timestamp: 120},
+			{Header: infoList[2].Header, Timestamp: 180},
+		}
+		var failList = h.failedPushBlocks(blockList, infoList, res)
+		check(len(failList) == 2 && failList[0] == blockList[1] && failList[1] == blockList[2], fmt.Sprint(failList))
+	}
+
+	// case 8: 数据有误时返回400，读取配置出错时返回5xx，由prometheus重试
+	{
+		var restore = newTestConfig(t, "[meta]\nweb = {\"interval\":0, \"count\":60}\n")
+		defer restore()
+
+		var request = func() *http.Request {
+			var data, _ = proto.Marshal(&prometheus.WriteRequest{Timeseries: []*prometheus.TimeSeries{{
+				Labels: []*prometheus.Label{
+					{Name: "__name__", Value: "up"},
+					{Name: "job", Value: "web"},
+					{Name: "instance", Value: "10.1.1.1:8080"},
+				},
+				Samples: []*prometheus.Sample{{Value: 1, Timestamp: time.Now().Unix() * 1000}},
+			}}})
+			var r = httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(snappy.Encode(nil, data)))
+			r.Header.Set(remoteForwardHeader, "10.1.1.2:6100")
+			return r
+		}
+
+		// web的interval非法
+		var w = httptest.NewRecorder()
+		h.RemoteWriteHandler(w, request())
+		check(w.Code == http.StatusBadRequest, w.Body.String())
+
+		var cs = configure.Options.ConfigServer
+		configure.Options.ConfigServer = &getIntErrorConfig{ConfigInterface: cs}
+		w = httptest.NewRecorder()
+		h.RemoteWriteHandler(w, request())
+		configure.Options.ConfigServer = cs
+		check(w.Code/100 == 5, w.Body.String())
+	}
+
+	// case 9: 按照hid路由到owner，不是owner的曲线转发，owner变化后移交尚未落盘的存储单元
+	{
+		var restore = newTestConfig(t, "[meta]\nweb = {\"interval\":5, \"count\":60, \"precision\":2}\n")
+		defer restore()
+		var originHb, originDelay = configure.Options.HeartbeatServer, configure.Options.RemoteWriteDelay
+		configure.Options.HeartbeatServer = &heartbeat.Heartbeat{Conf: &heartbeat.Conf{Service: "10.1.1.1:6100"}}
+		configure.Options.RemoteWriteDelay = 30
+		defer func() {
+			configure.Options.HeartbeatServer, configure.Options.RemoteWriteDelay = originHb, originDelay
+			globalPushOwners = new(pushOwners)
+		}()
+
+		var forwarded []*prometheus.TimeSeries
+		var fail bool
+		var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if fail || len(r.Header.Get(remoteForwardHeader)) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var compressed, _ = ioutil.ReadAll(r.Body)
+			var data, _ = snappy.Decode(nil, compressed)
+			var req = new(prometheus.WriteRequest)
+			proto.Unmarshal(data, req)
+			forwarded = append(forwarded, req.Timeseries...)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+		var other = strings.TrimPrefix(server.URL, "http://")
+		var ownerList = []string{"10.1.1.1:6100", other}
+		globalPushOwners = &pushOwners{list: ownerList, update: time.Now().Unix()}
+
+		// 分别找到自己与other是owner的hid
+		var localHid, otherHid int32 = -1, -1
+		for hid := int32(0); localHid < 0 || otherHid < 0; hid++ {
+			if pushOwnerOf(ownerList, hid) == other {
+				otherHid = hid
+			} else {
+				localHid = hid
+			}
+		}
+		check(pushOwnerOf(nil, otherHid) == "10.1.1.1:6100", "test")
+
+		var now = time.Unix(1200, 0)
+		var newSeries = func(hid int32, value float64) *prometheus.TimeSeries {
+			return &prometheus.TimeSeries{
+				Labels: []*prometheus.Label{
+					{Name: "__name__", Value: "up"},
+					{Name: "job", Value: "web"},
+					{Name: "instance", Value: "10.1.1.1:8080"},
+					{Name: "hid", Value: strconv.Itoa(int(hid))},
+				},
+				Samples: []*prometheus.Sample{{Value: value, Timestamp: 1195000}},
+			}
+		}
+		var buffer = newPushBuffer()
+		var result = h.writeSeries(buffer, []*prometheus.TimeSeries{newSeries(localHid, 1.5), newSeries(otherHid, 2.5)}, false, now)
+		check(result.accepted == 2 && result.firstErr == nil && result.backendErr == nil, fmt.Sprint(result))
+		check(len(buffer.blocks) == 1 && len(forwarded) == 1, fmt.Sprint(buffer.blocks, forwarded))
+		check(reflect.DeepEqual(forwarded[0], newSeries(otherHid, 2.5)), fmt.Sprint(forwarded[0]))
+
+		// 转发失败时返回unavailable，prometheus重试
+		fail = true
+		result = h.writeSeries(buffer, []*prometheus.TimeSeries{newSeries(otherHid, 2.5)}, false, now)
+		check(result.rejected == 1 && result.backendErr != nil && result.backendErr.typ == errorUnavailable, fmt.Sprint(result))
+
+		// 转发来的请求全部写入本地缓存
+		result = h.writeSeries(buffer, []*prometheus.TimeSeries{newSeries(otherHid, 2.5)}, true, now)
+		check(result.accepted == 1 && len(buffer.blocks) == 2, fmt.Sprint(result))
+
+		// 移交失败时放回，期间新写入的点优先
+		forwarded = nil
+		h.handoffPushBuffer(buffer, now)
+		check(len(buffer.blocks) == 2 && len(forwarded) == 0, "test")
+		var moved = buffer.popMoved(ownerList, now)
+		check(len(moved[other]) == 1 && len(buffer.blocks) == 1, fmt.Sprint(moved))
+		check(h.writeSeries(buffer, []*prometheus.TimeSeries{newSeries(otherHid, 3)}, true, now).accepted == 1, "test")
+		buffer.restore(moved[other])
+		check(len(buffer.blocks) == 2 && moved[other][0].data["up"][59] == 300, fmt.Sprint(moved[other][0].data))
+
+		// 移交后还原为相同的曲线，本地不再保留
+		fail = false
+		h.handoffPushBuffer(buffer, now)
+		check(len(buffer.blocks) == 1 && len(forwarded) == 1, fmt.Sprint(buffer.blocks, forwarded))
+		var target, err = h.pushTarget(forwarded[0].GetLabels())
+		check(err == nil && target.hid == otherHid && target.host == "10.1.1.1:8080" && target.key == "up", fmt.Sprint(target, err))
+		check(len(forwarded[0].Samples) == 1 && forwarded[0].Samples[0].Value == 3, fmt.Sprint(forwarded[0].Samples))
+		check(forwarded[0].Samples[0].Timestamp == 1195000, fmt.Sprint(forwarded[0].Samples))
+	}
+
+	check(true, "test")
+}
+
+// GetInt总是返回错误的config server
+type getIntErrorConfig struct {
+	config.ConfigInterface
+}
+
+func (c *getIntErrorConfig) GetInt(section, key string, path ...string) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestRecordRule(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var restore = newTestConfig(t, "[meta]\nmongo = {\"interval\":5, \"count\":60}\n"+
+		"[recordRule]\n"+
+		"b_rule = {\"expr\":\"sum(mongo|qps)\", \"record\":\"mongo_cluster|qps.sum\", \"interval\":60}\n"+
+		"a_rule = {\"expr\":\"mongo|cpu\", \"record\":\"mongo_cluster|cpu\"}\n"+
+		"no_expr = {\"record\":\"mongo_cluster|x\"}\n"+
+		"bad_record = {\"expr\":\"mongo|cpu\", \"record\":\"cpu\"}\n")
+	defer restore()
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: 读取规则，错误的规则被跳过
+	{
+		var ruleList, errList = h.loadRecordRules()
+		check(len(ruleList) == 2 && len(errList) == 2, fmt.Sprint(ruleList, errList))
+		check(ruleList[0].name == "a_rule" && ruleList[0].interval == 0, "test")
+		check(ruleList[1].service == "mongo_cluster" && ruleList[1].metric == "qps_sum", "test")
+		check(ruleList[1].interval == 60 && ruleList[1].expr == "sum(mongo|qps)", "test")
+	}
+
+	// case 2: 结果名称
+	{
+		var service, metric, err = h.parseRecordTarget("mongo_cluster|qps|sum")
+		check(err == nil && service == "mongo_cluster" && metric == "qps|sum", "test")
+		_, _, err = h.parseRecordTarget("|qps")
+		check(err != nil, "test")
+		_, _, err = h.parseRecordTarget("mongo.cluster|qps")
+		check(err != nil, "test")
+	}
+
+	// case 3: 结果曲线对应的实例
+	{
+		var rule = &recordRule{name: "cluster_qps", service: "mongo_cluster", metric: "qps"}
+		var stmt = &queryStatement{service: "mongo", metricList: []string{"qps"}}
+		var target = h.recordTarget(rule, stmt, map[string]string{"hid": "3", "pid": "1", "host": "10.1.1.1:3001", "name": "qps"})
+		check(target.service == "mongo_cluster" && target.key == "qps", "test")
+		check(target.hid == 3 && target.pid == 1 && target.host == "10.1.1.1:3001", "test")
+
+		// 聚合掉host
+		target = h.recordTarget(rule, stmt, map[string]string{"pid": "2", "name": "qps"})
+		check(target.host == "cluster_qps_pid-2" && target.pid == 2 && target.hid > 0, target.host)
+		var other = h.recordTarget(rule, stmt, map[string]string{"pid": "3", "name": "qps"})
+		check(other.host != target.host, "test")
+
+		// 多个metric
+		stmt = &queryStatement{service: "mongo", metricList: []string{"qps", "opcounters|insert"}}
+		target = h.recordTarget(rule, stmt, map[string]string{"host": "h", "field1": "opcounters", "field2": "insert"})
+		check(target.key == "qps|opcounters|insert", target.key)
+	}
+
+	// case 4: 结果曲线转换为remote write的label，路由到owner后解析得到相同的实例与metric
+	{
+		var rule = &recordRule{name: "cluster_qps", service: "mongo_cluster", metric: "qps"}
+		var stmt = &queryStatement{service: "mongo", metricList: []string{"qps", "opcounters|insert"}}
+		for _, labels := range []map[string]string{
+			{"pid": "2", "field1": "opcounters", "field2": "insert"},
+			{"hid": "3", "pid": "1", "host": "10.1.1.1:3001", "field1": "qps"},
+		} {
+			var target = h.recordTarget(rule, stmt, labels)
+			var parsed, err = h.pushTarget(pushTargetLabels(target, target.key))
+			check(err == nil && reflect.DeepEqual(parsed, target), fmt.Sprint(parsed, target, err))
+		}
+	}
+
+	// case 5: 结果service没有配置meta
+	{
+		var rule = &recordRule{name: "cluster_qps", service: "mongo_cluster", metric: "qps", expr: "mongo|qps"}
+		var _, err = h.evaluateRecordRule(rule, newPushBuffer(), time.Now())
+		check(err != nil, "test")
+	}
+
+	// case 6: 落盘时间
+	{
+		var origin = configure.Options.RemoteWriteDelay
+		configure.Options.RemoteWriteDelay = 30
+		check(pushBlockDue(1000, 5, 60) == 1230, "test")
+		check(pushBlockDue(1199, 5, 60) == 1230, "test")
+		check(pushBlockDue(1200, 5, 60) == 1530, "test")
+		configure.Options.RemoteWriteDelay = origin
+	}
+
+	check(true, "test")
+}
+
+func TestAlertRule(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	var resendInterval, webhook = configure.Options.AlertResendInterval, configure.Options.AlertWebhook
+	defer func() {
+		configure.Options.AlertResendInterval = resendInterval
+		configure.Options.AlertWebhook = webhook
+	}()
+	configure.Options.AlertResendInterval = 60
+
+	var h *ApiHandler = new(ApiHandler)
+
+	// case 1: 解析规则
+	{
+		var rule, err = h.newAlertRule("HighQps", map[string]interface{}{
+			"expr":        "mongo|qps",
+			"op":          ">=",
+			"threshold":   int32(100),
+			"for":         "2m",
+			"labels":      map[string]interface{}{"severity": "critical"},
+			"annotations": map[string]interface{}{"summary": "qps of {{ $labels.host }} is {{$value}}"},
+		})
+		check(err == nil, fmt.Sprint(err))
+		check(rule.op == ">=" && rule.threshold == 100 && rule.duration == 120, fmt.Sprint(rule))
+		check(rule.labels["severity"] == "critical" && rule.labels[alertLabelName] == "HighQps", fmt.Sprint(rule.labels))
+
+		rule, err = h.newAlertRule("r", map[string]interface{}{"expr": "mongo|qps", "threshold": 1.5, "for": 30})
+		check(err == nil && rule.op == ">" && rule.threshold == 1.5 && rule.duration == 30, fmt.Sprint(rule, err))
+
+		_, err = h.newAlertRule("r", map[string]interface{}{"threshold": 1})
+		check(err != nil, "test")
+		_, err = h.newAlertRule("r", map[string]interface{}{"expr": "mongo|qps"})
+		check(err != nil, "test")
+		_, err = h.newAlertRule("r", map[string]interface{}{"expr": "mongo|qps", "threshold": 1, "op": "=>"})
+		check(err != nil, "test")
+		_, err = h.newAlertRule("r", map[string]interface{}{"expr": "mongo|qps", "threshold": 1, "for": "2x"})
+		check(err != nil, "test")
+		_, err = h.newAlertRule("r", map[string]interface{}{"expr": "mongo|qps", "threshold": 1, "labels": "a"})
+		check(err != nil, "test")
+	}
+
+	// case 2: annotation模板与曲线label
+	{
+		var labels = h.alertSeriesLabels(map[string]string{"name": "opcounters|insert", "field1": "opcounters",
+			"field2": "insert", "host": "10.1.1.1:3001", "hid": "3"})
+		check(reflect.DeepEqual(labels, map[string]string{"metric": "opcounters|insert", "host": "10.1.1.1:3001", "hid": "3"}),
+			fmt.Sprint(labels))
+		var result = h.expandAnnotations(map[string]string{"summary": "{{ $labels.host }}: {{ $value }} {{ $labels.none }}"},
+			labels, 12.5)
+		check(result["summary"] == "10.1.1.1:3001: 12.5 ", result["summary"])
+	}
+
+	// case 3: 状态变化与重复发送
+	{
+		var rule = &alertRule{name: "HighQps", expr: "mongo|qps", op: ">", threshold: 10, duration: 20,
+			labels: map[string]string{alertLabelName: "HighQps"}}
+		var alerts = make(map[string]*alertInstance)
+		var now = time.Unix(1000, 0)
+		var sampleList = []alertSample{
+			{labels: map[string]string{"host": "a"}, value: 20},
+			{labels: map[string]string{"host": "b"}, value: 5},
+		}
+
+		h.updateAlerts(rule, alerts, sampleList, now)
+		check(len(alerts) == 1, fmt.Sprint(alerts))
+		var key = h.labelsKey(map[string]string{alertLabelName: "HighQps", "host": "a"})
+		check(alerts[key] != nil && alerts[key].state == alertStatePending, fmt.Sprint(alerts))
+		check(len(h.pickNotices(alerts, "a", now)) == 0, "test")
+
+		// pending期间恢复后删除
+		h.updateAlerts(rule, alerts, sampleList[1:], now.Add(10*time.Second))
+		check(len(alerts) == 0, fmt.Sprint(alerts))
+
+		// 持续for之后firing
+		h.updateAlerts(rule, alerts, sampleList, now.Add(20*time.Second))
+		h.updateAlerts(rule, alerts, sampleList, now.Add(30*time.Second))
+		check(alerts[key].state == alertStatePending, alerts[key].state)
+		h.updateAlerts(rule, alerts, sampleList, now.Add(40*time.Second))
+		check(alerts[key].state == alertStateFiring && alerts[key].firedAt.Equal(now.Add(40*time.Second)), alerts[key].state)
+		var notices = h.pickNotices(alerts, "a", now.Add(40*time.Second))
+		check(len(notices) == 1 && notices[0] == alerts[key], "test")
+		notices[0].lastSent["a"] = now.Add(40 * time.Second)
+
+		// 未到重发间隔不发送
+		h.updateAlerts(rule, alerts, sampleList, now.Add(50*time.Second))
+		check(len(h.pickNotices(alerts, "a", now.Add(50*time.Second))) == 0, "test")
+		check(len(h.pickNotices(alerts, "a", now.Add(100*time.Second))) == 1, "test")
+
+		// 曲线消失后resolved，只发送一次
+		h.updateAlerts(rule, alerts, nil, now.Add(60*time.Second))
+		check(alerts[key].state == alertStateResolved, alerts[key].state)
+		notices = h.pickNotices(alerts, "a", now.Add(60*time.Second))
+		check(len(notices) == 1, "test")
+		var webhook = h.alerts2webhook(notices, now.Add(60*time.Second))
+		check(webhook[0].Status == alertStateResolved && webhook[0].EndsAt.Equal(now.Add(60*time.Second)), fmt.Sprint(webhook[0]))
+		check(webhook[0].StartsAt.Equal(now.Add(40*time.Second)), fmt.Sprint(webhook[0]))
+		notices[0].lastSent["a"] = now.Add(60 * time.Second)
+		check(len(h.pickNotices(alerts, "a", now.Add(70*time.Second))) == 0, "test")
+
+		// 再次满足条件时重新进入pending
+		h.updateAlerts(rule, alerts, sampleList, now.Add(70*time.Second))
+		check(alerts[key].state == alertStatePending && alerts[key].activeAt.Equal(now.Add(70*time.Second)), alerts[key].state)
+	}
+
+	// case 4: 分别发送给每个webhook，只重发给失败的webhook
+	{
+		var received = make(map[string][]webhookAlert)
+		var failA bool
+		var handler = func(name string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if name == "a" && failA {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				var body, _ = ioutil.ReadAll(r.Body)
+				var alertList []webhookAlert
+				json.Unmarshal(body, &alertList)
+				received[name] = append(received[name], alertList...)
+			}
+		}
+		var serverA, serverB = httptest.NewServer(handler("a")), httptest.NewServer(handler("b"))
+		defer serverA.Close()
+		defer serverB.Close()
+		configure.Options.AlertWebhook = serverA.URL + ", " + serverB.URL
+		check(reflect.DeepEqual(h.alertWebhooks(), []string{serverA.URL, serverB.URL}), fmt.Sprint(h.alertWebhooks()))
+
+		var now = time.Unix(1000, 0)
+		var alert = &alertInstance{
+			labels:      map[string]string{alertLabelName: "HighQps", "host": "a"},
+			annotations: map[string]string{"summary": "test"},
+			state:       alertStateFiring,
+			firedAt:     now,
+			lastSent:    make(map[string]time.Time),
+		}
+		var err = h.sendAlerts(serverB.URL, []*alertInstance{alert}, now)
+		check(err == nil && len(received["b"]) == 1, fmt.Sprint(err, received))
+		check(received["b"][0].Status == alertStateFiring && received["b"][0].Labels["host"] == "a", fmt.Sprint(received))
+		check(received["b"][0].EndsAt.Equal(now.Add(4*time.Minute)) && received["b"][0].Annotations["summary"] == "test",
+			fmt.Sprint(received))
+
+		failA = true
+		received = make(map[string][]webhookAlert)
+		var alertMap = map[string]map[string]*alertInstance{"HighQps": {"a": alert}}
+		h.notifyAlerts(alertMap, now)
+		check(len(received["a"]) == 0 && len(received["b"]) == 1, fmt.Sprint(received))
+		check(len(alert.lastSent) == 1 && alert.lastSent[serverB.URL].Equal(now), fmt.Sprint(alert.lastSent))
+
+		// 下一轮只重发给失败的webhook
+		failA = false
+		h.notifyAlerts(alertMap, now.Add(10*time.Second))
+		check(len(received["a"]) == 1 && len(received["b"]) == 1, fmt.Sprint(received))
+
+		// 恢复发送给所有webhook之后删除
+		alert.state = alertStateResolved
+		alert.resolvedAt = now.Add(20 * time.Second)
+		alert.lastSent = make(map[string]time.Time)
+		failA = true
+		h.notifyAlerts(alertMap, now.Add(20*time.Second))
+		check(len(alertMap["HighQps"]) == 1 && len(received["b"]) == 2, fmt.Sprint(received))
+		failA = false
+		h.notifyAlerts(alertMap, now.Add(30*time.Second))
+		check(len(alertMap["HighQps"]) == 0 && len(received["a"]) == 2 && len(received["b"]) == 2, fmt.Sprint(received))
+		check(received["a"][1].Status == alertStateResolved, fmt.Sprint(received))
+	}
+
+	// case 5: 读取leader出错时保留告警状态，确认不是leader时才清空
+	{
+		var restore = newTestConfig(t, "[alertRule]\n"+alertLeaderName+" = 10.1.1.1:8080\n")
+		defer restore()
+		var origin = configure.Options.HeartbeatServer
+		configure.Options.HeartbeatServer = &heartbeat.Heartbeat{Conf: &heartbeat.Conf{Service: "10.1.1.1:8080"}}
+		defer func() { configure.Options.HeartbeatServer = origin }()
+
+		var manager = &alertManager{alerts: map[string]map[string]*alertInstance{"HighQps": {}}}
+		var cs = configure.Options.ConfigServer
+		configure.Options.ConfigServer = &getStringErrorConfig{ConfigInterface: cs}
+		var leader, err = h.quorumLeader(util.AlertRuleCollection, alertLeaderName)
+		check(!leader && err != nil, fmt.Sprint(leader, err))
+		h.runAlertRules(manager, time.Unix(1000, 0))
+		check(len(manager.alerts) == 1, fmt.Sprint(manager.alerts))
+
+		configure.Options.ConfigServer = cs
+		leader, err = h.quorumLeader(util.AlertRuleCollection, alertLeaderName)
+		check(leader && err == nil, fmt.Sprint(leader, err))
+	}
+}
+
+// GetString总是返回错误的config server
+type getStringErrorConfig struct {
+	config.ConfigInterface
+}
+
+func (c *getStringErrorConfig) GetString(section, key string, path ...string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func TestQueryTrace(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	// case 1: 没有开启trace时只传递trace id
+	{
+		var h = new(ApiHandler)
+		h.timeReset()
+		var r = httptest.NewRequest("GET", "/api/v1/query_range", nil)
+		var ctx, cancel, err = h.queryContext(r, r.URL.Query())
+		check(err == nil, "test")
+		defer cancel()
+		check(len(h.trace.traceID()) == 16 && h.trace.result() == nil, h.trace.traceID())
+
+		var spanCtx, span = h.startSpan(ctx, "filter")
+		check(span == nil && spanCtx == ctx, "test")
+		span.finish()
+
+		var md, _ = metadata.FromOutgoingContext(getQueryTrace(ctx).outgoing(ctx))
+		check(reflect.DeepEqual(md.Get(grpc2.TraceIDKey), []string{h.trace.traceID()}), fmt.Sprint(md))
+		check(len(md.Get(grpc2.TraceDebugKey)) == 0, fmt.Sprint(md))
+
+		// 没有trace的ctx
+		check(getQueryTrace(context.Background()).outgoing(context.Background()) == context.Background(), "test")
+	}
+
+	// case 2: debug=trace时记录各个阶段，collector与store返回的耗时作为子阶段
+	{
+		var h = new(ApiHandler)
+		h.timeReset()
+		var r = httptest.NewRequest("GET", "/api/v1/query_range?debug=trace", nil)
+		var ctx, cancel, err = h.queryContext(r, r.URL.Query())
+		check(err == nil, "test")
+		defer cancel()
+
+		var instanceCtx, instance = h.startSpan(ctx, "instance")
+		instance.setInstance("hid[1] pid[0] host[h]")
+		var _, store = h.startSpan(instanceCtx, "store")
+		store.setServer("10.1.1.1:6300")
+		store.addSteps(grpc2.ParseTraceTiming(metadata.Pairs(
+			grpc2.TraceTimingKey, "doQuery[h] from cache=1000000",
+			grpc2.TraceTimingKey, "doQuery[h] from mongo=2000000",
+			grpc2.TraceTimingKey, "invalid")))
+		store.finish()
+		instance.finish()
+		var _, filter = h.startSpan(ctx, "filter")
+		filter.finish()
+
+		var md, _ = metadata.FromOutgoingContext(getQueryTrace(instanceCtx).outgoing(instanceCtx))
+		check(len(md.Get(grpc2.TraceDebugKey)) == 1, fmt.Sprint(md))
+
+		var result = h.trace.result()
+		check(result != nil && result.ID == h.trace.traceID() && len(result.Spans) == 3, fmt.Sprint(result))
+		check(result.Spans[0].Name == "parse" && result.Spans[1].Name == "instance" && result.Spans[2].Name == "filter", "test")
+		check(result.Spans[1].Instance == "hid[1] pid[0] host[h]" && len(result.Spans[1].Children) == 1, "test")
+		var children = result.Spans[1].Children[0].Children
+		check(result.Spans[1].Children[0].Server == "10.1.1.1:6300" && len(children) == 2, fmt.Sprint(children))
+		check(children[0].Name == "doQuery[h] from cache" && children[0].DurationMs == 1, fmt.Sprint(children[0]))
+		check(children[1].DurationMs == 2 && children[1].StartMs-children[0].StartMs > 0.999 && children[1].StartMs-children[0].StartMs < 1.001, fmt.Sprint(children[1]))
+
+		var buf, _ = json.Marshal(&PrometheusQueryRangeModel{Status: "success", Trace: result})
+		check(bytes.Contains(buf, []byte(`"trace":{"id":"`)), string(buf))
+		buf, _ = json.Marshal(&PrometheusQueryRangeModel{Status: "success"})
+		check(!bytes.Contains(buf, []byte(`"trace"`)), string(buf))
+	}
+
+	// case 3: 通过请求头开启
+	{
+		var h = new(ApiHandler)
+		var r = httptest.NewRequest("GET", "/api/v1/query", nil)
+		r.Header.Set(traceDebugHeader, traceDebugValue)
+		var _, cancel, err = h.queryContext(r, r.URL.Query())
+		check(err == nil, "test")
+		cancel()
+		check(h.trace.result() != nil, "test")
+	}
+}
+
 func TestPerf(t *testing.T) {
 	check := func(statement bool, msg string) {
 		var line int
@@ -0,0 +1,445 @@
+/*
+// =====================================================================================
+//
+//       Filename:  labelHandler.go
+//
+//    Description:  label名称以及label值查询，对应prometheus的
+//                  /api/v1/labels与/api/v1/label/<name>/values
+//
+//        Version:  1.0
+//        Created:  10/18/2026 08:41:52 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
+	"inspector/dict_server"
+	"inspector/util"
+	"inspector/util/unsafe"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const labelValuesPrefix = "/api/v1/label/"
+const labelValuesSuffix = "/values"
+
+// 曲线上的label名称，按字典序排列
+var labelNameList = []string{
+	syntax.LabelName,
+	syntax.LabelHid,
+	syntax.LabelHost,
+	syntax.LabelPid,
+	syntax.LabelService,
+}
+
+// =====================================================================================
+//       Struct:  seriesSet
+//  Description:  一个service中被match[]选中的曲线集合
+//                metricList不带service前缀，只有查询__name__或者有__name__条件时才会填充
+// =====================================================================================
+type seriesSet struct {
+	service      string
+	metricList   []string
+	instanceList []map[string]string
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  LabelsHandler
+ *  Description:  返回label名称列表，没有任何曲线被选中时返回空列表
+ * =====================================================================================
+ */
+func (h *ApiHandler) LabelsHandler(w http.ResponseWriter, r *http.Request) {
+	glog.V(1).Infof("[Trace][LabelsHandler] called: Request[%v]", r)
+	h.timeReset()
+
+	var selectorList, startTime, endTime, err = h.parseLabelRequest(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.timeTick("parse request")
+
+	var seriesList []*seriesSet
+	if seriesList, err = h.findSeries(selectorList, startTime, endTime, false); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var nameList = make([]string, 0, len(labelNameList))
+	if len(seriesList) > 0 {
+		nameList = append(nameList, labelNameList...)
+	}
+	h.timeTick("find series")
+
+	fmt.Fprintln(w, h.labelList2json(nameList))
+	h.printLabelPerf("LabelsHandler")
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  LabelValuesHandler
+ *  Description:  路径为/api/v1/label/<name>/values，未知的label返回空列表
+ * =====================================================================================
+ */
+func (h *ApiHandler) LabelValuesHandler(w http.ResponseWriter, r *http.Request) {
+	var name = strings.TrimPrefix(r.URL.Path, labelValuesPrefix)
+	if !strings.HasSuffix(name, labelValuesSuffix) {
+		h.writeError(w, newApiError(errorNotFound, "path[%s] not found", r.URL.Path))
+		return
+	}
+	name = strings.TrimSuffix(name, labelValuesSuffix)
+	if len(name) == 0 || strings.Contains(name, "/") {
+		h.writeError(w, newApiError(errorNotFound, "path[%s] not found", r.URL.Path))
+		return
+	}
+	h.serveLabelValues(w, r, name)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  serveLabelValues
+ *  Description:  返回label的取值列表，__name__的取值需要查询DictServer
+ * =====================================================================================
+ */
+func (h *ApiHandler) serveLabelValues(w http.ResponseWriter, r *http.Request, name string) {
+	glog.V(1).Infof("[Trace][LabelValuesHandler] called: label[%s], Request[%v]", name, r)
+	h.timeReset()
+
+	var selectorList, startTime, endTime, err = h.parseLabelRequest(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.timeTick("parse request")
+
+	var valueList = make([]string, 0)
+	for _, it := range labelNameList {
+		if it == name {
+			var seriesList []*seriesSet
+			if seriesList, err = h.findSeries(selectorList, startTime, endTime, name == syntax.LabelName); err != nil {
+				h.writeError(w, err)
+				return
+			}
+			valueList = h.labelValues(name, seriesList)
+			break
+		}
+	}
+	h.timeTick("find series")
+
+	fmt.Fprintln(w, h.labelList2json(valueList))
+	h.printLabelPerf("LabelValuesHandler")
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseLabelRequest
+ *  Description:  解析match[]、start、end参数，start默认为0，end默认为当前时间
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseLabelRequest(r *http.Request) ([]*syntax.Selector, uint32, uint32, error) {
+	var params url.Values
+	var err error
+	if params, err = h.parseForm(r); err != nil {
+		return nil, 0, 0, err
+	}
+
+	var startTime uint32 = 0
+	var endTime = uint32(time.Now().Unix())
+	if t := params.Get("start"); len(t) > 0 {
+		if startTime, err = parseTimestamp(t); err != nil {
+			return nil, 0, 0, newApiError(errorBadData, "invalid parameter \"start\": %s", err.Error())
+		}
+	}
+	if t := params.Get("end"); len(t) > 0 {
+		if endTime, err = parseTimestamp(t); err != nil {
+			return nil, 0, 0, newApiError(errorBadData, "invalid parameter \"end\": %s", err.Error())
+		}
+	}
+	if startTime > endTime {
+		return nil, 0, 0, newApiError(errorBadData, "invalid parameter \"end\": end timestamp must not be before start time")
+	}
+
+	var selectorList []*syntax.Selector
+	for _, it := range params["match[]"] {
+		var selector *syntax.Selector
+		if selector, err = syntax.ParseSeriesSelector(it); err != nil {
+			return nil, 0, 0, newApiError(errorBadData, "invalid parameter \"match[]\": parse %s error: %s", it, err.Error())
+		}
+		selectorList = append(selectorList, selector)
+	}
+	return selectorList, startTime, endTime, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  findSeries
+ *  Description:  返回被任意一个selector选中的曲线，selectorList为空时选中所有曲线
+ *                service与实例来自taskList以及pushList，withMetric为true时同时填充metric列表
+ *                单个service出错时只记录日志并跳过，不影响其他service
+ *                读取taskList失败时返回errorUnavailable，避免返回不完整的空结果
+ * =====================================================================================
+ */
+func (h *ApiHandler) findSeries(selectorList []*syntax.Selector, startTime, endTime uint32, withMetric bool) ([]*seriesSet, error) {
+	var serviceList, err = configure.Options.ConfigServer.GetKeyList(util.TaskListCollection)
+	if err != nil {
+		glog.Errorf("get service list from [%s] error: %s", util.TaskListCollection, err.Error())
+		return nil, newApiError(errorUnavailable, "get service list from [%s] error: %s", util.TaskListCollection, err.Error())
+	}
+	// 只通过remote_write推送数据的service不在taskList中
+	var pushedList []string
+	if pushedList, err = configure.Options.ConfigServer.GetKeyList(util.PushListCollection); err != nil && !util.IsNotFound(err) {
+		glog.Warningf("get service list from [%s] error: %s", util.PushListCollection, err.Error())
+	}
+	var serviceSet = make(map[string]bool, len(serviceList))
+	for _, it := range serviceList {
+		serviceSet[it] = true
+	}
+	for _, it := range pushedList {
+		if !serviceSet[it] {
+			serviceList = append(serviceList, it)
+		}
+	}
+	sort.Strings(serviceList)
+
+	if len(selectorList) == 0 {
+		selectorList = []*syntax.Selector{new(syntax.Selector)}
+	}
+
+	var result []*seriesSet
+	for _, selector := range selectorList {
+		for _, service := range serviceList {
+			if util.FilterName(service) {
+				continue
+			}
+			if set := h.selectSeries(selector, service, startTime, endTime, withMetric); set != nil {
+				result = append(result, set)
+			}
+		}
+	}
+	return result, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  selectSeries
+ *  Description:  返回service中被selector选中的曲线，没有选中任何曲线时返回nil
+ * =====================================================================================
+ */
+func (h *ApiHandler) selectSeries(selector *syntax.Selector, service string, startTime, endTime uint32, withMetric bool) *seriesSet {
+	for _, it := range selector.MatchersOf(syntax.LabelService) {
+		if !it.Matches(service) {
+			return nil
+		}
+	}
+
+	var set = &seriesSet{service: service}
+	var err error
+
+	// metric
+	var prefix = service + "|"
+	var candidates []string
+	for _, it := range selector.Metrics {
+		if strings.HasPrefix(it, prefix) {
+			candidates = append(candidates, strings.TrimPrefix(it, prefix))
+		}
+	}
+	if len(selector.Metrics) > 0 && len(candidates) == 0 {
+		return nil
+	}
+	var nameMatchers = selector.MatchersOf(syntax.LabelName)
+	if withMetric || len(nameMatchers) > 0 {
+		if len(candidates) == 0 {
+			var dict, ok = configure.Options.DictServerMap.Load(service)
+			if !ok {
+				glog.Warningf("can't find DictServer[%v]", service)
+				return nil
+			}
+			if candidates, err = dict.(*dictServer.DictServer).GetKeyList(); err != nil {
+				glog.Warningf("get key list of DictServer[%s] error: %s", service, err.Error())
+				return nil
+			}
+		}
+		if set.metricList = h.filterMetrics(service, candidates, nameMatchers); len(set.metricList) == 0 {
+			return nil
+		}
+	}
+
+	// instance
+	var distribute map[string]interface{}
+	if distribute, err = h.serviceDistribute(service); err != nil {
+		glog.Warningf("get instance list of service[%s] error: %s", service, err.Error())
+		return nil
+	}
+	var matchers []*syntax.LabelMatcher
+	for _, it := range selector.Matchers {
+		switch it.Name {
+		case syntax.LabelHid, syntax.LabelPid, syntax.LabelHost:
+			matchers = append(matchers, it)
+		}
+	}
+	set.instanceList = h.matchInstances(distribute, matchers)
+	if set.instanceList = h.activeInstances(service, set.instanceList, startTime, endTime); len(set.instanceList) == 0 {
+		return nil
+	}
+	return set
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  activeInstances
+ *  Description:  按照start/end过滤实例：taskList中不记录历史，因此
+ *                start晚于当前时间时没有任何数据；
+ *                end在最近InstantLookback秒内时，只保留taskDistribute中正在被采集的实例；
+ *                更早的时间范围则返回taskList中登记的全部实例
+ *                推送的实例不会被分配给collector，总是保留
+ * =====================================================================================
+ */
+func (h *ApiHandler) activeInstances(service string, instanceList []map[string]string, startTime, endTime uint32) []map[string]string {
+	var now = uint32(time.Now().Unix())
+	if startTime > now {
+		return nil
+	}
+	if endTime+uint32(configure.Options.InstantLookback) < now {
+		return instanceList
+	}
+
+	// 只有推送实例的service在taskDistribute中不存在
+	var distribute, err = configure.Options.ConfigServer.GetMap(util.TaskDistributeCollection, service, util.TaskDistributeName)
+	if err != nil && !util.IsNotFound(err) {
+		glog.Warningf("get distribute of service[%s] error: %s", service, err.Error())
+		return nil
+	}
+	var distributed = h.distributedInstances(distribute)
+	var result = make([]map[string]string, 0, len(instanceList))
+	for _, it := range instanceList {
+		if it[instanceSourceName] == instanceSourcePush {
+			result = append(result, it)
+			continue
+		}
+		if distributed[h.instanceKey(it[syntax.LabelHid], it[syntax.LabelPid], it[syntax.LabelHost])] ||
+			distributed[h.instanceKey(it[syntax.LabelHid], it[syntax.LabelPid], "")] {
+			result = append(result, it)
+		}
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  distributedInstances
+ *  Description:  distribute为taskDistribute中service的distribute字段，
+ *                格式为 collector -> [instance, ...]，返回所有被分配的实例
+ *                实例没有host字段时只使用hid和pid
+ * =====================================================================================
+ */
+func (h *ApiHandler) distributedInstances(distribute map[string]interface{}) map[string]bool {
+	var result = make(map[string]bool)
+	for collector, value := range distribute {
+		if util.FilterName(collector) {
+			continue
+		}
+		var instanceList, ok = value.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, it := range instanceList {
+			var instance map[string]interface{}
+			if instance, ok = it.(map[string]interface{}); !ok {
+				continue
+			}
+			var hid, pid int
+			var err error
+			if hid, err = util.ConvertInterface2Int(instance[syntax.LabelHid]); err != nil {
+				continue
+			}
+			if pid, err = util.ConvertInterface2Int(instance[syntax.LabelPid]); err != nil {
+				pid = 0
+			}
+			var host, _ = instance[syntax.LabelHost].(string)
+			result[h.instanceKey(strconv.Itoa(hid), strconv.Itoa(pid), host)] = true
+		}
+	}
+	return result
+}
+
+func (h *ApiHandler) instanceKey(hid, pid, host string) string {
+	return hid + "/" + pid + "/" + host
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  labelValues
+ *  Description:  返回曲线集合中label的所有取值，去重并按字典序排列
+ *                __name__的取值为"service|metric"，与查询语句中的写法一致
+ * =====================================================================================
+ */
+func (h *ApiHandler) labelValues(name string, seriesList []*seriesSet) []string {
+	var valueMap = make(map[string]bool)
+	for _, set := range seriesList {
+		switch name {
+		case syntax.LabelName:
+			for _, it := range set.metricList {
+				valueMap[set.service+"|"+it] = true
+			}
+		case syntax.LabelService:
+			valueMap[set.service] = true
+		default:
+			for _, it := range set.instanceList {
+				valueMap[it[name]] = true
+			}
+		}
+	}
+
+	var valueList = make([]string, 0, len(valueMap))
+	for it := range valueMap {
+		valueList = append(valueList, it)
+	}
+	sort.Strings(valueList)
+	return valueList
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  labelList2json
+ *  Description:
+ * =====================================================================================
+ */
+func (h *ApiHandler) labelList2json(list []string) string {
+	var result = &PrometheusSuggestionModel{
+		Status: "success",
+		Data:   list,
+	}
+	var resultBytes, _ = json.Marshal(result)
+	return unsafe.Bytes2String(resultBytes)
+}
+
+func (h *ApiHandler) printLabelPerf(name string) {
+	if glog.V(2) {
+		bytesBuffer := bytes.NewBuffer([]byte{})
+		var durationAll, durationList = h.getTimeConsumeResult()
+
+		bytesBuffer.WriteString(fmt.Sprintf("[Perf][%s]: ", name))
+		for _, it := range durationList {
+			bytesBuffer.WriteString(
+				fmt.Sprintf("step[%v](%v) time duration[%v]|",
+					it.name, it.step, it.duration))
+		}
+		bytesBuffer.WriteString(fmt.Sprintf("all time duration[%v]\n", durationAll))
+		glog.Info(bytesBuffer.String())
+	}
+	glog.Flush()
+}
@@ -0,0 +1,393 @@
+/*
+// =====================================================================================
+//
+//       Filename:  queryCache.go
+//
+//    Description:  历史数据查询结果缓存
+//                  超出collector环形缓存范围的数据已经全部落盘，不会再变化，
+//                  按照存储单元(count个点)对齐后缓存解压后的数据，只有实时部分需要重新查询
+//
+//        Version:  1.0
+//        Created:  10/18/2026 10:41:37 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/proto/core"
+	"inspector/util"
+	"inspector/util/unsafe"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// 缓存项的固定开销(字节)，用于估算缓存占用的内存
+const queryCacheItemOverhead = 64
+
+// collector环形缓存保留2个存储单元，额外再留1个存储单元给sender落盘
+const queryCacheLiveBlocks = 3
+
+// sender发送失败的数据块写入本地文件，每20秒重发一批，store恢复后补写的数据
+// 在这个时间内仍然认为是可变的
+const queryCacheResendWindow = 10 * time.Minute
+
+// =====================================================================================
+//       Struct:  queryCache
+//  Description:  按照内存占用淘汰的LRU缓存，key为归一化之后的
+//                service|hid|pid|host|interval|count|store精度|聚合方式|metric key|存储单元起始虚拟时间
+//                全部为空值的存储单元不缓存，sender重发之后可能补上
+// =====================================================================================
+type queryCache struct {
+	lock      sync.Mutex
+	capacity  int64
+	size      int64
+	lru       *list.List // 队首为最近使用的
+	items     map[string]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type queryCacheItem struct {
+	key  string
+	data []int64
+}
+
+// =====================================================================================
+//       Struct:  QueryCacheStats
+//  Description:  缓存命中统计，命中/未命中按照存储单元计数
+// =====================================================================================
+type QueryCacheStats struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	HitRatio  float64 `json:"hitRatio"`
+	Evictions uint64  `json:"evictions"`
+	Entries   int     `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+	Capacity  int64   `json:"capacity"`
+}
+
+var (
+	globalQueryCache     *queryCache
+	globalQueryCacheOnce sync.Once
+)
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  getQueryCache
+ *  Description:  第一次调用时按照configure.Options.QueryCacheSize(MB)创建，
+ *                大小不大于0时表示关闭缓存，返回nil
+ * =====================================================================================
+ */
+func getQueryCache() *queryCache {
+	globalQueryCacheOnce.Do(func() {
+		if configure.Options.QueryCacheSize > 0 {
+			globalQueryCache = newQueryCache(int64(configure.Options.QueryCacheSize) << 20)
+		}
+	})
+	return globalQueryCache
+}
+
+func newQueryCache(capacity int64) *queryCache {
+	return &queryCache{
+		capacity: capacity,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func queryCacheItemSize(key string, data []int64) int64 {
+	return int64(len(key)+8*len(data)) + queryCacheItemOverhead
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  get
+ *  Description:  返回的数据为缓存内部的数据，调用方不能修改
+ * =====================================================================================
+ */
+func (c *queryCache) get(key string) ([]int64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var e, ok = c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*queryCacheItem).data, true
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  set
+ *  Description:  超出容量时从最久未使用的开始淘汰，单项超过容量时不缓存
+ * =====================================================================================
+ */
+func (c *queryCache) set(key string, data []int64) {
+	var size = queryCacheItemSize(key, data)
+	if size > c.capacity {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		var item = e.Value.(*queryCacheItem)
+		c.size += size - queryCacheItemSize(item.key, item.data)
+		item.data = data
+		c.lru.MoveToFront(e)
+	} else {
+		c.items[key] = c.lru.PushFront(&queryCacheItem{key: key, data: data})
+		c.size += size
+	}
+
+	for c.size > c.capacity {
+		var e = c.lru.Back()
+		var item = e.Value.(*queryCacheItem)
+		c.lru.Remove(e)
+		delete(c.items, item.key)
+		c.size -= queryCacheItemSize(item.key, item.data)
+		c.evictions++
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  stats
+ *  Description:
+ * =====================================================================================
+ */
+func (c *queryCache) stats() QueryCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var stats = QueryCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   len(c.items),
+		Bytes:     c.size,
+		Capacity:  c.capacity,
+	}
+	if c.hits+c.misses > 0 {
+		stats.HitRatio = float64(c.hits) / float64(c.hits+c.misses)
+	}
+	return stats
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  immutableBoundary
+ *  Description:  返回不可变数据的结束虚拟时间(不含)，按照count对齐
+ *                早于该时间的数据已经移出collector的环形缓存，并且超出了sender的重发时间，
+ *                只能从store中获取
+ * =====================================================================================
+ */
+func (h *ApiHandler) immutableBoundary(now time.Time, step, count int) uint32 {
+	var current = uint32(now.Unix()) / uint32(step)
+	var live = uint32(queryCacheLiveBlocks * count)
+	if resend := uint32(queryCacheResendWindow/time.Second) / uint32(step); resend > live {
+		live = resend
+	}
+	if current < live {
+		return 0
+	}
+	var boundary = current - live
+	return boundary - boundary%uint32(count)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  getHistory
+ *  Description:  获取[start, end)的历史数据，start与end需要按照count对齐
+ *                每个metric key的每个存储单元单独缓存，只向store查询缺失的部分
+ *                store返回错误时不写入缓存，已经获取到的数据仍然返回
+ *                全部为空值的存储单元不写入缓存，下次查询时重新从store获取
+ *                返回的数组长度为end-start，没有数据的key不在结果中
+ * =====================================================================================
+ */
+func (h *ApiHandler) getHistory(ctx context.Context, cache *queryCache,
+	service string, pid uint32, hid int32, host string,
+	keyList []string, start, end uint32, step, count int) (map[string][]int64, error) {
+
+	var blockCount = int(end-start) / count
+	// 不同精度的查询store可能返回不同层级的rollup数据，需要分开缓存
+	var resolution = getStoreResolution(ctx)
+	var prefix = fmt.Sprintf("%s|%d|%d|%s|%d|%d|%d|%s|", service, hid, pid, host, step, count,
+		resolution.step, resolution.aggregate)
+	var blockKey = func(key string, block int) string {
+		return fmt.Sprintf("%s%s|%d", prefix, key, start+uint32(block*count))
+	}
+
+	// read from cache
+	var blockMap = make(map[string][][]int64, len(keyList))
+	var missKeyList []string
+	var missBegin, missEnd = blockCount, 0
+	for _, key := range keyList {
+		if _, ok := blockMap[key]; ok || key == "" {
+			continue
+		}
+		var blockList = make([][]int64, blockCount)
+		var miss = false
+		for i := range blockList {
+			if data, ok := cache.get(blockKey(key, i)); ok {
+				blockList[i] = data
+				continue
+			}
+			miss = true
+			if i < missBegin {
+				missBegin = i
+			}
+			if i+1 > missEnd {
+				missEnd = i + 1
+			}
+		}
+		blockMap[key] = blockList
+		if miss {
+			missKeyList = append(missKeyList, key)
+		}
+	}
+
+	// read missing blocks from store
+	var err error
+	if len(missKeyList) > 0 {
+		var fetchStart = start + uint32(missBegin*count)
+		var fetchEnd = start + uint32(missEnd*count)
+		var infoRangeList []*core.InfoRange
+		infoRangeList, err = h.getFromStore(ctx, service, pid, hid, host, missKeyList, fetchStart-uint32(count), fetchEnd)
+		var fetched = h.infoRangeList2dataMap(infoRangeList, fetchStart, fetchEnd)
+		for _, key := range missKeyList {
+			var data = fetched[key]
+			for i := missBegin; i < missEnd; i++ {
+				var block []int64
+				if len(data) > 0 {
+					block = h.compactBlock(data[(i-missBegin)*count : (i-missBegin+1)*count])
+				}
+				blockMap[key][i] = block
+				if err == nil && block != nil {
+					cache.set(blockKey(key, i), block)
+				}
+			}
+		}
+	}
+
+	// compose data
+	var result = make(map[string][]int64, len(blockMap))
+	for key, blockList := range blockMap {
+		var data []int64
+		for i, block := range blockList {
+			if block == nil {
+				continue
+			}
+			if data == nil {
+				data = make([]int64, end-start)
+				for j := range data {
+					data[j] = util.NullData
+				}
+			}
+			copy(data[i*count:], block)
+		}
+		if data != nil {
+			result[key] = data
+		}
+	}
+	return result, err
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  compactBlock
+ *  Description:  全部为空值时返回nil，否则返回一份拷贝
+ * =====================================================================================
+ */
+func (h *ApiHandler) compactBlock(data []int64) []int64 {
+	for _, it := range data {
+		if it != util.NullData {
+			var block = make([]int64, len(data))
+			copy(block, data)
+			return block
+		}
+	}
+	return nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  concatDataMap
+ *  Description:  将[historyStart, tailStart)的历史数据与[tailStart, end)的实时数据
+ *                拼接为[start, end)的数据，start不小于historyStart
+ * =====================================================================================
+ */
+func (h *ApiHandler) concatDataMap(historyData map[string][]int64, historyStart uint32,
+	tailData map[string][]int64, tailStart uint32,
+	start, end uint32) map[string][]int64 {
+
+	var result = make(map[string][]int64, len(historyData)+len(tailData))
+	var output = func(key string) []int64 {
+		if data, ok := result[key]; ok {
+			return data
+		}
+		var data = make([]int64, end-start)
+		for i := range data {
+			data[i] = util.NullData
+		}
+		result[key] = data
+		return data
+	}
+	for key, it := range historyData {
+		if len(it) == 0 {
+			continue
+		}
+		copy(output(key), it[start-historyStart:])
+	}
+	for key, it := range tailData {
+		if len(it) == 0 {
+			continue
+		}
+		copy(output(key)[tailStart-start:], it)
+	}
+	return result
+}
+
+/*
+// =====================================================================================
+// grafana data model
+// =====================================================================================
+*/
+type QueryCacheStatusModel struct {
+	Status string          `json:"status"` // success
+	Data   QueryCacheStats `json:"data"`
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  QueryCacheStatusHandler
+ *  Description:  返回查询缓存的命中统计，缓存关闭时统计全部为0
+ * =====================================================================================
+ */
+func (h *ApiHandler) QueryCacheStatusHandler(w http.ResponseWriter, r *http.Request) {
+	glog.V(1).Infof("[Trace][QueryCacheStatusHandler] called: Request[%v]", r)
+
+	var result = &QueryCacheStatusModel{Status: "success"}
+	if cache := getQueryCache(); cache != nil {
+		result.Data = cache.stats()
+	}
+	var resultBytes, _ = json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, unsafe.Bytes2String(resultBytes))
+}
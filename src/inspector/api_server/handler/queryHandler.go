@@ -0,0 +1,224 @@
+/*
+// =====================================================================================
+//
+//       Filename:  queryHandler.go
+//
+//    Description:  即时查询处理函数
+//
+//        Version:  1.0
+//        Created:  10/18/2026 10:21:35 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/util"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+/*
+// =====================================================================================
+// grafana data model
+// =====================================================================================
+*/
+type PrometheusQueryResult struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"` // [timestamp, "value"]
+}
+type PrometheusQueryData struct {
+	ResultType string                  `json:"resultType"` // vector
+	Result     []PrometheusQueryResult `json:"result"`
+}
+type PrometheusQueryModel struct {
+	Status   string              `json:"status"` // success or failure
+	Data     PrometheusQueryData `json:"data"`
+	Warnings []string            `json:"warnings,omitempty"`
+	Trace    *traceResult        `json:"trace,omitempty"`
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  QueryHandler
+ *  Description:  即时查询，返回每条曲线在time时刻(含)之前最近的一个数据点
+ * =====================================================================================
+ */
+func (h *ApiHandler) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	glog.V(1).Infof("[Trace][QueryHandler] called: Request[%v]", r)
+	h.timeReset()
+
+	var params url.Values
+	var err error
+
+	// parse request
+	if params, err = h.parseForm(r); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var query string
+	if query, err = h.requireParam(params, "query"); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var evalTime = uint32(time.Now().Unix())
+	if t := params.Get("time"); len(t) > 0 {
+		if evalTime, err = parseTimestamp(t); err != nil {
+			h.writeError(w, newApiError(errorBadData, "invalid parameter \"time\": %s", err.Error()))
+			return
+		}
+	}
+
+	h.timeTick("parse request")
+
+	// parse query
+	var stmt *queryStatement
+	if stmt, err = h.parseQueryStatement(query); err != nil {
+		h.writeError(w, newApiError(errorBadData, "parse query[%s] error: %s", query, err.Error()))
+		return
+	}
+	var instanceList []map[string]string
+	if instanceList, err = h.selectInstances(stmt); err != nil {
+		h.writeError(w, newApiError(errorExecution, "select instances of query[%s] error: %s", query, err.Error()))
+		return
+	}
+
+	h.timeTick("parse query")
+
+	var dataStep int
+	if dataStep, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, stmt.service, "interval"); err != nil || dataStep <= 0 {
+		dataStep = 1
+	}
+
+	// 向前查找lookback范围内的数据，endTime为开区间，所以需要多取一个step
+	var lookback = uint32(configure.Options.InstantLookback)
+	if lookback < uint32(dataStep) {
+		lookback = uint32(dataStep)
+	}
+	var startTime = h.lookbackStart(evalTime, lookback)
+	var endTime = evalTime + uint32(dataStep)
+	var cost *queryCost
+	if cost, err = h.checkQueryLimits(query, stmt, instanceList, startTime, endTime); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var ctx, cancel, ctxErr = h.queryContext(r, params)
+	if ctxErr != nil {
+		h.writeError(w, ctxErr)
+		return
+	}
+	defer cancel()
+	w.Header().Set(traceIDHeader, h.trace.traceID())
+	var virtualStartTime, metricLabelList, _, dataList, warnings, queryErr = h.doQueryStatement(ctx, stmt, instanceList, startTime, endTime, dataStep)
+	h.timeTick("doQueryStatement")
+	if queryErr != nil {
+		h.printQueryPerf("QueryHandler", cost, queryErr)
+		h.writeError(w, queryErr)
+		return
+	}
+
+	var finalResult = h.array2vector(metricLabelList, virtualStartTime, uint32(dataStep), evalTime, dataList, warnings)
+
+	// print perf info
+	h.timeTick("array2vector")
+	cost.setResult(dataList, warnings)
+	h.printQueryPerf("QueryHandler", cost, nil)
+	fmt.Fprintln(w, finalResult)
+	glog.Flush()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  lookbackStart
+ *  Description:  返回向前查找的起始时间，evalTime小于lookback时从0开始，避免uint32回绕
+ * =====================================================================================
+ */
+func (h *ApiHandler) lookbackStart(evalTime, lookback uint32) uint32 {
+	if evalTime > lookback {
+		return evalTime - lookback
+	}
+	return 0
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pickInstantValue
+ *  Description:  在虚拟时间序列中查找at时刻(含)之前最近的有效数据
+ *                startTime为虚拟时间，返回的时间为真实时间
+ * =====================================================================================
+ */
+func (h *ApiHandler) pickInstantValue(data []int64, startTime, step, at uint32) (uint32, int64, bool) {
+	for j := len(data) - 1; j >= 0; j-- {
+		var timestamp = (startTime + uint32(j)) * step
+		if timestamp > at || data[j] == util.NullData {
+			continue
+		}
+		return timestamp, data[j], true
+	}
+	return 0, util.NullData, false
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  array2vector
+ *  Description:  将查询结果转换为prometheus vector格式，与prometheus相同，
+ *                所有元素的时间都是执行时刻at，而不是取到的数据点的时间
+ * =====================================================================================
+ */
+func (h *ApiHandler) array2vector(metricLabelList []map[string]string, startTime, step, at uint32, data [][]int64, warnings []string) string {
+	var resultBytes []byte
+	var result = &PrometheusQueryModel{}
+	result.Status = "success"
+	result.Warnings = warnings
+	result.Data.ResultType = "vector"
+	result.Trace = h.trace.result()
+	result.Data.Result = make([]PrometheusQueryResult, 0, len(metricLabelList))
+	for i, metric := range metricLabelList {
+		if i >= len(data) {
+			break
+		}
+		var _, value, ok = h.pickInstantValue(data[i], startTime, step, at)
+		if !ok {
+			continue
+		}
+		result.Data.Result = append(result.Data.Result, PrometheusQueryResult{
+			Metric: metric,
+			Value: [2]interface{}{
+				at,
+				strconv.FormatFloat(float64(value)/util.FloatMultiple, 'f', -1, 64),
+			},
+		})
+	}
+	resultBytes, _ = json.Marshal(result)
+	return string(resultBytes)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseTimestamp
+ *  Description:  解析prometheus格式的时间参数，支持unix时间戳(可带小数)和RFC3339
+ * =====================================================================================
+ */
+func parseTimestamp(input string) (uint32, error) {
+	if t, err := strconv.ParseFloat(input, 64); err == nil {
+		if t < 0 || t > math.MaxUint32 {
+			return 0, errors.New("timestamp out of range")
+		}
+		return uint32(t), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, input); err == nil {
+		return uint32(t.Unix()), nil
+	}
+	return 0, fmt.Errorf("cannot parse %q to a valid timestamp", input)
+}
@@ -0,0 +1,142 @@
+/*
+// =====================================================================================
+//
+//       Filename:  queryLimit.go
+//
+//    Description:  查询限制与查询代价统计，防止单个查询压垮store_server与collector
+//
+//        Version:  1.0
+//        Created:  10/18/2026 11:36:52 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
+	"inspector/util"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// =====================================================================================
+//       Struct:  queryCost
+//  Description:  单个查询的代价，instances/series/points为执行前的估算值，
+//                用于检查查询限制，resultSeries/resultPoints为实际返回的数据量
+// =====================================================================================
+type queryCost struct {
+	query        string
+	timeRange    uint32 // 需要查询的时间范围(秒)，包含窗口函数向前多取的部分
+	instances    int
+	series       int
+	points       int64
+	resultSeries int
+	resultPoints int
+	warnings     int
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  checkQueryLimits
+ *  Description:  估算查询代价并检查各项查询限制，限制为0时表示不限制
+ *                超出限制时返回execution错误，查询不会被发送到collector与store
+ * =====================================================================================
+ */
+func (h *ApiHandler) checkQueryLimits(query string, stmt *queryStatement, instanceList []map[string]string,
+	startTime, endTime uint32) (*queryCost, error) {
+
+	var cost = &queryCost{
+		query:     query,
+		timeRange: endTime - startTime + uint32(syntax.RangeLookback(stmt.opExpression)),
+		instances: len(instanceList),
+		series:    len(instanceList) * len(stmt.metricList),
+	}
+
+	var interval, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, stmt.service, "interval")
+	if err != nil {
+		return cost, h.metaError(stmt.service, "interval", err)
+	}
+	if interval <= 0 {
+		return cost, newApiError(errorInternal, "interval[%d] of service[%s] is invalid", interval, stmt.service)
+	}
+	cost.points = int64(cost.series) * int64(cost.timeRange/uint32(interval)+1)
+
+	if limit := configure.Options.MaxQueryRange; limit > 0 && cost.timeRange > uint32(limit) {
+		return cost, newApiError(errorExecution, "query time range %ds exceeds the limit of %ds, try a shorter time range",
+			cost.timeRange, limit)
+	}
+	if limit := configure.Options.MaxQueryInstances; limit > 0 && cost.instances > limit {
+		return cost, newApiError(errorExecution, "query selects %d instances, exceeds the limit of %d, try narrowing the hid/pid/host selector",
+			cost.instances, limit)
+	}
+	if limit := configure.Options.MaxQuerySeries; limit > 0 && cost.series > limit {
+		return cost, newApiError(errorExecution, "query selects %d series (%d metrics on %d instances), exceeds the limit of %d, try narrowing the metrics",
+			cost.series, len(stmt.metricList), cost.instances, limit)
+	}
+	if limit := configure.Options.MaxQueryPoints; limit > 0 && cost.points > int64(limit) {
+		return cost, newApiError(errorExecution, "query would load %d points, exceeds the limit of %d, try fewer series or a shorter time range",
+			cost.points, limit)
+	}
+	return cost, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  setResult
+ *  Description:  统计实际返回的曲线数以及数据点数
+ * =====================================================================================
+ */
+func (c *queryCost) setResult(dataList [][]int64, warnings []string) {
+	c.resultSeries = len(dataList)
+	c.resultPoints = 0
+	for _, it := range dataList {
+		c.resultPoints += len(it)
+	}
+	c.warnings = len(warnings)
+}
+
+func (c *queryCost) String() string {
+	return fmt.Sprintf("query[%s] range[%ds] instances[%d] series[%d] points[%d] resultSeries[%d] resultPoints[%d] warnings[%d]",
+		c.query, c.timeRange, c.instances, c.series, c.points, c.resultSeries, c.resultPoints, c.warnings)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  printQueryPerf
+ *  Description:  打印perf信息，耗时超过slow_query_threshold时记录慢查询日志
+ *                getTimeConsumeResult会修改perfTimeConsumeList，只能调用一次
+ * =====================================================================================
+ */
+func (h *ApiHandler) printQueryPerf(name string, cost *queryCost, err error) {
+	var durationAll, durationList = h.getTimeConsumeResult()
+	var threshold = time.Duration(configure.Options.SlowQueryThreshold) * time.Millisecond
+	var slow = configure.Options.SlowQueryThreshold > 0 && durationAll >= threshold
+	if !slow && !bool(glog.V(2)) {
+		return
+	}
+
+	bytesBuffer := bytes.NewBuffer([]byte{})
+	for _, it := range durationList {
+		bytesBuffer.WriteString(
+			fmt.Sprintf("step[%v](%v) time duration[%v]|",
+				it.name, it.step, it.duration))
+	}
+	bytesBuffer.WriteString(fmt.Sprintf("all time duration[%v]", durationAll))
+
+	if glog.V(2) {
+		glog.Infof("[Perf][%s]: %s\n", name, bytesBuffer.String())
+	}
+	if slow {
+		var status = "ok"
+		if err != nil {
+			status = err.Error()
+		}
+		glog.Warningf("[SlowQuery][%s]: trace[%s] %v status[%s] %s", name, h.trace.traceID(), cost, status, bytesBuffer.String())
+	}
+}
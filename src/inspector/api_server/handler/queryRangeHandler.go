@@ -31,16 +31,19 @@ import (
 	"inspector/proto/core"
 	"inspector/proto/store"
 	"inspector/util"
+	"inspector/util/grpc2"
 	"inspector/util/unsafe"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 /*
@@ -57,8 +60,10 @@ type PrometheusQueryRangeData struct {
 	Result     []PrometheusQueryRangeResult `json:"result"`
 }
 type PrometheusQueryRangeModel struct {
-	Status string                   `json:"status"` // success or failure
-	Data   PrometheusQueryRangeData `json:"data"`
+	Status   string                   `json:"status"` // success or failure
+	Data     PrometheusQueryRangeData `json:"data"`
+	Warnings []string                 `json:"warnings,omitempty"`
+	Trace    *traceResult             `json:"trace,omitempty"`
 }
 
 /*
@@ -71,124 +76,82 @@ func (h *ApiHandler) QueryRangeHandler(w http.ResponseWriter, r *http.Request) {
 	glog.V(1).Infof("[Trace][QueryRangeHandler] called: Request[%v]", r)
 	h.timeReset()
 
-	var params map[string][]string
+	var params url.Values
 	var err error
 
-	var metrics string
-	var metricList []string
-	var opExpression string
-	var instances string
-	var instanceSelector map[string]string
-
 	// parse request
-	params, err = url.ParseQuery(r.URL.RawQuery)
+	if params, err = h.parseForm(r); err != nil {
+		h.writeError(w, err)
+		return
+	}
 	glog.V(3).Infof("[Debug][QueryRangeHandler] parse http query: params[%v]", params)
-	// fmt.Printf("debug QueryRangeHandler: Request[%v]\n", params)
-	if err != nil {
-		var errStr = fmt.Sprintf("query_range parse query error: %s", err.Error())
-		glog.Warning(errStr)
-		fmt.Fprintln(w, errStr)
-	}
-	var query = params["query"][0]
-	var start = params["start"][0]
-	var end = params["end"][0]
-	var step = params["step"][0]
-	var tmpNum int
-	tmpNum, _ = strconv.Atoi(start)
-	var startTime = uint32(tmpNum)
-	tmpNum, _ = strconv.Atoi(end)
-	var endTime = uint32(tmpNum)
-	tmpNum, _ = strconv.Atoi(step)
-	var showStep = tmpNum
+	var query, startTime, endTime, showStep, parseErr = h.parseRangeParams(params)
+	if parseErr != nil {
+		h.writeError(w, parseErr)
+		return
+	}
 
 	h.timeTick("parse request")
 
 	// parse query
-	var service string
-	metrics, opExpression, instances = h.parsePanelQuery(query)
-	if metrics[0:4] == "reg(" {
-		// 正则表达式
-
-		metrics = metrics[4 : len(metrics)-1] // 去除"reg("和最后的")"
-		service = h.parseService(metrics)
-		var serviceEndIndex = strings.IndexByte(metrics, '|')
-		metrics = metrics[serviceEndIndex+1:] // 去除首部"service|"字符
-		var dict interface{}
-		var ok bool
-		if dict, ok = configure.Options.DictServerMap.Load(service); !ok {
-			var errStr = fmt.Sprintf("can't find DictServer[%v]", service)
-			glog.Errorf(errStr)
-			fmt.Fprintln(w, errStr)
-		}
-		if metricList, err = dict.(*dictServer.DictServer).GetKeyList(); err != nil {
-			var errStr = fmt.Sprintf("can't get dict key list from DictServer[%v]", service)
-			glog.Errorf(errStr)
-			fmt.Fprintln(w, errStr)
-		}
-		metricList = h.parseMetricsReg(metrics, metricList)
-	} else {
-		// 监控项列表
-		metricList = h.parseMetrics(metrics)
-		service = h.parseService(metricList[0])
-		// cut service
-		for i, it := range metricList {
-			metricList[i] = h.parseRealMetric(it)
-		}
-
+	var stmt *queryStatement
+	if stmt, err = h.parseQueryStatement(query); err != nil {
+		h.writeError(w, newApiError(errorBadData, "parse query[%s] error: %s", query, err.Error()))
+		return
+	}
+	if err = h.parseDownsample(params, stmt); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var instanceList []map[string]string
+	if instanceList, err = h.selectInstances(stmt); err != nil {
+		h.writeError(w, newApiError(errorExecution, "select instances of query[%s] error: %s", query, err.Error()))
+		return
 	}
 
-	instanceSelector = h.parseInstanceSelector(instances)
-	glog.V(3).Infof("[Debug][QueryRangeHandler] parse metric query: "+
-		"metrics[%v], metricList[%v], instanceSelector[%v]",
-		metrics, metricList, instanceSelector)
+	var cost *queryCost
+	if cost, err = h.checkQueryLimits(query, stmt, instanceList, startTime, endTime); err != nil {
+		h.writeError(w, err)
+		return
+	}
 
 	h.timeTick("parse query")
 
+	var ctx, cancel, ctxErr = h.queryContext(r, params)
+	if ctxErr != nil {
+		h.writeError(w, ctxErr)
+		return
+	}
+	defer cancel()
+	w.Header().Set(traceIDHeader, h.trace.traceID())
+
+	// compose final result(http data)
+	var metricLabelList []map[string]string
 	var filterIndexResult [][]int
 	var filterDataResult [][]int64
-	startTime, filterIndexResult, filterDataResult = h.doQueryRange(service, metricList, opExpression, instanceSelector, startTime, endTime, showStep)
-	if filterDataResult == nil {
-		glog.Errorf("query data error: service[%v], metricList[%v], opExpression[%v], instanceSelector[%v], startTime[%v], endTime[%v]",
-			service, metricList, opExpression, instanceSelector, startTime, endTime)
+	var virtualStartTime uint32
+	var warnings []string
+	virtualStartTime, metricLabelList, filterIndexResult, filterDataResult, warnings, err = h.doQueryStatement(ctx, stmt, instanceList, startTime, endTime, showStep)
+	h.timeTick("doQueryStatement")
+	if err != nil {
+		h.printQueryPerf("QueryRangeHandler", cost, err)
+		h.writeError(w, err)
 		return
 	}
+	cost.setResult(filterDataResult, warnings)
 	// fmt.Println("debug filterDataResult: ", filterIndexResult, filterDataResult)
 
-	h.timeTick("doQueryRange")
-
-	// compose final result(http data)
-	// 如果有过数组计算，则需要调整metricList，从而将数值与命名对应
-	// 目前只支持原地计算和数组多和一运算
-	// 原地计算结果保持与输入nameList相同的顺序，多和一运算返回运算过程
-	var nameList []string
-	if len(filterDataResult) == len(metricList) {
-		nameList = metricList
-	} else {
-		nameList = append(nameList, opExpression)
-	}
 	var dataStep uint32
 	var tmp int
-	tmp, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, service, "interval")
+	tmp, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, stmt.service, "interval")
 	dataStep = uint32(tmp)
 
-	var finalResult = h.array2json(nameList, startTime, dataStep, filterIndexResult, filterDataResult)
+	var finalResult = h.array2json(metricLabelList, virtualStartTime, dataStep, filterIndexResult, filterDataResult, warnings)
 	// fmt.Println("debug finalResult: ", len(finalResult), finalResult)
 
 	// print perf info
 	h.timeTick("array2json")
-	if glog.V(2) {
-		bytesBuffer := bytes.NewBuffer([]byte{})
-		var durationAll, durationList = h.getTimeConsumeResult()
-
-		bytesBuffer.WriteString("[Perf][QueryRangeHandler]: ")
-		for _, it := range durationList {
-			bytesBuffer.WriteString(
-				fmt.Sprintf("step[%v](%v) time duration[%v]|",
-					it.name, it.step, it.duration))
-		}
-		bytesBuffer.WriteString(fmt.Sprintf("all time duration[%v]\n", durationAll))
-		glog.Infof(bytesBuffer.String())
-	}
+	h.printQueryPerf("QueryRangeHandler", cost, nil)
 	fmt.Fprintln(w, finalResult)
 	glog.Flush()
 	return
@@ -203,22 +166,247 @@ func (h *ApiHandler) QueryRangeHandler(w http.ResponseWriter, r *http.Request) {
 
 /*
  * ===  FUNCTION  ======================================================================
- *         Name:  doQueryRange
- *  Description:  返回值中uint32为虚拟时间，需要乘以step变为真实时间
+ *         Name:  parseRangeParams
+ *  Description:  解析并检查query、start、end、step参数
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseRangeParams(params url.Values) (string, uint32, uint32, int, error) {
+	var query, start, end, step string
+	var err error
+	if query, err = h.requireParam(params, "query"); err != nil {
+		return "", 0, 0, 0, err
+	}
+	if start, err = h.requireParam(params, "start"); err != nil {
+		return "", 0, 0, 0, err
+	}
+	if end, err = h.requireParam(params, "end"); err != nil {
+		return "", 0, 0, 0, err
+	}
+	if step, err = h.requireParam(params, "step"); err != nil {
+		return "", 0, 0, 0, err
+	}
+
+	var startTime, endTime uint32
+	var showStep int
+	if startTime, err = parseTimestamp(start); err != nil {
+		return "", 0, 0, 0, newApiError(errorBadData, "invalid parameter \"start\": %s", err.Error())
+	}
+	if endTime, err = parseTimestamp(end); err != nil {
+		return "", 0, 0, 0, newApiError(errorBadData, "invalid parameter \"end\": %s", err.Error())
+	}
+	if endTime < startTime {
+		return "", 0, 0, 0, newApiError(errorBadData, "invalid parameter \"end\": end timestamp must not be before start time")
+	}
+	if showStep, err = parseDuration(step); err != nil {
+		return "", 0, 0, 0, newApiError(errorBadData, "invalid parameter \"step\": %s", err.Error())
+	}
+	if (endTime-startTime)/uint32(showStep) > maxPointsPerSeries {
+		return "", 0, 0, 0, newApiError(errorBadData,
+			"exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX)", maxPointsPerSeries)
+	}
+	return query, startTime, endTime, showStep, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  queryContext
+ *  Description:  请求的context，客户端断开连接时取消，并受query_timeout全局deadline约束
+ *                timeout参数只能缩短deadline，同时创建请求的trace并放入context
  * =====================================================================================
  */
-func (h *ApiHandler) doQueryRange(service string,
+func (h *ApiHandler) queryContext(r *http.Request, params url.Values) (context.Context, context.CancelFunc, error) {
+	var start = h.timeStart
+	if start.IsZero() {
+		start = time.Now()
+	}
+	h.trace = h.newQueryTrace(r, params, start)
+	var parent = withQueryTrace(r.Context(), h.trace)
+
+	var timeout = time.Duration(configure.Options.QueryTimeout) * time.Second
+	if t := params.Get("timeout"); len(t) > 0 {
+		var seconds, err = parseDuration(t)
+		if err != nil {
+			return nil, nil, newApiError(errorBadData, "invalid parameter \"timeout\": %s", err.Error())
+		}
+		if d := time.Duration(seconds) * time.Second; timeout <= 0 || d < timeout {
+			timeout = d
+		}
+	}
+	if timeout <= 0 {
+		var ctx, cancel = context.WithCancel(parent)
+		return ctx, cancel, nil
+	}
+	var ctx, cancel = context.WithTimeout(parent, timeout)
+	return ctx, cancel, nil
+}
+
+// =====================================================================================
+//       Struct:  instanceResult
+//  Description:  单个实例的查询结果
+// =====================================================================================
+type instanceResult struct {
+	startTime uint32
+	showStep  int
+	data      [][]int64
+	warnings  []string
+	err       error
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  doQueryStatement
+ *  Description:  并发查询instanceList中的每个实例，完成聚合运算后再统一做filter采样
+ *                返回值中uint32为虚拟时间，需要乘以step变为真实时间
+ *                部分实例失败时返回其余实例的结果，并将失败原因放入warnings
+ *                所有实例都失败时返回错误，只是没有数据时返回空结果
+ * =====================================================================================
+ */
+func (h *ApiHandler) doQueryStatement(ctx context.Context,
+	stmt *queryStatement,
+	instanceList []map[string]string,
+	startTime, endTime uint32, showStep int) (uint32, []map[string]string, [][]int, [][]int64, []string, error) {
+	ctx = withStoreResolution(ctx, showStep, stmt.downsample)
+	var concurrency = configure.Options.QueryConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var limit = make(chan struct{}, concurrency)
+	var resultList = make([]instanceResult, len(instanceList))
+	var wg sync.WaitGroup
+	for i, instance := range instanceList {
+		wg.Add(1)
+		go func(result *instanceResult, instance map[string]string) {
+			defer wg.Done()
+			select {
+			case limit <- struct{}{}:
+				defer func() { <-limit }()
+			case <-ctx.Done():
+				result.err = h.contextError(ctx.Err())
+				return
+			}
+			result.err = h.runSafe(func() error {
+				var err error
+				result.startTime, result.showStep, result.data, result.warnings, err =
+					h.doQueryInstance(ctx, stmt.service, stmt.metricList, stmt.offsetList, stmt.opExpression, instance, startTime, endTime, showStep)
+				return err
+			})
+		}(&resultList[i], instance)
+	}
+	wg.Wait()
+
+	var metricLabelList []map[string]string
+	var dataList [][]int64
+	var virtualStartTime uint32
+	var virtualShowStep int
+	var warnings []string
+	var firstErr error
+	for i, instance := range instanceList {
+		var result = &resultList[i]
+		for _, it := range result.warnings {
+			warnings = append(warnings, fmt.Sprintf("hid[%s] host[%s]: %s", instance["hid"], instance["host"], it))
+		}
+		if result.err != nil {
+			glog.Errorf("query data error: service[%v], metricList[%v], opExpression[%v], instanceSelector[%v], startTime[%v], endTime[%v]: %s",
+				stmt.service, stmt.metricList, stmt.opExpression, instance, startTime, endTime, result.err.Error())
+			warnings = append(warnings, fmt.Sprintf("hid[%s] host[%s]: %s", instance["hid"], instance["host"], result.err.Error()))
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		if result.data == nil {
+			glog.V(3).Infof("[Debug][doQueryStatement] no data: service[%v], metricList[%v], instanceSelector[%v], startTime[%v], endTime[%v]",
+				stmt.service, stmt.metricList, instance, startTime, endTime)
+			continue
+		}
+		virtualStartTime = result.startTime
+		virtualShowStep = result.showStep
+
+		// 如果有过数组计算，则需要调整metricList，从而将数值与命名对应
+		// 目前只支持原地计算和数组多和一运算
+		// 原地计算结果保持与输入nameList相同的顺序，多和一运算返回运算过程
+		var nameList []string
+		if len(result.data) == len(stmt.metricList) {
+			nameList = h.seriesNameList(stmt)
+		} else {
+			nameList = append(nameList, stmt.opExpression)
+		}
+		for i, it := range result.data {
+			if i >= len(nameList) {
+				break
+			}
+			metricLabelList = append(metricLabelList, h.seriesMetric(stmt, nameList[i], instance))
+			dataList = append(dataList, it)
+		}
+	}
+	if dataList == nil {
+		if ctx.Err() != nil {
+			return 0, nil, nil, nil, nil, h.contextError(ctx.Err())
+		}
+		return 0, nil, nil, nil, warnings, firstErr
+	}
+
+	// 聚合运算需要在filter之前进行，否则不同实例的采样点可能不一致
+	if stmt.aggregation != nil {
+		var _, span = h.startSpan(ctx, "aggregate")
+		metricLabelList, dataList = h.aggregateSeries(stmt.aggregation, metricLabelList, dataList)
+		span.finish()
+	}
+
+	var indexList [][]int
+	var _, span = h.startSpan(ctx, "filter")
+	virtualStartTime, indexList, dataList = h.doFilter(stmt.downsample, virtualStartTime, virtualShowStep, dataList)
+	span.finish()
+	return virtualStartTime, metricLabelList, indexList, dataList, warnings, nil
+}
+
+// =====================================================================================
+//       Struct:  offsetGroup
+//  Description:  doQueryInstance中时间偏移相同的一组metric，offset为虚拟时间
+// =====================================================================================
+type offsetGroup struct {
+	offset   uint32
+	keyList  []string
+	data     map[string][]int64
+	warnings []string
+	err      error
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  doQueryInstance
+ *  Description:  获取单个实例的数据并完成数组计算，尚未经过filter采样
+ *                返回值依次为虚拟起始时间、虚拟show step以及计算结果，没有数据时计算结果为nil
+ *                offsetList为每个metric的时间偏移(秒)，向下取整到interval，为nil时不偏移
+ *                不同偏移的metric并发查询，只有部分出错时返回其余的数据以及warning
+ * =====================================================================================
+ */
+func (h *ApiHandler) doQueryInstance(ctx context.Context,
+	service string,
 	metricList []string,
+	offsetList []int64,
 	opExpression string,
 	instanceSelector map[string]string,
-	startTime, endTime uint32, showStep int) (uint32, [][]int, [][]int64) {
-	glog.V(1).Infof("[Trace][doQueryRange] called: service[%v], metricList[%v], opExpression[%v], instanceSelector[%v], startTime[%v], endTime[%v], showStep[%v]",
-		service, metricList, opExpression, instanceSelector, startTime, endTime, showStep)
+	startTime, endTime uint32, showStep int) (uint32, int, [][]int64, []string, error) {
+	glog.V(1).Infof("[Trace][doQueryInstance] called: trace[%s] service[%v], metricList[%v], opExpression[%v], instanceSelector[%v], startTime[%v], endTime[%v], showStep[%v]",
+		getQueryTrace(ctx).traceID(), service, metricList, opExpression, instanceSelector, startTime, endTime, showStep)
+
+	var span *traceSpan
+	ctx, span = h.startSpan(ctx, "instance")
+	defer span.finish()
+	span.setInstance(fmt.Sprintf("hid[%s] pid[%s] host[%s]", instanceSelector["hid"], instanceSelector["pid"], instanceSelector["host"]))
 
 	var count, step int
 	var err error
-	count, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, service, "count")
-	step, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, service, "interval")
+	if count, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, service, "count"); err != nil {
+		return 0, 0, nil, nil, h.metaError(service, "count", err)
+	}
+	if step, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, service, "interval"); err != nil {
+		return 0, 0, nil, nil, h.metaError(service, "interval", err)
+	}
+	if step <= 0 {
+		return 0, 0, nil, nil, newApiError(errorInternal, "interval[%d] of service[%s] is invalid", step, service)
+	}
 
 	startTime /= uint32(step) // 针对大于1s采集的数据，通过虚拟时间变为1s采集
 	endTime /= uint32(step)   // 针对大于1s采集的数据，通过虚拟时间变为1s采集
@@ -235,8 +423,7 @@ func (h *ApiHandler) doQueryRange(service string,
 	var keyList = make([]string, len(metricList))
 	hid, err = strconv.Atoi(hidStr)
 	if err != nil {
-		glog.Errorf("hid[%s] is not a number", hidStr)
-		return 0, nil, nil
+		return 0, 0, nil, nil, newApiError(errorBadData, "hid[%s] is not a number", hidStr)
 	}
 	pid, err = strconv.Atoi(pidStr)
 	if err != nil {
@@ -246,76 +433,307 @@ func (h *ApiHandler) doQueryRange(service string,
 	}
 
 	if keyList, err = h.metricList2keyList(service, metricList, keyList); err != nil {
-		glog.Error(err)
-		return 0, nil, nil
+		return 0, 0, nil, nil, newApiError(errorExecution, "%s", err.Error())
 	}
-	glog.V(3).Infof("[Debug][doQueryRange] metricList2keyList: keyList[%s]", keyList)
+	glog.V(3).Infof("[Debug][doQueryInstance] metricList2keyList: keyList[%s]", keyList)
 
 	innerTimer.timeTick("convert key list")
 
 	// 先将startTime向前偏移一个showStep-1的位置，这是为了后面filter时的数据对齐考虑
 	startTime = startTime - uint32(showStep) + 1
-	// get data from collector
-	var collectorInfoRangeList = h.getFromCollector(service, uint32(pid), int32(hid), host, keyList, startTime, endTime)
-	innerTimer.timeTick("getFromCollector")
-	var collectorData = h.infoRangeList2dataMap(collectorInfoRangeList, startTime, endTime)
-	innerTimer.timeTick("parserCollectorData")
-	// fmt.Println("debug collectorData: ", len(collectorData), collectorData)
-
-	// get data from store
-	// startTime向前多取1个存储单元，这是因为db的存储如果想取到指定时间的数据，就得用这个时间段的起始时间
-	var storeInfoRangeList = h.getFromStore(service, uint32(pid), int32(hid), host, keyList, startTime-uint32(count), endTime)
-	innerTimer.timeTick("getFromStore")
-	var storeData = h.infoRangeList2dataMap(storeInfoRangeList, startTime, endTime)
-	innerTimer.timeTick("parserStoreData")
-	// fmt.Println("debug storeData: ", len(storeData), storeData)
+	// 窗口函数(rate等)需要向前多取一个窗口的数据，计算完成后再截掉，保证第一个点的窗口完整
+	var lookback = int((syntax.RangeLookback(opExpression) + int64(step) - 1) / int64(step))
+	startTime -= uint32(lookback)
+	// 按照offset分组，同一组的metric一起查询。偏移按interval换算为虚拟时间，
+	// 偏移后的数据与当前数据按下标对齐，后续的计算与filter采样不需要区分
+	var groupList []*offsetGroup
+	var groupIndex = make([]int, len(keyList))
+	for i, it := range keyList {
+		var offset uint32
+		if i < len(offsetList) {
+			offset = uint32(offsetList[i] / int64(step))
+		}
+		groupIndex[i] = -1
+		for j, group := range groupList {
+			if group.offset == offset {
+				groupIndex[i] = j
+				break
+			}
+		}
+		if groupIndex[i] < 0 {
+			groupIndex[i] = len(groupList)
+			groupList = append(groupList, &offsetGroup{offset: offset})
+		}
+		groupList[groupIndex[i]].keyList = append(groupList[groupIndex[i]].keyList, it)
+	}
 
-	if len(storeData) == 0 && len(collectorData) == 0 {
-		glog.Error("query data is not exist")
-		return 0, nil, nil
+	// 第一组在当前goroutine中查询，以便记录详细的耗时，其余组并发查询
+	var pushed = instanceSelector[instanceSourceName] == instanceSourcePush
+	var fetch = func(timer *ApiHandler, group *offsetGroup) {
+		if group.offset > startTime {
+			// 偏移后早于时间起点，没有数据
+			return
+		}
+		var name = "fetch"
+		if group.offset > 0 {
+			name = fmt.Sprintf("fetch offset %s", syntax.FormatDuration(int64(group.offset)*int64(step)))
+		}
+		var ctx, span = h.startSpan(ctx, name)
+		defer span.finish()
+		group.err = h.runSafe(func() error {
+			var err error
+			group.data, group.warnings, err = h.fetchInstanceData(ctx, timer, service, uint32(pid), int32(hid), host, pushed,
+				group.keyList, startTime-group.offset, endTime-group.offset, step, count)
+			return err
+		})
+	}
+	var wg sync.WaitGroup
+	for i, group := range groupList {
+		if i == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(group *offsetGroup) {
+			defer wg.Done()
+			var timer = new(ApiHandler)
+			timer.timeReset()
+			fetch(timer, group)
+		}(group)
+	}
+	if len(groupList) > 0 {
+		fetch(innerTimer, groupList[0])
+	}
+	wg.Wait()
+	if len(groupList) > 1 {
+		innerTimer.timeTick("fetchOffsetData")
 	}
 
-	// merge store and collector data
-	var mergedData = h.mergeDataMap(storeData, collectorData)
-	innerTimer.timeTick("mergeDataMap")
-	// fmt.Println("debug mergedData: ", len(mergedData), mergedData)
+	var warnings []string
+	var groupErr error
+	var hasData bool
+	for _, group := range groupList {
+		var prefix string
+		if group.offset > 0 {
+			prefix = fmt.Sprintf("offset %s: ", syntax.FormatDuration(int64(group.offset)*int64(step)))
+		}
+		for _, it := range group.warnings {
+			warnings = append(warnings, prefix+it)
+		}
+		if group.err != nil {
+			warnings = append(warnings, prefix+group.err.Error())
+			if groupErr == nil {
+				groupErr = group.err
+			}
+		}
+		if group.data != nil {
+			hasData = true
+		}
+	}
+	if !hasData {
+		// 所有分组都没有数据时才返回错误，否则出错的分组作为warning
+		if groupErr != nil {
+			return 0, 0, nil, nil, groupErr
+		}
+		glog.V(3).Infof("[Debug][doQueryInstance] query data is not exist")
+		return 0, 0, nil, nil, nil
+	}
 
 	// cauculate
 	var dataList [][]int64
-	for _, it := range keyList {
-		dataList = append(dataList, mergedData[it])
+	for i, it := range keyList {
+		dataList = append(dataList, groupList[groupIndex[i]].data[it])
 	}
 	// fmt.Println("debug dataList: ", len(dataList), dataList)
 	var calculateResule [][]int64
 	if len(opExpression) > 0 {
-		glog.V(3).Infof("[Debug][doQueryRange] do calculate")
+		glog.V(3).Infof("[Debug][doQueryInstance] do calculate")
+		var _, calculateSpan = h.startSpan(ctx, "calculate")
+		defer calculateSpan.finish()
 		// 目前数组计算只支持多个数组变成1个数组的模式，随着后续功能扩展，也可以支持返回矩阵
-		if calculateResule, err = syntax.ArrayCalculation(opExpression, dataList...); err != nil {
-			glog.Errorf("calculate data service[%s] hid[%s] host[%s] keyList[%v] error: %s",
+		if calculateResule, err = syntax.ArrayCalculationWithOption(opExpression,
+			syntax.CalculationOption{Step: step, Multiple: util.FloatMultiple}, dataList...); err != nil {
+			var typ = errorExecution
+			if _, ok := err.(*syntax.ParseError); ok {
+				typ = errorBadData
+			}
+			return 0, 0, nil, nil, newApiError(typ, "calculate data service[%s] hid[%d] host[%s] keyList[%v] error: %s",
 				service, hid, host, metricList, err.Error())
 		}
 	} else {
-		glog.V(3).Infof("[Debug][doQueryRange] no calculate")
+		glog.V(3).Infof("[Debug][doQueryInstance] no calculate")
 		calculateResule = dataList
 	}
+	if lookback > 0 {
+		startTime += uint32(h.trimLookback(calculateResule, lookback))
+	}
 	innerTimer.timeTick("calculate")
 	// fmt.Println("debug calculateResule: ", len(calculateResule), calculateResule)
 
+	// print perf info
+	if glog.V(2) {
+		bytesBuffer := bytes.NewBuffer([]byte{})
+		var durationAll, durationList = innerTimer.getTimeConsumeResult()
+		bytesBuffer.WriteString("[Perf][doQueryInstance]: ")
+		for _, it := range durationList {
+			bytesBuffer.WriteString(
+				fmt.Sprintf("step[%v](%v) time duration[%v]|",
+					it.name, it.step, it.duration))
+		}
+		bytesBuffer.WriteString(fmt.Sprintf("all time duration[%v]\n", durationAll))
+		glog.Infof(bytesBuffer.String())
+	}
+
+	return startTime, showStep, calculateResule, warnings, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  fetchInstanceData
+ *  Description:  获取单个实例在[startTime, endTime)内的数据，时间均为虚拟时间
+ *                历史部分从查询缓存获取，实时部分并发查询collector与store
+ *                只有一方出错时返回另一方的数据以及warning，没有数据时返回nil
+ * =====================================================================================
+ */
+func (h *ApiHandler) fetchInstanceData(ctx context.Context,
+	innerTimer *ApiHandler,
+	service string, pid uint32, hid int32, host string, pushed bool,
+	keyList []string,
+	startTime, endTime uint32, step, count int) (map[string][]int64, []string, error) {
+	// 超出collector环形缓存范围的历史数据从查询缓存中获取，只有实时部分需要查询collector与store
+	var tailStart = startTime
+	var historyStart uint32
+	var cache = getQueryCache()
+	if cache != nil && count > 0 {
+		var boundary = h.immutableBoundary(time.Now(), step, count)
+		if endTime < boundary {
+			boundary = endTime - endTime%uint32(count)
+		}
+		historyStart = startTime - startTime%uint32(count)
+		if historyStart < boundary {
+			tailStart = boundary
+		}
+	}
+
+	// get data from history, collector and store concurrently
+	var historyData map[string][]int64
+	var collectorInfoRangeList, storeInfoRangeList []*core.InfoRange
+	var historyErr, collectorErr, storeErr error
+	var wg sync.WaitGroup
+	if tailStart > startTime {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ctx, span = h.startSpan(ctx, "history")
+			defer span.finish()
+			historyErr = h.runSafe(func() error {
+				var err error
+				historyData, err = h.getHistory(ctx, cache, service, pid, hid, host, keyList,
+					historyStart, tailStart, step, count)
+				return err
+			})
+		}()
+	}
+	// 通过remote_write推送的实例没有collector，数据只在store中
+	if tailStart < endTime && !pushed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collectorErr = h.runSafe(func() error {
+				var err error
+				collectorInfoRangeList, err = h.getFromCollector(ctx, service, pid, hid, host, keyList, tailStart, endTime)
+				return err
+			})
+		}()
+	}
+	if tailStart < endTime {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// startTime向前多取1个存储单元，这是因为db的存储如果想取到指定时间的数据，就得用这个时间段的起始时间
+			storeErr = h.runSafe(func() error {
+				var err error
+				storeInfoRangeList, err = h.getFromStore(ctx, service, pid, hid, host, keyList, tailStart-uint32(count), endTime)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	innerTimer.timeTick("getFromCollectorAndStore")
+	var _, mergeSpan = h.startSpan(ctx, "merge")
+	defer mergeSpan.finish()
+
+	var collectorData = h.infoRangeList2dataMap(collectorInfoRangeList, tailStart, endTime)
+	innerTimer.timeTick("parserCollectorData")
+	var storeData = h.infoRangeList2dataMap(storeInfoRangeList, tailStart, endTime)
+	innerTimer.timeTick("parserStoreData")
+
+	// 历史数据只存在于store中，与store出错同等对待
+	if storeErr == nil {
+		storeErr = historyErr
+	}
+	if len(historyData) == 0 && len(storeData) == 0 && len(collectorData) == 0 {
+		// 只有一方出错时，另一方的结果仍然可信
+		if storeErr != nil && (collectorErr != nil || tailStart >= endTime || pushed) {
+			if ctx.Err() != nil {
+				return nil, nil, h.contextError(ctx.Err())
+			}
+			if collectorErr == nil {
+				return nil, nil, newApiError(errorUnavailable, "query store error: %s", storeErr.Error())
+			}
+			return nil, nil, newApiError(errorUnavailable, "query collector error: %s; query store error: %s",
+				collectorErr.Error(), storeErr.Error())
+		}
+		return nil, nil, nil
+	}
+	var warnings []string
+	if collectorErr != nil {
+		warnings = append(warnings, fmt.Sprintf("query collector error: %s", collectorErr.Error()))
+	}
+	if storeErr != nil {
+		warnings = append(warnings, fmt.Sprintf("query store error: %s", storeErr.Error()))
+	}
+
+	// merge store and collector data
+	var mergedData = h.mergeDataMap(storeData, collectorData)
+	if tailStart > startTime {
+		mergedData = h.concatDataMap(historyData, historyStart, mergedData, tailStart, startTime, endTime)
+	}
+	innerTimer.timeTick("mergeDataMap")
+
+	// 存储的数据精度由service的precision决定，统一转换为计算使用的精度
+	var precision int
+	var err error
+	if precision, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, service, util.PrecisionName); err != nil {
+		precision = 0
+	}
+	for _, it := range mergedData {
+		util.ConvertPrecision(it, precision, util.FloatPrecision)
+	}
+
+	return mergedData, warnings, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  doFilter
+ *  Description:  按照虚拟show step对计算结果进行采样，startTime与showStep均为虚拟值
+ *                showStep不大于1时不做采样，返回的index为nil
+ * =====================================================================================
+ */
+func (h *ApiHandler) doFilter(filterName string, startTime uint32, showStep int, calculateResule [][]int64) (uint32, [][]int, [][]int64) {
+	var err error
 	// filter
 	var filterIndexResult [][]int
 	var filterDataResult [][]int64
 	// 将时间和show step变为虚拟时间和虚拟step，返回数据需要将时间恢复变为实际值
 	if showStep > 1 {
 		// do filter
-		glog.V(3).Infof("[Debug][doQueryRange] do filter[%v]", instanceSelector["filter"])
-		var filterFunc func([]int64, []int64, []int) error
-		switch instanceSelector["filter"] {
-		case "fix":
-			filterFunc = filter.FixedPointSamplingFilter
-		case "peak":
-			filterFunc = filter.PeakSamplingFilterAvg
-		default:
-			filterFunc = filter.FixedPointSamplingFilter
+		glog.V(3).Infof("[Debug][doFilter] do filter[%v]", filterName)
+		var filterFunc, ok = filter.GetFilter(filterName)
+		if !ok {
+			// 旧语法中的filter没有经过检查，未知的过滤器使用默认过滤器
+			glog.Warningf("unknown filter[%s], use filter[%s] instead", filterName, filter.DefaultFilterName)
+			filterFunc, _ = filter.GetFilter(filter.DefaultFilterName)
 		}
 		var align = int(startTime % uint32(showStep))
 		if align != 0 {
@@ -339,8 +757,7 @@ func (h *ApiHandler) doQueryRange(service string,
 				filterIndexResult[i] = nil
 			} else {
 				if err = filterFunc(it[align:align+inputLen], filterDataResult[i], filterIndexResult[i]); err != nil {
-					glog.Errorf("filter data service[%s] hid[%s] host[%s] keyList[%v] error: %s",
-						service, hid, host, metricList, err.Error())
+					glog.Errorf("filter data with filter[%s] error: %s", filterName, err.Error())
 				}
 			}
 
@@ -348,28 +765,33 @@ func (h *ApiHandler) doQueryRange(service string,
 		}
 	} else {
 		// don't use filter
-		glog.V(3).Infof("[Debug][doQueryRange] no filter")
+		glog.V(3).Infof("[Debug][doFilter] no filter")
 		filterIndexResult = nil
 		filterDataResult = calculateResule
 	}
-	innerTimer.timeTick("filter")
 	// fmt.Println("debug filterDataResult: ", filterIndexResult, filterDataResult)
 
-	// print perf info
-	if glog.V(2) {
-		bytesBuffer := bytes.NewBuffer([]byte{})
-		var durationAll, durationList = innerTimer.getTimeConsumeResult()
-		bytesBuffer.WriteString("[Perf][doQueryRange]: ")
-		for _, it := range durationList {
-			bytesBuffer.WriteString(
-				fmt.Sprintf("step[%v](%v) time duration[%v]|",
-					it.name, it.step, it.duration))
+	return startTime, filterIndexResult, filterDataResult
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  trimLookback
+ *  Description:  截掉为窗口函数多取的lookback个点，返回实际截掉的点数
+ *                数据不足lookback个点时只截掉实际有的部分，startTime只能前移这么多
+ * =====================================================================================
+ */
+func (h *ApiHandler) trimLookback(dataList [][]int64, lookback int) int {
+	var trimmed = lookback
+	for _, it := range dataList {
+		if len(it) < trimmed {
+			trimmed = len(it)
 		}
-		bytesBuffer.WriteString(fmt.Sprintf("all time duration[%v]\n", durationAll))
-		glog.Infof(bytesBuffer.String())
 	}
-
-	return startTime, filterIndexResult, filterDataResult
+	for i, it := range dataList {
+		dataList[i] = it[trimmed:]
+	}
+	return trimmed
 }
 
 /*
@@ -604,30 +1026,69 @@ func (h *ApiHandler) parseInstanceSelector(instances string) map[string]string {
 	return instanceSelector
 }
 
+type storeResolutionKey struct{}
+
+// =====================================================================================
+//       Struct:  storeResolution
+//  Description:  查询store时可以接受的数据精度，store据此选择降采样后的rollup数据
+// =====================================================================================
+type storeResolution struct {
+	step      uint32 // second, 0表示原始数据
+	aggregate string // min、max、avg、last，空表示由store决定
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  withStoreResolution
+ *  Description:  showStep为真实的显示间隔(秒)，过滤器与rollup的聚合方式同名时使用
+ *                对应的聚合数据，其他过滤器使用store默认的聚合方式
+ * =====================================================================================
+ */
+func withStoreResolution(ctx context.Context, showStep int, downsample string) context.Context {
+	var resolution storeResolution
+	if showStep > 0 {
+		resolution.step = uint32(showStep)
+	}
+	switch downsample {
+	case "min", "max", "avg", "last":
+		resolution.aggregate = downsample
+	}
+	return context.WithValue(ctx, storeResolutionKey{}, resolution)
+}
+
+func getStoreResolution(ctx context.Context) storeResolution {
+	var resolution, _ = ctx.Value(storeResolutionKey{}).(storeResolution)
+	return resolution
+}
+
 /*
  * ===  FUNCTION  ======================================================================
  *         Name:  getFromStore
  *  Description:
  * =====================================================================================
  */
-func (h *ApiHandler) getFromStore(service string, pid uint32, hid int32, host string,
-	keyList []string, start, end uint32) []*core.InfoRange {
+func (h *ApiHandler) getFromStore(ctx context.Context, service string, pid uint32, hid int32, host string,
+	keyList []string, start, end uint32) ([]*core.InfoRange, error) {
 
 	var conn *grpc.ClientConn
 	var err error
+	var span *traceSpan
+	ctx, span = h.startSpan(ctx, "store")
+	defer span.finish()
 
 	// select store server
 	var allStore []*heartbeat.NodeStatus
 	if allStore, err = configure.Options.HeartbeatServer.GetServices(heartbeat.ModuleStore, heartbeat.ServiceBoth); err != nil {
 		glog.Errorf("fail to get store server address list: %s", err.Error())
-		return nil
+		return nil, err
 	}
 	if len(allStore) == 0 {
 		glog.Errorf("store server all done")
-		return nil
+		return nil, errors.New("no store server available")
 	}
 	var n = util.HashInstanceByHid(hid, len(allStore))
 	var address = strings.Replace(allStore[n].Name, "_", ".", -1)
+	span.setServer(address)
 	if glog.V(3) {
 		for _, it := range allStore {
 			glog.Infof("[Debug][getFromStore] allStoreList[%v]", *it)
@@ -636,19 +1097,23 @@ func (h *ApiHandler) getFromStore(service string, pid uint32, hid int32, host st
 	}
 
 	// get data from store server
-	if conn, err = grpc.Dial(address, grpc.WithInsecure()); err != nil {
+	if conn, err = grpc.DialContext(ctx, address, grpc.WithInsecure()); err != nil {
 		glog.Errorf("fail to dial to address[%s] with grpc: %s", address, err.Error())
-		return nil
+		return nil, err
 	}
 	defer conn.Close()
 
 	c := store.NewStoreServiceClient(conn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(configure.Options.StoreTimeout)*time.Second)
+	// 每个后端单独的超时，同时受请求的全局deadline约束
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(configure.Options.StoreTimeout)*time.Second)
 	defer cancel()
 
 	var res *store.StoreQueryResponse
-	res, err = c.Query(ctx, &store.StoreQueryRequest{
+	var trailer metadata.MD
+	var resolution = getStoreResolution(ctx)
+	defer func() { span.addSteps(grpc2.ParseTraceTiming(trailer)) }()
+	res, err = c.Query(getQueryTrace(ctx).outgoing(ctx), &store.StoreQueryRequest{
 		QueryList: []*core.Query{
 			&core.Query{
 				Header: &core.Header{
@@ -659,18 +1124,26 @@ func (h *ApiHandler) getFromStore(service string, pid uint32, hid int32, host st
 				KeyList:   keyList,
 				TimeBegin: start,
 				TimeEnd:   end,
+				Step:      resolution.step,
+				Aggregate: resolution.aggregate,
 			},
 		},
-	})
+	}, grpc.Trailer(&trailer))
 	if err != nil {
 		glog.Errorf("fail to query from store server[%s]: %s", address, err.Error())
-		return nil
+		return nil, err
 	}
 	if res.GetError().GetErrno() != 0 {
 		glog.Errorf("query from store server[%s] with error: %s[%d]", address, res.GetError().GetErrmsg(), res.GetError().GetErrno())
+		// 返回部分成功的数据，同时返回错误，避免不完整的数据被写入查询缓存
+		var errmsg = res.GetError().GetErrmsg()
+		if failureList := res.GetFailureList(); len(failureList) > 0 {
+			errmsg = failureList[0].GetError().GetErrmsg()
+		}
+		return res.GetSuccessList(), fmt.Errorf("store server[%s] error: %s", address, errmsg)
 	}
 
-	return res.GetSuccessList()
+	return res.GetSuccessList(), nil
 }
 
 /*
@@ -679,24 +1152,28 @@ func (h *ApiHandler) getFromStore(service string, pid uint32, hid int32, host st
  *  Description:
  * =====================================================================================
  */
-func (h *ApiHandler) getFromCollector(service string, pid uint32, hid int32, host string,
-	keyList []string, start, end uint32) []*core.InfoRange {
+func (h *ApiHandler) getFromCollector(ctx context.Context, service string, pid uint32, hid int32, host string,
+	keyList []string, start, end uint32) ([]*core.InfoRange, error) {
 
 	var conn *grpc.ClientConn
 	var err error
+	var span *traceSpan
+	ctx, span = h.startSpan(ctx, "collector")
+	defer span.finish()
 
 	// select collector server
 	var aliveCollector []*heartbeat.NodeStatus
 	if aliveCollector, err = configure.Options.HeartbeatServer.GetServices(heartbeat.ModuleCollector, heartbeat.ServiceAlive); err != nil {
 		glog.Errorf("fail to get collector server address list: %s", err.Error())
-		return nil
+		return nil, err
 	}
 	if len(aliveCollector) == 0 {
 		glog.Errorf("collector server all done")
-		return nil
+		return nil, errors.New("no collector server available")
 	}
 	var n = util.HashInstance(pid, hid, len(aliveCollector))
 	var address = strings.Replace(aliveCollector[n].Name, "_", ".", -1)
+	span.setServer(address)
 	if glog.V(3) {
 		for _, it := range aliveCollector {
 			glog.Infof("[Debug][getFromCollector] aliveCollector[%v]", *it)
@@ -705,20 +1182,23 @@ func (h *ApiHandler) getFromCollector(service string, pid uint32, hid int32, hos
 	}
 
 	// get data from collector server
-	conn, err = grpc.Dial(address, grpc.WithInsecure())
+	conn, err = grpc.DialContext(ctx, address, grpc.WithInsecure())
 	if err != nil {
 		glog.Errorf("fail to dial to address[%s] with grpc: %s", address, err.Error())
-		return nil
+		return nil, err
 	}
 	defer conn.Close()
 
 	c := collector.NewCollectorClient(conn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(configure.Options.CollectorTimeout)*time.Second)
+	// 每个后端单独的超时，同时受请求的全局deadline约束
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(configure.Options.CollectorTimeout)*time.Second)
 	defer cancel()
 
 	var res *collector.CollectorQueryResponse
-	res, err = c.Query(ctx, &collector.CollectorQueryRequest{
+	var trailer metadata.MD
+	defer func() { span.addSteps(grpc2.ParseTraceTiming(trailer)) }()
+	res, err = c.Query(getQueryTrace(ctx).outgoing(ctx), &collector.CollectorQueryRequest{
 		QueryList: []*core.Query{
 			&core.Query{
 				Header: &core.Header{
@@ -731,10 +1211,10 @@ func (h *ApiHandler) getFromCollector(service string, pid uint32, hid int32, hos
 				TimeEnd:   end,
 			},
 		},
-	})
+	}, grpc.Trailer(&trailer))
 	if err != nil {
 		glog.Errorf("fail to query from collector server[%s]: %s", address, err.Error())
-		return nil
+		return nil, err
 	}
 	if res.GetError().GetErrno() != 0 {
 		glog.Errorf("query from collector server[%s] with error: %s[%d]", address, res.GetError().GetErrmsg(), res.GetError().GetErrno())
@@ -743,7 +1223,7 @@ func (h *ApiHandler) getFromCollector(service string, pid uint32, hid int32, hos
 		}
 	}
 
-	return res.GetSuccessList()
+	return res.GetSuccessList(), nil
 }
 
 /*
@@ -938,22 +1418,19 @@ func (h *ApiHandler) uncompressValue(data []byte, output []int64) []int64 {
  *  Description:  value解压缩
  * =====================================================================================
  */
-func (h *ApiHandler) array2json(nameList []string,
+func (h *ApiHandler) array2json(metricLabelList []map[string]string,
 	timestamp uint32, step uint32,
-	index [][]int, data [][]int64) string {
+	index [][]int, data [][]int64, warnings []string) string {
 
 	var resultBytes []byte
 	var result = &PrometheusQueryRangeModel{}
 	result.Status = "success"
+	result.Warnings = warnings
+	result.Trace = h.trace.result()
 	result.Data.ResultType = "matrix"
-	result.Data.Result = make([]PrometheusQueryRangeResult, len(nameList))
-	for i, _ := range nameList {
-		result.Data.Result[i].Metric = make(map[string]string)
-		var fieldList = strings.Split(nameList[i], "|")
-		for j, it := range fieldList {
-			result.Data.Result[i].Metric[fmt.Sprintf("field%d", j+1)] = it
-		}
-		result.Data.Result[i].Metric["name"] = fieldList[len(fieldList)-1]
+	result.Data.Result = make([]PrometheusQueryRangeResult, len(metricLabelList))
+	for i, _ := range metricLabelList {
+		result.Data.Result[i].Metric = metricLabelList[i]
 		result.Data.Result[i].Values = make([][2]float64, len(data[i]))
 		result.Data.Result[i].Values = result.Data.Result[i].Values[0:0]
 		for j, it := range data[i] {
@@ -976,6 +1453,22 @@ func (h *ApiHandler) array2json(nameList []string,
 	return string(resultBytes)
 }
 
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  name2metric
+ *  Description:  将metric名称按"|"拆分为grafana可显示的label集合
+ * =====================================================================================
+ */
+func (h *ApiHandler) name2metric(name string) map[string]string {
+	var metric = make(map[string]string)
+	var fieldList = strings.Split(name, "|")
+	for j, it := range fieldList {
+		metric[fmt.Sprintf("field%d", j+1)] = it
+	}
+	metric["name"] = fieldList[len(fieldList)-1]
+	return metric
+}
+
 /*
 // =====================================================================================
 //  test
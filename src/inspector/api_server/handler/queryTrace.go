@@ -0,0 +1,262 @@
+/*
+// =====================================================================================
+//
+//       Filename:  queryTrace.go
+//
+//    Description:  查询的trace：每个请求生成trace id，通过grpc metadata传递给collector与store，
+//                  请求带有debug=trace参数或者X-Inspector-Debug: trace头时，
+//                  记录各个阶段的耗时，连同collector与store返回的耗时一起放在响应的trace中
+//
+//        Version:  1.0
+//        Created:  10/19/2026 07:48:02 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"context"
+	"inspector/util/grpc2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// 开启trace的参数值
+const traceDebugValue = "trace"
+
+// 开启trace的请求头
+const traceDebugHeader = "X-Inspector-Debug"
+
+// 返回trace id的响应头
+const traceIDHeader = "X-Inspector-Trace-Id"
+
+type queryTraceKey struct{}
+type traceSpanKey struct{}
+
+// =====================================================================================
+//       Struct:  queryTrace
+//  Description:  一个请求的trace，debug为false时只传递trace id，不记录耗时
+// =====================================================================================
+type queryTrace struct {
+	id    string
+	debug bool
+	start time.Time
+	lock  sync.Mutex
+	spans []*traceSpan
+}
+
+// =====================================================================================
+//       Struct:  traceSpan
+//  Description:  trace中的一个阶段，时间均为毫秒，startMs为相对请求开始的时间
+// =====================================================================================
+type traceSpan struct {
+	Name       string       `json:"name"`
+	Instance   string       `json:"instance,omitempty"`
+	Server     string       `json:"server,omitempty"`
+	StartMs    float64      `json:"startMs"`
+	DurationMs float64      `json:"durationMs"`
+	Children   []*traceSpan `json:"children,omitempty"`
+
+	trace *queryTrace
+	start time.Time
+}
+
+// =====================================================================================
+//       Struct:  traceResult
+//  Description:  响应中的trace
+// =====================================================================================
+type traceResult struct {
+	ID         string       `json:"id"`
+	DurationMs float64      `json:"durationMs"`
+	Spans      []*traceSpan `json:"spans"`
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  newQueryTrace
+ *  Description:  start为请求开始的时间，请求开始到创建trace之间记为parse阶段
+ * =====================================================================================
+ */
+func (h *ApiHandler) newQueryTrace(r *http.Request, params url.Values, start time.Time) *queryTrace {
+	var t = &queryTrace{
+		id:    grpc2.NewTraceID(),
+		debug: params.Get("debug") == traceDebugValue || r.Header.Get(traceDebugHeader) == traceDebugValue,
+		start: start,
+	}
+	if t.debug {
+		var span = &traceSpan{Name: "parse", trace: t, start: start}
+		span.finish()
+		t.spans = append(t.spans, span)
+	}
+	return t
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  withQueryTrace
+ *  Description:
+ * =====================================================================================
+ */
+func withQueryTrace(ctx context.Context, t *queryTrace) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, t)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  getQueryTrace
+ *  Description:  ctx中没有trace时返回nil，queryTrace与traceSpan的方法均可以在nil上调用
+ * =====================================================================================
+ */
+func getQueryTrace(ctx context.Context) *queryTrace {
+	var t, _ = ctx.Value(queryTraceKey{}).(*queryTrace)
+	return t
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  outgoing
+ *  Description:  将trace id放入发往collector与store的grpc metadata
+ * =====================================================================================
+ */
+func (t *queryTrace) outgoing(ctx context.Context) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return grpc2.WithTrace(ctx, t.id, t.debug)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  traceID
+ *  Description:
+ * =====================================================================================
+ */
+func (t *queryTrace) traceID() string {
+	if t == nil {
+		return ""
+	}
+	return t.id
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  result
+ *  Description:  返回响应中的trace，没有开启时返回nil
+ * =====================================================================================
+ */
+func (t *queryTrace) result() *traceResult {
+	if t == nil || !t.debug {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return &traceResult{
+		ID:         t.id,
+		DurationMs: durationMs(time.Since(t.start)),
+		Spans:      t.spans,
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  startSpan
+ *  Description:  开始一个阶段，ctx中已有阶段时作为其子阶段，返回带有新阶段的ctx
+ *                没有开启trace时返回原ctx与nil
+ * =====================================================================================
+ */
+func (h *ApiHandler) startSpan(ctx context.Context, name string) (context.Context, *traceSpan) {
+	var t = getQueryTrace(ctx)
+	if t == nil || !t.debug {
+		return ctx, nil
+	}
+	var span = &traceSpan{Name: name, trace: t, start: time.Now()}
+	var parent, _ = ctx.Value(traceSpanKey{}).(*traceSpan)
+	t.lock.Lock()
+	span.StartMs = durationMs(span.start.Sub(t.start))
+	if parent != nil {
+		parent.Children = append(parent.Children, span)
+	} else {
+		t.spans = append(t.spans, span)
+	}
+	t.lock.Unlock()
+	return context.WithValue(ctx, traceSpanKey{}, span), span
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  finish
+ *  Description:  结束阶段，记录耗时
+ * =====================================================================================
+ */
+func (s *traceSpan) finish() {
+	if s == nil {
+		return
+	}
+	s.trace.lock.Lock()
+	s.StartMs = durationMs(s.start.Sub(s.trace.start))
+	s.DurationMs = durationMs(time.Since(s.start))
+	s.trace.lock.Unlock()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  setInstance
+ *  Description:
+ * =====================================================================================
+ */
+func (s *traceSpan) setInstance(instance string) {
+	if s == nil {
+		return
+	}
+	s.trace.lock.Lock()
+	s.Instance = instance
+	s.trace.lock.Unlock()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  setServer
+ *  Description:
+ * =====================================================================================
+ */
+func (s *traceSpan) setServer(server string) {
+	if s == nil {
+		return
+	}
+	s.trace.lock.Lock()
+	s.Server = server
+	s.trace.lock.Unlock()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  addSteps
+ *  Description:  将collector或store返回的耗时作为子阶段，各步骤按顺序从阶段开始时依次排列
+ * =====================================================================================
+ */
+func (s *traceSpan) addSteps(steps []grpc2.TraceStep) {
+	if s == nil || len(steps) == 0 {
+		return
+	}
+	s.trace.lock.Lock()
+	defer s.trace.lock.Unlock()
+	var start = s.start
+	for _, it := range steps {
+		s.Children = append(s.Children, &traceSpan{
+			Name:       it.Name,
+			StartMs:    durationMs(start.Sub(s.trace.start)),
+			DurationMs: durationMs(it.Duration),
+			trace:      s.trace,
+			start:      start,
+		})
+		start = start.Add(it.Duration)
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
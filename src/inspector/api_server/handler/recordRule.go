@@ -0,0 +1,343 @@
+/*
+// =====================================================================================
+//
+//       Filename:  recordRule.go
+//
+//    Description:  recording rule，定期执行配置的查询语句，将结果作为新的service写回store
+//                  规则保存在config server的recordRule中，所有api_server共享，
+//                  只有heartbeat leader执行。结果复用remote_write的路由与缓存落盘，
+//                  注册DictServer与pushList后可以像采集的数据一样查询
+//
+//        Version:  1.0
+//        Created:  10/19/2026 11:02:37 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
+	"inspector/proto/prometheus"
+	"inspector/util"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// 检查leader以及规则是否到达执行时间的间隔(秒)
+const recordCheckInterval = 5
+
+// recordRule中的字段
+const (
+	recordExprName     = "expr"     // 查询语句，与query_range的query参数相同
+	recordTargetName   = "record"   // 结果写入的"service|metric"
+	recordIntervalName = "interval" // 执行间隔(秒)，不配置时使用结果service的interval
+)
+
+// =====================================================================================
+//       Struct:  recordRule
+//  Description:  一条recording rule，结果写入service的metric中
+// =====================================================================================
+type recordRule struct {
+	name     string
+	expr     string
+	service  string
+	metric   string
+	interval int
+}
+
+// =====================================================================================
+//       Struct:  recordManager
+//  Description:  记录每条规则上次执行的时间，running中的规则不会重复执行
+// =====================================================================================
+type recordManager struct {
+	lock     sync.Mutex
+	lastEval map[string]int64 // 规则名称 -> 上次执行时间(unix秒)
+	running  map[string]bool
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  StartRecordRules
+ *  Description:  启动recording rule的执行，每个api_server都启动，由leader实际执行
+ * =====================================================================================
+ */
+func StartRecordRules() {
+	var manager = &recordManager{
+		lastEval: make(map[string]int64),
+		running:  make(map[string]bool),
+	}
+	go func() {
+		for now := range time.Tick(recordCheckInterval * time.Second) {
+			new(ApiHandler).runRecordRules(manager, now)
+		}
+	}()
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  runRecordRules
+ *  Description:  执行所有到达执行时间的规则，规则在进入新的执行周期后执行一次
+ *                不是leader时清空执行记录，成为leader后立即执行
+ * =====================================================================================
+ */
+func (h *ApiHandler) runRecordRules(manager *recordManager, now time.Time) {
+	var leader, err = configure.Options.HeartbeatServer.IsLeader()
+	if err != nil {
+		glog.Errorf("[RecordRule] check leader error: %s", err.Error())
+		return
+	}
+	if !leader {
+		manager.lock.Lock()
+		manager.lastEval = make(map[string]int64)
+		manager.lock.Unlock()
+		return
+	}
+
+	var ruleList, errList = h.loadRecordRules()
+	for _, it := range errList {
+		glog.Errorf("[RecordRule] %s", it.Error())
+	}
+	for _, rule := range ruleList {
+		var interval = rule.interval
+		if interval <= 0 {
+			if interval, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, rule.service, util.IntervalName); err != nil || interval <= 0 {
+				glog.Errorf("[RecordRule] rule[%s]: interval of service[%s] is invalid", rule.name, rule.service)
+				continue
+			}
+		}
+
+		manager.lock.Lock()
+		if manager.running[rule.name] || now.Unix()/int64(interval) == manager.lastEval[rule.name]/int64(interval) {
+			manager.lock.Unlock()
+			continue
+		}
+		manager.running[rule.name] = true
+		manager.lastEval[rule.name] = now.Unix()
+		manager.lock.Unlock()
+
+		go func(rule *recordRule) {
+			defer func() {
+				manager.lock.Lock()
+				delete(manager.running, rule.name)
+				manager.lock.Unlock()
+			}()
+			var n int
+			var err = h.runSafe(func() error {
+				var err error
+				n, err = new(ApiHandler).evaluateRecordRule(rule, getPushBuffer(), now)
+				return err
+			})
+			if err != nil {
+				glog.Errorf("[RecordRule] evaluate rule[%s] error: %s", rule.name, err.Error())
+				return
+			}
+			glog.V(2).Infof("[RecordRule] evaluate rule[%s]: %d points recorded", rule.name, n)
+		}(rule)
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  loadRecordRules
+ *  Description:  从config server读取所有规则，按照名称排序，配置错误的规则跳过并返回错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) loadRecordRules() ([]*recordRule, []error) {
+	var cs = configure.Options.ConfigServer
+	var nameList, err = cs.GetKeyList(util.RecordRuleCollection)
+	if err != nil {
+		if util.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("get rule list error: %s", err.Error())}
+	}
+	sort.Strings(nameList)
+
+	var ruleList []*recordRule
+	var errList []error
+	for _, name := range nameList {
+		var rule = &recordRule{name: name}
+		var target string
+		if rule.expr, err = cs.GetString(util.RecordRuleCollection, name, recordExprName); err != nil || len(rule.expr) == 0 {
+			errList = append(errList, fmt.Errorf("rule[%s]: %s is not configured", name, recordExprName))
+			continue
+		}
+		if target, err = cs.GetString(util.RecordRuleCollection, name, recordTargetName); err != nil {
+			errList = append(errList, fmt.Errorf("rule[%s]: %s is not configured", name, recordTargetName))
+			continue
+		}
+		if rule.service, rule.metric, err = h.parseRecordTarget(target); err != nil {
+			errList = append(errList, fmt.Errorf("rule[%s]: %s", name, err.Error()))
+			continue
+		}
+		if rule.interval, err = cs.GetInt(util.RecordRuleCollection, name, recordIntervalName); err != nil {
+			rule.interval = 0
+		}
+		ruleList = append(ruleList, rule)
+	}
+	return ruleList, errList
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseRecordTarget
+ *  Description:  解析"service|metric"形式的结果名称，metric中可以带"|"
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseRecordTarget(target string) (string, string, error) {
+	var fieldList = strings.SplitN(target, "|", 2)
+	if len(fieldList) != 2 || len(fieldList[0]) == 0 || len(fieldList[1]) == 0 {
+		return "", "", fmt.Errorf("%s[%s] should be like \"service|metric\"", recordTargetName, target)
+	}
+	if util.FilterName(fieldList[0]) || strings.ContainsAny(fieldList[0], ".$") {
+		return "", "", fmt.Errorf("service[%s] of %s is invalid", fieldList[0], recordTargetName)
+	}
+	return fieldList[0], pushNameReplacer.Replace(fieldList[1]), nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  evaluateRecordRule
+ *  Description:  计算最近RecordLookback个点并写入缓存，返回写入的点数
+ *                按照结果service的interval采样，只写入已经结束的点，
+ *                每次重新计算之前的点，修正执行时还没有到达的数据
+ *                与remote write相同写入hid的owner，leader切换时新旧leader的结果在owner中合并
+ * =====================================================================================
+ */
+func (h *ApiHandler) evaluateRecordRule(rule *recordRule, buffer *pushBuffer, now time.Time) (int, error) {
+	var cs = configure.Options.ConfigServer
+	var step, count, dataStep int
+	var err error
+	if step, err = cs.GetInt(util.MetaCollection, rule.service, util.IntervalName); err != nil {
+		return 0, h.metaError(rule.service, util.IntervalName, err)
+	}
+	if count, err = cs.GetInt(util.MetaCollection, rule.service, util.CountName); err != nil {
+		return 0, h.metaError(rule.service, util.CountName, err)
+	}
+	if step <= 0 || count <= 0 {
+		return 0, fmt.Errorf("interval[%d] or count[%d] of service[%s] is invalid", step, count, rule.service)
+	}
+
+	var stmt *queryStatement
+	if stmt, err = h.parseQueryStatement(rule.expr); err != nil {
+		return 0, fmt.Errorf("parse %s[%s] error: %s", recordExprName, rule.expr, err.Error())
+	}
+	if dataStep, err = cs.GetInt(util.MetaCollection, stmt.service, util.IntervalName); err != nil {
+		return 0, h.metaError(stmt.service, util.IntervalName, err)
+	}
+	var instanceList []map[string]string
+	if instanceList, err = h.selectInstances(stmt); err != nil {
+		return 0, err
+	}
+
+	var lookback = configure.Options.RecordLookback
+	if lookback <= 0 {
+		lookback = 1
+	}
+	var endTime = uint32(now.Unix()) - uint32(now.Unix())%uint32(step)
+	var startTime = endTime - uint32(lookback*step)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Duration(step)*time.Second)
+	defer cancel()
+	var virtualStartTime, metricLabelList, indexList, dataList, warnings, queryErr = h.doQueryStatement(ctx, stmt, instanceList, startTime, endTime, step)
+	if queryErr != nil {
+		return 0, queryErr
+	}
+	for _, it := range warnings {
+		glog.Warningf("[RecordRule] rule[%s]: %s", rule.name, it)
+	}
+
+	var seriesList []*prometheus.TimeSeries
+	for i, data := range dataList {
+		var target = h.recordTarget(rule, stmt, metricLabelList[i])
+		var series = &prometheus.TimeSeries{Labels: pushTargetLabels(target, target.key)}
+		for j, it := range data {
+			if it == util.NullData {
+				continue
+			}
+			var index = j
+			if indexList != nil && i < len(indexList) && indexList[i] != nil {
+				index = indexList[i][j]
+			}
+			var timestamp = (virtualStartTime + uint32(index)) * uint32(dataStep)
+			if timestamp < startTime || timestamp >= endTime {
+				continue
+			}
+			// 所在存储单元已经落盘，不再修正
+			if now.Unix() >= pushBlockDue(timestamp, step, count) {
+				continue
+			}
+			series.Samples = append(series.Samples, &prometheus.Sample{
+				Value:     float64(it) / util.FloatMultiple,
+				Timestamp: int64(timestamp) * 1000,
+			})
+		}
+		if len(series.Samples) > 0 {
+			seriesList = append(seriesList, series)
+		}
+	}
+
+	var result = h.writeSeries(buffer, seriesList, false, now)
+	if result.backendErr != nil {
+		return result.accepted, result.backendErr
+	}
+	return result.accepted, result.firstErr
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  recordTarget
+ *  Description:  结果曲线对应的实例与metric，保留查询结果中的hid、pid、host
+ *                聚合掉host时以规则名称以及剩余的实例label作为host，hid由host计算
+ *                没有计算表达式且查询多个metric时，metric名称后面加上原始metric名称
+ * =====================================================================================
+ */
+func (h *ApiHandler) recordTarget(rule *recordRule, stmt *queryStatement, labels map[string]string) *pushTarget {
+	var target = &pushTarget{
+		service: rule.service,
+		host:    labels[syntax.LabelHost],
+		key:     rule.metric,
+	}
+	if len(target.host) == 0 {
+		var fieldList = []string{rule.name}
+		for _, it := range []string{syntax.LabelHid, syntax.LabelPid} {
+			if value, ok := labels[it]; ok {
+				fieldList = append(fieldList, it+"-"+value)
+			}
+		}
+		target.host = strings.Join(fieldList, "_")
+	}
+	if hid, err := strconv.ParseInt(labels[syntax.LabelHid], 10, 32); err == nil {
+		target.hid = int32(hid)
+	} else {
+		target.hid = int32(crc32.ChecksumIEEE([]byte(target.host)) & math.MaxInt32)
+	}
+	if pid, err := strconv.Atoi(labels[syntax.LabelPid]); err == nil {
+		target.pid = pid
+	}
+
+	if len(stmt.opExpression) == 0 && len(stmt.metricList) > 1 {
+		var fieldList []string
+		for i := 1; ; i++ {
+			var value, ok = labels[fmt.Sprintf("field%d", i)]
+			if !ok {
+				break
+			}
+			fieldList = append(fieldList, value)
+		}
+		target.key = rule.metric + "|" + pushNameReplacer.Replace(strings.Join(fieldList, "|"))
+	}
+	return target
+}
@@ -0,0 +1,250 @@
+/*
+// =====================================================================================
+//
+//       Filename:  remoteReadHandler.go
+//
+//    Description:  prometheus remote read协议，请求与返回均为snappy压缩的protobuf
+//                  用于将秒级数据联邦到prometheus/thanos中，而不需要重新采集
+//
+//        Version:  1.0
+//        Created:  10/19/2026 12:27:45 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
+	"inspector/proto/prometheus"
+	"inspector/util"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// prometheus的matcher类型与syntax中的类型对应关系
+var remoteMatchTypeMap = map[prometheus.LabelMatcher_Type]syntax.MatchType{
+	prometheus.LabelMatcher_EQ:  syntax.MatchEqual,
+	prometheus.LabelMatcher_NEQ: syntax.MatchNotEqual,
+	prometheus.LabelMatcher_RE:  syntax.MatchRegexp,
+	prometheus.LabelMatcher_NRE: syntax.MatchNotRegexp,
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  RemoteReadHandler
+ *  Description:  只支持SAMPLES类型的返回，不支持流式返回
+ *                每个query按照matchers选择曲线后，与query_range相同的方式获取原始数据
+ * =====================================================================================
+ */
+func (h *ApiHandler) RemoteReadHandler(w http.ResponseWriter, r *http.Request) {
+	glog.V(1).Infof("[Trace][RemoteReadHandler] called: Request[%v]", r)
+	h.timeReset()
+
+	var req, err = h.parseRemoteReadRequest(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.timeTick("parse request")
+
+	var ctx, cancel, ctxErr = h.queryContext(r, r.URL.Query())
+	if ctxErr != nil {
+		h.writeError(w, ctxErr)
+		return
+	}
+	defer cancel()
+
+	var res = &prometheus.ReadResponse{
+		Results: make([]*prometheus.QueryResult, 0, len(req.Queries)),
+	}
+	for _, query := range req.Queries {
+		var result *prometheus.QueryResult
+		if result, err = h.doRemoteQuery(ctx, query); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		res.Results = append(res.Results, result)
+	}
+	h.timeTick("doRemoteQuery")
+
+	var data []byte
+	if data, err = proto.Marshal(res); err != nil {
+		h.writeError(w, newApiError(errorInternal, "marshal response error: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	w.Write(snappy.Encode(nil, data))
+	h.timeTick("write response")
+	h.printLabelPerf("RemoteReadHandler")
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseRemoteReadRequest
+ *  Description:
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseRemoteReadRequest(r *http.Request) (*prometheus.ReadRequest, error) {
+	var compressed, err = ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newApiError(errorBadData, "read request body error: %s", err.Error())
+	}
+	var data []byte
+	if data, err = snappy.Decode(nil, compressed); err != nil {
+		return nil, newApiError(errorBadData, "decode snappy request error: %s", err.Error())
+	}
+	var req = new(prometheus.ReadRequest)
+	if err = proto.Unmarshal(data, req); err != nil {
+		return nil, newApiError(errorBadData, "unmarshal read request error: %s", err.Error())
+	}
+	return req, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  remoteSelector
+ *  Description:  将prometheus的matchers转换为selector，service/hid/pid/host/__name__之外的
+ *                label在infinsight中不存在，按照prometheus的语义视为空字符串，
+ *                不能匹配空字符串时返回false，表示不会选中任何曲线
+ * =====================================================================================
+ */
+func (h *ApiHandler) remoteSelector(matchers []*prometheus.LabelMatcher) (*syntax.Selector, bool, error) {
+	var selector = new(syntax.Selector)
+	for _, it := range matchers {
+		var t, ok = remoteMatchTypeMap[it.GetType()]
+		if !ok {
+			return nil, false, newApiError(errorBadData, "unknown matcher type[%v]", it.GetType())
+		}
+		var m, err = syntax.NewLabelMatcher(t, it.GetName(), it.GetValue())
+		if err != nil {
+			return nil, false, newApiError(errorBadData, "invalid matcher %s: %s", it.String(), err.Error())
+		}
+
+		switch m.Name {
+		case syntax.LabelName:
+			// 精确匹配时直接使用metric名称，不需要遍历字典
+			if m.Type == syntax.MatchEqual {
+				selector.Metrics = append(selector.Metrics, m.Value)
+			}
+		case syntax.LabelService, syntax.LabelHid, syntax.LabelPid, syntax.LabelHost:
+		default:
+			if !m.Matches("") {
+				return selector, false, nil
+			}
+			continue
+		}
+		selector.Matchers = append(selector.Matchers, m)
+	}
+	return selector, true, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  doRemoteQuery
+ *  Description:  query中的时间为毫秒，start向上取整，end为闭区间
+ *                单个实例出错时只记录日志，所有实例都出错时返回错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) doRemoteQuery(ctx context.Context, query *prometheus.Query) (*prometheus.QueryResult, error) {
+	var result = &prometheus.QueryResult{
+		Timeseries: make([]*prometheus.TimeSeries, 0),
+	}
+	var selector, hit, err = h.remoteSelector(query.GetMatchers())
+	if err != nil || !hit {
+		return result, err
+	}
+	if query.GetEndTimestampMs() < query.GetStartTimestampMs() || query.GetEndTimestampMs() < 0 {
+		return result, nil
+	}
+	var startTime = uint32(0)
+	if query.GetStartTimestampMs() > 0 {
+		startTime = uint32((query.GetStartTimestampMs() + 999) / 1000)
+	}
+	var endTime = uint32(query.GetEndTimestampMs()/1000) + 1
+
+	var seriesList []*seriesSet
+	if seriesList, err = h.findSeries([]*syntax.Selector{selector}, startTime, endTime, true); err != nil {
+		return nil, err
+	}
+	for _, set := range seriesList {
+		var step int
+		if step, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, set.service, "interval"); err != nil {
+			return nil, h.metaError(set.service, "interval", err)
+		}
+		var stmt = &queryStatement{
+			service:    set.service,
+			metricList: set.metricList,
+		}
+		if _, err = h.checkQueryLimits(query.String(), stmt, set.instanceList, startTime, endTime); err != nil {
+			return nil, err
+		}
+
+		var virtualStartTime, metricLabelList, _, dataList, warnings, queryErr = h.doQueryStatement(ctx, stmt, set.instanceList, startTime, endTime, step)
+		if queryErr != nil {
+			return nil, queryErr
+		}
+		for _, it := range warnings {
+			glog.Warningf("[RemoteReadHandler] service[%s]: %s", set.service, it)
+		}
+		for i, data := range dataList {
+			var series = &prometheus.TimeSeries{
+				Labels: h.remoteLabels(set.service, metricLabelList[i]),
+			}
+			for j, value := range data {
+				if value == util.NullData {
+					continue
+				}
+				var timestamp = int64(virtualStartTime+uint32(j)) * int64(step)
+				if timestamp < int64(startTime) || timestamp >= int64(endTime) {
+					continue
+				}
+				series.Samples = append(series.Samples, &prometheus.Sample{
+					Value:     float64(value) / util.FloatMultiple,
+					Timestamp: timestamp * 1000,
+				})
+			}
+			if len(series.Samples) > 0 {
+				result.Timeseries = append(result.Timeseries, series)
+			}
+		}
+	}
+	return result, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  remoteLabels
+ *  Description:  metric为seriesMetric返回的label集合，按照字典序返回
+ *                __name__与label values接口相同，为"service|metric"
+ * =====================================================================================
+ */
+func (h *ApiHandler) remoteLabels(service string, metric map[string]string) []*prometheus.Label {
+	var fieldList []string
+	for j := 1; ; j++ {
+		var field, ok = metric[fmt.Sprintf("field%d", j)]
+		if !ok {
+			break
+		}
+		fieldList = append(fieldList, field)
+	}
+	var labels = make([]*prometheus.Label, 0, len(labelNameList))
+	for _, name := range labelNameList {
+		var value = metric[name]
+		if name == syntax.LabelName {
+			value = service + "|" + strings.Join(fieldList, "|")
+		}
+		labels = append(labels, &prometheus.Label{Name: name, Value: value})
+	}
+	return labels
+}
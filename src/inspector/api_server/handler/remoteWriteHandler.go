@@ -0,0 +1,693 @@
+/*
+// =====================================================================================
+//
+//       Filename:  remoteWriteHandler.go
+//
+//    Description:  prometheus remote write协议，接收推送的数据并写入store_server
+//                  数据按照存储单元(count个点)缓存，存储单元结束后统一压缩落盘，
+//                  每个实例只由hid的owner缓存与落盘，见remoteWriteRoute.go，
+//                  与collector写入的数据格式相同，可以直接在已有的dashboard中查询
+//
+//        Version:  1.0
+//        Created:  10/19/2026 02:14:08 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
+	"inspector/compress"
+	"inspector/dict_server"
+	"inspector/heartbeat"
+	"inspector/proto/core"
+	"inspector/proto/prometheus"
+	"inspector/proto/store"
+	"inspector/util"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"google.golang.org/grpc"
+)
+
+// service与host缺失时分别使用prometheus的job与instance
+const (
+	remoteLabelJob      = "job"
+	remoteLabelInstance = "instance"
+)
+
+// pushList中实例的来源字段，matchInstances会将其复制到实例map中
+// 推送的实例没有被分配给collector，查询时只需要查询store
+const (
+	instanceSourceName = "source"
+	instanceSourcePush = "push"
+)
+
+// 推送实例在pushList中的登记时间刷新间隔(秒)
+const pushRegisterInterval = 600
+
+// metric名称中不能出现的字符：'.'与'$'不能作为mongo的字段名，label中也不能出现metric的分隔符'|'
+var (
+	pushNameReplacer  = strings.NewReplacer(".", "_", "$", "_")
+	pushLabelReplacer = strings.NewReplacer(".", "_", "$", "_", "|", "_")
+)
+
+// =====================================================================================
+//       Struct:  pushTarget
+//  Description:  由一条曲线的label解析得到的实例以及metric名称
+// =====================================================================================
+type pushTarget struct {
+	service string
+	hid     int32
+	pid     int
+	host    string
+	key     string // metric的long key
+}
+
+// =====================================================================================
+//       Struct:  pushBlock
+//  Description:  一个实例的一个存储单元，data为long key -> 定点数数组(长度为count)
+//                due之后落盘，落盘失败时重试到expire为止
+// =====================================================================================
+type pushBlock struct {
+	target *pushTarget
+	start  uint32 // 存储单元起始虚拟时间
+	count  int
+	step   int
+	due    int64 // unix秒
+	expire int64 // unix秒
+	data   map[string][]int64
+}
+
+// =====================================================================================
+//       Struct:  pushBuffer
+//  Description:  尚未落盘的存储单元，key为service|hid|host|存储单元起始虚拟时间
+//                registered记录实例最后一次登记到pushList的时间
+// =====================================================================================
+type pushBuffer struct {
+	lock       sync.Mutex
+	blocks     map[string]*pushBlock
+	registered map[string]int64
+}
+
+var (
+	globalPushBuffer     *pushBuffer
+	globalPushBufferOnce sync.Once
+)
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  getPushBuffer
+ *  Description:  第一次调用时创建，并启动每秒一次的移交与落盘
+ * =====================================================================================
+ */
+func getPushBuffer() *pushBuffer {
+	globalPushBufferOnce.Do(func() {
+		globalPushBuffer = newPushBuffer()
+		go func() {
+			for now := range time.Tick(time.Second) {
+				new(ApiHandler).handoffPushBuffer(globalPushBuffer, now)
+				new(ApiHandler).flushPushBuffer(globalPushBuffer, now)
+			}
+		}()
+	})
+	return globalPushBuffer
+}
+
+func newPushBuffer() *pushBuffer {
+	return &pushBuffer{
+		blocks:     make(map[string]*pushBlock),
+		registered: make(map[string]int64),
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  add
+ *  Description:  timestamp为unix秒，已经落盘或者超前一个存储单元以上的数据返回错误
+ *                同一个点写入多次时以最后一次为准
+ * =====================================================================================
+ */
+func (b *pushBuffer) add(target *pushTarget, timestamp uint32, value int64, step, count int, now time.Time) error {
+	var virtual = timestamp / uint32(step)
+	var start = virtual - virtual%uint32(count)
+	var end = int64(start+uint32(count)) * int64(step)
+	var due = pushBlockDue(timestamp, step, count)
+	if now.Unix() >= due {
+		return fmt.Errorf("sample at %d is too old, block ended at %d", timestamp, end)
+	}
+	if int64(timestamp) > now.Unix()+int64(count*step) {
+		return fmt.Errorf("sample at %d is too far in the future", timestamp)
+	}
+
+	var key = fmt.Sprintf("%s|%d|%s|%d", target.service, target.hid, target.host, start)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var block, ok = b.blocks[key]
+	if !ok {
+		block = &pushBlock{
+			target: target,
+			start:  start,
+			count:  count,
+			step:   step,
+			due:    due,
+			expire: due + int64(count*step),
+			data:   make(map[string][]int64),
+		}
+		b.blocks[key] = block
+	}
+	var data, exist = block.data[target.key]
+	if !exist {
+		data = make([]int64, count)
+		for i := range data {
+			data[i] = util.NullData
+		}
+		block.data[target.key] = data
+	}
+	data[virtual-start] = value
+	return nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushBlockDue
+ *  Description:  timestamp所在存储单元的落盘时间(unix秒)，之后该存储单元不再接收数据
+ * =====================================================================================
+ */
+func pushBlockDue(timestamp uint32, step, count int) int64 {
+	var virtual = timestamp / uint32(step)
+	var start = virtual - virtual%uint32(count)
+	return int64(start+uint32(count))*int64(step) + int64(configure.Options.RemoteWriteDelay)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  popDue
+ *  Description:  取出所有到达落盘时间的存储单元
+ * =====================================================================================
+ */
+func (b *pushBuffer) popDue(now time.Time) []*pushBlock {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var result []*pushBlock
+	for key, block := range b.blocks {
+		if now.Unix() >= block.due {
+			result = append(result, block)
+			delete(b.blocks, key)
+		}
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  retry
+ *  Description:  将落盘失败的存储单元放回，超过expire的直接丢弃
+ * =====================================================================================
+ */
+func (b *pushBuffer) retry(blockList []*pushBlock, now time.Time, reason string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, block := range blockList {
+		var target = block.target
+		if now.Unix() >= block.expire {
+			glog.Errorf("[RemoteWrite] drop block[%d] of service[%s] hid[%d] host[%s] with %d metrics: %s",
+				block.start, target.service, target.hid, target.host, len(block.data), reason)
+			continue
+		}
+		b.blocks[fmt.Sprintf("%s|%d|%s|%d", target.service, target.hid, target.host, block.start)] = block
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  RemoteWriteHandler
+ *  Description:  部分曲线无法写入时，其余曲线仍然写入。只有数据本身有误时返回bad_data，
+ *                prometheus不会重试4xx的请求；读取配置、字典以及转发等后端错误返回5xx，
+ *                由prometheus重试，重试时已经写入的点被覆盖为相同的值
+ * =====================================================================================
+ */
+func (h *ApiHandler) RemoteWriteHandler(w http.ResponseWriter, r *http.Request) {
+	glog.V(1).Infof("[Trace][RemoteWriteHandler] called: Request[%v]", r)
+	h.timeReset()
+
+	var req, err = h.parseRemoteWriteRequest(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.timeTick("parse request")
+
+	var forwarded = len(r.Header.Get(remoteForwardHeader)) > 0
+	var result = h.writeSeries(getPushBuffer(), req.Timeseries, forwarded, time.Now())
+	h.timeTick("writeSeries")
+	h.printLabelPerf("RemoteWriteHandler")
+
+	if result.backendErr != nil {
+		h.writeError(w, newApiError(result.backendErr.typ, "%d of %d samples rejected, first backend error: %s",
+			result.rejected, result.accepted+result.rejected, result.backendErr.Error()))
+		return
+	}
+	if result.firstErr != nil {
+		h.writeError(w, newApiError(errorBadData, "%d of %d samples rejected, first error: %s",
+			result.rejected, result.accepted+result.rejected, result.firstErr.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseRemoteWriteRequest
+ *  Description:
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseRemoteWriteRequest(r *http.Request) (*prometheus.WriteRequest, error) {
+	var compressed, err = ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newApiError(errorBadData, "read request body error: %s", err.Error())
+	}
+	var data []byte
+	if data, err = snappy.Decode(nil, compressed); err != nil {
+		return nil, newApiError(errorBadData, "decode snappy request error: %s", err.Error())
+	}
+	var req = new(prometheus.WriteRequest)
+	if err = proto.Unmarshal(data, req); err != nil {
+		return nil, newApiError(errorBadData, "unmarshal write request error: %s", err.Error())
+	}
+	return req, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushSeries
+ *  Description:  将一条曲线的数据写入缓存，返回写入成功的点数，后端错误返回非bad_data的apiError
+ *                service需要在meta中配置interval与count，数据按照precision转换为定点数
+ *                NaN(prometheus的stale marker)与Inf直接忽略
+ * =====================================================================================
+ */
+func (h *ApiHandler) pushSeries(buffer *pushBuffer, target *pushTarget, series *prometheus.TimeSeries, now time.Time) (int, error) {
+	var step, count, precision int
+	var err error
+	if step, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, target.service, util.IntervalName); err != nil {
+		return 0, h.metaError(target.service, util.IntervalName, err)
+	}
+	if count, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, target.service, util.CountName); err != nil {
+		return 0, h.metaError(target.service, util.CountName, err)
+	}
+	if step <= 0 || count <= 0 {
+		return 0, fmt.Errorf("interval[%d] or count[%d] of service[%s] is invalid", step, count, target.service)
+	}
+	if precision, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, target.service, util.PrecisionName); err != nil {
+		precision = 0
+	}
+	var multiple = util.PrecisionMultiple(precision)
+
+	// 提前注册metric，注册是异步的，落盘时再获取short key
+	var dict *dictServer.DictServer
+	if dict, err = h.loadDictServer(target.service); err != nil {
+		return 0, newApiError(errorUnavailable, "%s", err.Error())
+	}
+	dict.GetValue(target.key)
+
+	var accepted int
+	var lastErr error
+	for _, sample := range series.GetSamples() {
+		if math.IsNaN(sample.GetValue()) || math.IsInf(sample.GetValue(), 0) {
+			accepted++
+			continue
+		}
+		if sample.GetTimestamp() < 0 {
+			lastErr = fmt.Errorf("sample timestamp[%d] is negative", sample.GetTimestamp())
+			continue
+		}
+		var value int64
+		if value, err = util.Float2Fixed(sample.GetValue(), multiple); err != nil {
+			lastErr = err
+			continue
+		}
+		if err = buffer.add(target, uint32(sample.GetTimestamp()/1000), value, step, count, now); err != nil {
+			lastErr = err
+			continue
+		}
+		accepted++
+	}
+	if lastErr != nil {
+		return accepted, fmt.Errorf("series[%s|%s] host[%s]: %s", target.service, target.key, target.host, lastErr.Error())
+	}
+	return accepted, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushTarget
+ *  Description:  service取自service label，不存在时使用job；host取自host label，不存在时使用instance
+ *                hid不存在时由host计算得到，保证同一个实例总是写入同一个store
+ *                其余label按照名称排序后以"|name:value"的形式拼接在__name__之后作为metric名称
+ * =====================================================================================
+ */
+func (h *ApiHandler) pushTarget(labels []*prometheus.Label) (*pushTarget, error) {
+	var target = new(pushTarget)
+	var name, job, instance, hid, pid string
+	var extra []*prometheus.Label
+	for _, it := range labels {
+		switch it.GetName() {
+		case syntax.LabelName:
+			name = it.GetValue()
+		case syntax.LabelService:
+			target.service = it.GetValue()
+		case syntax.LabelHost:
+			target.host = it.GetValue()
+		case syntax.LabelHid:
+			hid = it.GetValue()
+		case syntax.LabelPid:
+			pid = it.GetValue()
+		case remoteLabelJob:
+			job = it.GetValue()
+		case remoteLabelInstance:
+			instance = it.GetValue()
+		default:
+			if len(it.GetValue()) > 0 {
+				extra = append(extra, it)
+			}
+		}
+	}
+	if len(target.service) == 0 {
+		target.service = job
+	}
+	if len(target.host) == 0 {
+		target.host = instance
+	}
+	if len(target.service) == 0 || util.FilterName(target.service) || strings.ContainsAny(target.service, ".$|") {
+		return nil, fmt.Errorf("series %v without valid service or job label", labels)
+	}
+	if len(target.host) == 0 {
+		return nil, fmt.Errorf("series %v without host or instance label", labels)
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("series %v without metric name", labels)
+	}
+
+	if len(hid) > 0 {
+		var v, err = strconv.ParseInt(hid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("hid[%s] is not a number", hid)
+		}
+		target.hid = int32(v)
+	} else {
+		target.hid = int32(crc32.ChecksumIEEE([]byte(target.host)) & math.MaxInt32)
+	}
+	if len(pid) > 0 {
+		var err error
+		if target.pid, err = strconv.Atoi(pid); err != nil {
+			return nil, fmt.Errorf("pid[%s] is not a number", pid)
+		}
+	}
+
+	// remote read返回的__name__为service|metric，写回时去掉前缀
+	var fieldList = []string{pushNameReplacer.Replace(strings.TrimPrefix(name, target.service+"|"))}
+	sort.Slice(extra, func(i, j int) bool {
+		return extra[i].GetName() < extra[j].GetName()
+	})
+	for _, it := range extra {
+		fieldList = append(fieldList, pushLabelReplacer.Replace(it.GetName())+":"+pushLabelReplacer.Replace(it.GetValue()))
+	}
+	target.key = strings.Join(fieldList, "|")
+	return target, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  loadDictServer
+ *  Description:  返回service的DictServer，不存在时创建，推送的service第一次写入时还没有字典
+ * =====================================================================================
+ */
+func (h *ApiHandler) loadDictServer(service string) (*dictServer.DictServer, error) {
+	if dict, ok := configure.Options.DictServerMap.Load(service); ok {
+		return dict.(*dictServer.DictServer), nil
+	}
+
+	var dict = dictServer.NewDictServer(&dictServer.Conf{
+		Address:    configure.Options.ConfigServerAddress,
+		Username:   configure.Options.ConfigServerUsername,
+		Password:   configure.Options.ConfigServerPassword,
+		DB:         configure.Options.ConfigServerDB,
+		ServerType: service,
+	}, configure.Options.ConfigServer)
+	if dict == nil {
+		return nil, fmt.Errorf("new DictServer[%s] error", service)
+	}
+	var actual, loaded = configure.Options.DictServerMap.LoadOrStore(service, dict)
+	if loaded {
+		dict.Close()
+	}
+	return actual.(*dictServer.DictServer), nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushBlock2info
+ *  Description:  将存储单元转换为store的Info，metric还没有注册完成时返回false，
+ *                超过expire时不再等待，丢弃没有注册的metric
+ * =====================================================================================
+ */
+func (h *ApiHandler) pushBlock2info(block *pushBlock, now time.Time) (*core.Info, bool) {
+	var target = block.target
+	var dict, err = h.loadDictServer(target.service)
+	if err != nil {
+		glog.Errorf("[RemoteWrite] %s", err.Error())
+		return nil, now.Unix() >= block.expire
+	}
+
+	var info = &core.Info{
+		Header: &core.Header{
+			Service: target.service,
+			Hid:     target.hid,
+			Host:    target.host,
+		},
+		Timestamp: block.start,
+		Count:     uint32(block.count),
+		Step:      uint32(block.step),
+		Items:     make([]*core.KVPair, 0, len(block.data)),
+	}
+	for key, data := range block.data {
+		var shortKey string
+		if shortKey, err = dict.GetValue(key); err != nil {
+			if now.Unix() < block.expire {
+				return nil, false
+			}
+			glog.Errorf("[RemoteWrite] drop metric[%s] of service[%s] host[%s]: not registered in DictServer",
+				key, target.service, target.host)
+			continue
+		}
+		var value []byte
+		if value, err = h.compressBlockData(data); err != nil {
+			glog.Errorf("[RemoteWrite] compress metric[%s] of service[%s] host[%s] error: %s",
+				key, target.service, target.host, err.Error())
+			continue
+		}
+		info.Items = append(info.Items, &core.KVPair{Key: shortKey, Value: value})
+	}
+	return info, true
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  compressBlockData
+ *  Description:  与collector相同：全部相同时使用SameDigitCompress，否则使用DiffCompress
+ *                DiffCompress会修改输入，这里压缩的是一份拷贝，失败重试时原数据不变
+ * =====================================================================================
+ */
+func (h *ApiHandler) compressBlockData(data []int64) ([]byte, error) {
+	var same = true
+	var gcd int64
+	for _, it := range data {
+		if it == util.NullData || it != data[0] {
+			same = false
+		}
+		if it != util.NullData {
+			gcd = util.GCD(gcd, it)
+		}
+	}
+	if same {
+		return compress.Compress(compress.SameDigitCompress, len(data), data[0])
+	}
+	var valList = make([]int64, len(data))
+	copy(valList, data)
+	return compress.Compress(compress.DiffCompress, gcd, valList)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  flushPushBuffer
+ *  Description:  将到达落盘时间的存储单元写入store，按照hid选择store，与查询时相同
+ *                写入失败的存储单元放回重试，写入成功后将实例登记到pushList中
+ * =====================================================================================
+ */
+func (h *ApiHandler) flushPushBuffer(buffer *pushBuffer, now time.Time) {
+	var blockList = buffer.popDue(now)
+	if len(blockList) == 0 {
+		return
+	}
+
+	var allStore, err = configure.Options.HeartbeatServer.GetServices(heartbeat.ModuleStore, heartbeat.ServiceBoth)
+	if err != nil {
+		buffer.retry(blockList, now, fmt.Sprintf("get store server address list error: %s", err.Error()))
+		return
+	}
+	if len(allStore) == 0 {
+		buffer.retry(blockList, now, "no store server available")
+		return
+	}
+
+	var infoMap = make(map[string][]*core.Info)
+	var blockMap = make(map[string][]*pushBlock)
+	var pending []*pushBlock
+	for _, block := range blockList {
+		var info, ready = h.pushBlock2info(block, now)
+		if !ready {
+			pending = append(pending, block)
+			continue
+		}
+		if info == nil || len(info.Items) == 0 {
+			continue
+		}
+		var n = util.HashInstanceByHid(block.target.hid, len(allStore))
+		var address = strings.Replace(allStore[n].Name, "_", ".", -1)
+		infoMap[address] = append(infoMap[address], info)
+		blockMap[address] = append(blockMap[address], block)
+	}
+	buffer.retry(pending, now, "metrics are not registered in DictServer")
+
+	for address, infoList := range infoMap {
+		var res *store.StoreSaveResponse
+		if res, err = h.saveToStore(address, infoList); err != nil {
+			glog.Errorf("[RemoteWrite] save %d blocks to store server[%s] error: %s", len(infoList), address, err.Error())
+			buffer.retry(blockMap[address], now, err.Error())
+			continue
+		}
+		// 只重试失败的存储单元，已经写入的不再重复写入
+		var failList = h.failedPushBlocks(blockMap[address], infoList, res)
+		var failSet = make(map[*pushBlock]bool, len(failList))
+		if len(failList) > 0 {
+			var reason = fmt.Sprintf("%s[%d]", res.GetError().GetErrmsg(), res.GetError().GetErrno())
+			glog.Errorf("[RemoteWrite] save %d of %d blocks to store server[%s] error: %s",
+				len(failList), len(infoList), address, reason)
+			buffer.retry(failList, now, reason)
+			for _, block := range failList {
+				failSet[block] = true
+			}
+		}
+		for _, block := range blockMap[address] {
+			if !failSet[block] {
+				h.registerPushInstance(buffer, block.target, now)
+			}
+		}
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  saveToStore
+ *  Description:  返回的error表示整个请求失败，部分存储单元的失败在response中
+ * =====================================================================================
+ */
+func (h *ApiHandler) saveToStore(address string, infoList []*core.Info) (*store.StoreSaveResponse, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Duration(configure.Options.StoreTimeout)*time.Second)
+	defer cancel()
+
+	var conn, err = grpc.DialContext(ctx, address, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return store.NewStoreServiceClient(conn).Save(ctx, &store.StoreSaveRequest{InfoList: infoList})
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  failedPushBlocks
+ *  Description:  按照FailureList找出写入失败的存储单元，blockList与infoList一一对应
+ *                store没有返回每个数据块的结果时，全局错误表示全部失败
+ * =====================================================================================
+ */
+func (h *ApiHandler) failedPushBlocks(blockList []*pushBlock, infoList []*core.Info,
+	res *store.StoreSaveResponse) []*pushBlock {
+
+	if res.GetError().GetErrno() == 0 {
+		return nil
+	}
+	if len(res.GetFailureList()) == 0 && len(res.GetSuccessList()) == 0 {
+		return blockList
+	}
+
+	var blockKey = func(header *core.Header, timestamp uint32) string {
+		return fmt.Sprintf("%s|%d|%s|%d", header.GetService(), header.GetHid(), header.GetHost(), timestamp)
+	}
+	var failSet = make(map[string]struct{}, len(res.GetFailureList()))
+	for _, it := range res.GetFailureList() {
+		failSet[blockKey(it.GetHeader(), it.GetTimestamp())] = struct{}{}
+	}
+	var failList []*pushBlock
+	for i, info := range infoList {
+		if _, ok := failSet[blockKey(info.GetHeader(), info.GetTimestamp())]; ok {
+			failList = append(failList, blockList[i])
+		}
+	}
+	return failList
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  registerPushInstance
+ *  Description:  将实例登记到pushList中，格式与taskList相同，查询时与taskList中的实例合并
+ *                每个实例每pushRegisterInterval秒最多登记一次
+ * =====================================================================================
+ */
+func (h *ApiHandler) registerPushInstance(buffer *pushBuffer, target *pushTarget, now time.Time) {
+	var name = strings.Replace(target.host, ".", "_", -1)
+	var key = fmt.Sprintf("%s|%s", target.service, name)
+
+	buffer.lock.Lock()
+	var last = buffer.registered[key]
+	if now.Unix()-last < pushRegisterInterval {
+		buffer.lock.Unlock()
+		return
+	}
+	buffer.registered[key] = now.Unix()
+	buffer.lock.Unlock()
+
+	var instance = map[string]interface{}{
+		syntax.LabelHid:    int(target.hid),
+		syntax.LabelPid:    target.pid,
+		syntax.LabelHost:   target.host,
+		instanceSourceName: instanceSourcePush,
+		"timestamp":        int(now.Unix()),
+	}
+	if err := configure.Options.ConfigServer.SetItem(util.PushListCollection, target.service, instance,
+		util.TaskDistributeName, name); err != nil {
+		glog.Errorf("[RemoteWrite] register instance[%s] of service[%s] error: %s", target.host, target.service, err.Error())
+		buffer.lock.Lock()
+		delete(buffer.registered, key)
+		buffer.lock.Unlock()
+	}
+}
@@ -0,0 +1,364 @@
+/*
+// =====================================================================================
+//
+//       Filename:  remoteWriteRoute.go
+//
+//    Description:  remote write数据按照hid路由到唯一的owner api_server，
+//                  保证同一个实例的存储单元只在一个api_server中缓存，不会写入多个不完整的数据块
+//                  owner为存活的api_server中按照hid一致性hash选出的一个，非owner收到的数据转发给owner，
+//                  api_server变化导致owner改变时，尚未落盘的存储单元移交给新的owner
+//
+//        Version:  1.0
+//        Created:  10/21/2026 03:12:40 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/api_server/syntax"
+	"inspector/heartbeat"
+	"inspector/proto/prometheus"
+	"inspector/util"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// 转发给owner的请求带有该header，owner直接写入本地缓存，不再转发
+const remoteForwardHeader = "X-Infinsight-Forwarded"
+
+const (
+	pushOwnerRefreshInterval = 5                // owner列表的刷新间隔(秒)
+	remoteForwardTimeout     = 10 * time.Second // 转发给owner的超时时间
+)
+
+// =====================================================================================
+//       Struct:  pushOwners
+//  Description:  存活的api_server地址列表，按照gid排序，每pushOwnerRefreshInterval秒刷新一次
+// =====================================================================================
+type pushOwners struct {
+	lock   sync.Mutex
+	list   []string
+	update int64 // unix秒
+}
+
+var globalPushOwners = new(pushOwners)
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  get
+ *  Description:  刷新失败时继续使用上一次的列表，从来没有获取成功时返回错误
+ * =====================================================================================
+ */
+func (o *pushOwners) get(now time.Time) ([]string, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.list != nil && now.Unix()-o.update < pushOwnerRefreshInterval {
+		return o.list, nil
+	}
+	var serviceList, err = configure.Options.HeartbeatServer.GetServices(heartbeat.ModuleApi, heartbeat.ServiceAlive)
+	if err != nil {
+		if o.list != nil {
+			glog.Warningf("[RemoteWrite] get api_server list error, use the last one: %s", err.Error())
+			return o.list, nil
+		}
+		return nil, err
+	}
+	var list = make([]string, 0, len(serviceList))
+	for _, it := range serviceList {
+		list = append(list, strings.Replace(it.Name, "_", ".", -1))
+	}
+	o.list, o.update = list, now.Unix()
+	return list, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushOwnerOf
+ *  Description:  hid的owner，与选择store相同使用一致性hash，列表为空时由自己写入
+ * =====================================================================================
+ */
+func pushOwnerOf(ownerList []string, hid int32) string {
+	if len(ownerList) == 0 {
+		return configure.Options.HeartbeatServer.Conf.Service
+	}
+	return ownerList[util.HashInstanceByHid(hid, len(ownerList))]
+}
+
+// =====================================================================================
+//       Struct:  writeResult
+//  Description:  一次写入的结果，firstErr为数据本身的错误，backendErr为后端的错误
+// =====================================================================================
+type writeResult struct {
+	accepted   int
+	rejected   int
+	firstErr   error
+	backendErr *apiError
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  add
+ *  Description:  记录一条曲线(或者一批转发)的结果，不是bad_data的apiError视为后端错误
+ * =====================================================================================
+ */
+func (r *writeResult) add(accepted, total int, err error) {
+	r.accepted += accepted
+	if err == nil {
+		return
+	}
+	r.rejected += total - accepted
+	if it, ok := err.(*apiError); ok && it.typ != errorBadData {
+		if r.backendErr == nil {
+			r.backendErr = it
+		}
+	} else if r.firstErr == nil {
+		r.firstErr = err
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  writeSeries
+ *  Description:  自己是owner的曲线写入本地缓存，其余的按照owner分组转发
+ *                forwarded为true时是其他api_server转发来的请求，全部写入本地缓存
+ * =====================================================================================
+ */
+func (h *ApiHandler) writeSeries(buffer *pushBuffer, seriesList []*prometheus.TimeSeries, forwarded bool,
+	now time.Time) *writeResult {
+
+	var result = new(writeResult)
+	var ownerList []string
+	var current string
+	if !forwarded {
+		var err error
+		current = configure.Options.HeartbeatServer.Conf.Service
+		if ownerList, err = globalPushOwners.get(now); err != nil {
+			var total int
+			for _, series := range seriesList {
+				total += len(series.GetSamples())
+			}
+			result.add(0, total, newApiError(errorUnavailable, "get api_server list error: %s", err.Error()))
+			return result
+		}
+	}
+
+	var forwardMap = make(map[string][]*prometheus.TimeSeries)
+	for _, series := range seriesList {
+		var target, err = h.pushTarget(series.GetLabels())
+		if err != nil {
+			result.add(0, len(series.GetSamples()), err)
+			continue
+		}
+		if !forwarded {
+			if owner := pushOwnerOf(ownerList, target.hid); owner != current {
+				forwardMap[owner] = append(forwardMap[owner], series)
+				continue
+			}
+		}
+		var n int
+		n, err = h.pushSeries(buffer, target, series, now)
+		result.add(n, len(series.GetSamples()), err)
+	}
+
+	for owner, list := range forwardMap {
+		var total int
+		for _, series := range list {
+			total += len(series.GetSamples())
+		}
+		if err := h.forwardSeries(owner, list); err != nil {
+			result.add(0, total, err)
+			continue
+		}
+		result.add(total, total, nil)
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  forwardSeries
+ *  Description:  以remote write的格式转发给owner，owner返回4xx时为数据错误，其余为unavailable
+ * =====================================================================================
+ */
+func (h *ApiHandler) forwardSeries(owner string, seriesList []*prometheus.TimeSeries) error {
+	var data, err = proto.Marshal(&prometheus.WriteRequest{Timeseries: seriesList})
+	if err != nil {
+		return newApiError(errorInternal, "marshal write request error: %s", err.Error())
+	}
+	var req *http.Request
+	if req, err = http.NewRequest("POST", "http://"+owner+"/api/v1/write", bytes.NewReader(snappy.Encode(nil, data))); err != nil {
+		return newApiError(errorInternal, "new request to api_server[%s] error: %s", owner, err.Error())
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set(remoteForwardHeader, configure.Options.HeartbeatServer.Conf.Service)
+
+	var client = &http.Client{Timeout: remoteForwardTimeout}
+	var res *http.Response
+	if res, err = client.Do(req); err != nil {
+		return newApiError(errorUnavailable, "forward to api_server[%s] error: %s", owner, err.Error())
+	}
+	var content, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode/100 == 2 {
+		return nil
+	}
+	if res.StatusCode/100 == 4 {
+		return fmt.Errorf("api_server[%s] returns %d: %s", owner, res.StatusCode, content)
+	}
+	return newApiError(errorUnavailable, "api_server[%s] returns %d: %s", owner, res.StatusCode, content)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  popMoved
+ *  Description:  取出所有还没有到达落盘时间、owner已经不是自己的存储单元，owner为hid -> owner
+ * =====================================================================================
+ */
+func (b *pushBuffer) popMoved(ownerList []string, now time.Time) map[string][]*pushBlock {
+	var current = configure.Options.HeartbeatServer.Conf.Service
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var result = make(map[string][]*pushBlock)
+	for key, block := range b.blocks {
+		if now.Unix() >= block.due {
+			continue
+		}
+		if owner := pushOwnerOf(ownerList, block.target.hid); owner != current {
+			result[owner] = append(result[owner], block)
+			delete(b.blocks, key)
+		}
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  restore
+ *  Description:  将移交失败的存储单元放回，期间新写入的点优先
+ * =====================================================================================
+ */
+func (b *pushBuffer) restore(blockList []*pushBlock) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, block := range blockList {
+		var target = block.target
+		var key = fmt.Sprintf("%s|%d|%s|%d", target.service, target.hid, target.host, block.start)
+		if newer, ok := b.blocks[key]; ok {
+			for metric, data := range newer.data {
+				var old, exist = block.data[metric]
+				if !exist {
+					block.data[metric] = data
+					continue
+				}
+				for i, it := range data {
+					if it != util.NullData {
+						old[i] = it
+					}
+				}
+			}
+		}
+		b.blocks[key] = block
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  handoffPushBuffer
+ *  Description:  api_server变化后，将owner已经不是自己的存储单元转发给新的owner，
+ *                新的owner与其收到的数据合并；转发失败时放回，落盘时间之前继续重试
+ * =====================================================================================
+ */
+func (h *ApiHandler) handoffPushBuffer(buffer *pushBuffer, now time.Time) {
+	buffer.lock.Lock()
+	var empty = len(buffer.blocks) == 0
+	buffer.lock.Unlock()
+	if empty {
+		return
+	}
+
+	var ownerList, err = globalPushOwners.get(now)
+	if err != nil {
+		glog.Errorf("[RemoteWrite] get api_server list error: %s", err.Error())
+		return
+	}
+	for owner, blockList := range buffer.popMoved(ownerList, now) {
+		var seriesList []*prometheus.TimeSeries
+		for _, block := range blockList {
+			seriesList = append(seriesList, h.pushBlock2series(block)...)
+		}
+		if err = h.forwardSeries(owner, seriesList); err != nil {
+			glog.Warningf("[RemoteWrite] hand off %d blocks to api_server[%s] error: %s", len(blockList), owner, err.Error())
+			buffer.restore(blockList)
+			continue
+		}
+		glog.Infof("[RemoteWrite] hand off %d blocks to api_server[%s]", len(blockList), owner)
+	}
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushBlock2series
+ *  Description:  将存储单元还原为remote write的曲线，定点数按照precision还原为浮点数
+ * =====================================================================================
+ */
+func (h *ApiHandler) pushBlock2series(block *pushBlock) []*prometheus.TimeSeries {
+	var target = block.target
+	var precision, err = configure.Options.ConfigServer.GetInt(util.MetaCollection, target.service, util.PrecisionName)
+	if err != nil {
+		precision = 0
+	}
+	var multiple = float64(util.PrecisionMultiple(precision))
+
+	var result = make([]*prometheus.TimeSeries, 0, len(block.data))
+	for key, data := range block.data {
+		var series = &prometheus.TimeSeries{Labels: pushTargetLabels(target, key)}
+		for i, it := range data {
+			if it == util.NullData {
+				continue
+			}
+			series.Samples = append(series.Samples, &prometheus.Sample{
+				Value:     float64(it) / multiple,
+				Timestamp: int64(block.start+uint32(i)) * int64(block.step) * 1000,
+			})
+		}
+		if len(series.Samples) > 0 {
+			result = append(result, series)
+		}
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  pushTargetLabels
+ *  Description:  实例与metric对应的label，__name__带有service前缀，
+ *                pushTarget解析后得到相同的实例与metric
+ * =====================================================================================
+ */
+func pushTargetLabels(target *pushTarget, key string) []*prometheus.Label {
+	return []*prometheus.Label{
+		{Name: syntax.LabelName, Value: target.service + "|" + key},
+		{Name: syntax.LabelService, Value: target.service},
+		{Name: syntax.LabelHost, Value: target.host},
+		{Name: syntax.LabelHid, Value: strconv.Itoa(int(target.hid))},
+		{Name: syntax.LabelPid, Value: strconv.Itoa(target.pid)},
+	}
+}
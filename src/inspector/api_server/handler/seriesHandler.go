@@ -18,7 +18,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"inspector/api_server/configure"
 	"inspector/util"
 	"net/http"
 	"net/url"
@@ -129,23 +128,32 @@ func (h *ApiHandler) SeriesHandler(w http.ResponseWriter, r *http.Request) {
 	var tasks map[string]interface{}
 	var err error
 
-	if params, err = url.ParseQuery(r.URL.RawQuery); err != nil {
-		glog.Errorf("ParseQuery[%s] error: %s", r.URL.RawQuery, err.Error())
+	if params, err = h.parseForm(r); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var match string
+	if match, err = h.requireParam(params, "match[]"); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	filter, instName, hid, pid := h.parserVar(match)
+	if len(filter) == 0 {
+		h.writeError(w, newApiError(errorBadData, "invalid parameter \"match[]\": %s", match))
 		return
 	}
-	filter, instName, hid, pid := h.parserVar(params["match[]"][0])
 	_ = instName
 	glog.V(3).Infof("[Debug][SeriesHandler] query parse: filter[%v], instName[%v], hid[%v], pid[%v]", filter, instName, hid, pid)
 	fmt.Println("[Debug][SeriesHandler] query parse: filter[%v], instName[%v], hid[%v], pid[%v]", filter, instName, hid, pid)
 
 	var index int
 	if index = strings.LastIndexByte(filter, '_'); index == -1 {
-		glog.Errorf("filter[%s] is in invalid format, invalid format is 'service_name' ", filter)
+		h.writeError(w, newApiError(errorBadData, "filter[%s] is in invalid format, invalid format is 'service_name'", filter))
 		return
 	}
 	var service = filter[:index]
-	if tasks, err = configure.Options.ConfigServer.GetMap(util.TaskListCollection, service, "distribute"); err != nil {
-		glog.Errorf("get task from [%s.%s] error", util.TaskListCollection, service)
+	if tasks, err = h.serviceDistribute(service); err != nil {
+		h.writeError(w, newApiError(errorExecution, "get instance list of service[%s] error: %s", service, err.Error()))
 		return
 	}
 
@@ -171,6 +179,10 @@ func (h *ApiHandler) SeriesHandler(w http.ResponseWriter, r *http.Request) {
 	h.timeTick("find in taskList")
 
 	var result = h.list2json(filter, l)
+	if len(result) == 0 {
+		h.writeError(w, newApiError(errorBadData, "unknown series type of filter[%s]", filter))
+		return
+	}
 	fmt.Fprintln(w, result)
 	h.timeTick("to json")
 
@@ -0,0 +1,436 @@
+/*
+// =====================================================================================
+//
+//       Filename:  statement.go
+//
+//    Description:  查询语句解析以及实例选择
+//
+//        Version:  1.0
+//        Created:  10/18/2026 03:30:05 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"inspector/api_server/configure"
+	"inspector/api_server/filter"
+	"inspector/api_server/syntax"
+	"inspector/dict_server"
+	"inspector/util"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// =====================================================================================
+//       Struct:  queryStatement
+//  Description:  解析后的查询语句
+//                新语法使用matchers选择实例，旧语法使用instanceSelector选择单个实例
+// =====================================================================================
+type queryStatement struct {
+	service          string
+	metricList       []string
+	offsetList       []int64 // 与metricList一一对应的时间偏移(秒)，旧语法为nil
+	opExpression     string
+	instanceSelector map[string]string      // 旧语法的实例选择器
+	matchers         []*syntax.LabelMatcher // 新语法中hid、pid、host上的匹配条件
+	aggregation      *syntax.Aggregation    // 跨实例的聚合运算，没有时为nil
+	downsample       string                 // 采样过滤器名称，为空时使用默认过滤器
+	legacy           bool
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseQueryStatement
+ *  Description:  优先使用syntax包解析PromQL风格的语句，解析失败时只有符合旧的DIY语法
+ *                形式的语句交给parseLegacyStatement处理，其余直接返回语法错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseQueryStatement(query string) (*queryStatement, error) {
+	var selector, err = syntax.ParseSelector(query)
+	if err != nil {
+		if !h.isLegacyStatement(query) {
+			return nil, err
+		}
+		glog.V(3).Infof("[Debug][parseQueryStatement] fall back to legacy syntax: query[%s], reason[%s]", query, err.Error())
+		var stmt, legacyErr = h.parseLegacyStatement(query)
+		if legacyErr != nil {
+			return nil, fmt.Errorf("%s; as legacy syntax: %s", err.Error(), legacyErr.Error())
+		}
+		return stmt, nil
+	}
+
+	var stmt = &queryStatement{
+		opExpression: util.StringTrim(selector.Expression),
+		aggregation:  selector.Aggregation,
+		downsample:   selector.Downsample,
+	}
+	if _, ok := filter.GetFilter(stmt.downsample); !ok {
+		return nil, h.unknownFilterError(stmt.downsample)
+	}
+
+	// service
+	for _, it := range selector.MatchersOf(syntax.LabelService) {
+		if it.Type != syntax.MatchEqual {
+			return nil, fmt.Errorf("only \"=\" is supported on label %s", syntax.LabelService)
+		}
+		if len(stmt.service) != 0 && stmt.service != it.Value {
+			return nil, fmt.Errorf("query across services[%s, %s] is not supported", stmt.service, it.Value)
+		}
+		stmt.service = it.Value
+	}
+	if len(stmt.service) == 0 {
+		if len(selector.Metrics) == 0 {
+			return nil, fmt.Errorf("service is unknown, use label %s or a metric name like \"service|metric\"", syntax.LabelService)
+		}
+		if stmt.service = h.parseService(selector.Metrics[0]); len(stmt.service) == 0 {
+			return nil, fmt.Errorf("metric[%s] is not prefixed with service", selector.Metrics[0])
+		}
+	}
+
+	// metric list，统一转换为不带service的形式，选择器的offset叠加到每个metric上
+	var prefix = stmt.service + "|"
+	for i, it := range selector.Metrics {
+		stmt.metricList = append(stmt.metricList, strings.TrimPrefix(it, prefix))
+		stmt.offsetList = append(stmt.offsetList, selector.Offsets[i]+selector.Offset)
+	}
+	if nameMatchers := selector.MatchersOf(syntax.LabelName); len(nameMatchers) > 0 {
+		var metricList, offsetList = stmt.metricList, stmt.offsetList
+		if len(metricList) == 0 {
+			var dict, ok = configure.Options.DictServerMap.Load(stmt.service)
+			if !ok {
+				return nil, fmt.Errorf("can't find DictServer[%v]", stmt.service)
+			}
+			if metricList, err = dict.(*dictServer.DictServer).GetKeyList(); err != nil {
+				return nil, fmt.Errorf("can't get dict key list from DictServer[%v]", stmt.service)
+			}
+			offsetList = make([]int64, len(metricList))
+			for i := range offsetList {
+				offsetList[i] = selector.Offset
+			}
+		}
+		stmt.metricList, stmt.offsetList = nil, nil
+		for i, it := range metricList {
+			if h.matchMetric(stmt.service, it, nameMatchers) {
+				stmt.metricList = append(stmt.metricList, it)
+				stmt.offsetList = append(stmt.offsetList, offsetList[i])
+			}
+		}
+	}
+	if len(stmt.metricList) == 0 {
+		return nil, fmt.Errorf("no metric matched in service[%s]", stmt.service)
+	}
+
+	// instance matchers
+	for _, it := range selector.Matchers {
+		switch it.Name {
+		case syntax.LabelHid, syntax.LabelPid, syntax.LabelHost:
+			stmt.matchers = append(stmt.matchers, it)
+		}
+	}
+
+	glog.V(3).Infof("[Debug][parseQueryStatement] parse metric query: "+
+		"service[%v], metricList[%v], offsetList[%v], opExpression[%v], matchers[%v], aggregation[%v], downsample[%v]",
+		stmt.service, stmt.metricList, stmt.offsetList, stmt.opExpression, stmt.matchers, stmt.aggregation, stmt.downsample)
+	return stmt, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseDownsample
+ *  Description:  请求中的downsample参数作为查询语句没有指定过滤器时的默认值
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseDownsample(params url.Values, stmt *queryStatement) error {
+	var name = params.Get("downsample")
+	if len(name) == 0 {
+		return nil
+	}
+	if _, ok := filter.GetFilter(name); !ok {
+		return newApiError(errorBadData, "invalid parameter \"downsample\": %s", h.unknownFilterError(name).Error())
+	}
+	if len(stmt.downsample) == 0 {
+		stmt.downsample = name
+	}
+	return nil
+}
+
+func (h *ApiHandler) unknownFilterError(name string) error {
+	return fmt.Errorf("unknown downsample filter %q, expected one of %s", name, strings.Join(filter.FilterNameList(), ", "))
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  isLegacyStatement
+ *  Description:  判断语句是否符合旧的DIY语法：以"reg("开头，或者为metrics[op expression]
+ *                {instance selector}的形式，metrics中没有函数调用，实例选择器的值不带引号，
+ *                并且只使用"="
+ * =====================================================================================
+ */
+func (h *ApiHandler) isLegacyStatement(query string) bool {
+	query = util.StringTrim(query)
+	if strings.HasPrefix(query, "reg(") {
+		return true
+	}
+	if strings.ContainsAny(query, "\"'`") || !strings.HasSuffix(query, "}") {
+		return false
+	}
+
+	var metrics, _, instances = h.parsePanelQuery(query)
+	if len(metrics) == 0 || len(instances) == 0 || strings.ContainsAny(metrics, "()") {
+		return false
+	}
+	if strings.Contains(instances, "hostId=") || strings.Contains(instances, ";") ||
+		strings.Contains(strings.ToLower(instances), "-p") {
+		return true
+	}
+	return strings.Contains(instances, "=") && !strings.Contains(instances, "=~") &&
+		!strings.Contains(instances, "!=") && !strings.Contains(instances, "!~")
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  parseLegacyStatement
+ *  Description:  兼容旧的DIY语法：metrics[op expression]{instance selector}
+ * =====================================================================================
+ */
+func (h *ApiHandler) parseLegacyStatement(query string) (*queryStatement, error) {
+	var stmt = &queryStatement{legacy: true}
+	var err error
+
+	var metrics, opExpression, instances = h.parsePanelQuery(query)
+	if len(metrics) == 0 {
+		return nil, errors.New("metrics of query is empty")
+	}
+	if strings.HasPrefix(metrics, "reg(") {
+		// 正则表达式
+
+		metrics = metrics[4 : len(metrics)-1] // 去除"reg("和最后的")"
+		stmt.service = h.parseService(metrics)
+		var serviceEndIndex = strings.IndexByte(metrics, '|')
+		metrics = metrics[serviceEndIndex+1:] // 去除首部"service|"字符
+		var dict interface{}
+		var ok bool
+		if dict, ok = configure.Options.DictServerMap.Load(stmt.service); !ok {
+			return nil, fmt.Errorf("can't find DictServer[%v]", stmt.service)
+		}
+		if stmt.metricList, err = dict.(*dictServer.DictServer).GetKeyList(); err != nil {
+			return nil, fmt.Errorf("can't get dict key list from DictServer[%v]", stmt.service)
+		}
+		stmt.metricList = h.parseMetricsReg(metrics, stmt.metricList)
+	} else {
+		// 监控项列表
+		stmt.metricList = h.parseMetrics(metrics)
+		if len(stmt.metricList) == 0 {
+			return nil, fmt.Errorf("no metric found in query[%s]", query)
+		}
+		stmt.service = h.parseService(stmt.metricList[0])
+		// cut service
+		for i, it := range stmt.metricList {
+			stmt.metricList[i] = h.parseRealMetric(it)
+		}
+	}
+
+	stmt.opExpression = opExpression
+	stmt.instanceSelector = h.parseInstanceSelector(instances)
+	stmt.downsample = stmt.instanceSelector["filter"]
+	glog.V(3).Infof("[Debug][parseLegacyStatement] parse metric query: "+
+		"metrics[%v], metricList[%v], instanceSelector[%v]",
+		metrics, stmt.metricList, stmt.instanceSelector)
+
+	return stmt, nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  filterMetrics
+ *  Description:  使用__name__匹配条件过滤metric，匹配对象为"service|metric"
+ * =====================================================================================
+ */
+func (h *ApiHandler) filterMetrics(service string, metricList []string, matchers []*syntax.LabelMatcher) []string {
+	var hitList = make([]string, 0)
+	for _, it := range metricList {
+		if h.matchMetric(service, it, matchers) {
+			hitList = append(hitList, it)
+		}
+	}
+	return hitList
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  matchMetric
+ *  Description:  metric是否满足所有__name__匹配条件
+ * =====================================================================================
+ */
+func (h *ApiHandler) matchMetric(service, metric string, matchers []*syntax.LabelMatcher) bool {
+	var name = service + "|" + metric
+	for _, m := range matchers {
+		if !m.Matches(name) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  seriesNameList
+ *  Description:  返回与metricList对应的曲线名称，带时间偏移的metric加上offset后缀，
+ *                以便区分同一个metric的当前曲线与偏移曲线
+ * =====================================================================================
+ */
+func (h *ApiHandler) seriesNameList(stmt *queryStatement) []string {
+	var nameList = make([]string, len(stmt.metricList))
+	for i, it := range stmt.metricList {
+		nameList[i] = it
+		if i < len(stmt.offsetList) && stmt.offsetList[i] > 0 {
+			nameList[i] = fmt.Sprintf("%s %s %s", it, syntax.KeywordOffset, syntax.FormatDuration(stmt.offsetList[i]))
+		}
+	}
+	return nameList
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  selectInstances
+ *  Description:  返回查询涉及的实例列表，每个实例使用与parseInstanceSelector相同的
+ *                map格式，以便直接传入doQueryRange
+ * =====================================================================================
+ */
+func (h *ApiHandler) selectInstances(stmt *queryStatement) ([]map[string]string, error) {
+	if stmt.legacy {
+		return []map[string]string{stmt.instanceSelector}, nil
+	}
+
+	var distribute, err = h.serviceDistribute(stmt.service)
+	if err != nil {
+		return nil, fmt.Errorf("get instance list of service[%s] error: %s", stmt.service, err.Error())
+	}
+	return h.matchInstances(distribute, stmt.matchers), nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  serviceDistribute
+ *  Description:  合并taskList与pushList中service的distribute字段，两者都不存在时返回taskList的错误
+ * =====================================================================================
+ */
+func (h *ApiHandler) serviceDistribute(service string) (map[string]interface{}, error) {
+	var distribute, err = configure.Options.ConfigServer.GetMap(util.TaskListCollection, service, util.TaskDistributeName)
+	if err != nil && !util.IsNotFound(err) {
+		return nil, err
+	}
+	var pushed, pushErr = configure.Options.ConfigServer.GetMap(util.PushListCollection, service, util.TaskDistributeName)
+	if pushErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return distribute, nil
+	}
+
+	return h.mergeDistribute(distribute, pushed), nil
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  mergeDistribute
+ *  Description:  同名实例以distribute(taskList)为准
+ * =====================================================================================
+ */
+func (h *ApiHandler) mergeDistribute(distribute, pushed map[string]interface{}) map[string]interface{} {
+	var result = make(map[string]interface{}, len(distribute)+len(pushed))
+	for key, value := range pushed {
+		result[key] = value
+	}
+	for key, value := range distribute {
+		result[key] = value
+	}
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  matchInstances
+ *  Description:  distribute为taskList中service的distribute字段，按hid、host排序返回
+ * =====================================================================================
+ */
+func (h *ApiHandler) matchInstances(distribute map[string]interface{}, matchers []*syntax.LabelMatcher) []map[string]string {
+	var result = make([]map[string]string, 0)
+	for key, value := range distribute {
+		if util.FilterName(key) {
+			continue
+		}
+		var instance, ok = value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var hid, pid int
+		var err error
+		if hid, err = util.ConvertInterface2Int(instance[syntax.LabelHid]); err != nil {
+			glog.Warningf("instance[%s] without valid hid: %v", key, instance[syntax.LabelHid])
+			continue
+		}
+		if pid, err = util.ConvertInterface2Int(instance[syntax.LabelPid]); err != nil {
+			pid = 0
+		}
+		var host, _ = instance[syntax.LabelHost].(string)
+		if len(host) == 0 {
+			host = strings.Replace(key, "_", ".", -1)
+		}
+
+		var labels = map[string]string{
+			syntax.LabelHid:  strconv.Itoa(hid),
+			syntax.LabelPid:  strconv.Itoa(pid),
+			syntax.LabelHost: host,
+		}
+		if source, ok := instance[instanceSourceName].(string); ok {
+			labels[instanceSourceName] = source
+		}
+		var hit = true
+		for _, m := range matchers {
+			if !m.Matches(labels[m.Name]) {
+				hit = false
+				break
+			}
+		}
+		if hit {
+			result = append(result, labels)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		var x, _ = strconv.Atoi(result[i][syntax.LabelHid])
+		var y, _ = strconv.Atoi(result[j][syntax.LabelHid])
+		if x != y {
+			return x < y
+		}
+		return result[i][syntax.LabelHost] < result[j][syntax.LabelHost]
+	})
+	return result
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  seriesMetric
+ *  Description:  组装返回给grafana的label集合，新语法额外带上实例信息
+ * =====================================================================================
+ */
+func (h *ApiHandler) seriesMetric(stmt *queryStatement, name string, instance map[string]string) map[string]string {
+	var metric = h.name2metric(name)
+	if !stmt.legacy {
+		metric[syntax.LabelService] = stmt.service
+		metric[syntax.LabelHid] = instance[syntax.LabelHid]
+		metric[syntax.LabelPid] = instance[syntax.LabelPid]
+		metric[syntax.LabelHost] = instance[syntax.LabelHost]
+	}
+	return metric
+}
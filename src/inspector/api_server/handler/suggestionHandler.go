@@ -15,14 +15,8 @@
 package handler
 
 import (
-	"encoding/json"
-	"fmt"
-	"inspector/api_server/configure"
-	"inspector/dict_server"
-	"inspector/util/unsafe"
+	"inspector/api_server/syntax"
 	"net/http"
-
-	"github.com/golang/glog"
 )
 
 /*
@@ -38,27 +32,9 @@ type PrometheusSuggestionModel struct {
 /*
 // =====================================================================================
 //  handler
+//  返回__name__的取值，与/api/v1/label/<name>/values相同，支持match[]、start、end
 // =====================================================================================
 */
 func (h *ApiHandler) SuggestionHandler(w http.ResponseWriter, r *http.Request) {
-	glog.V(1).Infof("[Trace][SuggestionHandler] called: Request[%v] ", r)
-
-	var result = &PrometheusSuggestionModel{}
-	result.Status = "success"
-	result.Data = make([]string, 0)
-
-	configure.Options.DictServerMap.Range(func(k, v interface{}) bool {
-		keyList, err := v.(*dictServer.DictServer).GetKeyList()
-		if err != nil {
-			glog.Warning("get key list of [%s] error: %s", k, err.Error())
-			return false
-		}
-		for _, it := range keyList {
-			result.Data = append(result.Data, it)
-		}
-		return true
-	})
-
-	var resultBytes, _ = json.Marshal(result)
-	fmt.Fprintln(w, unsafe.Bytes2String(resultBytes))
+	h.serveLabelValues(w, r, syntax.LabelName)
 }
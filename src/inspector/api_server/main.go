@@ -54,6 +54,20 @@ func main() {
 
 	flag.IntVar(&configure.Options.CollectorTimeout, "collector_timeout", 3, "timeout of query from collector")
 	flag.IntVar(&configure.Options.StoreTimeout, "store_timeout", 5, "timeout of query from store")
+	flag.IntVar(&configure.Options.InstantLookback, "instant_lookback", 300, "lookback seconds of instant query")
+	flag.IntVar(&configure.Options.QueryTimeout, "query_timeout", 30, "global timeout of a query request, 0 means no limit")
+	flag.IntVar(&configure.Options.QueryConcurrency, "query_concurrency", 32, "max instances queried concurrently in a query request")
+	flag.IntVar(&configure.Options.QueryCacheSize, "query_cache_size", 256, "size(MB) of historical query result cache, 0 means disable")
+	flag.IntVar(&configure.Options.MaxQuerySeries, "query_max_series", 10000, "max series selected by a query, 0 means no limit")
+	flag.IntVar(&configure.Options.MaxQueryPoints, "query_max_points", 50000000, "max points loaded by a query, 0 means no limit")
+	flag.IntVar(&configure.Options.MaxQueryInstances, "query_max_instances", 1000, "max instances selected by a query, 0 means no limit")
+	flag.IntVar(&configure.Options.MaxQueryRange, "query_max_range", 31*24*3600, "max time range(seconds) of a query, 0 means no limit")
+	flag.IntVar(&configure.Options.SlowQueryThreshold, "slow_query_threshold", 5000, "queries slower than this(ms) are logged as slow queries, 0 means disable")
+	flag.IntVar(&configure.Options.RemoteWriteDelay, "remote_write_delay", 30, "seconds to wait for late remote_write samples after a block ends before saving it")
+	flag.IntVar(&configure.Options.RecordLookback, "record_lookback", 3, "points recomputed by each evaluation of recording rules")
+	flag.StringVar(&configure.Options.AlertWebhook, "alert_webhook", "", "comma separated webhook urls receiving alerts, e.g. http://alertmanager:9093/api/v2/alerts")
+	flag.IntVar(&configure.Options.AlertInterval, "alert_interval", 10, "evaluation interval(seconds) of alerting rules, 0 means disable")
+	flag.IntVar(&configure.Options.AlertResendInterval, "alert_resend_interval", 60, "seconds to wait before resending a firing alert")
 
 	var version bool
 	flag.BoolVar(&version, "version", false, "show version")
@@ -79,6 +93,8 @@ func main() {
 	if err = initialize.StartHeartBeat(); err != nil {
 		panic(err)
 	}
+	handler.StartRecordRules()
+	handler.StartAlertRules()
 	// if err = initialize.StartDictServer(); err != nil {
 	// 	panic(err)
 	// }
@@ -91,16 +107,35 @@ func main() {
 	// http.HandleFunc("/api/v1/label/__name__/values", handler.SuggestionHandler)
 	// http.HandleFunc("/api/v1/series", handler.SeriesHandler)
 
-	http.HandleFunc("/", handler.IndexHandler)
-	http.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+	// 每个请求单独捕获panic，返回prometheus格式的错误
+	http.HandleFunc("/", handler.Recover(handler.IndexHandler))
+	http.HandleFunc("/api/v1/query", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
+		new(handler.ApiHandler).QueryHandler(w, r)
+	}))
+	http.HandleFunc("/api/v1/query_range", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
 		new(handler.ApiHandler).QueryRangeHandler(w, r)
-	})
-	http.HandleFunc("/api/v1/label/__name__/values", func(w http.ResponseWriter, r *http.Request) {
+	}))
+	http.HandleFunc("/api/v1/label/__name__/values", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
 		new(handler.ApiHandler).SuggestionHandler(w, r)
-	})
-	http.HandleFunc("/api/v1/series", func(w http.ResponseWriter, r *http.Request) {
+	}))
+	http.HandleFunc("/api/v1/label/", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
+		new(handler.ApiHandler).LabelValuesHandler(w, r)
+	}))
+	http.HandleFunc("/api/v1/labels", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
+		new(handler.ApiHandler).LabelsHandler(w, r)
+	}))
+	http.HandleFunc("/api/v1/series", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
 		new(handler.ApiHandler).SeriesHandler(w, r)
-	})
+	}))
+	http.HandleFunc("/api/v1/read", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
+		new(handler.ApiHandler).RemoteReadHandler(w, r)
+	}))
+	http.HandleFunc("/api/v1/write", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
+		new(handler.ApiHandler).RemoteWriteHandler(w, r)
+	}))
+	http.HandleFunc("/api/v1/status/query_cache", handler.Recover(func(w http.ResponseWriter, r *http.Request) {
+		new(handler.ApiHandler).QueryCacheStatusHandler(w, r)
+	}))
 
 	http.ListenAndServe(fmt.Sprintf(":%d", configure.Options.ServicePort), nil)
 }
@@ -0,0 +1,279 @@
+/*
+// =====================================================================================
+//
+//       Filename:  aggregation.go
+//
+//    Description:  跨实例的聚合运算，例如sum by (pid)、topk(10, ...)
+//
+//        Version:  1.0
+//        Created:  10/18/2026 06:12:40 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"inspector/util"
+	"sort"
+	"strconv"
+)
+
+const (
+	AggregateSum     = "sum"
+	AggregateAvg     = "avg"
+	AggregateMin     = "min"
+	AggregateMax     = "max"
+	AggregateTopk    = "topk"
+	AggregateBottomk = "bottomk"
+)
+
+// 支持的聚合运算，value表示是否需要参数
+var aggregateOps = map[string]bool{
+	AggregateSum:     false,
+	AggregateAvg:     false,
+	AggregateMin:     false,
+	AggregateMax:     false,
+	AggregateTopk:    true,
+	AggregateBottomk: true,
+}
+
+// 允许用于分组的label
+var groupingLabels = map[string]bool{
+	LabelHid:  true,
+	LabelPid:  true,
+	LabelHost: true,
+}
+
+// =====================================================================================
+//       Struct:  Aggregation
+//  Description:  聚合运算描述，语法与prometheus相同：
+//                op [by|without (label, ...)] ([k,] selector) [by|without (label, ...)]
+//                Grouping为空且Without为false时，所有实例聚合为一组
+// =====================================================================================
+type Aggregation struct {
+	Op       string
+	Param    int64 // topk/bottomk的k
+	Grouping []string
+	Without  bool
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  KeepSeries
+//  Description:  topk/bottomk只挑选曲线，保留原有的label，其他运算每组只返回一条曲线
+// =====================================================================================
+*/
+func (a *Aggregation) KeepSeries() bool {
+	return a.Op == AggregateTopk || a.Op == AggregateBottomk
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  isAggregation
+//  Description:  当前位置是否为聚合运算，聚合运算名后面必须紧跟"("或者by/without
+// =====================================================================================
+*/
+func (p *selectorParser) isAggregation() bool {
+	var it = p.peek()
+	if it.Type != ItemIdentifier {
+		return false
+	}
+	if _, ok := aggregateOps[it.Val]; !ok {
+		return false
+	}
+	var next = p.items[p.pos+1]
+	return next.Type == ItemLeftParen ||
+		(next.Type == ItemIdentifier && (next.Val == "by" || next.Val == "without"))
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseAggregation
+//  Description:  解析聚合运算以及其中的选择器
+// =====================================================================================
+*/
+func (p *selectorParser) parseAggregation() (*Selector, error) {
+	var op = p.next()
+	var agg = &Aggregation{Op: op.Val}
+	var err error
+
+	var groupingParsed bool
+	if p.peek().Type == ItemIdentifier {
+		if err = p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+		groupingParsed = true
+	}
+
+	if _, err = p.expect(ItemLeftParen, "aggregation"); err != nil {
+		return nil, err
+	}
+	if aggregateOps[agg.Op] {
+		var param Item
+		if param, err = p.expect(ItemNumber, "aggregation "+agg.Op); err != nil {
+			return nil, err
+		}
+		if agg.Param, err = strconv.ParseInt(param.Val, 10, 64); err != nil || agg.Param <= 0 {
+			return nil, newParseError(param.Pos, "parameter of %s must be a positive integer, got %q", agg.Op, param.Val)
+		}
+		if _, err = p.expect(ItemComma, "aggregation "+agg.Op); err != nil {
+			return nil, err
+		}
+	}
+
+	var s *Selector
+	if s, err = p.parseSelector(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(ItemRightParen, "aggregation"); err != nil {
+		return nil, err
+	}
+
+	if p.peek().Type == ItemIdentifier {
+		if groupingParsed {
+			return nil, newParseError(p.peek().Pos, "grouping of aggregation is specified twice")
+		}
+		if err = p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Aggregation = agg
+	return s, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseGrouping
+//  Description:  解析 by|without (label, ...)
+// =====================================================================================
+*/
+func (p *selectorParser) parseGrouping(agg *Aggregation) error {
+	var keyword = p.next()
+	switch keyword.Val {
+	case "by":
+	case "without":
+		agg.Without = true
+	default:
+		return newParseError(keyword.Pos, "unexpected %s in aggregation, expected \"by\" or \"without\"", keyword)
+	}
+
+	var err error
+	if _, err = p.expect(ItemLeftParen, "grouping"); err != nil {
+		return err
+	}
+	agg.Grouping = make([]string, 0)
+	for {
+		if p.peek().Type == ItemRightParen {
+			p.next()
+			return nil
+		}
+
+		var label Item
+		if label, err = p.expect(ItemIdentifier, "grouping"); err != nil {
+			return err
+		}
+		if !groupingLabels[label.Val] {
+			return newParseError(label.Pos, "unknown grouping label %q, expected one of %s, %s or %s",
+				label.Val, LabelHid, LabelPid, LabelHost)
+		}
+		agg.Grouping = append(agg.Grouping, label.Val)
+
+		switch it := p.next(); it.Type {
+		case ItemComma:
+		case ItemRightParen:
+			return nil
+		default:
+			return newParseError(it.Pos, "unexpected %s in grouping, expected \",\" or \")\"", it)
+		}
+	}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  AggregateArray
+//  Description:  对一组对齐的曲线逐点做聚合运算，空值不参与计算
+//                sum/avg/min/max返回1条曲线，某个点所有曲线都为空时结果为空
+//                topk/bottomk返回与输入相同条数的曲线，每个点只保留前k个值，其余置空
+//                长度不一致的曲线，缺少的部分按空值处理
+// =====================================================================================
+*/
+func AggregateArray(agg *Aggregation, group [][]int64) [][]int64 {
+	var length int
+	for _, it := range group {
+		if len(it) > length {
+			length = len(it)
+		}
+	}
+	var at = func(i, j int) int64 {
+		if j < len(group[i]) {
+			return group[i][j]
+		}
+		return util.NullData
+	}
+
+	if agg.KeepSeries() {
+		var result = make([][]int64, len(group))
+		for i := range result {
+			result[i] = make([]int64, length)
+		}
+		var order = make([]int, 0, len(group))
+		for j := 0; j < length; j++ {
+			order = order[:0]
+			for i := range group {
+				result[i][j] = util.NullData
+				if at(i, j) != util.NullData {
+					order = append(order, i)
+				}
+			}
+			sort.SliceStable(order, func(x, y int) bool {
+				if agg.Op == AggregateTopk {
+					return at(order[x], j) > at(order[y], j)
+				}
+				return at(order[x], j) < at(order[y], j)
+			})
+			for k := 0; k < len(order) && int64(k) < agg.Param; k++ {
+				result[order[k]][j] = at(order[k], j)
+			}
+		}
+		return result
+	}
+
+	var result = make([]int64, length)
+	for j := 0; j < length; j++ {
+		var value int64
+		var count int64
+		for i := range group {
+			var v = at(i, j)
+			if v == util.NullData {
+				continue
+			}
+			switch {
+			case count == 0:
+				value = v
+			case agg.Op == AggregateMin:
+				if v < value {
+					value = v
+				}
+			case agg.Op == AggregateMax:
+				if v > value {
+					value = v
+				}
+			default:
+				value += v
+			}
+			count++
+		}
+		switch {
+		case count == 0:
+			result[j] = util.NullData
+		case agg.Op == AggregateAvg:
+			result[j] = roundDiv(value, count)
+		default:
+			result[j] = value
+		}
+	}
+	return [][]int64{result}
+}
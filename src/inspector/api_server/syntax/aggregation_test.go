@@ -0,0 +1,139 @@
+/*
+// =====================================================================================
+//
+//       Filename:  aggregation_test.go
+//
+//    Description:
+//
+//        Version:  1.0
+//        Created:  10/18/2026 07:05:26 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"fmt"
+	"inspector/util"
+	"runtime"
+	"testing"
+)
+
+func TestParseAggregation(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	// case 1: by在前
+	{
+		var s, err = ParseSelector(`sum by (pid) (mongo|opcounters|insert [rate($1, 1m)] {hid!="1"})`)
+		check(err == nil, "test")
+		check(s.Aggregation != nil, "test")
+		check(s.Aggregation.Op == AggregateSum && !s.Aggregation.Without, "test")
+		check(len(s.Aggregation.Grouping) == 1 && s.Aggregation.Grouping[0] == LabelPid, "test")
+		check(len(s.Metrics) == 1 && s.Metrics[0] == "mongo|opcounters|insert", "test")
+		check(s.Expression == "rate($1, 1m)", "test")
+		check(len(s.Matchers) == 1, "test")
+	}
+
+	// case 2: without在后，带参数
+	{
+		var s, err = ParseSelector(`topk(10, redis|commands {host=~"10\..*"}) without (host, hid)`)
+		check(err == nil, "test")
+		check(s.Aggregation.Op == AggregateTopk && s.Aggregation.Param == 10, "test")
+		check(s.Aggregation.Without && len(s.Aggregation.Grouping) == 2, "test")
+		check(s.Aggregation.KeepSeries(), "test")
+	}
+
+	// case 3: 不分组
+	{
+		var s, err = ParseSelector(`max(redis|cpu)`)
+		check(err == nil, "test")
+		check(s.Aggregation.Op == AggregateMax && len(s.Aggregation.Grouping) == 0, "test")
+
+		// 没有聚合运算
+		s, err = ParseSelector(`redis|sum`)
+		check(err == nil && s.Aggregation == nil, "test")
+	}
+
+	// case 4: 错误
+	{
+		var _, err = ParseSelector(`sum by (name) (redis|cpu)`)
+		check(err != nil && err.(*ParseError).Pos == 8, "test")
+
+		_, err = ParseSelector(`topk(redis|cpu)`)
+		check(err != nil && err.(*ParseError).Pos == 5, "test")
+
+		_, err = ParseSelector(`bottomk(0, redis|cpu)`)
+		check(err != nil && err.(*ParseError).Pos == 8, "test")
+
+		_, err = ParseSelector(`sum by (pid) (redis|cpu) by (hid)`)
+		check(err != nil && err.(*ParseError).Pos == 25, "test")
+
+		_, err = ParseSelector(`sum (redis|cpu`)
+		check(err != nil && err.(*ParseError).Pos == 14, "test")
+	}
+
+	check(true, "test")
+}
+
+func TestAggregateArray(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var group = [][]int64{
+		[]int64{1, 5, null, null},
+		[]int64{3, 2, null, 4},
+		[]int64{2, 8, null},
+	}
+	var result [][]int64
+
+	result = AggregateArray(&Aggregation{Op: AggregateSum}, group)
+	check(len(result) == 1 && arrayEqual(result[0], []int64{6, 15, null, 4}), fmt.Sprint(result))
+
+	result = AggregateArray(&Aggregation{Op: AggregateAvg}, group)
+	check(arrayEqual(result[0], []int64{2, 5, null, 4}), fmt.Sprint(result))
+
+	result = AggregateArray(&Aggregation{Op: AggregateMin}, group)
+	check(arrayEqual(result[0], []int64{1, 2, null, 4}), fmt.Sprint(result))
+
+	result = AggregateArray(&Aggregation{Op: AggregateMax}, group)
+	check(arrayEqual(result[0], []int64{3, 8, null, 4}), fmt.Sprint(result))
+
+	result = AggregateArray(&Aggregation{Op: AggregateTopk, Param: 1}, group)
+	check(len(result) == 3, "test")
+	check(arrayEqual(result[0], []int64{null, null, null, null}), fmt.Sprint(result[0]))
+	check(arrayEqual(result[1], []int64{3, null, null, 4}), fmt.Sprint(result[1]))
+	check(arrayEqual(result[2], []int64{null, 8, null, null}), fmt.Sprint(result[2]))
+
+	result = AggregateArray(&Aggregation{Op: AggregateBottomk, Param: 2}, group)
+	check(arrayEqual(result[0], []int64{1, 5, null, null}), fmt.Sprint(result[0]))
+	check(arrayEqual(result[1], []int64{null, 2, null, 4}), fmt.Sprint(result[1]))
+	check(arrayEqual(result[2], []int64{2, null, null, null}), fmt.Sprint(result[2]))
+
+	check(true, "test")
+}
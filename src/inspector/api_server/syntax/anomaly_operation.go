@@ -0,0 +1,263 @@
+/*
+// =====================================================================================
+//
+//       Filename:  anomaly_operation.go
+//
+//    Description:  异常检测运算，输出异常分数(偏离正常值多少个标准差)，
+//                  配合anomaly()转换为0/1序列，秒级数据上比固定阈值更稳定
+//
+//        Version:  1.0
+//        Created:  10/19/2026 05:16:48 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"inspector/util"
+	"math"
+	"sort"
+)
+
+// MAD换算为正态分布标准差的系数
+const madScale = 1.4826
+
+// MAD为0时使用平均绝对偏差，换算为正态分布标准差的系数
+const meanADScale = 1.2533
+
+// =====================================================================================
+//         Type:  baselineFunc
+//  Description:  与基线数组比较的函数，基线通常为offset之后的同一个metric
+// =====================================================================================
+type baselineFunc func(pinput *[]int64, pbaseline *[]int64, args *rangeArgs, poutput *[]int64) *[]int64
+
+// =====================================================================================
+//         Type:  thresholdFunc
+//  Description:  数组与阈值比较的函数，threshold与数组使用相同的定点数倍数
+// =====================================================================================
+type thresholdFunc func(pinput *[]int64, threshold int64, multiple int64, poutput *[]int64) *[]int64
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  anomalyScore
+//  Description:  diff/scale按照定点数输出，scale为0时无法计算，
+//                diff也为0时认为没有偏离，否则输出空值
+// =====================================================================================
+*/
+func anomalyScore(diff, scale float64, multiple int64) int64 {
+	if scale <= 0 {
+		if diff == 0 {
+			return 0
+		}
+		return util.NullData
+	}
+	return int64(math.Round(diff / scale * float64(multiple)))
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ewmaZscore
+//  Description:  指数加权移动平均的z-score，平滑系数为2/(N+1)，N为窗口内的下标个数
+//                每个点与之前数据的加权均值、加权标准差比较后再更新均值与方差，
+//                空值不参与计算，输出空值；第一个有效数据没有历史，输出空值
+// =====================================================================================
+*/
+func ewmaZscore(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	var alpha = 2 / float64(w.size+1)
+
+	var mean, variance float64
+	var count int
+	for i, it := range w.input {
+		if it == util.NullData {
+			output[i] = util.NullData
+			continue
+		}
+		var x = float64(it)
+		if count == 0 {
+			mean = x
+			output[i] = util.NullData
+		} else {
+			var diff = x - mean
+			output[i] = anomalyScore(diff, math.Sqrt(variance), args.multiple)
+			var incr = alpha * diff
+			mean += incr
+			variance = (1 - alpha) * (variance + diff*incr)
+		}
+		count++
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  madScore
+//  Description:  中位数绝对偏差(MAD)的稳健z-score：(x - median) / (1.4826 * MAD)
+//                窗口与*_over_time相同，MAD为0时使用平均绝对偏差，
+//                维护有序窗口，MAD通过从中位数向两侧归并绝对偏差求得
+// =====================================================================================
+*/
+func madScore(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	var sorted = make([]int64, 0, w.size)
+	for i := range output {
+		if v := w.input[i]; v != util.NullData {
+			var k = sort.Search(len(sorted), func(j int) bool { return sorted[j] >= v })
+			sorted = append(sorted, 0)
+			copy(sorted[k+1:], sorted[k:])
+			sorted[k] = v
+		}
+		if out := i - w.size; out >= 0 && w.input[out] != util.NullData {
+			var v = w.input[out]
+			var k = sort.Search(len(sorted), func(j int) bool { return sorted[j] >= v })
+			sorted = append(sorted[:k], sorted[k+1:]...)
+		}
+
+		if w.input[i] == util.NullData || len(sorted) == 0 {
+			output[i] = util.NullData
+			continue
+		}
+		var median = sortedMedian(sorted)
+		var scale = madScale * medianAbsDeviation(sorted, median)
+		if scale == 0 {
+			var sum float64
+			for _, it := range sorted {
+				sum += math.Abs(float64(it) - median)
+			}
+			scale = meanADScale * sum / float64(len(sorted))
+		}
+		output[i] = anomalyScore(float64(w.input[i])-median, scale, args.multiple)
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  sortedMedian
+//  Description:  有序数组的中位数，sorted不能为空
+// =====================================================================================
+*/
+func sortedMedian(sorted []int64) float64 {
+	var n = len(sorted)
+	if n%2 == 1 {
+		return float64(sorted[n/2])
+	}
+	return (float64(sorted[n/2-1]) + float64(sorted[n/2])) / 2
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  medianAbsDeviation
+//  Description:  有序数组到median的绝对偏差的中位数，
+//                中位数左侧向左、右侧向右的绝对偏差都是递增的，归并到一半即可
+// =====================================================================================
+*/
+func medianAbsDeviation(sorted []int64, median float64) float64 {
+	var n = len(sorted)
+	var r = sort.Search(n, func(j int) bool { return float64(sorted[j]) >= median })
+	var l = r - 1
+	var prev, current float64
+	for k := 0; k <= n/2; k++ {
+		prev = current
+		if l >= 0 && (r >= n || median-float64(sorted[l]) <= float64(sorted[r])-median) {
+			current = median - float64(sorted[l])
+			l--
+		} else {
+			current = float64(sorted[r]) - median
+			r++
+		}
+	}
+	if n%2 == 1 {
+		return current
+	}
+	return (prev + current) / 2
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  seasonalScore
+//  Description:  与基线比较的z-score：(x - 基线窗口均值) / 基线窗口标准差
+//                基线为"offset 1d"等偏移之后的数据，即N天前同一时间窗口的数据，
+//                当前值或者基线窗口为空时输出空值
+// =====================================================================================
+*/
+func seasonalScore(pinput *[]int64, pbaseline *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var input = *pinput
+	var w = newOverTimeWindow(*pbaseline, args)
+	var output []int64
+	if poutput != nil && len(*poutput) >= len(input) {
+		output = (*poutput)[:len(input)]
+	} else {
+		output = make([]int64, len(input))
+	}
+
+	// 与stddevOverTime相同，先减去第一个有效数据再累加，减小浮点误差
+	var shift int64
+	for _, it := range w.input {
+		if it != util.NullData {
+			shift = it
+			break
+		}
+	}
+	var sum = make([]float64, len(w.input)+1)
+	var square = make([]float64, len(w.input)+1)
+	for i, it := range w.input {
+		sum[i+1], square[i+1] = sum[i], square[i]
+		if it != util.NullData {
+			var v = float64(it - shift)
+			sum[i+1] += v
+			square[i+1] += v * v
+		}
+	}
+
+	for i, it := range input {
+		if it == util.NullData || i >= len(w.input) {
+			output[i] = util.NullData
+			continue
+		}
+		var _, count = w.stat(i)
+		if count == 0 {
+			output[i] = util.NullData
+			continue
+		}
+		var b = w.begin(i)
+		var mean = (sum[i+1] - sum[b]) / float64(count)
+		var variance = (square[i+1]-square[b])/float64(count) - mean*mean
+		if variance < 0 {
+			variance = 0
+		}
+		output[i] = anomalyScore(float64(it-shift)-mean, math.Sqrt(variance), args.multiple)
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  anomaly
+//  Description:  将异常分数转换为0/1序列，|x| >= threshold时为1，空值保持空值
+// =====================================================================================
+*/
+func anomaly(pinput *[]int64, threshold int64, multiple int64, poutput *[]int64) *[]int64 {
+	var input = *pinput
+	var output []int64
+	if poutput != nil && len(*poutput) >= len(input) {
+		output = (*poutput)[:len(input)]
+	} else {
+		output = make([]int64, len(input))
+	}
+	for i, it := range input {
+		switch {
+		case it == util.NullData:
+			output[i] = util.NullData
+		case it >= threshold || -it >= threshold:
+			output[i] = multiple
+		default:
+			output[i] = 0
+		}
+	}
+	return &output
+}
@@ -14,7 +14,10 @@
 
 package syntax
 
-import "inspector/util"
+import (
+	"inspector/util"
+	"math"
+)
 
 /*
 // ===  FUNCTION  ======================================================================
@@ -113,7 +116,7 @@ func arrayOP(px, py *[]int64, op byte, poutput *[]int64) *[]int64 {
 		}
 	case '/':
 		for i := 0; i < len(result); i++ {
-			if x[i] == util.NullData || y[i] == util.NullData {
+			if x[i] == util.NullData || y[i] == util.NullData || y[i] == 0 {
 				result[i] = util.NullData
 			} else {
 				result[i] = x[i] / y[i]
@@ -121,7 +124,7 @@ func arrayOP(px, py *[]int64, op byte, poutput *[]int64) *[]int64 {
 		}
 	case '%':
 		for i := 0; i < len(result); i++ {
-			if x[i] == util.NullData || y[i] == util.NullData {
+			if x[i] == util.NullData || y[i] == util.NullData || y[i] == 0 {
 				result[i] = util.NullData
 			} else {
 				result[i] = x[i] % y[i]
@@ -225,7 +228,7 @@ func arrayDigitOP(parray *[]int64, digit int64, op byte, poutput *[]int64) *[]in
 		}
 	case '/':
 		for i := 0; i < len(array); i++ {
-			if array[i] == util.NullData {
+			if array[i] == util.NullData || digit == 0 {
 				result[i] = util.NullData
 			} else {
 				result[i] = array[i] / digit
@@ -233,7 +236,7 @@ func arrayDigitOP(parray *[]int64, digit int64, op byte, poutput *[]int64) *[]in
 		}
 	case '%':
 		for i := 0; i < len(array); i++ {
-			if array[i] == util.NullData {
+			if array[i] == util.NullData || digit == 0 {
 				result[i] = util.NullData
 			} else {
 				result[i] = array[i] % digit
@@ -244,3 +247,92 @@ func arrayDigitOP(parray *[]int64, digit int64, op byte, poutput *[]int64) *[]in
 	}
 	return &result
 }
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  fixedMulDiv
+//  Description:  定点数乘除法，x、y均为放大了multiple倍的定点数
+//                使用浮点数计算中间结果，避免两个定点数相乘时int64溢出
+//                除数为0或结果溢出时返回空值
+// =====================================================================================
+*/
+func fixedMulDiv(x, y int64, op byte, multiple int64) int64 {
+	var result float64
+	switch op {
+	case '*':
+		result = float64(x) * float64(y) / float64(multiple)
+	case '/':
+		if y == 0 {
+			return util.NullData
+		}
+		result = float64(x) * float64(multiple) / float64(y)
+	default:
+		return util.NullData
+	}
+	result = math.Round(result)
+	if math.IsNaN(result) || result >= float64(util.NullData) || result <= float64(util.INT64_MIN) {
+		return util.NullData
+	}
+	return int64(result)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  arrayFixedOP
+//  Description:  数组间定点数乘除法，其余运算与arrayOP相同
+// =====================================================================================
+*/
+func arrayFixedOP(px, py *[]int64, op byte, multiple int64, poutput *[]int64) *[]int64 {
+	if multiple == 1 || (op != '*' && op != '/') {
+		return arrayOP(px, py, op, poutput)
+	}
+	var x = *px
+	var y = *py
+	var result []int64
+	if poutput == nil {
+		if len(x) <= len(y) {
+			result = make([]int64, len(x))
+		} else {
+			result = make([]int64, len(y))
+		}
+	} else {
+		result = *poutput
+	}
+
+	for i := 0; i < len(result); i++ {
+		if x[i] == util.NullData || y[i] == util.NullData {
+			result[i] = util.NullData
+		} else {
+			result[i] = fixedMulDiv(x[i], y[i], op, multiple)
+		}
+	}
+	return &result
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  arrayDigitFixedOP
+//  Description:  数组与数值的定点数乘除法，其余运算与arrayDigitOP相同
+// =====================================================================================
+*/
+func arrayDigitFixedOP(parray *[]int64, digit int64, op byte, multiple int64, poutput *[]int64) *[]int64 {
+	if multiple == 1 || (op != '*' && op != '/') {
+		return arrayDigitOP(parray, digit, op, poutput)
+	}
+	var array = *parray
+	var result []int64
+	if poutput == nil {
+		result = make([]int64, len(array))
+	} else {
+		result = *poutput
+	}
+
+	for i := 0; i < len(array); i++ {
+		if array[i] == util.NullData {
+			result[i] = util.NullData
+		} else {
+			result[i] = fixedMulDiv(array[i], digit, op, multiple)
+		}
+	}
+	return &result
+}
@@ -0,0 +1,301 @@
+/*
+// =====================================================================================
+//
+//       Filename:  lexer.go
+//
+//    Description:  查询语句词法分析
+//
+//        Version:  1.0
+//        Created:  10/18/2026 02:16:40 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// =====================================================================================
+//         Type:  ItemType
+//  Description:  词法单元类型
+// =====================================================================================
+type ItemType int
+
+const (
+	ItemError ItemType = iota
+	ItemEOF
+
+	ItemIdentifier // metric名称或label名称
+	ItemString     // 引号包围的字符串
+	ItemNumber
+	ItemVariable   // $n
+	ItemExpression // [...]中的原始计算表达式
+
+	ItemLeftBrace
+	ItemRightBrace
+	ItemLeftParen
+	ItemRightParen
+	ItemComma
+
+	ItemEQL      // =
+	ItemNEQ      // !=
+	ItemEQLRegex // =~
+	ItemNEQRegex // !~
+
+	ItemADD
+	ItemSUB
+	ItemMUL
+	ItemDIV
+	ItemMOD
+)
+
+var itemTypeStr = map[ItemType]string{
+	ItemError:      "error",
+	ItemEOF:        "end of input",
+	ItemIdentifier: "identifier",
+	ItemString:     "string",
+	ItemNumber:     "number",
+	ItemVariable:   "variable",
+	ItemExpression: "expression",
+	ItemLeftBrace:  "\"{\"",
+	ItemRightBrace: "\"}\"",
+	ItemLeftParen:  "\"(\"",
+	ItemRightParen: "\")\"",
+	ItemComma:      "\",\"",
+	ItemEQL:        "\"=\"",
+	ItemNEQ:        "\"!=\"",
+	ItemEQLRegex:   "\"=~\"",
+	ItemNEQRegex:   "\"!~\"",
+	ItemADD:        "\"+\"",
+	ItemSUB:        "\"-\"",
+	ItemMUL:        "\"*\"",
+	ItemDIV:        "\"/\"",
+	ItemMOD:        "\"%\"",
+}
+
+func (t ItemType) String() string {
+	if s, ok := itemTypeStr[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("item(%d)", int(t))
+}
+
+// =====================================================================================
+//       Struct:  Item
+//  Description:  词法单元，Pos为在原始输入中的字节偏移
+// =====================================================================================
+type Item struct {
+	Type ItemType
+	Pos  int
+	Val  string
+}
+
+func (i Item) String() string {
+	switch i.Type {
+	case ItemEOF:
+		return i.Type.String()
+	case ItemError:
+		return i.Val
+	}
+	return fmt.Sprintf("%q", i.Val)
+}
+
+// =====================================================================================
+//       Struct:  ParseError
+//  Description:  带位置信息的语法错误
+// =====================================================================================
+type ParseError struct {
+	Pos int
+	Err string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Err)
+}
+
+func newParseError(pos int, format string, args ...interface{}) *ParseError {
+	return &ParseError{Pos: pos, Err: fmt.Sprintf(format, args...)}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  isIdentifierChar
+//  Description:  metric名称中允许"|"、"."、":"等分隔符
+// =====================================================================================
+*/
+func isIdentifierChar(r rune, first bool) bool {
+	if r == '_' || unicode.IsLetter(r) {
+		return true
+	}
+	if first {
+		return false
+	}
+	return unicode.IsDigit(r) || r == '|' || r == '.' || r == ':'
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Lex
+//  Description:  将输入切分为词法单元列表，最后一个元素总是ItemEOF
+// =====================================================================================
+*/
+func Lex(input string) ([]Item, error) {
+	var items = make([]Item, 0, 16)
+	var pos = 0
+	for pos < len(input) {
+		var r, width = utf8.DecodeRuneInString(input[pos:])
+		if unicode.IsSpace(r) {
+			pos += width
+			continue
+		}
+
+		var start = pos
+		switch {
+		case r == '{':
+			items = append(items, Item{ItemLeftBrace, start, "{"})
+			pos++
+		case r == '}':
+			items = append(items, Item{ItemRightBrace, start, "}"})
+			pos++
+		case r == '(':
+			items = append(items, Item{ItemLeftParen, start, "("})
+			pos++
+		case r == ')':
+			items = append(items, Item{ItemRightParen, start, ")"})
+			pos++
+		case r == ',':
+			items = append(items, Item{ItemComma, start, ","})
+			pos++
+		case r == '+':
+			items = append(items, Item{ItemADD, start, "+"})
+			pos++
+		case r == '-':
+			items = append(items, Item{ItemSUB, start, "-"})
+			pos++
+		case r == '*':
+			items = append(items, Item{ItemMUL, start, "*"})
+			pos++
+		case r == '/':
+			items = append(items, Item{ItemDIV, start, "/"})
+			pos++
+		case r == '%':
+			items = append(items, Item{ItemMOD, start, "%"})
+			pos++
+		case r == '=':
+			if strings.HasPrefix(input[pos:], "=~") {
+				items = append(items, Item{ItemEQLRegex, start, "=~"})
+				pos += 2
+			} else {
+				items = append(items, Item{ItemEQL, start, "="})
+				pos++
+			}
+		case r == '!':
+			if strings.HasPrefix(input[pos:], "!=") {
+				items = append(items, Item{ItemNEQ, start, "!="})
+			} else if strings.HasPrefix(input[pos:], "!~") {
+				items = append(items, Item{ItemNEQRegex, start, "!~"})
+			} else {
+				return nil, newParseError(start, "unexpected character after \"!\"")
+			}
+			pos += 2
+		case r == '"' || r == '\'' || r == '`':
+			var value, end, err = lexString(input, pos)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, Item{ItemString, start, value})
+			pos = end
+		case r == '[':
+			// 计算表达式整体作为一个词法单元，内部由表达式解析器处理
+			var depth = 0
+			var end = -1
+			for i := pos; i < len(input); i++ {
+				if input[i] == '[' {
+					depth++
+				} else if input[i] == ']' {
+					depth--
+					if depth == 0 {
+						end = i
+						break
+					}
+				}
+			}
+			if end == -1 {
+				return nil, newParseError(start, "unclosed \"[\"")
+			}
+			items = append(items, Item{ItemExpression, start + 1, input[pos+1 : end]})
+			pos = end + 1
+		case r == '$':
+			pos++
+			for pos < len(input) && input[pos] >= '0' && input[pos] <= '9' {
+				pos++
+			}
+			if pos == start+1 {
+				return nil, newParseError(start, "expect digit after \"$\"")
+			}
+			items = append(items, Item{ItemVariable, start, input[start:pos]})
+		case unicode.IsDigit(r):
+			for pos < len(input) && (isDigit(input[pos]) || input[pos] == '.') {
+				pos++
+			}
+			items = append(items, Item{ItemNumber, start, input[start:pos]})
+		case isIdentifierChar(r, true):
+			pos += width
+			for pos < len(input) {
+				r, width = utf8.DecodeRuneInString(input[pos:])
+				if !isIdentifierChar(r, false) {
+					break
+				}
+				pos += width
+			}
+			items = append(items, Item{ItemIdentifier, start, input[start:pos]})
+		default:
+			return nil, newParseError(start, "unexpected character %q", r)
+		}
+	}
+	items = append(items, Item{ItemEOF, len(input), ""})
+	return items, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  lexString
+//  Description:  解析引号字符串，支持反斜杠转义，反引号字符串不转义
+// =====================================================================================
+*/
+func lexString(input string, pos int) (string, int, error) {
+	var quote = input[pos]
+	var buffer = make([]byte, 0, 16)
+	for i := pos + 1; i < len(input); i++ {
+		var c = input[i]
+		if c == quote {
+			return string(buffer), i + 1, nil
+		}
+		if c == '\\' && quote != '`' {
+			if i+1 >= len(input) {
+				break
+			}
+			i++
+			switch input[i] {
+			case 'n':
+				buffer = append(buffer, '\n')
+			case 't':
+				buffer = append(buffer, '\t')
+			case '\\', '"', '\'':
+				buffer = append(buffer, input[i])
+			default:
+				// 保留转义符，便于在正则表达式中直接使用"\."之类的写法
+				buffer = append(buffer, '\\', input[i])
+			}
+			continue
+		}
+		buffer = append(buffer, c)
+	}
+	return "", 0, newParseError(pos, "unterminated quoted string")
+}
@@ -0,0 +1,282 @@
+/*
+// =====================================================================================
+//
+//       Filename:  over_time_operation.go
+//
+//    Description:  *_over_time窗口聚合运算，用于gauge类型数据在时间维度上的统计
+//
+//        Version:  1.0
+//        Created:  10/18/2026 08:03:26 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"inspector/util"
+	"math"
+	"sort"
+)
+
+// =====================================================================================
+//       Struct:  overTimeWindow
+//  Description:  *_over_time的公共中间结果，与prometheus相同，窗口为(t-window, t]，
+//                即以i结尾的size个下标，空值不参与计算，窗口内没有有效数据时结果为空值
+//                sum/count为有效数据的前缀和，第k项表示下标k之前(不含)的累计
+// =====================================================================================
+type overTimeWindow struct {
+	input []int64
+	sum   []int64
+	count []int64
+	size  int
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  newOverTimeWindow
+//  Description:  会拷贝一份input，因此output可以与input共用同一块内存
+// =====================================================================================
+*/
+func newOverTimeWindow(input []int64, args *rangeArgs) *overTimeWindow {
+	var step = args.step
+	if step <= 0 {
+		step = 1
+	}
+	var size = int(args.window / step)
+	if size < 1 {
+		size = 1
+	}
+
+	var n = len(input)
+	var w = &overTimeWindow{
+		input: make([]int64, n),
+		sum:   make([]int64, n+1),
+		count: make([]int64, n+1),
+		size:  size,
+	}
+	copy(w.input, input)
+	for i, it := range w.input {
+		w.sum[i+1] = w.sum[i]
+		w.count[i+1] = w.count[i]
+		if it != util.NullData {
+			w.sum[i+1] += it
+			w.count[i+1]++
+		}
+	}
+	return w
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  begin
+//  Description:  以i结尾的窗口的第一个下标
+// =====================================================================================
+*/
+func (w *overTimeWindow) begin(i int) int {
+	if i-w.size+1 < 0 {
+		return 0
+	}
+	return i - w.size + 1
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  stat
+//  Description:  返回以i结尾的窗口内有效数据的和与个数
+// =====================================================================================
+*/
+func (w *overTimeWindow) stat(i int) (int64, int64) {
+	var b = w.begin(i)
+	return w.sum[i+1] - w.sum[b], w.count[i+1] - w.count[b]
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  output
+//  Description:
+// =====================================================================================
+*/
+func (w *overTimeWindow) output(poutput *[]int64) []int64 {
+	if poutput == nil || len(*poutput) < len(w.input) {
+		return make([]int64, len(w.input))
+	}
+	return (*poutput)[:len(w.input)]
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  sumOverTime
+//  Description:
+// =====================================================================================
+*/
+func sumOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	for i := range output {
+		if sum, count := w.stat(i); count > 0 {
+			output[i] = sum
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  countOverTime
+//  Description:  个数按照定点数返回，与其他数据的精度保持一致
+// =====================================================================================
+*/
+func countOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	for i := range output {
+		if _, count := w.stat(i); count > 0 {
+			output[i] = count * args.multiple
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  avgOverTime
+//  Description:
+// =====================================================================================
+*/
+func avgOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	for i := range output {
+		if sum, count := w.stat(i); count > 0 {
+			output[i] = roundDiv(sum, count)
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  extremeOverTime
+//  Description:  使用单调队列计算窗口内的最小值/最大值，less决定队列的单调方向
+// =====================================================================================
+*/
+func extremeOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64, less func(x, y int64) bool) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	var queue = make([]int, 0, w.size)
+	for i := range output {
+		if v := w.input[i]; v != util.NullData {
+			for len(queue) > 0 && !less(w.input[queue[len(queue)-1]], v) {
+				queue = queue[:len(queue)-1]
+			}
+			queue = append(queue, i)
+		}
+		for len(queue) > 0 && queue[0] < w.begin(i) {
+			queue = queue[1:]
+		}
+		if len(queue) > 0 {
+			output[i] = w.input[queue[0]]
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+func minOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	return extremeOverTime(pinput, args, poutput, func(x, y int64) bool { return x < y })
+}
+
+func maxOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	return extremeOverTime(pinput, args, poutput, func(x, y int64) bool { return x > y })
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  stddevOverTime
+//  Description:  总体标准差，所有数据先减去第一个有效数据再累加，减小浮点误差
+// =====================================================================================
+*/
+func stddevOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+
+	var shift int64
+	for _, it := range w.input {
+		if it != util.NullData {
+			shift = it
+			break
+		}
+	}
+	var sum = make([]float64, len(w.input)+1)
+	var square = make([]float64, len(w.input)+1)
+	for i, it := range w.input {
+		sum[i+1], square[i+1] = sum[i], square[i]
+		if it != util.NullData {
+			var v = float64(it - shift)
+			sum[i+1] += v
+			square[i+1] += v * v
+		}
+	}
+
+	for i := range output {
+		var _, count = w.stat(i)
+		if count == 0 {
+			output[i] = util.NullData
+			continue
+		}
+		var b = w.begin(i)
+		var mean = (sum[i+1] - sum[b]) / float64(count)
+		var variance = (square[i+1]-square[b])/float64(count) - mean*mean
+		if variance < 0 {
+			variance = 0
+		}
+		output[i] = int64(math.Round(math.Sqrt(variance)))
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  quantileOverTime
+//  Description:  窗口内的φ分位数，与prometheus相同，在相邻两个数据之间线性插值
+//                维护一个有序的窗口，每次移动时删除移出的数据并插入新数据
+// =====================================================================================
+*/
+func quantileOverTime(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newOverTimeWindow(*pinput, args)
+	var output = w.output(poutput)
+	var sorted = make([]int64, 0, w.size)
+	for i := range output {
+		if v := w.input[i]; v != util.NullData {
+			var k = sort.Search(len(sorted), func(j int) bool { return sorted[j] >= v })
+			sorted = append(sorted, 0)
+			copy(sorted[k+1:], sorted[k:])
+			sorted[k] = v
+		}
+		if out := i - w.size; out >= 0 && w.input[out] != util.NullData {
+			var v = w.input[out]
+			var k = sort.Search(len(sorted), func(j int) bool { return sorted[j] >= v })
+			sorted = append(sorted[:k], sorted[k+1:]...)
+		}
+
+		if len(sorted) == 0 {
+			output[i] = util.NullData
+			continue
+		}
+		var rank = args.quantile * float64(len(sorted)-1)
+		var lower = int(math.Floor(rank))
+		var upper = int(math.Ceil(rank))
+		var weight = rank - float64(lower)
+		output[i] = int64(math.Round(float64(sorted[lower])*(1-weight) + float64(sorted[upper])*weight))
+	}
+	return &output
+}
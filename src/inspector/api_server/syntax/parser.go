@@ -15,11 +15,7 @@
 package syntax
 
 import (
-	"bytes"
-	"errors"
-	"fmt"
 	"inspector/util"
-	"inspector/util/unsafe"
 	"strconv"
 )
 
@@ -48,12 +44,71 @@ func isDigit(x byte) bool {
 	return true
 }
 
+// 时间单位对应的秒数
+var durationUnitMap = map[byte]int64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+}
+
+// 二元运算函数对应的运算符，计算时统一使用定点数运算
+var funcOPMap = map[string]byte{
+	"arrayAdd":      '+',
+	"arraySub":      '-',
+	"arrayMul":      '*',
+	"arrayDiv":      '/',
+	"arrayMod":      '%',
+	"arrayDigitAdd": '+',
+	"arrayDigitSub": '-',
+	"arrayDigitMul": '*',
+	"arrayDigitDiv": '/',
+	"arrayDigitMod": '%',
+}
+
+// 函数列表，按照函数签名区分调用方式
+var funcMap = map[string]interface{}{
+	"sum":           sum,
+	"arrayDiff":     arrayDiff,
+	"arrayAdd":      arrayAdd,
+	"arraySub":      arraySub,
+	"arrayMul":      arrayMul,
+	"arrayDiv":      arrayDiv,
+	"arrayMod":      arrayMod,
+	"arrayDigitAdd": arrayDigitAdd,
+	"arrayDigitSub": arrayDigitSub,
+	"arrayDigitMul": arrayDigitMul,
+	"arrayDigitDiv": arrayDigitDiv,
+	"arrayDigitMod": arrayDigitMod,
+	"rate":          rangeFunc(rate),
+	"irate":         rangeFunc(irate),
+	"increase":      rangeFunc(increase),
+	"delta":         rangeFunc(delta),
+
+	"avg_over_time":      rangeFunc(avgOverTime),
+	"min_over_time":      rangeFunc(minOverTime),
+	"max_over_time":      rangeFunc(maxOverTime),
+	"sum_over_time":      rangeFunc(sumOverTime),
+	"count_over_time":    rangeFunc(countOverTime),
+	"quantile_over_time": rangeFunc(quantileOverTime),
+	"stddev_over_time":   rangeFunc(stddevOverTime),
+
+	"ewma_zscore":    rangeFunc(ewmaZscore),
+	"mad_score":      rangeFunc(madScore),
+	"seasonal_score": baselineFunc(seasonalScore),
+	"anomaly":        thresholdFunc(anomaly),
+}
+
 /*
 // ===  FUNCTION  ======================================================================
 //         Name:  ArrayCalculation
 //  Description:  数组计算：将params矩阵每一行作为一个数组，进行数组计算
 //                          $n表示使用第n行进行计算，$0表示使用每一行都进行相同的计算
-//                          目前由于数组与数值混合计算，暂不提供运算符操作，所有的计算都必须使用函数
+//                支持 + - * / % 运算符以及括号，优先级与常见语言相同，例如：
+//                          ($1 - $2) / $3 * 100
+//                运算对象可以是数组也可以是数值(数字常量、sum的结果)，数组间逐点计算，
+//                任意一方为空值或除数为0时结果为空值
+//                ArrayCalculation按照整数计算，需要保留小数时使用ArrayCalculationWithOption
 //                函数列表如下：
 //                一元运算：
 //                          sum($n)
@@ -69,192 +124,507 @@ func isDigit(x byte) bool {
 //                          arrayDigitMul($n, num)
 //                          arrayDigitDiv($n, num)
 //                          arrayDigitMod($n, num)
+//                窗口运算(window为窗口长度，可带s/m/h/d单位，默认为秒)：
+//                          rate($n, window)
+//                          irate($n, window)
+//                          increase($n, window)
+//                          delta($n, window)
+//                          avg_over_time($n, window)
+//                          min_over_time($n, window)
+//                          max_over_time($n, window)
+//                          sum_over_time($n, window)
+//                          count_over_time($n, window)
+//                          stddev_over_time($n, window)
+//                          quantile_over_time(φ, $n, window)
+//                异常检测(输出偏离的标准差个数，anomaly将其转换为0/1)：
+//                          ewma_zscore($n, window)
+//                          mad_score($n, window)
+//                          seasonal_score($n, $m, window)，$m为offset之后的基线
+//                          anomaly(score, num)
 // =====================================================================================
 */
 func ArrayCalculation(format string, params ...[]int64) ([][]int64, error) {
-	var result = make([][]int64, len(params))
-	for i, _ := range result {
-		result[i] = make([]int64, len(params[0]))
+	return ArrayCalculationWithOption(format, CalculationOption{Step: 1, Multiple: 1}, params...)
+}
+
+// =====================================================================================
+//       Struct:  CalculationOption
+//  Description:  数组计算的参数
+// =====================================================================================
+type CalculationOption struct {
+	Step     int   // 数组中相邻两个元素间隔的真实秒数，窗口运算通过step将窗口长度换算为下标个数
+	Multiple int64 // params中的数据为放大了Multiple倍的定点数，数字常量与计算结果使用相同的倍数
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ArrayCalculationWithOption
+//  Description:  与ArrayCalculation相同，按照option中的step与定点数倍数进行计算
+// =====================================================================================
+*/
+func ArrayCalculationWithOption(format string, option CalculationOption, params ...[]int64) ([][]int64, error) {
+	var items, err = Lex(format)
+	if err != nil {
+		return nil, err
+	}
+	if option.Step <= 0 {
+		option.Step = 1
+	}
+	if option.Multiple <= 0 {
+		option.Multiple = 1
+	}
+	var c = &calculator{
+		items:    items,
+		params:   params,
+		step:     int64(option.Step),
+		multiple: option.Multiple,
+	}
+
+	var result *operand
+	if result, err = c.parseExpression(0); err != nil {
+		return nil, err
 	}
-	var ok bool
-	var funcStack = util.NewStack()
-	var dataStack = util.NewStack()
-
-	var str = unsafe.String2Bytes(format)
-	var i int = 0
-	var j int = 0
-	for i < len(str) {
-		// empty byte
-		if str[i] == ' ' || str[i] == '\t' || str[i] == '\n' {
-			i++
-			continue
+	if it := c.peek(); it.Type != ItemEOF {
+		return nil, newParseError(it.Pos, "unexpected %s, expected end of expression", it)
+	}
+	return result.data, nil
+}
+
+// =====================================================================================
+//       Struct:  operand
+//  Description:  计算过程中的运算对象，data的每一行对应params中的一行
+//                scalar为true时每一行只有一个元素，表示数值
+//                temporary为true时data为计算过程中新分配的内存，可以原地写入结果
+// =====================================================================================
+type operand struct {
+	data      [][]int64
+	scalar    bool
+	temporary bool
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  row
+//  Description:  只有一行的运算对象与多行运算时，每一行都使用这一行数据
+// =====================================================================================
+*/
+func (o *operand) row(k int) *[]int64 {
+	if len(o.data) == 1 {
+		return &o.data[0]
+	}
+	return &o.data[k]
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  output
+//  Description:  返回第k行结果的写入位置，临时运算对象直接原地写入，避免重复分配内存
+// =====================================================================================
+*/
+func (o *operand) output(rows int, k int, length int) *[]int64 {
+	var output []int64
+	if o.temporary && len(o.data) == rows && len(o.data[k]) >= length {
+		output = o.data[k][:length]
+	} else {
+		output = make([]int64, length)
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  rows
+//  Description:  两个运算对象计算后的行数，一方只有一行时按照另一方的行数计算
+// =====================================================================================
+*/
+func rows(x, y *operand) int {
+	if len(x.data) == 1 {
+		return len(y.data)
+	}
+	if len(y.data) == 1 || len(x.data) < len(y.data) {
+		return len(x.data)
+	}
+	return len(y.data)
+}
+
+// =====================================================================================
+//       Struct:  calculator
+//  Description:  使用优先级爬升(precedence climbing)方式解析并计算表达式
+// =====================================================================================
+type calculator struct {
+	items    []Item
+	pos      int
+	params   [][]int64
+	step     int64
+	multiple int64
+}
+
+func (c *calculator) peek() Item {
+	return c.items[c.pos]
+}
+
+func (c *calculator) next() Item {
+	var it = c.items[c.pos]
+	if it.Type != ItemEOF {
+		c.pos++
+	}
+	return it
+}
+
+func (c *calculator) expect(t ItemType, context string) (Item, error) {
+	var it = c.next()
+	if it.Type != t {
+		return it, newParseError(it.Pos, "unexpected %s in %s, expected %s", it, context, t)
+	}
+	return it, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  binaryPriority
+//  Description:  返回二元运算符的优先级，不是二元运算符时返回-1
+// =====================================================================================
+*/
+func binaryPriority(it Item) int {
+	switch it.Type {
+	case ItemADD, ItemSUB, ItemMUL, ItemDIV, ItemMOD:
+		return priorityMap[it.Val[0]]
+	}
+	return -1
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseExpression
+//  Description:  解析并计算优先级不低于minPriority的表达式，二元运算均为左结合
+// =====================================================================================
+*/
+func (c *calculator) parseExpression(minPriority int) (*operand, error) {
+	var lhs, err = c.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op = c.peek()
+		var priority = binaryPriority(op)
+		if priority < 0 || priority < minPriority {
+			return lhs, nil
 		}
-		// params list split
-		if str[i] == ',' || str[i] == '(' {
-			i++
-			continue
+		c.next()
+
+		var rhs *operand
+		if rhs, err = c.parseExpression(priority + 1); err != nil {
+			return nil, err
 		}
+		lhs = c.binaryOP(op.Val[0], lhs, rhs)
+	}
+}
 
-		// do func
-		if str[i] == ')' {
-			var data1 [][]int64
-			var data2 [][]int64
-			var numList [][]int64
-			var ok bool
-
-			var lastFunc = funcStack.Pop()
-			switch opFunc := lastFunc.(type) {
-			case func([]int64) int64:
-				if data1, ok = dataStack.Pop().([][]int64); !ok {
-					return nil, errors.New(fmt.Sprintf("invalid params at %d: expact []int64", j))
-				}
-				var k int
-				for k = 0; k < len(data1); k++ {
-					result[k][0] = opFunc(data1[k])
-					result[k] = result[k]
-				}
-				result = result[:k]
-				dataStack.Push(result)
-			case func(*[]int64, *[]int64) *[]int64:
-				if data1, ok = dataStack.Pop().([][]int64); !ok {
-					return nil, errors.New(fmt.Sprintf("invalid params at %d: expact []int64", j))
-				}
-				for k, it := range data1 {
-					result[k] = *opFunc(&it, &result[k])
-				}
-				dataStack.Push(result)
-			case func(*[]int64, *[]int64, *[]int64) *[]int64:
-				if data2, ok = dataStack.Pop().([][]int64); !ok {
-					return nil, errors.New(fmt.Sprintf("invalid params at %d: expact []int64 for second param", j))
-				}
-				if data1, ok = dataStack.Pop().([][]int64); !ok {
-					return nil, errors.New(fmt.Sprintf("invalid params at %d: expact []int64 for first param", j))
-				}
-				var k int
-				for k = 0; k < len(data1) && k < len(data2); k++ {
-					result[k] = *opFunc(&data1[k], &data2[k], &result[k])
-				}
-				result = result[:k]
-				dataStack.Push(result)
-			case func(*[]int64, int64, *[]int64) *[]int64:
-				if numList, ok = dataStack.Pop().([][]int64); !ok {
-					return nil, errors.New(fmt.Sprintf("invalid params at %d: expact int64 for second param", j))
-				}
-				if data1, ok = dataStack.Pop().([][]int64); !ok {
-					return nil, errors.New(fmt.Sprintf("invalid params at %d: expact []int64 for first param", j))
-				}
-				var k int
-				for k = 0; k < len(data1) && k < len(numList); k++ {
-					result[k] = *opFunc(&data1[k], numList[k][0], &result[k])
-				}
-				dataStack.Push(result)
-			default:
-				return nil, errors.New(fmt.Sprintf("invalid func type at %d", j))
-			}
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseUnary
+//  Description:  一元正负号
+// =====================================================================================
+*/
+func (c *calculator) parseUnary() (*operand, error) {
+	switch c.peek().Type {
+	case ItemADD:
+		c.next()
+		return c.parseUnary()
+	case ItemSUB:
+		c.next()
+		var x, err = c.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return c.binaryOP('*', x, scalarOperand(-c.multiple)), nil
+	}
+	return c.parsePrimary()
+}
 
-			i++
-			continue
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parsePrimary
+//  Description:  数字、变量、括号表达式以及函数调用
+// =====================================================================================
+*/
+func (c *calculator) parsePrimary() (*operand, error) {
+	var it = c.next()
+	switch it.Type {
+	case ItemNumber:
+		var f, err = strconv.ParseFloat(it.Val, 64)
+		if err != nil {
+			return nil, newParseError(it.Pos, "invalid number %q", it.Val)
+		}
+		// 数字后面可以紧跟时间单位，统一换算为秒
+		if unit := c.peek(); unit.Type == ItemIdentifier && unit.Pos == it.Pos+len(it.Val) &&
+			len(unit.Val) == 1 && durationUnitMap[unit.Val[0]] != 0 {
+			c.next()
+			f *= float64(durationUnitMap[unit.Val[0]])
+		}
+		var n int64
+		if n, err = util.Float2Fixed(f, c.multiple); err != nil {
+			return nil, newParseError(it.Pos, "invalid number %q: %s", it.Val, err.Error())
 		}
+		return scalarOperand(n), nil
+	case ItemVariable:
+		var n, err = strconv.Atoi(it.Val[1:])
+		if err != nil {
+			return nil, newParseError(it.Pos, "invalid variable %q", it.Val)
+		}
+		if n == 0 && len(c.params) > 0 { // $0
+			return &operand{data: c.params}, nil
+		}
+		if n == 0 || len(c.params) < n { // $n
+			return nil, newParseError(it.Pos, "params[%d] not exist", n)
+		}
+		return &operand{data: c.params[n-1 : n]}, nil
+	case ItemLeftParen:
+		var x, err = c.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = c.expect(ItemRightParen, "parenthesized expression"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case ItemIdentifier:
+		return c.parseCall(it)
+	}
+	return nil, newParseError(it.Pos, "unexpected %s, expected number, variable, function or \"(\"", it)
+}
 
-		// variable
-		if str[i] == '$' {
-			i++
-			j = i
-			for j < len(str) {
-				if isDigit(str[j]) {
-					j++
-				} else {
-					break
-				}
-			}
-			if j == i {
-				return nil, errors.New(fmt.Sprintf("invalid syntax at %d: expact digit after \"$\"", j))
-			}
-			if n, err := strconv.Atoi(unsafe.Bytes2String(str[i:j])); err != nil {
-				return nil, errors.New(fmt.Sprintf("invalid syntax at %d: %s", j, err.Error()))
-			} else {
-
-				if n == 0 && len(params) > 0 { // $0
-					dataStack.Push(params)
-				} else { // $n
-					if len(params) < n {
-						return nil, errors.New(fmt.Sprintf("invalid param list at %d: params[%d] not exist", j, n))
-					}
-					dataStack.Push(params[n-1 : n])
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseCall
+//  Description:  解析参数列表并调用函数
+// =====================================================================================
+*/
+func (c *calculator) parseCall(name Item) (*operand, error) {
+	var function, ok = funcMap[name.Val]
+	if !ok {
+		return nil, newParseError(name.Pos, "unexpect func[%s]", name.Val)
+	}
+	var err error
+	if _, err = c.expect(ItemLeftParen, "function call "+name.Val); err != nil {
+		return nil, err
+	}
+	var args []*operand
+	for {
+		var arg *operand
+		if arg, err = c.parseExpression(0); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		var it = c.next()
+		if it.Type == ItemRightParen {
+			break
+		}
+		if it.Type != ItemComma {
+			return nil, newParseError(it.Pos, "unexpected %s in function call %s, expected \",\" or \")\"", it, name.Val)
+		}
+	}
+
+	var checkArgs = func(scalar ...bool) error {
+		if len(args) != len(scalar) {
+			return newParseError(name.Pos, "func[%s] expects %d params, got %d", name.Val, len(scalar), len(args))
+		}
+		for i, it := range args {
+			if it.scalar != scalar[i] {
+				if scalar[i] {
+					return newParseError(name.Pos, "func[%s] expects number for param %d", name.Val, i+1)
 				}
+				return newParseError(name.Pos, "func[%s] expects array for param %d", name.Val, i+1)
 			}
-			i = j
-			continue
 		}
+		return nil
+	}
 
-		// digit
-		if str[i] >= '0' && str[i] <= '9' {
-			j = i
-			j++
-			for j < len(str) {
-				if isDigit(str[j]) {
-					j++
-				} else {
-					break
-				}
+	switch opFunc := function.(type) {
+	case func([]int64) int64:
+		if err = checkArgs(false); err != nil {
+			return nil, err
+		}
+		var result = &operand{data: make([][]int64, len(args[0].data)), scalar: true, temporary: true}
+		for k, it := range args[0].data {
+			result.data[k] = []int64{opFunc(it)}
+		}
+		return result, nil
+	case func(*[]int64, *[]int64) *[]int64:
+		if err = checkArgs(false); err != nil {
+			return nil, err
+		}
+		var x = args[0]
+		var result = &operand{data: make([][]int64, len(x.data)), temporary: true}
+		for k := range result.data {
+			result.data[k] = *opFunc(x.row(k), x.output(len(result.data), k, len(*x.row(k))))
+		}
+		return result, nil
+	case func(*[]int64, *[]int64, *[]int64) *[]int64:
+		if err = checkArgs(false, false); err != nil {
+			return nil, err
+		}
+		return seriesOP(c.arrayOP(funcOPMap[name.Val]), args[0], args[1]), nil
+	case func(*[]int64, int64, *[]int64) *[]int64:
+		if err = checkArgs(false, true); err != nil {
+			return nil, err
+		}
+		return digitOP(c.arrayDigitOP(funcOPMap[name.Val]), args[0], args[1]), nil
+	case rangeFunc:
+		// quantile_over_time的第一个参数为分位数，其余窗口函数只有数组和窗口两个参数
+		var quantile *operand
+		if name.Val == "quantile_over_time" {
+			if err = checkArgs(true, false, true); err != nil {
+				return nil, err
 			}
-			if n, err := strconv.Atoi(unsafe.Bytes2String(str[i:j])); err != nil {
-				return nil, errors.New(fmt.Sprintf("invalid syntax at %d: %s", j, err.Error()))
-			} else {
-				var numList = make([][]int64, len(params))
-				for i, _ := range result {
-					numList[i] = append(numList[i], int64(n))
+			quantile, args = args[0], args[1:]
+		} else if err = checkArgs(false, true); err != nil {
+			return nil, err
+		}
+		var x, window = args[0], args[1]
+		var result = &operand{data: make([][]int64, rows(x, window)), temporary: true}
+		for k := range result.data {
+			var rangeArg = &rangeArgs{
+				window:   (*window.row(k))[0] / c.multiple,
+				step:     c.step,
+				multiple: c.multiple,
+			}
+			if quantile != nil {
+				rangeArg.quantile = float64((*quantile.row(k))[0]) / float64(c.multiple)
+				if rangeArg.quantile < 0 || rangeArg.quantile > 1 {
+					return nil, newParseError(name.Pos, "func[%s] expects quantile between 0 and 1, got %v", name.Val, rangeArg.quantile)
 				}
-				dataStack.Push(numList)
 			}
-			i = j
-			continue
+			result.data[k] = *opFunc(x.row(k), rangeArg, x.output(len(result.data), k, len(*x.row(k))))
 		}
-
-		// alphabet (func)
-		if (str[i] >= 'a' && str[i] <= 'z') || (str[i] >= 'A' && str[i] <= 'Z') {
-			if j = i + bytes.IndexByte(str[i:], '('); j == -1 {
-				return nil, errors.New(fmt.Sprintf("invalid syntax at %d: expect \"(\" after func[%s]", i, unsafe.Bytes2String(str[i:])))
-			}
-			var funcName = unsafe.Bytes2String(str[i:j])
-			switch funcName {
-			case "sum":
-				funcStack.Push(sum)
-			case "arrayDiff":
-				funcStack.Push(arrayDiff)
-			case "arrayAdd":
-				funcStack.Push(arrayAdd)
-			case "arraySub":
-				funcStack.Push(arraySub)
-			case "arrayMul":
-				funcStack.Push(arrayMul)
-			case "arrayDiv":
-				funcStack.Push(arrayDiv)
-			case "arrayMod":
-				funcStack.Push(arrayMod)
-			case "arrayDigitAdd":
-				funcStack.Push(arrayDigitAdd)
-			case "arrayDigitSub":
-				funcStack.Push(arrayDigitSub)
-			case "arrayDigitMul":
-				funcStack.Push(arrayDigitMul)
-			case "arrayDigitDiv":
-				funcStack.Push(arrayDigitDiv)
-			case "arrayDigitMod":
-				funcStack.Push(arrayDigitMod)
-			default:
-				return nil, errors.New(fmt.Sprintf("invalid syntax at %d: unexpect func[%s]", i, unsafe.Bytes2String(str[i:j])))
+		return result, nil
+	case baselineFunc:
+		if err = checkArgs(false, false, true); err != nil {
+			return nil, err
+		}
+		var x, baseline, window = args[0], args[1], args[2]
+		var n = rows(x, baseline)
+		var result = &operand{data: make([][]int64, n), temporary: true}
+		for k := range result.data {
+			var rangeArg = &rangeArgs{
+				window:   (*window.row(k))[0] / c.multiple,
+				step:     c.step,
+				multiple: c.multiple,
 			}
+			result.data[k] = *opFunc(x.row(k), baseline.row(k), rangeArg, x.output(n, k, len(*x.row(k))))
+		}
+		return result, nil
+	case thresholdFunc:
+		if err = checkArgs(false, true); err != nil {
+			return nil, err
+		}
+		return digitOP(func(parray *[]int64, digit int64, poutput *[]int64) *[]int64 {
+			return opFunc(parray, digit, c.multiple, poutput)
+		}, args[0], args[1]), nil
+	}
+	return nil, newParseError(name.Pos, "invalid func type of func[%s]", name.Val)
+}
 
-			i = j
-			continue
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  scalarOperand
+//  Description:
+// =====================================================================================
+*/
+func scalarOperand(n int64) *operand {
+	return &operand{data: [][]int64{[]int64{n}}, scalar: true, temporary: true}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  arrayOP
+//  Description:  返回运算符对应的数组间运算，乘除法按照定点数计算
+// =====================================================================================
+*/
+func (c *calculator) arrayOP(op byte) func(*[]int64, *[]int64, *[]int64) *[]int64 {
+	return func(px, py *[]int64, poutput *[]int64) *[]int64 {
+		return arrayFixedOP(px, py, op, c.multiple, poutput)
+	}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  arrayDigitOP
+//  Description:  返回运算符对应的数组与数值运算，乘除法按照定点数计算
+// =====================================================================================
+*/
+func (c *calculator) arrayDigitOP(op byte) func(*[]int64, int64, *[]int64) *[]int64 {
+	return func(parray *[]int64, digit int64, poutput *[]int64) *[]int64 {
+		return arrayDigitFixedOP(parray, digit, op, c.multiple, poutput)
+	}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  binaryOP
+//  Description:  根据两侧运算对象的类型选择数组间运算或者数组与数值运算
+// =====================================================================================
+*/
+func (c *calculator) binaryOP(op byte, x, y *operand) *operand {
+	switch {
+	case !x.scalar && y.scalar:
+		return digitOP(c.arrayDigitOP(op), x, y)
+	case x.scalar && !y.scalar && (op == '+' || op == '*'):
+		// 满足交换律，直接交换两侧
+		return digitOP(c.arrayDigitOP(op), y, x)
+	case x.scalar && !y.scalar:
+		// 将数值扩展为与数组等长的常量数组
+		var constant = &operand{data: make([][]int64, rows(x, y)), scalar: false}
+		for k := range constant.data {
+			var array = make([]int64, len(*y.row(k)))
+			for i := range array {
+				array[i] = (*x.row(k))[0]
+			}
+			constant.data[k] = array
 		}
+		return seriesOP(c.arrayOP(op), constant, y)
+	}
+	// 数值之间的运算按照长度为1的数组计算，结果仍为数值
+	var result = seriesOP(c.arrayOP(op), x, y)
+	result.scalar = x.scalar && y.scalar
+	return result
+}
 
-		return nil, errors.New(fmt.Sprintf("invalid syntax at %d", i))
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  seriesOP
+//  Description:  数组间运算，长度不一致时按照较短的数组计算
+// =====================================================================================
+*/
+func seriesOP(opFunc func(*[]int64, *[]int64, *[]int64) *[]int64, x, y *operand) *operand {
+	var n = rows(x, y)
+	var result = &operand{data: make([][]int64, n), temporary: true}
+	for k := 0; k < n; k++ {
+		var length = len(*x.row(k))
+		if len(*y.row(k)) < length {
+			length = len(*y.row(k))
+		}
+		var output = x.output(n, k, length)
+		if !x.temporary {
+			output = y.output(n, k, length)
+		}
+		result.data[k] = *opFunc(x.row(k), y.row(k), output)
 	}
+	return result
+}
 
-	if result, ok = dataStack.Pop().([][]int64); !ok {
-		return nil, errors.New(fmt.Sprintf("invalid final result: expect type is [][]int64"))
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  digitOP
+//  Description:  数组与数值运算
+// =====================================================================================
+*/
+func digitOP(opFunc func(*[]int64, int64, *[]int64) *[]int64, x, y *operand) *operand {
+	var n = rows(x, y)
+	var result = &operand{data: make([][]int64, n), temporary: true}
+	for k := 0; k < n; k++ {
+		result.data[k] = *opFunc(x.row(k), (*y.row(k))[0], x.output(n, k, len(*x.row(k))))
 	}
-	return result, nil
+	return result
 }
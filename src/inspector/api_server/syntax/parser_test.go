@@ -190,5 +190,376 @@ func TestParse(t *testing.T) {
 	check(arrayEqual(result[2], []int64{0, 0, 0, 0, 0}), "test")
 	check(arrayEqual(result[3], []int64{0, 0, 0, 0, 0}), "test")
 
+	// case 9: 多个中间结果互不覆盖
+	result, err = ArrayCalculation("arrayAdd(arrayMul($1, $2), arrayMul($3, $4))", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{4, 8, 14, 22, 32}), fmt.Sprint(result))
+
+	check(true, "test")
+}
+
+func TestParseInfix(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var result [][]int64
+	var err error
+	var params = [][]int64{
+		[]int64{1, 2, 3, 4, 5},
+		[]int64{2, 3, 4, 5, 6},
+	}
+
+	// case 1: 优先级与结合性
+	result, err = ArrayCalculation("$1 + $2 * 2", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{5, 8, 11, 14, 17}), fmt.Sprint(result))
+
+	result, err = ArrayCalculation("($1 + $2) * 2", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{6, 10, 14, 18, 22}), fmt.Sprint(result))
+
+	result, err = ArrayCalculation("$2 - $1 - 1", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{0, 0, 0, 0, 0}), fmt.Sprint(result))
+
+	// case 2: 数值在左侧、一元负号
+	result, err = ArrayCalculation("10 - $1", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{9, 8, 7, 6, 5}), fmt.Sprint(result))
+
+	result, err = ArrayCalculation("60 / -$1 % 7", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{-4, -2, -6, -1, -5}), fmt.Sprint(result))
+
+	result, err = ArrayCalculation("2 * (3 + 4)", params...)
+	check(err == nil, "test")
+	check(len(result) == 1 && arrayEqual(result[0], []int64{14}), fmt.Sprint(result))
+
+	// case 3: 空值以及除数为0
+	result, err = ArrayCalculation("($1 - $2) / $3 * 100",
+		[]int64{10, 20, null, 40}, []int64{5, 10, 5, 0}, []int64{5, 2, 1, 0})
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{100, 500, null, null}), fmt.Sprint(result))
+
+	// case 4: 与函数混合，$0对每一行计算
+	result, err = ArrayCalculation("$0 * 100 / sum($0)", params...)
+	check(err == nil, "test")
+	check(len(result) == 2, "test")
+	check(arrayEqual(result[0], []int64{6, 13, 20, 26, 33}), fmt.Sprint(result))
+	check(arrayEqual(result[1], []int64{10, 15, 20, 25, 30}), fmt.Sprint(result))
+
+	result, err = ArrayCalculation("arrayDiff($1 * 2) + increase($2, 1)", params...)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 3, 3, 3, 3}), fmt.Sprint(result))
+
+	// case 5: 语法错误
+	_, err = ArrayCalculation("($1 + 2", params...)
+	check(err != nil && err.(*ParseError).Pos == 7, "test")
+
+	_, err = ArrayCalculation("$1 +", params...)
+	check(err != nil && err.(*ParseError).Pos == 4, "test")
+
+	_, err = ArrayCalculation("unknown($1)", params...)
+	check(err != nil && err.(*ParseError).Pos == 0, "test")
+
+	_, err = ArrayCalculation("sum(2)", params...)
+	check(err != nil, "test")
+
+	_, err = ArrayCalculation("$3 * 2", params...)
+	check(err != nil && err.(*ParseError).Pos == 0, "test")
+
+	_, err = ArrayCalculation("$1 $2", params...)
+	check(err != nil && err.(*ParseError).Pos == 3, "test")
+
+	check(true, "test")
+}
+
+func TestRange(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	// 下标3处计数器重置，下标5为断点
+	var counter = []int64{0, 100, 200, 50, 150, null, 350}
+	var result [][]int64
+	var err error
+
+	// case 1: increase
+	result, err = ArrayCalculationWithOption("increase($1, 2)", CalculationOption{Step: 1, Multiple: 1}, counter)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 100, 200, 150, 150, null, 200}), fmt.Sprint(result[0]))
+
+	// case 2: rate，窗口带单位，step为10秒
+	result, err = ArrayCalculationWithOption("rate($1, 20s)", CalculationOption{Step: 10, Multiple: 1}, counter)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 10, 10, 8, 8, null, 10}), fmt.Sprint(result[0]))
+
+	result, err = ArrayCalculationWithOption("rate($1, 1m)", CalculationOption{Step: 30, Multiple: 1}, counter)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 3, 3, 3, 3, null, 3}), fmt.Sprint(result[0]))
+
+	// case 3: irate
+	result, err = ArrayCalculationWithOption("irate($1, 20)", CalculationOption{Step: 10, Multiple: 1}, counter)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 10, 10, 5, 10, null, 10}), fmt.Sprint(result[0]))
+
+	// case 4: delta不做重置修正
+	result, err = ArrayCalculationWithOption("delta($1, 2)", CalculationOption{Step: 1, Multiple: 1}, counter)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 100, 200, -50, -50, null, 200}), fmt.Sprint(result[0]))
+
+	// case 5: 与其他函数组合
+	result, err = ArrayCalculation("arrayDigitMul(increase($1, 1), 2)", counter)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 200, 200, 100, 200, null, null}), fmt.Sprint(result[0]))
+
+	// case 6: lookback
+	check(RangeLookback("arrayAdd(rate($1, 5m), irate($2, 30))") == 300, "test")
+	check(RangeLookback("delta($1, 1h)") == 3600, "test")
+	check(RangeLookback("arrayDiff($1)") == 0, "test")
+	check(RangeLookback("") == 0, "test")
+	// 嵌套的窗口累加，同一层取最大值
+	check(RangeLookback("max_over_time(rate($1, 5m), 1h)") == 3900, "test")
+	check(RangeLookback("arrayAdd(avg_over_time(rate($1, 1m), 10m), rate($2, 5m))") == 660, "test")
+	check(RangeLookback("arrayDiv(sum_over_time(arrayAdd(rate($1, 30), $2), 60), 2)") == 90, "test")
+
+	check(true, "test")
+}
+
+func TestOverTime(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var gauge = []int64{4, null, 2, 8, null, null, null, 6}
+	var result [][]int64
+	var err error
+
+	// case 1: sum/count/avg/min/max，空值不参与计算，窗口内全为空值时结果为空值
+	var expect = map[string][]int64{
+		"sum_over_time($1, 2)":    {4, 4, 2, 10, 8, null, null, 6},
+		"count_over_time($1, 2)":  {1, 1, 1, 2, 1, null, null, 1},
+		"avg_over_time($1, 2)":    {4, 4, 2, 5, 8, null, null, 6},
+		"min_over_time($1, 2)":    {4, 4, 2, 2, 8, null, null, 6},
+		"max_over_time($1, 2)":    {4, 4, 2, 8, 8, null, null, 6},
+		"stddev_over_time($1, 3)": {0, 0, 1, 3, 3, 0, null, 0},
+	}
+	for format, it := range expect {
+		result, err = ArrayCalculation(format, gauge)
+		check(err == nil, format)
+		check(arrayEqual(result[0], it), fmt.Sprint(format, result[0]))
+	}
+
+	// case 2: step为10秒时，窗口按照采集间隔换算
+	result, err = ArrayCalculationWithOption("max_over_time($1, 20s)", CalculationOption{Step: 10, Multiple: 1}, gauge)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{4, 4, 2, 8, 8, null, null, 6}), fmt.Sprint(result[0]))
+
+	// case 3: 定点数
+	var option = CalculationOption{Step: 1, Multiple: 1000}
+	var fixed = []int64{1000, 2000, 3000, 4000, 5000}
+	result, err = ArrayCalculationWithOption("quantile_over_time(0.9, $1, 4)", option, fixed)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{1000, 1900, 2800, 3700, 4700}), fmt.Sprint(result[0]))
+
+	result, err = ArrayCalculationWithOption("quantile_over_time(0.5, $1, 3)", option, fixed)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{1000, 1500, 2000, 3000, 4000}), fmt.Sprint(result[0]))
+
+	result, err = ArrayCalculationWithOption("count_over_time($1, 2) * 2", option, fixed)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{2000, 4000, 4000, 4000, 4000}), fmt.Sprint(result[0]))
+
+	// case 4: 参数错误
+	_, err = ArrayCalculationWithOption("quantile_over_time(2, $1, 3)", option, fixed)
+	check(err != nil, "test")
+	_, err = ArrayCalculationWithOption("quantile_over_time($1, 3)", option, fixed)
+	check(err != nil, "test")
+	_, err = ArrayCalculationWithOption("avg_over_time(0.5, $1, 3)", option, fixed)
+	check(err != nil, "test")
+
+	// case 5: lookback
+	check(RangeLookback("avg_over_time($1, 5m)") == 300, "test")
+	check(RangeLookback("quantile_over_time(0.9, $1, 1h)") == 3600, "test")
+
+	check(true, "test")
+}
+
+func TestAnomaly(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var option = CalculationOption{Step: 1, Multiple: 1000}
+	var result [][]int64
+	var err error
+
+	// case 1: ewma z-score，平滑系数为0.5，标准差为0时只有没有偏离才输出0
+	var gauge = []int64{10000, 10000, 12000, null, 10000, 30000}
+	result, err = ArrayCalculationWithOption("ewma_zscore($1, 3)", option, gauge)
+	check(err == nil, fmt.Sprint(err))
+	check(arrayEqual(result[0], []int64{null, 0, null, null, -1000, 22517}), fmt.Sprint(result[0]))
+
+	// case 2: MAD，MAD为0时使用平均绝对偏差
+	result, err = ArrayCalculationWithOption("mad_score($1, 5)", option, []int64{1000, 2000, 3000, 4000, 100000})
+	check(err == nil, fmt.Sprint(err))
+	check(result[0][0] == 0 && result[0][1] == 674 && result[0][4] == 65426, fmt.Sprint(result[0]))
+	result, err = ArrayCalculationWithOption("mad_score($1, 5)", option, []int64{1000, 1000, 1000, null, 5000})
+	check(err == nil, fmt.Sprint(err))
+	check(result[0][3] == null && result[0][4] == 3192, fmt.Sprint(result[0]))
+
+	// case 3: 与基线比较，基线窗口为空时输出空值
+	var baseline = []int64{10000, 12000, 14000, null, 10000}
+	var current = []int64{11000, 20000, 14000, 15000, null}
+	result, err = ArrayCalculationWithOption("seasonal_score($1, $2, 3)", option, current, baseline)
+	check(err == nil, fmt.Sprint(err))
+	check(arrayEqual(result[0], []int64{null, 9000, 1225, 2000, null}), fmt.Sprint(result[0]))
+
+	// case 4: 转换为0/1序列
+	result, err = ArrayCalculationWithOption("anomaly(seasonal_score($1, $2, 3), 2)", option, current, baseline)
+	check(err == nil, fmt.Sprint(err))
+	check(arrayEqual(result[0], []int64{null, 1000, 0, 1000, null}), fmt.Sprint(result[0]))
+
+	// case 5: 参数错误与lookback
+	_, err = ArrayCalculationWithOption("seasonal_score($1, 3)", option, current, baseline)
+	check(err != nil, "test")
+	_, err = ArrayCalculationWithOption("anomaly($1, $2)", option, current, baseline)
+	check(err != nil, "test")
+	check(RangeLookback("anomaly(ewma_zscore($1, 10m), 3)") == 600, "test")
+	check(RangeLookback("seasonal_score($1, $2, 1h)") == 3600, "test")
+}
+
+func TestFixedPoint(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	arrayEqual := func(x, y []int64) bool {
+		if len(x) != len(y) {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var null = util.NullData
+	var option = CalculationOption{Step: 1, Multiple: 1000}
+	var result [][]int64
+	var err error
+
+	// 数据为放大1000倍的定点数：0.9, 0.99, 空值
+	var hits = []int64{900, 990, null}
+	var total = []int64{1000, 3000, 0}
+
+	// case 1: 乘除法保留小数
+	result, err = ArrayCalculationWithOption("$1 / $2 * 100", option, hits, total)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{90000, 33000, null}), fmt.Sprint(result))
+
+	result, err = ArrayCalculationWithOption("arrayDiv($1, $2)", option, hits, total)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{900, 330, null}), fmt.Sprint(result))
+
+	// case 2: 数字常量同样按定点数处理
+	result, err = ArrayCalculationWithOption("$1 * 0.5 + 1", option, hits, total)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{1450, 1495, null}), fmt.Sprint(result))
+
+	result, err = ArrayCalculationWithOption("arrayDigitMul(-$1, 2)", option, hits, total)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{-1800, -1980, null}), fmt.Sprint(result))
+
+	result, err = ArrayCalculationWithOption("1 / 3", option, hits)
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{333}), fmt.Sprint(result))
+
+	// case 3: 窗口长度不受定点数倍数影响
+	result, err = ArrayCalculationWithOption("rate($1, 2s)", CalculationOption{Step: 1, Multiple: 1000},
+		[]int64{0, 1500, 2000, 2500})
+	check(err == nil, "test")
+	check(arrayEqual(result[0], []int64{null, 1500, 1000, 500}), fmt.Sprint(result))
+
+	// case 4: 大数相乘不溢出
+	result, err = ArrayCalculationWithOption("$1 * $1", option, []int64{3000000000})
+	check(err == nil, "test")
+	check(result[0][0] == 9000000000000000, fmt.Sprint(result))
+
 	check(true, "test")
 }
@@ -0,0 +1,325 @@
+/*
+// =====================================================================================
+//
+//       Filename:  range_operation.go
+//
+//    Description:  滑动窗口数组运算，用于计数器类型数据的速率计算
+//
+//        Version:  1.0
+//        Created:  10/18/2026 04:52:19 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"inspector/util"
+	"math"
+	"strconv"
+)
+
+// =====================================================================================
+//       Struct:  rangeArgs
+//  Description:  窗口函数的参数
+//                doQueryRange中的数据为虚拟时间，一个下标对应一个采集间隔
+// =====================================================================================
+type rangeArgs struct {
+	window   int64   // 窗口长度(秒)
+	step     int64   // 每个下标代表的秒数
+	multiple int64   // 定点数倍数
+	quantile float64 // quantile_over_time的分位数
+}
+
+// =====================================================================================
+//         Type:  rangeFunc
+//  Description:  窗口函数
+// =====================================================================================
+type rangeFunc func(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64
+
+// =====================================================================================
+//       Struct:  rangeWindow
+//  Description:  窗口计算的公共中间结果
+//                prev/next分别记录每个下标(含)之前/之后最近的有效数据下标，-1表示不存在
+//                prefix为经过计数器重置修正后的增量前缀和
+// =====================================================================================
+type rangeWindow struct {
+	input  []int64
+	prev   []int
+	next   []int
+	prefix []int64
+	size   int // 窗口包含的下标间隔数
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  newRangeWindow
+//  Description:  会拷贝一份input，因此output可以与input共用同一块内存
+// =====================================================================================
+*/
+func newRangeWindow(input []int64, window, step int64) *rangeWindow {
+	if step <= 0 {
+		step = 1
+	}
+	var size = int(window / step)
+	if size < 1 {
+		size = 1
+	}
+
+	var n = len(input)
+	var w = &rangeWindow{
+		input:  make([]int64, n),
+		prev:   make([]int, n),
+		next:   make([]int, n),
+		prefix: make([]int64, n),
+		size:   size,
+	}
+	copy(w.input, input)
+
+	var last = -1
+	var acc int64 = 0
+	for i := 0; i < n; i++ {
+		if w.input[i] != util.NullData {
+			if last != -1 {
+				// 计数器重置(例如实例重启)时，认为计数器从0开始重新累加
+				if w.input[i] >= w.input[last] {
+					acc += w.input[i] - w.input[last]
+				} else {
+					acc += w.input[i]
+				}
+			}
+			last = i
+		}
+		w.prev[i] = last
+		w.prefix[i] = acc
+	}
+	last = -1
+	for i := n - 1; i >= 0; i-- {
+		if w.input[i] != util.NullData {
+			last = i
+		}
+		w.next[i] = last
+	}
+	return w
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  bounds
+//  Description:  返回以i结尾的窗口中第一个和最后一个有效数据的下标
+//                i本身为空值时返回false，从而保留原始数据中的断点
+// =====================================================================================
+*/
+func (w *rangeWindow) bounds(i int) (int, int, bool) {
+	if w.input[i] == util.NullData {
+		return -1, -1, false
+	}
+	var begin = i - w.size
+	if begin < 0 {
+		begin = 0
+	}
+	var first = w.next[begin]
+	if first == -1 || first >= i {
+		return -1, -1, false
+	}
+	return first, i, true
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  output
+//  Description:
+// =====================================================================================
+*/
+func (w *rangeWindow) output(poutput *[]int64) []int64 {
+	if poutput == nil || len(*poutput) < len(w.input) {
+		return make([]int64, len(w.input))
+	}
+	return (*poutput)[:len(w.input)]
+}
+
+func roundDiv(x int64, y int64) int64 {
+	return int64(math.Round(float64(x) / float64(y)))
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  increase
+//  Description:  计数器在窗口内的增量，计数器重置时自动修正
+// =====================================================================================
+*/
+func increase(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newRangeWindow(*pinput, args.window, args.step)
+	var output = w.output(poutput)
+	for i := range output {
+		if first, last, ok := w.bounds(i); ok {
+			output[i] = w.prefix[last] - w.prefix[first]
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  rate
+//  Description:  计数器在窗口内的每秒平均增长速率，计数器重置时自动修正
+//                时间跨度使用窗口内第一个与最后一个有效数据之间的真实秒数
+// =====================================================================================
+*/
+func rate(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var step = args.step
+	if step <= 0 {
+		step = 1
+	}
+	var w = newRangeWindow(*pinput, args.window, step)
+	var output = w.output(poutput)
+	for i := range output {
+		if first, last, ok := w.bounds(i); ok {
+			output[i] = roundDiv(w.prefix[last]-w.prefix[first], int64(last-first)*step)
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  irate
+//  Description:  使用窗口内最后两个有效数据计算的瞬时速率，计数器重置时自动修正
+// =====================================================================================
+*/
+func irate(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var step = args.step
+	if step <= 0 {
+		step = 1
+	}
+	var w = newRangeWindow(*pinput, args.window, step)
+	var output = w.output(poutput)
+	for i := range output {
+		if _, last, ok := w.bounds(i); ok {
+			var before = w.prev[last-1]
+			output[i] = roundDiv(w.prefix[last]-w.prefix[before], int64(last-before)*step)
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  delta
+//  Description:  窗口内最后一个与第一个有效数据的差值，用于gauge类型，不做重置修正
+// =====================================================================================
+*/
+func delta(pinput *[]int64, args *rangeArgs, poutput *[]int64) *[]int64 {
+	var w = newRangeWindow(*pinput, args.window, args.step)
+	var output = w.output(poutput)
+	for i := range output {
+		if first, last, ok := w.bounds(i); ok {
+			output[i] = w.input[last] - w.input[first]
+		} else {
+			output[i] = util.NullData
+		}
+	}
+	return &output
+}
+
+// 窗口函数名称
+var rangeFuncNames = map[string]bool{
+	"rate":     true,
+	"irate":    true,
+	"increase": true,
+	"delta":    true,
+
+	"avg_over_time":      true,
+	"min_over_time":      true,
+	"max_over_time":      true,
+	"sum_over_time":      true,
+	"count_over_time":    true,
+	"quantile_over_time": true,
+	"stddev_over_time":   true,
+
+	"ewma_zscore":    true,
+	"mad_score":      true,
+	"seasonal_score": true,
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  RangeLookback
+//  Description:  返回表达式需要向前多取的数据长度(秒)，查询时需要向前多取这么长的数据，
+//                才能保证第一个点的窗口是完整的。嵌套的窗口函数需要累加，
+//                例如max_over_time(rate($1, 5m), 1h)的第一个点需要1h+5m之前的数据
+// =====================================================================================
+*/
+func RangeLookback(format string) int64 {
+	var items, err = Lex(format)
+	if err != nil {
+		return 0
+	}
+
+	type frame struct {
+		name     string
+		argBegin int
+		inner    int64 // 参数中子表达式的最大lookback
+	}
+	var stack []frame
+	var lookback int64 = 0
+	for i, it := range items {
+		switch it.Type {
+		case ItemLeftParen:
+			var name string
+			if i > 0 && items[i-1].Type == ItemIdentifier {
+				name = items[i-1].Val
+			}
+			stack = append(stack, frame{name: name, argBegin: i + 1})
+		case ItemComma:
+			if len(stack) > 0 {
+				stack[len(stack)-1].argBegin = i + 1
+			}
+		case ItemRightParen:
+			if len(stack) == 0 {
+				return lookback
+			}
+			var f = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var total = f.inner
+			if rangeFuncNames[f.name] {
+				total += rangeWindowOf(items[f.argBegin:i])
+			}
+			if len(stack) > 0 {
+				if total > stack[len(stack)-1].inner {
+					stack[len(stack)-1].inner = total
+				}
+			} else if total > lookback {
+				lookback = total
+			}
+		}
+	}
+	return lookback
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  rangeWindowOf
+//  Description:  解析窗口函数最后一个参数中的窗口长度(秒)，如300、5m，不是窗口时返回0
+// =====================================================================================
+*/
+func rangeWindowOf(items []Item) int64 {
+	if len(items) == 0 || items[0].Type != ItemNumber {
+		return 0
+	}
+	var n, err = strconv.ParseInt(items[0].Val, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if len(items) > 1 && items[1].Type == ItemIdentifier && len(items[1].Val) == 1 {
+		n *= durationUnitMap[items[1].Val[0]]
+	}
+	return n
+}
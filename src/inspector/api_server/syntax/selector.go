@@ -0,0 +1,498 @@
+/*
+// =====================================================================================
+//
+//       Filename:  selector.go
+//
+//    Description:  PromQL风格的metric选择器与label匹配
+//
+//        Version:  1.0
+//        Created:  10/18/2026 02:48:12 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	LabelName    = "__name__"
+	LabelHid     = "hid"
+	LabelPid     = "pid"
+	LabelHost    = "host"
+	LabelService = "service"
+)
+
+// 指定采样过滤器的函数名
+const FunctionDownsample = "downsample"
+
+// 时间偏移修饰符，跟在metric或者选择器后面
+const KeywordOffset = "offset"
+
+// 选择器中允许出现的label
+var validLabels = map[string]bool{
+	LabelName:    true,
+	LabelHid:     true,
+	LabelPid:     true,
+	LabelHost:    true,
+	LabelService: true,
+}
+
+// =====================================================================================
+//         Type:  MatchType
+//  Description:  label匹配方式
+// =====================================================================================
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchEqual:
+		return "="
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	}
+	return fmt.Sprintf("MatchType(%d)", int(t))
+}
+
+// =====================================================================================
+//       Struct:  LabelMatcher
+//  Description:  单个label匹配条件
+// =====================================================================================
+type LabelMatcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  NewLabelMatcher
+//  Description:  正则匹配与prometheus一致，需要完整匹配label值
+// =====================================================================================
+*/
+func NewLabelMatcher(t MatchType, name, value string) (*LabelMatcher, error) {
+	var m = &LabelMatcher{
+		Name:  name,
+		Type:  t,
+		Value: value,
+	}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		var re, err = regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, err
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+func (m *LabelMatcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Matches
+//  Description:
+// =====================================================================================
+*/
+func (m *LabelMatcher) Matches(s string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return s == m.Value
+	case MatchNotEqual:
+		return s != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(s)
+	case MatchNotRegexp:
+		return !m.re.MatchString(s)
+	}
+	return false
+}
+
+// =====================================================================================
+//       Struct:  Selector
+//  Description:  查询语句的解析结果，形如：
+//                service|path|metric1, "service|path|metric 2" [expression] {hid="1", host=~"10\.1\..*"}
+//                外层可以带一个聚合运算，例如：sum by (pid) (service|path|metric1 {...})
+//                最外层可以指定采样过滤器，例如：downsample(service|path|metric1 {...}, "avg")
+//                metric或者整个选择器后面可以带时间偏移，例如：service|qps, service|qps offset 1d
+// =====================================================================================
+type Selector struct {
+	Metrics     []string
+	Offsets     []int64 // 与Metrics一一对应，每个metric自己的时间偏移(秒)
+	Offset      int64   // 整个选择器的时间偏移(秒)，作用于所有metric
+	Expression  string
+	Matchers    []*LabelMatcher
+	Aggregation *Aggregation // 没有聚合运算时为nil
+	Downsample  string       // 采样过滤器名称，没有指定时为空
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  MatchersOf
+//  Description:  返回指定label上的所有匹配条件
+// =====================================================================================
+*/
+func (s *Selector) MatchersOf(name string) []*LabelMatcher {
+	var result []*LabelMatcher
+	for _, it := range s.Matchers {
+		if it.Name == name {
+			result = append(result, it)
+		}
+	}
+	return result
+}
+
+// =====================================================================================
+//       Struct:  selectorParser
+//  Description:
+// =====================================================================================
+type selectorParser struct {
+	items  []Item
+	pos    int
+	series bool // 只选择曲线，不允许表达式，metric名称可以省略
+}
+
+func (p *selectorParser) peek() Item {
+	return p.items[p.pos]
+}
+
+func (p *selectorParser) next() Item {
+	var it = p.items[p.pos]
+	if it.Type != ItemEOF {
+		p.pos++
+	}
+	return it
+}
+
+func (p *selectorParser) expect(t ItemType, context string) (Item, error) {
+	var it = p.next()
+	if it.Type != t {
+		return it, newParseError(it.Pos, "unexpected %s in %s, expected %s", it, context, t)
+	}
+	return it, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ParseSelector
+//  Description:  解析查询语句，语法为：
+//                downsample "(" (aggregation | selector) "," string ")" | aggregation | selector
+//                selector为 metric_list? ("[" expression "]")? ("{" matcher_list? "}")? offset?
+//                metric可以为标识符或者引号字符串，后面可以带offset，matcher形如 label op "value"
+//                offset为 "offset" duration，duration为整数加上可选的s/m/h/d单位，默认为秒
+// =====================================================================================
+*/
+func ParseSelector(input string) (*Selector, error) {
+	var items, err = Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	var p = &selectorParser{items: items}
+	var s *Selector
+	if p.isDownsample() {
+		s, err = p.parseDownsample()
+	} else {
+		s, err = p.parseQuery()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if it := p.next(); it.Type != ItemEOF {
+		return nil, newParseError(it.Pos, "unexpected %s, expected end of input", it)
+	}
+	return s, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseQuery
+//  Description:  解析聚合运算或者不带聚合运算的选择器
+// =====================================================================================
+*/
+func (p *selectorParser) parseQuery() (*Selector, error) {
+	if p.isAggregation() {
+		return p.parseAggregation()
+	}
+	return p.parseSelector()
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  isDownsample
+//  Description:  当前位置是否为downsample函数，函数名后面必须紧跟"("
+// =====================================================================================
+*/
+func (p *selectorParser) isDownsample() bool {
+	var it = p.peek()
+	return it.Type == ItemIdentifier && it.Val == FunctionDownsample &&
+		p.items[p.pos+1].Type == ItemLeftParen
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseDownsample
+//  Description:  解析downsample函数，过滤器名称在执行时检查
+// =====================================================================================
+*/
+func (p *selectorParser) parseDownsample() (*Selector, error) {
+	p.next()
+	var err error
+	if _, err = p.expect(ItemLeftParen, FunctionDownsample); err != nil {
+		return nil, err
+	}
+	var s *Selector
+	if s, err = p.parseQuery(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(ItemComma, FunctionDownsample); err != nil {
+		return nil, err
+	}
+	var name Item
+	if name, err = p.expect(ItemString, FunctionDownsample); err != nil {
+		return nil, err
+	}
+	if len(name.Val) == 0 {
+		return nil, newParseError(name.Pos, "filter name of %s is empty", FunctionDownsample)
+	}
+	if _, err = p.expect(ItemRightParen, FunctionDownsample); err != nil {
+		return nil, err
+	}
+	s.Downsample = name.Val
+	return s, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ParseSeriesSelector
+//  Description:  解析/api/v1/series、/api/v1/labels等接口的match[]参数
+//                与prometheus相同，只包含metric和label匹配，例如{service="mongo", hid="1"}
+//                metric名称可以省略，但至少需要一个metric名称或者label匹配条件
+// =====================================================================================
+*/
+func ParseSeriesSelector(input string) (*Selector, error) {
+	var items, err = Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	var p = &selectorParser{items: items, series: true}
+	var s *Selector
+	if s, err = p.parseSelector(); err != nil {
+		return nil, err
+	}
+
+	if it := p.next(); it.Type != ItemEOF {
+		return nil, newParseError(it.Pos, "unexpected %s, expected end of input", it)
+	}
+	return s, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseSelector
+//  Description:  解析不带聚合运算的选择器
+// =====================================================================================
+*/
+func (p *selectorParser) parseSelector() (*Selector, error) {
+	var s = new(Selector)
+	var begin = p.peek().Pos
+	var err error
+
+	// metric list
+	for {
+		var it = p.peek()
+		if it.Type != ItemIdentifier && it.Type != ItemString {
+			if len(s.Metrics) > 0 {
+				return nil, newParseError(it.Pos, "unexpected %s in metric list, expected metric name", it)
+			}
+			break
+		}
+		p.next()
+		if len(it.Val) == 0 {
+			return nil, newParseError(it.Pos, "metric name is empty")
+		}
+		s.Metrics = append(s.Metrics, it.Val)
+		var offset int64
+		if p.isOffset() {
+			if offset, err = p.parseOffset(); err != nil {
+				return nil, err
+			}
+		}
+		s.Offsets = append(s.Offsets, offset)
+		if p.peek().Type != ItemComma {
+			break
+		}
+		p.next()
+	}
+
+	// expression
+	if p.peek().Type == ItemExpression {
+		if p.series {
+			return nil, newParseError(p.peek().Pos, "expression is not allowed in series selector")
+		}
+		s.Expression = p.next().Val
+	}
+
+	// label matchers
+	if p.peek().Type == ItemLeftBrace {
+		p.next()
+		if s.Matchers, err = p.parseMatchers(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 整个选择器的offset
+	if p.isOffset() {
+		if s.Offset, err = p.parseOffset(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.series {
+		if len(s.Metrics) == 0 && len(s.Matchers) == 0 {
+			return nil, newParseError(begin, "metric name or label matcher must be specified")
+		}
+	} else if len(s.Metrics) == 0 && len(s.MatchersOf(LabelName)) == 0 {
+		return nil, newParseError(begin, "metric name or %s matcher must be specified", LabelName)
+	}
+	return s, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  isOffset
+//  Description:  当前位置是否为offset修饰符，关键字后面必须紧跟数字，
+//                这样名为offset的metric仍然可以使用
+// =====================================================================================
+*/
+func (p *selectorParser) isOffset() bool {
+	var it = p.peek()
+	return it.Type == ItemIdentifier && it.Val == KeywordOffset &&
+		p.items[p.pos+1].Type == ItemNumber
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseOffset
+//  Description:  解析offset修饰符，返回偏移的秒数，只允许非负整数
+// =====================================================================================
+*/
+func (p *selectorParser) parseOffset() (int64, error) {
+	var keyword = p.next()
+	if p.series {
+		return 0, newParseError(keyword.Pos, "offset is not allowed in series selector")
+	}
+	var it = p.next()
+	var n, err = strconv.ParseInt(it.Val, 10, 64)
+	if err != nil || n < 0 {
+		return 0, newParseError(it.Pos, "invalid offset %q, expected a non-negative integer", it.Val)
+	}
+	// 数字后面可以紧跟时间单位
+	if unit := p.peek(); unit.Type == ItemIdentifier && unit.Pos == it.Pos+len(it.Val) {
+		if len(unit.Val) != 1 || durationUnitMap[unit.Val[0]] == 0 {
+			return 0, newParseError(unit.Pos, "unknown duration unit %q, expected one of s, m, h or d", unit.Val)
+		}
+		p.next()
+		n *= durationUnitMap[unit.Val[0]]
+	}
+	return n, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  FormatDuration
+//  Description:  将秒数格式化为能整除的最大单位，例如86400为1d，90为90s
+// =====================================================================================
+*/
+func FormatDuration(seconds int64) string {
+	for _, unit := range []byte{'d', 'h', 'm'} {
+		if seconds != 0 && seconds%durationUnitMap[unit] == 0 {
+			return fmt.Sprintf("%d%c", seconds/durationUnitMap[unit], unit)
+		}
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  parseMatchers
+//  Description:  解析"{"之后的label匹配列表，直到"}"
+// =====================================================================================
+*/
+func (p *selectorParser) parseMatchers() ([]*LabelMatcher, error) {
+	var matchers []*LabelMatcher
+	for {
+		if p.peek().Type == ItemRightBrace {
+			p.next()
+			return matchers, nil
+		}
+
+		var label, err = p.expect(ItemIdentifier, "label matching")
+		if err != nil {
+			return nil, err
+		}
+		if !validLabels[label.Val] {
+			return nil, newParseError(label.Pos, "unknown label %q, expected one of %s, %s, %s, %s or %s",
+				label.Val, LabelHid, LabelPid, LabelHost, LabelService, LabelName)
+		}
+
+		var op = p.next()
+		var matchType MatchType
+		switch op.Type {
+		case ItemEQL:
+			matchType = MatchEqual
+		case ItemNEQ:
+			matchType = MatchNotEqual
+		case ItemEQLRegex:
+			matchType = MatchRegexp
+		case ItemNEQRegex:
+			matchType = MatchNotRegexp
+		default:
+			return nil, newParseError(op.Pos, "unexpected %s in label matching, expected one of \"=\", \"!=\", \"=~\" or \"!~\"", op)
+		}
+
+		var value Item
+		if value, err = p.expect(ItemString, "label matching"); err != nil {
+			return nil, err
+		}
+		if matchType == MatchEqual && (label.Val == LabelHid || label.Val == LabelPid) {
+			if _, err = strconv.Atoi(value.Val); err != nil {
+				return nil, newParseError(value.Pos, "%s must be an integer, got %q", label.Val, value.Val)
+			}
+		}
+
+		var m *LabelMatcher
+		if m, err = NewLabelMatcher(matchType, label.Val, value.Val); err != nil {
+			return nil, newParseError(value.Pos, "invalid regular expression %q: %s", value.Val, err.Error())
+		}
+		matchers = append(matchers, m)
+
+		switch it := p.next(); it.Type {
+		case ItemComma:
+		case ItemRightBrace:
+			return matchers, nil
+		default:
+			return nil, newParseError(it.Pos, "unexpected %s in label matching, expected \",\" or \"}\"", it)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+/*
+// =====================================================================================
+//
+//       Filename:  selector_test.go
+//
+//    Description:
+//
+//        Version:  1.0
+//        Created:  10/18/2026 03:05:47 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package syntax
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	// case 1
+	{
+		var items, err = Lex(`mongo|opcounters|insert [arrayDiff($1)] {hid!="1", host=~"10\.1.*"}`)
+		check(err == nil, "test")
+		var types = []ItemType{ItemIdentifier, ItemExpression, ItemLeftBrace,
+			ItemIdentifier, ItemNEQ, ItemString, ItemComma,
+			ItemIdentifier, ItemEQLRegex, ItemString, ItemRightBrace, ItemEOF}
+		check(len(items) == len(types), "test")
+		for i := 0; i < len(items) && i < len(types); i++ {
+			check(items[i].Type == types[i], fmt.Sprintf("item[%d] is %v", i, items[i].Type))
+		}
+		check(items[0].Val == "mongo|opcounters|insert", "test")
+		check(items[1].Val == "arrayDiff($1)", "test")
+		check(items[1].Pos == 25, "test")
+		check(items[9].Val == `10\.1.*`, "test")
+	}
+
+	// case 2: 未闭合的字符串
+	{
+		var _, err = Lex(`mongo|cpu{hid="1}`)
+		check(err != nil, "test")
+		check(err.(*ParseError).Pos == 14, "test")
+	}
+
+	// case 3: 非法字符
+	{
+		var _, err = Lex(`mongo|cpu{hid!"1"}`)
+		check(err != nil, "test")
+		check(err.(*ParseError).Pos == 13, "test")
+	}
+
+	check(true, "test")
+}
+
+func TestParseSelector(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	// case 1
+	{
+		var s, err = ParseSelector(`mongo|c1|cpu, "mongo|wiredTiger|cache|bytes currently in the cache" [arrayAdd($1, $2)] {hid="4931065", host=~"11\.218\..*", pid!~"0|1",}`)
+		check(err == nil, "test")
+		check(len(s.Metrics) == 2, "test")
+		check(s.Metrics[0] == "mongo|c1|cpu", "test")
+		check(s.Metrics[1] == "mongo|wiredTiger|cache|bytes currently in the cache", "test")
+		check(s.Expression == "arrayAdd($1, $2)", "test")
+		check(len(s.Matchers) == 3, "test")
+		check(s.Matchers[0].Name == "hid" && s.Matchers[0].Type == MatchEqual && s.Matchers[0].Value == "4931065", "test")
+		check(s.Matchers[1].Matches("11.218.80.161:3079"), "test")
+		check(!s.Matchers[1].Matches("11.2189.80.161:3079"), "test")
+		check(!s.Matchers[2].Matches("1"), "test")
+		check(s.Matchers[2].Matches("10"), "test")
+	}
+
+	// case 2: 只有__name__
+	{
+		var s, err = ParseSelector(`{__name__=~"redis\|.*commands.*", service="redis"}`)
+		check(err == nil, "test")
+		check(len(s.Metrics) == 0, "test")
+		check(len(s.MatchersOf(LabelName)) == 1, "test")
+		check(s.MatchersOf(LabelName)[0].Matches("redis|total_commands_processed"), "test")
+		check(len(s.MatchersOf(LabelService)) == 1, "test")
+	}
+
+	// case 3: 错误位置
+	{
+		var _, err = ParseSelector(`mongo|cpu{hostId="1"}`)
+		check(err != nil && err.(*ParseError).Pos == 10, "test")
+
+		_, err = ParseSelector(`mongo|cpu{hid="abc"}`)
+		check(err != nil && err.(*ParseError).Pos == 14, "test")
+
+		_, err = ParseSelector(`mongo|cpu{hid="1" host="a"}`)
+		check(err != nil && err.(*ParseError).Pos == 18, "test")
+
+		_, err = ParseSelector(`mongo|cpu{host=~"(a"}`)
+		check(err != nil && err.(*ParseError).Pos == 16, "test")
+
+		_, err = ParseSelector(`mongo|cpu{hid="1"} extra`)
+		check(err != nil && err.(*ParseError).Pos == 19, "test")
+
+		_, err = ParseSelector(`{hid="1"}`)
+		check(err != nil, "test")
+
+		_, err = ParseSelector(`mongo|cpu, {hid="1"}`)
+		check(err != nil && err.(*ParseError).Pos == 11, "test")
+	}
+
+	// case 4: downsample
+	{
+		var s, err = ParseSelector(`downsample(mongo|cpu {hid="1"}, "lttb")`)
+		check(err == nil, "test")
+		check(s.Downsample == "lttb", "test")
+		check(len(s.Metrics) == 1 && s.Metrics[0] == "mongo|cpu", "test")
+		check(len(s.Matchers) == 1 && s.Aggregation == nil, "test")
+
+		s, err = ParseSelector(`downsample(sum by (pid) (mongo|cpu), "max")`)
+		check(err == nil, "test")
+		check(s.Downsample == "max", "test")
+		check(s.Aggregation != nil && s.Aggregation.Op == AggregateSum, "test")
+
+		// 没有downsample
+		s, err = ParseSelector(`mongo|cpu`)
+		check(err == nil && s.Downsample == "", "test")
+
+		_, err = ParseSelector(`downsample(mongo|cpu)`)
+		check(err != nil, "test")
+
+		_, err = ParseSelector(`downsample(mongo|cpu, "")`)
+		check(err != nil, "test")
+
+		_, err = ParseSelector(`downsample(mongo|cpu, "avg") extra`)
+		check(err != nil, "test")
+	}
+
+	// case 5: offset
+	{
+		var s, err = ParseSelector(`redis|qps, redis|qps offset 1d [arrayDiv($1, $2)] {hid="1"}`)
+		check(err == nil, fmt.Sprint(err))
+		check(len(s.Metrics) == 2 && len(s.Offsets) == 2, "test")
+		check(s.Offsets[0] == 0 && s.Offsets[1] == 86400, "test")
+		check(s.Offset == 0 && s.Expression == "arrayDiv($1, $2)", "test")
+
+		s, err = ParseSelector(`redis|qps, redis|cpu offset 30 {hid="1"} offset 2h`)
+		check(err == nil, fmt.Sprint(err))
+		check(s.Offsets[0] == 0 && s.Offsets[1] == 30 && s.Offset == 7200, "test")
+
+		s, err = ParseSelector(`sum(redis|qps offset 5m)`)
+		check(err == nil && s.Offsets[0] == 300, fmt.Sprint(err))
+
+		// 名为offset的metric
+		s, err = ParseSelector(`redis|qps, offset {hid="1"}`)
+		check(err == nil && len(s.Metrics) == 2 && s.Metrics[1] == "offset", fmt.Sprint(err))
+
+		_, err = ParseSelector(`redis|qps offset 1w`)
+		check(err != nil && err.(*ParseError).Pos == 18, fmt.Sprint(err))
+
+		_, err = ParseSelector(`redis|qps offset 1.5h`)
+		check(err != nil && err.(*ParseError).Pos == 17, fmt.Sprint(err))
+
+		_, err = ParseSelector(`redis|qps offset -1d`)
+		check(err != nil, "test")
+
+		_, err = ParseSeriesSelector(`redis|qps offset 1d`)
+		check(err != nil, "test")
+
+		check(FormatDuration(86400) == "1d", "test")
+		check(FormatDuration(5400) == "90m", "test")
+		check(FormatDuration(90) == "90s", "test")
+		check(FormatDuration(0) == "0s", "test")
+	}
+
+	check(true, "test")
+}
+
+func TestParseSeriesSelector(t *testing.T) {
+	check := func(statement bool, msg string) {
+		var line int
+		_, _, line, _ = runtime.Caller(1)
+		if statement == false {
+			t.Error(fmt.Sprintf("line[%d] msg: %s\n", line, msg))
+		}
+	}
+
+	// case 1: metric名称可以省略
+	{
+		var s, err = ParseSeriesSelector(`{service="mongo", hid=~"1|2"}`)
+		check(err == nil, "test")
+		check(len(s.Metrics) == 0, "test")
+		check(len(s.MatchersOf(LabelService)) == 1, "test")
+		check(s.MatchersOf(LabelHid)[0].Matches("2"), "test")
+
+		s, err = ParseSeriesSelector(`mongo|opcounters|insert`)
+		check(err == nil, "test")
+		check(len(s.Metrics) == 1 && len(s.Matchers) == 0, "test")
+	}
+
+	// case 2: 不允许表达式以及空选择器
+	{
+		var _, err = ParseSeriesSelector(`mongo|cpu [arrayDiff($1)]`)
+		check(err != nil && err.(*ParseError).Pos == 11, "test")
+
+		_, err = ParseSeriesSelector(`{}`)
+		check(err != nil, "test")
+
+		_, err = ParseSeriesSelector(`sum(mongo|cpu)`)
+		check(err != nil, "test")
+	}
+
+	check(true, "test")
+}
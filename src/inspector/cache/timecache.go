@@ -45,6 +45,7 @@ type instanceList struct {
 type TimeCache struct {
 	concurrencty uint32                     // how much pieces the cache divided
 	timeReserve  uint32                     // max duration of time remain(unit:second)
+	reserveMap   sync.Map                   // service -> timeReserve, overwrite timeReserve
 	cache        []map[string]*instanceList // cache data
 	lockers      []sync.RWMutex             // rwmutex for each instance map
 	mm           []*memManager              // memory manager for cache
@@ -98,6 +99,7 @@ func (tc *TimeCache) Set(info *core.Info) error {
 	host := info.Header.Host
 	timestamp := info.Timestamp
 	items := info.Items
+	timeReserve := tc.GetReserve(info.Header.Service)
 
 	index := tc.hash([]byte(host)) % tc.concurrencty
 
@@ -106,7 +108,7 @@ func (tc *TimeCache) Set(info *core.Info) error {
 	if _, ok = tc.cache[index][host]; !ok { // check if the instance exist
 		tc.cache[index][host] = new(instanceList)
 		tc.cache[index][host].timeLevelList = new(list.List)
-		tc.newInstance(host, index, info)
+		tc.newInstance(host, index, info, timeReserve)
 	}
 	tc.lockers[index].Unlock()
 	insList = tc.cache[index][host]
@@ -127,12 +129,19 @@ func (tc *TimeCache) Set(info *core.Info) error {
 		// 	(timestamp-ins.lastTime)%uint32(count*step) != 0 { // check if data time is continuous
 		if isSameTimeLevel(ins, info) == false {
 			// remove element if timeout
-			if ins.lastTime+tc.timeReserve < timestamp {
+			if ins.lastTime+ins.timeReserve < timestamp {
 				var tmp = e.Next()
 				tlList.Remove(e)
 				e = tmp
 				continue
 			}
+		} else if ins.timeReserve != timeReserve {
+			// reserve of service changed, rebuild instance
+			glog.Infof("instance[%s] reserve changed from %d to %d", host, ins.timeReserve, timeReserve)
+			var tmp = e.Next()
+			tlList.Remove(e)
+			e = tmp
+			continue
 		} else {
 			instanceHit = ins
 		}
@@ -143,7 +152,7 @@ func (tc *TimeCache) Set(info *core.Info) error {
 	}
 	// create a new instance branch
 	if instanceHit == nil {
-		tc.newInstance(host, index, info)
+		tc.newInstance(host, index, info, timeReserve)
 		instanceHit = tlList.Back().Value.(*instance)
 
 	}
@@ -285,6 +294,34 @@ func (tc *TimeCache) Get(query *core.Query) ([]*core.InfoRange, uint32, uint32,
 	return infoRanges, timeBegin, timeEnd, nil
 }
 
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  SetReserve
+ *  Description:  set reserve(unit: virtual second) of service, 0 means using default.
+ *                instances of service will be rebuilt when next data comes
+ * =====================================================================================
+ */
+func (tc *TimeCache) SetReserve(service string, timeReserve uint32) {
+	if timeReserve == 0 {
+		tc.reserveMap.Delete(service)
+		return
+	}
+	tc.reserveMap.Store(service, timeReserve)
+}
+
+/*
+ * ===  FUNCTION  ======================================================================
+ *         Name:  GetReserve
+ *  Description:
+ * =====================================================================================
+ */
+func (tc *TimeCache) GetReserve(service string) uint32 {
+	if v, ok := tc.reserveMap.Load(service); ok {
+		return v.(uint32)
+	}
+	return tc.timeReserve
+}
+
 /*
  * ===  FUNCTION  ======================================================================
  *         Name:  Stat
@@ -301,9 +338,9 @@ func (tc *TimeCache) Stat() core.Stat {
  *  Description:
  * =====================================================================================
  */
-func (tc *TimeCache) newInstance(name string, index uint32, info *core.Info) *instance {
+func (tc *TimeCache) newInstance(name string, index uint32, info *core.Info, timeReserve uint32) *instance {
 	ins := new(instance)
-	ins.init(name, tc.mm[index], info.Timestamp, tc.timeReserve, int(info.Count), int(info.Step))
+	ins.init(name, tc.mm[index], info.Timestamp, timeReserve, int(info.Count), int(info.Step))
 	tc.cache[index][info.Header.Host].timeLevelList.PushBack(ins)
 	glog.V(1).Infof("create instance[%s]", name)
 	return ins
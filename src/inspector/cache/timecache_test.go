@@ -785,3 +785,47 @@ func TestCacheBug2(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheReserve(t *testing.T) {
+	check := func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	var tc *TimeCache = NewTimeCache(1, 100)
+	tc.SetReserve("redis", 30)
+	check(tc.GetReserve("redis") == 30 && tc.GetReserve("mysql") == 100, "get reserve")
+
+	var set = func(service, host string, timestamp uint32) {
+		var info = &core.Info{
+			Header:    &core.Header{Service: service, Host: host},
+			Timestamp: timestamp,
+			Count:     10,
+			Step:      1,
+			Items:     []*core.KVPair{{Key: "a", Value: []byte(fmt.Sprintf("a%d", timestamp))}},
+		}
+		check(tc.Set(info) == nil, fmt.Sprintf("set %s[%d]", host, timestamp))
+	}
+	var get = func(host string) uint32 {
+		var query = &core.Query{Header: &core.Header{Host: host}, KeyList: []string{"a"}, TimeBegin: 0, TimeEnd: 2000}
+		var _, timeBegin, _, err = tc.Get(query)
+		check(err == nil, fmt.Sprintf("get %s: %v", host, err))
+		return timeBegin
+	}
+	for timestamp := uint32(1000); timestamp < 1060; timestamp += 10 {
+		set("redis", "ins0", timestamp)
+		set("mysql", "ins1", timestamp)
+	}
+	check(get("ins0") == 1030, fmt.Sprintf("redis should keep 30 seconds: %d", get("ins0")))
+	check(get("ins1") == 1000, fmt.Sprintf("mysql should keep 100 seconds: %d", get("ins1")))
+
+	// reserve变化后重建实例
+	tc.SetReserve("redis", 0)
+	check(tc.GetReserve("redis") == 100, "reserve should be reset")
+	set("redis", "ins0", 1060)
+	check(get("ins0") == 1060, fmt.Sprintf("instance should be rebuilt: %d", get("ins0")))
+	set("redis", "ins0", 1070)
+	check(get("ins0") == 1060, fmt.Sprintf("instance should be kept: %d", get("ins0")))
+}
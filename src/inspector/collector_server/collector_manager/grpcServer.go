@@ -1,9 +1,14 @@
 package collectorManager
 
 import (
+	"fmt"
+	"time"
+
 	"inspector/proto/core"
 	"inspector/proto/collector"
+	"inspector/util/grpc2"
 
+	"github.com/golang/glog"
 	"golang.org/x/net/context"
 )
 
@@ -29,8 +34,19 @@ func (s *GrpcServer) Query(ctx context.Context, in *collector.CollectorQueryRequ
 		Errno:  okCode,
 		Errmsg: "ok",
 	}
+	// return the timing of each query in the trailer when api_server asks for it
+	traceID, traceDebug := grpc2.TraceFromContext(ctx)
+	glog.V(1).Infof("GrpcServer: Query trace[%s] query count[%d]", traceID, len(in.GetQueryList()))
+	var steps []grpc2.TraceStep
 	for _, query := range in.GetQueryList() {
+		start := time.Now()
 		ret, errString := s.cm.QueryData(query)
+		if traceDebug {
+			steps = append(steps, grpc2.TraceStep{
+				Name:     fmt.Sprintf("QueryData[%s] from ring cache", query.GetHeader().GetHost()),
+				Duration: time.Since(start),
+			})
+		}
 		if errString != "" {
 			failureInfoList = append(failureInfoList, &core.InfoRange{
 				Header: query.Header,
@@ -45,6 +61,12 @@ func (s *GrpcServer) Query(ctx context.Context, in *collector.CollectorQueryRequ
 		}
 	}
 
+	if traceDebug {
+		if err := grpc2.SetTraceTiming(ctx, steps); err != nil {
+			glog.Warningf("set trace[%s] timing error[%v]", traceID, err)
+		}
+	}
+
 	return &collector.CollectorQueryResponse{
 		Error:       retErr,
 		SuccessList: successInfoList,
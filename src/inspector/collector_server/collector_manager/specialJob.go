@@ -625,6 +625,12 @@ func (sj *SpecialJob) convertMap2Instance(input map[string]interface{}) *model.I
 			} else {
 				return nil
 			}
+		case model.Precision:
+			if precision, err := util.ConvertInterface2Int(val); err == nil {
+				ins.Precision = precision
+			} else {
+				return nil
+			}
 		case model.Commands:
 			var cmds = val.([]interface{})
 			for _, it := range cmds {
@@ -1,9 +1,7 @@
 package redisSteps
 
 import (
-	"fmt"
 	"math"
-	"reflect"
 	"time"
 
 	"inspector/cache"
@@ -130,35 +128,8 @@ func (ss *StepStore) After(input interface{}, params ...interface{}) (bool, erro
 	return true, nil
 }
 
-func (ss *StepStore) parseValue(input interface{}) (ret int64, err error) {
-	switch v := input.(type) {
-	case int:
-		ret = int64(v) * util.FloatMultiple
-	case uint:
-		ret = int64(v) * util.FloatMultiple
-	case int32:
-		ret = int64(v) * util.FloatMultiple
-	case uint32:
-		ret = int64(v) * util.FloatMultiple
-	case bool:
-		if v == true {
-			ret = 1
-		} else {
-			ret = 0
-		}
-	case int64:
-		ret = v * util.FloatMultiple
-	case uint64:
-		ret = int64(v) * util.FloatMultiple
-	case float64:
-		ret = int64(v) * util.FloatMultiple
-	case float32:
-		ret = int64(v) * util.FloatMultiple
-	default:
-		err = fmt.Errorf("unknown type[%v]", reflect.TypeOf(v))
-	}
-
-	return ret, err
+func (ss *StepStore) parseValue(input interface{}) (int64, error) {
+	return util.ParseFixedValue(input, util.PrecisionMultiple(ss.Instance.Precision))
 }
 
 /*
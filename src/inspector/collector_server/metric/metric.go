@@ -106,6 +106,7 @@ type Metric struct {
 	BytesGet                 Combine    // how many bytes we get for total and every minute
 	BytesSend                Combine    // how many bytes we send after compressing for total and every minute
 	BytesSendClient          *sync.Map  // bytes send of every grpc client, int -> Combine
+	SendFailure              Combine    // how many infos failed to store for total and every second, re-sent later
 	SameDigitCompressPercent Percent    // the compress percentage of same digit algorithm
 	DiffCompressPercent      Percent    // the compress percentage of diff algorithm
 	TotalCompressPercent     Percent    // the compress percentage of total algorithm
@@ -138,7 +139,7 @@ func (m *Metric) resetEverySecond(items []*Delta) {
 }
 
 func (m *Metric) run() {
-	resetItems := []*Delta{&m.BytesGet.Delta, &m.BytesSend.Delta}
+	resetItems := []*Delta{&m.BytesGet.Delta, &m.BytesSend.Delta, &m.SendFailure.Delta}
 	time.Sleep(beginningWaitInterval * time.Second)
 	go func() {
 		tick := 0
@@ -151,12 +152,14 @@ func (m *Metric) run() {
 
 			glog.Infof("metric statistics: ItemsMax[%v], ItemsMin[%v], ItemsAvg[%v], ItemsEmpty[%v] "+
 				"BytesGet_Total[%v], BytesGet_Delta[%v], BytesSend_Total[%v], BytesSend_Delta[%v], "+
+				"SendFailure_Total[%v], SendFailure_Delta[%v], "+
 				"SameDigitCompressPercent[%v],  DiffCompressPercent[%v], TotalCompressPercent[%v], "+
 				"InstanceNumber[%v], StepRunTimes[%v], WorkflowDuration_Max[%v], WorkflowDuration_Min[%v], "+
 				"WorkflowDuration_Avg[%v], Uptime[%v]",
 				m.GetItemsMax(), m.GetItemsMin(), m.GetItemsAvg(), m.GetItemsEmpty(),
 				util.ConvertTraffic(m.GetBytesGetTotal()), util.ConvertTraffic(m.GetBytesGetDelta()),
 				util.ConvertTraffic(m.GetBytesSendTotal()), util.ConvertTraffic(m.GetBytesSendDelta()),
+				m.GetSendFailureTotal(), m.GetSendFailureDelta(),
 				m.GetSameDigitCompressPercent(), m.GetDiffCompressPercent(), m.GetTotalCompressPercent(),
 				m.GetInstanceNumber(), m.GetStepCount(), m.GetWorkflowDurationMax(), m.GetWorkflowDurationMin(),
 				m.GetWorkflowDurationAvg(), m.GetUptime())
@@ -219,6 +222,18 @@ func (m *Metric) GetBytesSendTotal() uint64 {
 	return atomic.LoadUint64(&m.BytesSend.Total)
 }
 
+func (m *Metric) AddSendFailure(val uint64) {
+	m.SendFailure.Set(val)
+}
+
+func (m *Metric) GetSendFailureDelta() uint64 {
+	return atomic.LoadUint64(&m.SendFailure.Delta.Delta)
+}
+
+func (m *Metric) GetSendFailureTotal() uint64 {
+	return atomic.LoadUint64(&m.SendFailure.Total)
+}
+
 func (m *Metric) AddBytesSendClient(key interface{}, val uint64) {
 	client, ok := m.BytesSendClient.Load(key)
 	if !ok {
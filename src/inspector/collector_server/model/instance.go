@@ -13,6 +13,7 @@ const (
 	DBTypeName   = "dbType" // mysql, redis, mongodb
 	Count        = "count"
 	Interval     = "interval"
+	Precision    = "precision"
 	Commands     = "cmds"
 )
 
@@ -29,9 +30,10 @@ type Instance struct {
 
 	/* above information are stored in the taskList and taskDistribute collection*/
 
-	// "count" and "interval" fields are got from meta collection
-	Count    int
-	Interval int
+	// "count", "interval" and "precision" fields are got from meta collection
+	Count     int
+	Interval  int
+	Precision int // 数值保留的小数位数，采集值按10^Precision倍转换为int64
 
 	Commands []string
 }
@@ -89,6 +89,8 @@ func registerMetric() {
 		BytesSendDelta           interface{}
 		BytesSendTotal           interface{}
 		BytesSendEachClient      interface{}
+		SendFailureDelta         interface{}
+		SendFailureTotal         interface{}
 		SameDigitCompressPercent interface{}
 		DiffCompressPercent      interface{}
 		TotalCompressPercent     interface{}
@@ -113,6 +115,8 @@ func registerMetric() {
 				BytesSendDelta:           util.ConvertTraffic(metricRet.GetBytesSendDelta()),
 				BytesSendTotal:           util.ConvertTraffic(metricRet.GetBytesSendTotal()),
 				BytesSendEachClient:      metricRet.GetBytesSendClient(),
+				SendFailureDelta:         metricRet.GetSendFailureDelta(),
+				SendFailureTotal:         metricRet.GetSendFailureTotal(),
 				SameDigitCompressPercent: metricRet.GetSameDigitCompressPercent(),
 				DiffCompressPercent:      metricRet.GetDiffCompressPercent(),
 				TotalCompressPercent:     metricRet.GetTotalCompressPercent(),
@@ -47,11 +47,12 @@ var (
 
 // grpc client
 type sender struct {
-	client  *grpc2.Connection // grpc connection
-	msgChan chan *core.Info   // send context info
+	client      *grpc2.Connection // grpc connection
+	msgChan     chan *core.Info   // send context info
+	serviceName string            // used in metric
 }
 
-func newSender(address string) *sender {
+func newSender(address, serviceName string) *sender {
 	// create new connection
 	client := grpc2.NewConnection(address)
 
@@ -62,8 +63,9 @@ func newSender(address string) *sender {
 	}
 
 	s := &sender{
-		client:  client,
-		msgChan: make(chan *core.Info, senderChanSize),
+		client:      client,
+		msgChan:     make(chan *core.Info, senderChanSize),
+		serviceName: serviceName,
 	}
 	go s.Run() // run as goroutine
 	return s
@@ -118,38 +120,46 @@ func (s *sender) Run() {
 	glog.Info("Sender: I'm unreachable")
 }
 
+// send the request, only the failed infos are stored locally and re-sent later
 func (s *sender) send(request *store.StoreSaveRequest) error {
+	failList, err := s.doSend(request)
+	if len(failList) == 0 {
+		return err
+	}
+
+	metric.GetMetric(s.serviceName).AddSendFailure(uint64(len(failList)))
+	// store local file: directory/time(int32)_nanoseconds_suffix
+	newPath := generateFilename()
+	storeLocal(&store.StoreSaveRequest{InfoList: failList}, newPath)
+	return err
+}
+
+// return the infos failed to store
+func (s *sender) doSend(request *store.StoreSaveRequest) ([]*core.Info, error) {
 	// do send
 	if unitTestSwitch { // for unit test only
 		glog.Infof("unit test open, send request to channel")
 		if unitTestSendFail { // mock send fail
 			glog.Infof("unit test open, mock send request to channel fail")
-			newPath := generateFilename()
-			storeLocal(request, newPath)
-			return fmt.Errorf("send to unit test failed")
+			return request.InfoList, fmt.Errorf("send to unit test failed")
 		} else {
 			unitTestChannel <- request
 		}
 	} else { // normal send
 		var err error
 		var ret *store.StoreSaveResponse
-		ctx, _ := context.WithTimeout(context.Background(), sendGrpcTimeout * time.Second)
-		if ret, err = s.client.Client.Save(ctx, request); err == nil {
-			if errRet := ret.GetError(); errRet == nil || errRet.Errno == 0 {
-				return nil
-			} else {
-				//err = fmt.Errorf("send to address[%s] return error[%v] with errno[%v]",
-				//	s.client.Addr, errRet.Errmsg, errRet.Errno)
-			}
-			return nil // todo, return nil directly
+		ctx, cancel := context.WithTimeout(context.Background(), sendGrpcTimeout*time.Second)
+		defer cancel()
+		if ret, err = s.client.Client.Save(ctx, request); err != nil {
+			return request.InfoList, fmt.Errorf("send to address[%s] failed[%v]", s.client.Addr, err)
+		}
+		if failList := failedInfoList(request.InfoList, ret); len(failList) != 0 {
+			return failList, fmt.Errorf("send to address[%s] return error[%v] with errno[%v], %d of %d infos failed",
+				s.client.Addr, ret.GetError().GetErrmsg(), ret.GetError().GetErrno(), len(failList), len(request.InfoList))
 		}
-		// store local file: directory/time(int32)_nanoseconds_suffix
-		newPath := generateFilename()
-		storeLocal(request, newPath)
-		return fmt.Errorf("send to address[%s] failed[%v]", s.client.Addr, err)
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (s *sender) Close() {
@@ -328,7 +338,7 @@ func (e *Executor) checkConnection() {
 			continue
 		}
 
-		sender := newSender(util.ConvertUnderline2Dot(val.Name))
+		sender := newSender(util.ConvertUnderline2Dot(val.Name), e.serviceName)
 		if sender == nil {
 			glog.Errorf("Executor: create sender with store_server address[%s] fail", val.Name)
 			continue
@@ -364,6 +374,47 @@ func (e *Executor) generateStoreSaveInfo(input *model.SenderContext) *core.Info
 	return info
 }
 
+// find the infos in the failure list of the response. the store server without
+// per-info result only sets the global error, all infos are failed in this case
+func failedInfoList(infoList []*core.Info, ret *store.StoreSaveResponse) []*core.Info {
+	if errRet := ret.GetError(); errRet == nil || errRet.Errno == 0 {
+		return nil
+	}
+	if len(ret.FailureList) == 0 && len(ret.SuccessList) == 0 {
+		return infoList
+	}
+
+	type infoKey struct {
+		service   string
+		hid       int32
+		host      string
+		timestamp uint32
+	}
+	failSet := make(map[infoKey]struct{}, len(ret.FailureList))
+	for _, item := range ret.FailureList {
+		failSet[infoKey{
+			service:   item.GetHeader().GetService(),
+			hid:       item.GetHeader().GetHid(),
+			host:      item.GetHeader().GetHost(),
+			timestamp: item.GetTimestamp(),
+		}] = struct{}{}
+	}
+
+	failList := make([]*core.Info, 0, len(ret.FailureList))
+	for _, info := range infoList {
+		key := infoKey{
+			service:   info.GetHeader().GetService(),
+			hid:       info.GetHeader().GetHid(),
+			host:      info.GetHeader().GetHost(),
+			timestamp: info.GetTimestamp(),
+		}
+		if _, ok := failSet[key]; ok {
+			failList = append(failList, info)
+		}
+	}
+	return failList
+}
+
 func (e *Executor) pickSender(hid int32) *sender {
 	if n := len(e.storeServerList); n == 0 {
 		glog.Errorf("no store server exist including dead")
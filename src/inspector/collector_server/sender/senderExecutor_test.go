@@ -666,4 +666,57 @@ func TestSenderExecutor3(t *testing.T) {
 //
 //		senderExecutor.Close()
 //	}
-//}
\ No newline at end of file
+//}
+
+func TestFailedInfoList(t *testing.T) {
+	var nr int
+	newInfo := func(host string, timestamp uint32) *core.Info {
+		return &core.Info{
+			Header:    &core.Header{Service: serviceName, Hid: 1, Host: host},
+			Timestamp: timestamp,
+		}
+	}
+	newItem := func(info *core.Info, errno uint32) *core.ResponseItem {
+		return &core.ResponseItem{
+			Header:    info.Header,
+			Timestamp: info.Timestamp,
+			Error:     &core.Error{Errno: errno},
+		}
+	}
+	infoList := []*core.Info{newInfo("h1", 60), newInfo("h1", 120), newInfo("h2", 60)}
+
+	// all success
+	{
+		nr++
+		fmt.Printf("TestFailedInfoList case %d.\n", nr)
+		ret := &store.StoreSaveResponse{
+			Error:       &core.Error{Errno: 0},
+			SuccessList: []*core.ResponseItem{newItem(infoList[0], 0), newItem(infoList[1], 0), newItem(infoList[2], 0)},
+		}
+		assert.Equal(t, 0, len(failedInfoList(infoList, ret)), "should be equal")
+	}
+
+	// only the failed infos are returned
+	{
+		nr++
+		fmt.Printf("TestFailedInfoList case %d.\n", nr)
+		ret := &store.StoreSaveResponse{
+			Error:       &core.Error{Errno: 255},
+			SuccessList: []*core.ResponseItem{newItem(infoList[0], 0), newItem(infoList[2], 0)},
+			FailureList: []*core.ResponseItem{newItem(infoList[1], 255)},
+		}
+		failList := failedInfoList(infoList, ret)
+		assert.Equal(t, 1, len(failList), "should be equal")
+		assert.Equal(t, uint32(120), failList[0].Timestamp, "should be equal")
+	}
+
+	// store server without per-info result
+	{
+		nr++
+		fmt.Printf("TestFailedInfoList case %d.\n", nr)
+		ret := &store.StoreSaveResponse{
+			Error: &core.Error{Errno: 255},
+		}
+		assert.Equal(t, 3, len(failedInfoList(infoList, ret)), "should be equal")
+	}
+}
@@ -235,7 +235,7 @@ func (h *Heartbeat) GetServices(module ModuleType, status ServiceStatus) ([]*Nod
 		if ans[i].Gid != ans[j].Gid {
 			return ans[i].Gid < ans[j].Gid
 		}
-		return ans[i].Name < ans[i].Name
+		return ans[i].Name < ans[j].Name
 	})
 
 	return ans, nil
@@ -272,6 +272,19 @@ func (h *Heartbeat) IsAlive(module ModuleType, service string) ServiceStatus {
 	return ServiceDead
 }
 
+/*
+ * is current service the leader of its module?
+ * the alive service with the smallest gid is the leader, so every service comes to
+ * the same answer without extra election, and another one takes over after the leader dies.
+ */
+func (h *Heartbeat) IsLeader() (bool, error) {
+	services, err := h.GetServices(h.Conf.Module, ServiceAlive)
+	if err != nil {
+		return false, err
+	}
+	return len(services) > 0 && services[0].Name == h.Conf.Service, nil
+}
+
 // get the number of given service
 func (h *Heartbeat) GetServiceCount(module ModuleType) (int, error) {
 	return h.cfgHandler.GetInt(SectionName, string(module), idName)
@@ -177,6 +177,8 @@ type Query struct {
 	TimeEnd   uint32   `protobuf:"varint,3,opt,name=TimeEnd" json:"TimeEnd,omitempty"`
 	Index     *Index   `protobuf:"bytes,4,opt,name=Index" json:"Index,omitempty"`
 	KeyList   []string `protobuf:"bytes,5,rep,name=KeyList" json:"KeyList,omitempty"`
+	Step      uint32   `protobuf:"varint,6,opt,name=Step" json:"Step,omitempty"`
+	Aggregate string   `protobuf:"bytes,7,opt,name=Aggregate" json:"Aggregate,omitempty"`
 }
 
 func (m *Query) Reset()                    { *m = Query{} }
@@ -219,6 +221,20 @@ func (m *Query) GetKeyList() []string {
 	return nil
 }
 
+func (m *Query) GetStep() uint32 {
+	if m != nil {
+		return m.Step
+	}
+	return 0
+}
+
+func (m *Query) GetAggregate() string {
+	if m != nil {
+		return m.Aggregate
+	}
+	return ""
+}
+
 type InfoRange struct {
 	Header *Header `protobuf:"bytes,1,opt,name=Header" json:"Header,omitempty"`
 	Count  uint32  `protobuf:"varint,2,opt,name=Count" json:"Count,omitempty"`
@@ -292,8 +308,9 @@ func (m *Error) GetErrmsg() string {
 }
 
 type ResponseItem struct {
-	Header *Header `protobuf:"bytes,1,opt,name=Header" json:"Header,omitempty"`
-	Error  *Error  `protobuf:"bytes,2,opt,name=Error" json:"Error,omitempty"`
+	Header    *Header `protobuf:"bytes,1,opt,name=Header" json:"Header,omitempty"`
+	Error     *Error  `protobuf:"bytes,2,opt,name=Error" json:"Error,omitempty"`
+	Timestamp uint32  `protobuf:"varint,3,opt,name=Timestamp" json:"Timestamp,omitempty"`
 }
 
 func (m *ResponseItem) Reset()                    { *m = ResponseItem{} }
@@ -315,6 +332,13 @@ func (m *ResponseItem) GetError() *Error {
 	return nil
 }
 
+func (m *ResponseItem) GetTimestamp() uint32 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
 type Stat struct {
 	InstanceCount uint64 `protobuf:"varint,1,opt,name=InstanceCount" json:"InstanceCount,omitempty"`
 	ItemCount     uint64 `protobuf:"varint,2,opt,name=ItemCount" json:"ItemCount,omitempty"`
@@ -386,35 +410,36 @@ func init() {
 func init() { proto.RegisterFile("inspector/proto/core/core.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 474 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0xcd, 0x8a, 0x13, 0x41,
-	0x10, 0x66, 0x32, 0x3f, 0x21, 0x65, 0x02, 0xd2, 0x88, 0xcc, 0x21, 0xb2, 0x61, 0xd8, 0xc3, 0x9e,
-	0x12, 0x51, 0x7c, 0x01, 0xd7, 0xc0, 0x84, 0x28, 0x98, 0x8e, 0xec, 0xbd, 0x9d, 0xd4, 0xc6, 0x01,
-	0xd3, 0x3d, 0xf4, 0x74, 0xc4, 0xf8, 0x04, 0x3e, 0x82, 0x0f, 0xe2, 0xd1, 0x87, 0x93, 0xaa, 0xee,
-	0xd9, 0xd9, 0x85, 0x85, 0xcd, 0x65, 0xa8, 0xef, 0xeb, 0xae, 0xea, 0xef, 0xfb, 0x8a, 0x81, 0x8b,
-	0x5a, 0xb7, 0x0d, 0x56, 0xce, 0xd8, 0x45, 0x63, 0x8d, 0x33, 0x8b, 0xca, 0x58, 0xe4, 0xcf, 0x9c,
-	0x71, 0x51, 0x42, 0x56, 0xa2, 0xda, 0xa1, 0x15, 0x39, 0x0c, 0xb7, 0x68, 0x7f, 0xd4, 0x15, 0xe6,
-	0xd1, 0x2c, 0xba, 0x1a, 0xc9, 0x0e, 0x8a, 0xe7, 0x10, 0x97, 0xf5, 0x2e, 0x1f, 0xcc, 0xa2, 0xab,
-	0x54, 0x52, 0x29, 0x04, 0x24, 0xa5, 0x69, 0x5d, 0x1e, 0xf3, 0x45, 0xae, 0x8b, 0xd7, 0x90, 0xad,
-	0x6f, 0x3e, 0xab, 0xda, 0xd2, 0xfd, 0x35, 0x9e, 0xc2, 0x14, 0x2a, 0xc5, 0x0b, 0x48, 0x6f, 0xd4,
-	0xf7, 0x23, 0xf2, 0x8c, 0xb1, 0xf4, 0xa0, 0xf8, 0x1b, 0x41, 0xb2, 0xd2, 0xb7, 0x46, 0x5c, 0x74,
-	0x22, 0xb8, 0xe7, 0xd9, 0x9b, 0xe1, 0xdc, 0x43, 0xd9, 0x69, 0x9b, 0xc2, 0xe8, 0x4b, 0x7d, 0xc0,
-	0xd6, 0xa9, 0x43, 0xc3, 0x33, 0x26, 0xb2, 0x27, 0x68, 0xfa, 0xb5, 0x39, 0x6a, 0x2f, 0x67, 0x22,
-	0x3d, 0x20, 0x8d, 0x5b, 0x87, 0x4d, 0x9e, 0x30, 0xc9, 0xb5, 0xb8, 0x84, 0xd1, 0x4a, 0xef, 0xf0,
-	0xe7, 0xc7, 0xba, 0x75, 0x79, 0xca, 0x6f, 0x65, 0x73, 0x66, 0x64, 0x7f, 0x20, 0x5e, 0x41, 0xba,
-	0x72, 0x78, 0x68, 0xf3, 0x6c, 0x16, 0xb3, 0x1a, 0xef, 0x4b, 0x7a, 0xb6, 0x58, 0x40, 0xca, 0x77,
-	0x9f, 0xf2, 0x39, 0xea, 0x7c, 0xfe, 0x89, 0x20, 0xdd, 0x1c, 0xd1, 0x9e, 0xce, 0x36, 0xfa, 0x1e,
-	0xf7, 0xb5, 0xbe, 0x6f, 0x94, 0x09, 0x5a, 0x11, 0x81, 0xa5, 0xde, 0x05, 0xab, 0x1d, 0x14, 0xd3,
-	0xa0, 0x89, 0xdd, 0xf6, 0xa6, 0x82, 0xd0, 0x1c, 0x86, 0x6b, 0x3c, 0x05, 0xd3, 0x31, 0xad, 0x36,
-	0xc0, 0xe2, 0x77, 0x44, 0x89, 0xdc, 0x1a, 0xa9, 0xf4, 0x1e, 0x9f, 0x96, 0x77, 0x97, 0xf4, 0xe0,
-	0xb1, 0xa4, 0xe3, 0x7b, 0x49, 0x4f, 0x21, 0x5d, 0x5a, 0x6b, 0xec, 0x9d, 0x20, 0x46, 0xd2, 0x93,
-	0xd4, 0xf1, 0x41, 0x39, 0xc5, 0x2b, 0x18, 0x4b, 0xae, 0x8b, 0x77, 0xa1, 0x83, 0x1e, 0x59, 0x5a,
-	0xab, 0x0d, 0x8b, 0x98, 0x48, 0x0f, 0xc4, 0x4b, 0xc8, 0x96, 0xd6, 0x1e, 0xda, 0x7d, 0xc8, 0x36,
-	0xa0, 0xe2, 0x13, 0x8c, 0x25, 0xb6, 0x8d, 0xd1, 0x2d, 0xd2, 0x7a, 0xce, 0x89, 0x38, 0x28, 0x1b,
-	0x3c, 0xa2, 0xac, 0xf8, 0x17, 0x91, 0x19, 0xe5, 0xc4, 0x25, 0x4c, 0x56, 0xba, 0x75, 0x4a, 0x57,
-	0xe8, 0x2d, 0xd3, 0xb8, 0x44, 0x3e, 0x24, 0x69, 0x5f, 0xf4, 0x6a, 0x1f, 0x4a, 0x22, 0x7b, 0x82,
-	0x4e, 0xaf, 0x55, 0xf5, 0x0d, 0xb7, 0xf5, 0x2f, 0xe4, 0x74, 0x12, 0xd9, 0x13, 0xe4, 0x68, 0xe3,
-	0x1b, 0x13, 0x3e, 0x0a, 0x88, 0xfc, 0x6f, 0xb8, 0x23, 0x65, 0xda, 0x03, 0xd2, 0xc3, 0xad, 0x65,
-	0xed, 0x7c, 0x53, 0xe6, 0xf5, 0x3c, 0x20, 0xbf, 0x66, 0xfc, 0x57, 0xbf, 0xfd, 0x1f, 0x00, 0x00,
-	0xff, 0xff, 0x4c, 0x61, 0x34, 0xb8, 0xf8, 0x03, 0x00, 0x00,
+	// 496 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xcd, 0x8e, 0xd3, 0x30,
+	0x10, 0x56, 0x9a, 0x9f, 0xaa, 0xa6, 0x95, 0x90, 0x85, 0x90, 0x0f, 0x45, 0x5b, 0x45, 0x7b, 0xd8,
+	0x53, 0x8b, 0x40, 0x3c, 0x00, 0x2c, 0x95, 0x5a, 0x95, 0x03, 0x75, 0xd1, 0xde, 0x4d, 0x3a, 0x1b,
+	0x22, 0xa8, 0x1d, 0x39, 0x2e, 0xa2, 0x3c, 0x01, 0x0f, 0xc4, 0x91, 0x27, 0xe0, 0xa9, 0xd0, 0x8c,
+	0x9d, 0x66, 0x83, 0x56, 0xda, 0xbd, 0x44, 0xf3, 0x7d, 0xf6, 0xcc, 0x7c, 0xdf, 0x8c, 0x15, 0x76,
+	0x51, 0xe9, 0xa6, 0x86, 0xc2, 0x19, 0xbb, 0xa8, 0xad, 0x71, 0x66, 0x51, 0x18, 0x0b, 0xf4, 0x99,
+	0x13, 0xce, 0x57, 0x2c, 0x5b, 0x81, 0xda, 0x83, 0xe5, 0x82, 0x0d, 0x77, 0x60, 0xbf, 0x57, 0x05,
+	0x88, 0x68, 0x16, 0x5d, 0x8d, 0x64, 0x0b, 0xf9, 0x53, 0x16, 0xaf, 0xaa, 0xbd, 0x18, 0xcc, 0xa2,
+	0xab, 0x54, 0x62, 0xc8, 0x39, 0x4b, 0x56, 0xa6, 0x71, 0x22, 0xa6, 0x8b, 0x14, 0xe7, 0x2f, 0x59,
+	0xb6, 0xb9, 0xf9, 0xa8, 0x2a, 0x8b, 0xf7, 0x37, 0x70, 0x0a, 0x55, 0x30, 0xe4, 0xcf, 0x58, 0x7a,
+	0xa3, 0xbe, 0x1d, 0x81, 0x6a, 0x8c, 0xa5, 0x07, 0xf9, 0xef, 0x88, 0x25, 0x6b, 0x7d, 0x6b, 0xf8,
+	0x45, 0x2b, 0x82, 0x72, 0x9e, 0xbc, 0x1a, 0xce, 0x3d, 0x94, 0xad, 0xb6, 0x29, 0x1b, 0x7d, 0xaa,
+	0x0e, 0xd0, 0x38, 0x75, 0xa8, 0xa9, 0xc6, 0x44, 0x76, 0x04, 0x56, 0xbf, 0x36, 0x47, 0xed, 0xe5,
+	0x4c, 0xa4, 0x07, 0xa8, 0x71, 0xe7, 0xa0, 0x16, 0x09, 0x91, 0x14, 0xf3, 0x4b, 0x36, 0x5a, 0xeb,
+	0x3d, 0xfc, 0xf8, 0x50, 0x35, 0x4e, 0xa4, 0xd4, 0x2b, 0x9b, 0x13, 0x23, 0xbb, 0x03, 0xfe, 0x82,
+	0xa5, 0x6b, 0x07, 0x87, 0x46, 0x64, 0xb3, 0x98, 0xd4, 0x78, 0x5f, 0xd2, 0xb3, 0xf9, 0x82, 0xa5,
+	0x74, 0xf7, 0x21, 0x9f, 0xa3, 0xd6, 0xe7, 0xdf, 0x88, 0xa5, 0xdb, 0x23, 0xd8, 0xd3, 0xa3, 0x8d,
+	0xbe, 0x83, 0xb2, 0xd2, 0x77, 0x8d, 0x12, 0x81, 0x2b, 0x42, 0xb0, 0xd4, 0xfb, 0x60, 0xb5, 0x85,
+	0x7c, 0x1a, 0x34, 0x89, 0xa4, 0x67, 0x2a, 0x08, 0x15, 0x6c, 0xb8, 0x81, 0x53, 0x30, 0x1d, 0xe3,
+	0x6a, 0x03, 0x3c, 0x0f, 0x29, 0xbb, 0x33, 0xa4, 0x29, 0x1b, 0xbd, 0x2d, 0x4b, 0x0b, 0xa5, 0x72,
+	0x20, 0x86, 0x64, 0xa4, 0x23, 0xf2, 0x5f, 0x11, 0xce, 0xf0, 0xd6, 0x48, 0xa5, 0x4b, 0x78, 0xd8,
+	0xd0, 0x79, 0x37, 0x83, 0xfb, 0x76, 0x13, 0xf7, 0xda, 0xa6, 0x4b, 0x6b, 0x8d, 0x3d, 0x5b, 0x20,
+	0x24, 0x3d, 0x89, 0x19, 0xef, 0x95, 0x53, 0xb4, 0xb4, 0xb1, 0xa4, 0x38, 0x7f, 0x13, 0x32, 0xb0,
+	0xc9, 0xd2, 0x5a, 0x6d, 0x48, 0xc4, 0x44, 0x7a, 0xc0, 0x9f, 0xb3, 0x6c, 0x69, 0xed, 0xa1, 0x29,
+	0xc3, 0x36, 0x02, 0xca, 0xbf, 0xb2, 0xb1, 0x84, 0xa6, 0x36, 0xba, 0x01, 0x5c, 0xe8, 0x63, 0x96,
+	0x12, 0x94, 0x0d, 0xee, 0x53, 0xd6, 0x7b, 0x9b, 0xf1, 0x7f, 0x6f, 0x33, 0xff, 0x13, 0xa1, 0x55,
+	0xe5, 0xf8, 0x25, 0x9b, 0xac, 0x75, 0xe3, 0x94, 0x2e, 0xc0, 0x0f, 0x04, 0x9b, 0x25, 0xb2, 0x4f,
+	0x62, 0x31, 0xd4, 0xd4, 0x8d, 0x2c, 0x91, 0x1d, 0x81, 0xa7, 0xd7, 0xaa, 0xf8, 0x02, 0xbb, 0xea,
+	0x27, 0x50, 0xab, 0x44, 0x76, 0x04, 0xfa, 0xdd, 0xfa, 0xc4, 0x84, 0x8e, 0x02, 0xc2, 0xe9, 0x6c,
+	0x29, 0x23, 0x25, 0xda, 0x03, 0xd4, 0x43, 0xa9, 0xab, 0xca, 0xf9, 0xa4, 0xcc, 0xeb, 0xe9, 0x91,
+	0x9f, 0x33, 0xfa, 0x4b, 0xbc, 0xfe, 0x37, 0x00, 0x97, 0x76, 0x50, 0x17, 0x48, 0x04, 0x00, 0x00,
 }
@@ -0,0 +1,568 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: inspector/proto/prometheus/remote.proto
+
+package prometheus
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type LabelMatcher_Type int32
+
+const (
+	LabelMatcher_EQ  LabelMatcher_Type = 0
+	LabelMatcher_NEQ LabelMatcher_Type = 1
+	LabelMatcher_RE  LabelMatcher_Type = 2
+	LabelMatcher_NRE LabelMatcher_Type = 3
+)
+
+var LabelMatcher_Type_name = map[int32]string{
+	0: "EQ",
+	1: "NEQ",
+	2: "RE",
+	3: "NRE",
+}
+var LabelMatcher_Type_value = map[string]int32{
+	"EQ":  0,
+	"NEQ": 1,
+	"RE":  2,
+	"NRE": 3,
+}
+
+func (x LabelMatcher_Type) String() string {
+	return proto.EnumName(LabelMatcher_Type_name, int32(x))
+}
+func (LabelMatcher_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{3, 0}
+}
+
+type Sample struct {
+	Value                float64  `protobuf:"fixed64,1,opt,name=Value,proto3" json:"Value,omitempty"`
+	Timestamp            int64    `protobuf:"varint,2,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Sample) Reset()         { *m = Sample{} }
+func (m *Sample) String() string { return proto.CompactTextString(m) }
+func (*Sample) ProtoMessage()    {}
+func (*Sample) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{0}
+}
+func (m *Sample) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Sample.Unmarshal(m, b)
+}
+func (m *Sample) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Sample.Marshal(b, m, deterministic)
+}
+func (dst *Sample) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Sample.Merge(dst, src)
+}
+func (m *Sample) XXX_Size() int {
+	return xxx_messageInfo_Sample.Size(m)
+}
+func (m *Sample) XXX_DiscardUnknown() {
+	xxx_messageInfo_Sample.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Sample proto.InternalMessageInfo
+
+func (m *Sample) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *Sample) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type TimeSeries struct {
+	Labels               []*Label  `protobuf:"bytes,1,rep,name=Labels,proto3" json:"Labels,omitempty"`
+	Samples              []*Sample `protobuf:"bytes,2,rep,name=Samples,proto3" json:"Samples,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *TimeSeries) Reset()         { *m = TimeSeries{} }
+func (m *TimeSeries) String() string { return proto.CompactTextString(m) }
+func (*TimeSeries) ProtoMessage()    {}
+func (*TimeSeries) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{1}
+}
+func (m *TimeSeries) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TimeSeries.Unmarshal(m, b)
+}
+func (m *TimeSeries) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TimeSeries.Marshal(b, m, deterministic)
+}
+func (dst *TimeSeries) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TimeSeries.Merge(dst, src)
+}
+func (m *TimeSeries) XXX_Size() int {
+	return xxx_messageInfo_TimeSeries.Size(m)
+}
+func (m *TimeSeries) XXX_DiscardUnknown() {
+	xxx_messageInfo_TimeSeries.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TimeSeries proto.InternalMessageInfo
+
+func (m *TimeSeries) GetLabels() []*Label {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetSamples() []*Sample {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+type Label struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Label) Reset()         { *m = Label{} }
+func (m *Label) String() string { return proto.CompactTextString(m) }
+func (*Label) ProtoMessage()    {}
+func (*Label) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{2}
+}
+func (m *Label) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Label.Unmarshal(m, b)
+}
+func (m *Label) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Label.Marshal(b, m, deterministic)
+}
+func (dst *Label) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Label.Merge(dst, src)
+}
+func (m *Label) XXX_Size() int {
+	return xxx_messageInfo_Label.Size(m)
+}
+func (m *Label) XXX_DiscardUnknown() {
+	xxx_messageInfo_Label.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Label proto.InternalMessageInfo
+
+func (m *Label) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Label) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type LabelMatcher struct {
+	Type                 LabelMatcher_Type `protobuf:"varint,1,opt,name=Type,proto3,enum=prometheus.LabelMatcher_Type" json:"Type,omitempty"`
+	Name                 string            `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	Value                string            `protobuf:"bytes,3,opt,name=Value,proto3" json:"Value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *LabelMatcher) Reset()         { *m = LabelMatcher{} }
+func (m *LabelMatcher) String() string { return proto.CompactTextString(m) }
+func (*LabelMatcher) ProtoMessage()    {}
+func (*LabelMatcher) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{3}
+}
+func (m *LabelMatcher) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LabelMatcher.Unmarshal(m, b)
+}
+func (m *LabelMatcher) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LabelMatcher.Marshal(b, m, deterministic)
+}
+func (dst *LabelMatcher) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelMatcher.Merge(dst, src)
+}
+func (m *LabelMatcher) XXX_Size() int {
+	return xxx_messageInfo_LabelMatcher.Size(m)
+}
+func (m *LabelMatcher) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelMatcher.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelMatcher proto.InternalMessageInfo
+
+func (m *LabelMatcher) GetType() LabelMatcher_Type {
+	if m != nil {
+		return m.Type
+	}
+	return LabelMatcher_EQ
+}
+
+func (m *LabelMatcher) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *LabelMatcher) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type ReadHints struct {
+	StepMs               int64    `protobuf:"varint,1,opt,name=StepMs,proto3" json:"StepMs,omitempty"`
+	Func                 string   `protobuf:"bytes,2,opt,name=Func,proto3" json:"Func,omitempty"`
+	StartMs              int64    `protobuf:"varint,3,opt,name=StartMs,proto3" json:"StartMs,omitempty"`
+	EndMs                int64    `protobuf:"varint,4,opt,name=EndMs,proto3" json:"EndMs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReadHints) Reset()         { *m = ReadHints{} }
+func (m *ReadHints) String() string { return proto.CompactTextString(m) }
+func (*ReadHints) ProtoMessage()    {}
+func (*ReadHints) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{4}
+}
+func (m *ReadHints) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReadHints.Unmarshal(m, b)
+}
+func (m *ReadHints) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReadHints.Marshal(b, m, deterministic)
+}
+func (dst *ReadHints) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReadHints.Merge(dst, src)
+}
+func (m *ReadHints) XXX_Size() int {
+	return xxx_messageInfo_ReadHints.Size(m)
+}
+func (m *ReadHints) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReadHints.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReadHints proto.InternalMessageInfo
+
+func (m *ReadHints) GetStepMs() int64 {
+	if m != nil {
+		return m.StepMs
+	}
+	return 0
+}
+
+func (m *ReadHints) GetFunc() string {
+	if m != nil {
+		return m.Func
+	}
+	return ""
+}
+
+func (m *ReadHints) GetStartMs() int64 {
+	if m != nil {
+		return m.StartMs
+	}
+	return 0
+}
+
+func (m *ReadHints) GetEndMs() int64 {
+	if m != nil {
+		return m.EndMs
+	}
+	return 0
+}
+
+type WriteRequest struct {
+	Timeseries           []*TimeSeries `protobuf:"bytes,1,rep,name=Timeseries,proto3" json:"Timeseries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
+func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteRequest) ProtoMessage()    {}
+func (*WriteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{5}
+}
+func (m *WriteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteRequest.Unmarshal(m, b)
+}
+func (m *WriteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteRequest.Marshal(b, m, deterministic)
+}
+func (dst *WriteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteRequest.Merge(dst, src)
+}
+func (m *WriteRequest) XXX_Size() int {
+	return xxx_messageInfo_WriteRequest.Size(m)
+}
+func (m *WriteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteRequest proto.InternalMessageInfo
+
+func (m *WriteRequest) GetTimeseries() []*TimeSeries {
+	if m != nil {
+		return m.Timeseries
+	}
+	return nil
+}
+
+type ReadRequest struct {
+	Queries              []*Query `protobuf:"bytes,1,rep,name=Queries,proto3" json:"Queries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReadRequest) Reset()         { *m = ReadRequest{} }
+func (m *ReadRequest) String() string { return proto.CompactTextString(m) }
+func (*ReadRequest) ProtoMessage()    {}
+func (*ReadRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{6}
+}
+func (m *ReadRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReadRequest.Unmarshal(m, b)
+}
+func (m *ReadRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReadRequest.Marshal(b, m, deterministic)
+}
+func (dst *ReadRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReadRequest.Merge(dst, src)
+}
+func (m *ReadRequest) XXX_Size() int {
+	return xxx_messageInfo_ReadRequest.Size(m)
+}
+func (m *ReadRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReadRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReadRequest proto.InternalMessageInfo
+
+func (m *ReadRequest) GetQueries() []*Query {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+type ReadResponse struct {
+	Results              []*QueryResult `protobuf:"bytes,1,rep,name=Results,proto3" json:"Results,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ReadResponse) Reset()         { *m = ReadResponse{} }
+func (m *ReadResponse) String() string { return proto.CompactTextString(m) }
+func (*ReadResponse) ProtoMessage()    {}
+func (*ReadResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{7}
+}
+func (m *ReadResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReadResponse.Unmarshal(m, b)
+}
+func (m *ReadResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReadResponse.Marshal(b, m, deterministic)
+}
+func (dst *ReadResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReadResponse.Merge(dst, src)
+}
+func (m *ReadResponse) XXX_Size() int {
+	return xxx_messageInfo_ReadResponse.Size(m)
+}
+func (m *ReadResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReadResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReadResponse proto.InternalMessageInfo
+
+func (m *ReadResponse) GetResults() []*QueryResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type Query struct {
+	StartTimestampMs     int64           `protobuf:"varint,1,opt,name=StartTimestampMs,proto3" json:"StartTimestampMs,omitempty"`
+	EndTimestampMs       int64           `protobuf:"varint,2,opt,name=EndTimestampMs,proto3" json:"EndTimestampMs,omitempty"`
+	Matchers             []*LabelMatcher `protobuf:"bytes,3,rep,name=Matchers,proto3" json:"Matchers,omitempty"`
+	Hints                *ReadHints      `protobuf:"bytes,4,opt,name=Hints,proto3" json:"Hints,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *Query) Reset()         { *m = Query{} }
+func (m *Query) String() string { return proto.CompactTextString(m) }
+func (*Query) ProtoMessage()    {}
+func (*Query) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{8}
+}
+func (m *Query) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Query.Unmarshal(m, b)
+}
+func (m *Query) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Query.Marshal(b, m, deterministic)
+}
+func (dst *Query) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Query.Merge(dst, src)
+}
+func (m *Query) XXX_Size() int {
+	return xxx_messageInfo_Query.Size(m)
+}
+func (m *Query) XXX_DiscardUnknown() {
+	xxx_messageInfo_Query.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Query proto.InternalMessageInfo
+
+func (m *Query) GetStartTimestampMs() int64 {
+	if m != nil {
+		return m.StartTimestampMs
+	}
+	return 0
+}
+
+func (m *Query) GetEndTimestampMs() int64 {
+	if m != nil {
+		return m.EndTimestampMs
+	}
+	return 0
+}
+
+func (m *Query) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+func (m *Query) GetHints() *ReadHints {
+	if m != nil {
+		return m.Hints
+	}
+	return nil
+}
+
+type QueryResult struct {
+	Timeseries           []*TimeSeries `protobuf:"bytes,1,rep,name=Timeseries,proto3" json:"Timeseries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *QueryResult) Reset()         { *m = QueryResult{} }
+func (m *QueryResult) String() string { return proto.CompactTextString(m) }
+func (*QueryResult) ProtoMessage()    {}
+func (*QueryResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remote_908c3fbc065dbb7c, []int{9}
+}
+func (m *QueryResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_QueryResult.Unmarshal(m, b)
+}
+func (m *QueryResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_QueryResult.Marshal(b, m, deterministic)
+}
+func (dst *QueryResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryResult.Merge(dst, src)
+}
+func (m *QueryResult) XXX_Size() int {
+	return xxx_messageInfo_QueryResult.Size(m)
+}
+func (m *QueryResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryResult proto.InternalMessageInfo
+
+func (m *QueryResult) GetTimeseries() []*TimeSeries {
+	if m != nil {
+		return m.Timeseries
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Sample)(nil), "prometheus.Sample")
+	proto.RegisterType((*TimeSeries)(nil), "prometheus.TimeSeries")
+	proto.RegisterType((*Label)(nil), "prometheus.Label")
+	proto.RegisterType((*LabelMatcher)(nil), "prometheus.LabelMatcher")
+	proto.RegisterType((*ReadHints)(nil), "prometheus.ReadHints")
+	proto.RegisterType((*WriteRequest)(nil), "prometheus.WriteRequest")
+	proto.RegisterType((*ReadRequest)(nil), "prometheus.ReadRequest")
+	proto.RegisterType((*ReadResponse)(nil), "prometheus.ReadResponse")
+	proto.RegisterType((*Query)(nil), "prometheus.Query")
+	proto.RegisterType((*QueryResult)(nil), "prometheus.QueryResult")
+	proto.RegisterEnum("prometheus.LabelMatcher_Type", LabelMatcher_Type_name, LabelMatcher_Type_value)
+}
+
+func init() {
+	proto.RegisterFile("inspector/proto/prometheus/remote.proto", fileDescriptor_remote_908c3fbc065dbb7c)
+}
+
+var fileDescriptor_remote_908c3fbc065dbb7c = []byte{
+	// 471 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x53, 0xcd, 0x8e, 0xd3, 0x4c,
+	0x10, 0xfc, 0x6c, 0x27, 0xf6, 0x97, 0x4e, 0xb4, 0x32, 0x2d, 0x58, 0x7c, 0x00, 0x29, 0x9a, 0x03,
+	0x04, 0x16, 0x65, 0x95, 0x80, 0x38, 0x20, 0x2e, 0x1c, 0xbc, 0xe2, 0x40, 0x56, 0xca, 0x64, 0x05,
+	0x67, 0x6f, 0xd2, 0x62, 0x2d, 0xc5, 0x3f, 0x78, 0xc6, 0x87, 0x7d, 0x10, 0x5e, 0x87, 0x67, 0x43,
+	0xd3, 0xe3, 0x3f, 0x08, 0x5c, 0xb8, 0x44, 0xee, 0xea, 0xea, 0xa9, 0x9a, 0xae, 0x09, 0x3c, 0x4f,
+	0x73, 0x55, 0xd2, 0x5e, 0x17, 0xd5, 0x65, 0x59, 0x15, 0xba, 0x30, 0xbf, 0x19, 0xe9, 0x3b, 0xaa,
+	0xd5, 0x65, 0x45, 0x59, 0xa1, 0x69, 0xc9, 0x38, 0x42, 0xdf, 0x10, 0xef, 0xc1, 0xdf, 0x25, 0x59,
+	0x79, 0x24, 0x7c, 0x08, 0xe3, 0xcf, 0xc9, 0xb1, 0xa6, 0xc8, 0x99, 0x3b, 0x0b, 0x47, 0xda, 0x02,
+	0x9f, 0xc0, 0xe4, 0x26, 0xcd, 0x48, 0xe9, 0x24, 0x2b, 0x23, 0x77, 0xee, 0x2c, 0x3c, 0xd9, 0x03,
+	0x82, 0x00, 0x4c, 0xb1, 0xa3, 0x2a, 0x25, 0x85, 0x2f, 0xc0, 0xff, 0x94, 0xdc, 0xd2, 0x51, 0x45,
+	0xce, 0xdc, 0x5b, 0x4c, 0xd7, 0x0f, 0x96, 0xbd, 0xd0, 0x92, 0x3b, 0xb2, 0x21, 0xe0, 0x2b, 0x08,
+	0xac, 0xac, 0x8a, 0x5c, 0xe6, 0xe2, 0x90, 0x6b, 0x5b, 0xb2, 0xa5, 0x88, 0x15, 0x8c, 0x79, 0x0e,
+	0x11, 0x46, 0xd7, 0x49, 0x66, 0x2d, 0x4e, 0x24, 0x7f, 0xf7, 0xbe, 0x5d, 0x06, 0x6d, 0x21, 0xbe,
+	0x3b, 0x30, 0xe3, 0x99, 0x4d, 0xa2, 0xf7, 0x77, 0x54, 0xe1, 0x0a, 0x46, 0x37, 0xf7, 0xa5, 0x1d,
+	0x3d, 0x5b, 0x3f, 0x3d, 0xb1, 0xd6, 0xf0, 0x96, 0x86, 0x24, 0x99, 0xda, 0xa9, 0xb9, 0x7f, 0x52,
+	0xf3, 0x86, 0x6a, 0x0b, 0x7b, 0x38, 0xfa, 0xe0, 0xc6, 0xdb, 0xf0, 0x3f, 0x0c, 0xc0, 0xbb, 0x8e,
+	0xb7, 0xa1, 0x63, 0x00, 0x19, 0x87, 0x2e, 0x03, 0x32, 0x0e, 0x3d, 0xf1, 0x15, 0x26, 0x92, 0x92,
+	0xc3, 0xc7, 0x34, 0xd7, 0x0a, 0xcf, 0xc1, 0xdf, 0x69, 0x2a, 0x37, 0x8a, 0x5d, 0x79, 0xb2, 0xa9,
+	0x8c, 0xf0, 0x55, 0x9d, 0xef, 0x5b, 0x61, 0xf3, 0x8d, 0x11, 0x04, 0x3b, 0x9d, 0x54, 0x7a, 0xa3,
+	0x58, 0xda, 0x93, 0x6d, 0x69, 0x2c, 0xc5, 0xf9, 0x61, 0xa3, 0xa2, 0x11, 0xe3, 0xb6, 0x10, 0x57,
+	0x30, 0xfb, 0x52, 0xa5, 0x9a, 0x24, 0x7d, 0xab, 0x49, 0x69, 0x7c, 0x6b, 0xa3, 0x52, 0x1c, 0x55,
+	0x13, 0xd0, 0xf9, 0x70, 0x0b, 0x7d, 0x90, 0x72, 0xc0, 0x14, 0xef, 0x60, 0x6a, 0x0c, 0xb7, 0xc7,
+	0x5c, 0x40, 0xb0, 0xad, 0x87, 0x67, 0xfc, 0x12, 0xb2, 0x69, 0xdd, 0xcb, 0x96, 0x21, 0x3e, 0xc0,
+	0xcc, 0xce, 0xaa, 0xb2, 0xc8, 0x15, 0xe1, 0x0a, 0x02, 0x49, 0xaa, 0x3e, 0xea, 0x76, 0xf8, 0xf1,
+	0xe9, 0x30, 0xf7, 0x65, 0xcb, 0x13, 0x3f, 0x1c, 0x18, 0x73, 0x03, 0x5f, 0x42, 0xc8, 0x37, 0xee,
+	0x5e, 0x5f, 0xb7, 0xb6, 0x13, 0x1c, 0x9f, 0xc1, 0x59, 0x9c, 0x1f, 0x86, 0x4c, 0xfb, 0x74, 0x7f,
+	0x43, 0xf1, 0x0d, 0xfc, 0xdf, 0xe4, 0x6e, 0xb6, 0x6a, 0x1c, 0x45, 0x7f, 0x7b, 0x18, 0xb2, 0x63,
+	0xe2, 0x05, 0x8c, 0x39, 0x3f, 0x5e, 0xf8, 0x74, 0xfd, 0x68, 0x38, 0xd2, 0x85, 0x2b, 0x2d, 0x47,
+	0xc4, 0x30, 0x1d, 0x5c, 0xec, 0x5f, 0x63, 0xb8, 0xf5, 0xf9, 0xaf, 0xfb, 0xfa, 0xe7, 0x00, 0x18,
+	0x6c, 0x8a, 0x7b, 0xe5, 0x03, 0x00, 0x00,
+}
@@ -16,11 +16,10 @@ package configure
 
 import (
 	"inspector/cache"
-	"inspector/client"
 	"inspector/config"
-	"inspector/util/pool"
-
-	"github.com/vinllen/mgo"
+	"inspector/store_server/engine"
+	"inspector/store_server/journal"
+	"inspector/store_server/rollup"
 )
 
 // =====================================================================================
@@ -40,14 +39,20 @@ type StoreServerGlobalConfigure struct {
 	StoreServerUsername string
 	StoreServerPassword string
 	StoreServerDB       string
-	StorageClient       client.ClientInterface
 	StoreReadTimeout    int
 	StoreWriteTimeout   int
 
-	MongoQuerySession          *mgo.Session
 	MongoStoreSessionListCount int
-	MongoStoreSessionList      []*mgo.Session
-	MongoStorePool             *pool.GoroutinePool
+
+	StorageEngineName    string
+	StorageEngine        engine.Engine
+	LocalDataDir         string
+	LocalSegmentInterval int // second
+	DataRetention        int // hour, 0 means never delete
+
+	Rollup1mRetention int // hour, 0 means never delete, negative means disabled
+	Rollup1hRetention int // hour, 0 means never delete, negative means disabled
+	Rollup            *rollup.Rollup
 
 	ServicePort   int
 	MonitorPort   int
@@ -56,6 +61,10 @@ type StoreServerGlobalConfigure struct {
 	CacheConcurrence int
 	CacheDataReserve int
 	TimeCache        *cache.TimeCache
+
+	JournalDir        string // empty means disabled
+	JournalCheckpoint int    // second
+	Journal           *journal.Journal
 }
 
 var Options StoreServerGlobalConfigure
@@ -0,0 +1,288 @@
+/*
+// =====================================================================================
+//
+//       Filename:  engine.go
+//
+//    Description:  存储引擎的抽象，StoreService的Save与Query通过Engine读写持久化数据
+//                  mongo: 每个service一个collection，每个数据块一个文档
+//                  local: 内嵌的文件存储，数据块按照时间分段写入segment文件，
+//                         不依赖外部数据库，适合小规模部署
+//
+//        Version:  1.0
+//        Created:  10/20/2026 10:12:36 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"inspector/proto/core"
+)
+
+const (
+	MongoEngineName = "mongo"
+	LocalEngineName = "local"
+)
+
+// =====================================================================================
+//         Type:  Engine
+//  Description:  存储引擎，数据块为collector压缩后的每分钟数据，
+//                Timestamp与Query的时间均为虚拟时间(unix秒 / step)
+// =====================================================================================
+type Engine interface {
+	// 引擎名称
+	Name() string
+	// 保存数据块，同一批次中可以包含多个service，部分失败时返回*SaveError
+	Save(infoList []*core.Info) error
+	// 查询[begin, end]之间的数据块，KeyList为空时返回所有key，没有数据时返回nil
+	Query(query *core.Query, begin, end uint32) (*core.InfoRange, error)
+	// 删除service中before之前的数据块，service为空时删除所有service
+	Delete(service string, before time.Time) error
+	// 设置service数据的保留时间，0表示不删除。引擎不能自动删除时返回nil，由调用方定期Delete
+	SetRetention(service string, retention time.Duration) error
+	// 各个service的存储统计
+	Stats() (*Stats, error)
+	Close() error
+}
+
+// =====================================================================================
+//       Struct:  Conf
+//  Description:  创建引擎的参数，每种引擎只使用自己的参数
+// =====================================================================================
+type Conf struct {
+	// mongo
+	Address      string
+	Username     string
+	Password     string
+	DB           string
+	SessionCount int
+	ReadTimeout  int // second
+	WriteTimeout int // second
+
+	// local
+	Dir             string
+	SegmentInterval int // second
+}
+
+// =====================================================================================
+//       Struct:  Stats
+//  Description:  引擎的存储统计，时间为unix秒，引擎无法统计的字段为0
+// =====================================================================================
+type Stats struct {
+	Engine   string                   `json:"engine"`
+	Services map[string]*ServiceStats `json:"services"`
+}
+
+type ServiceStats struct {
+	Blocks   int64 `json:"blocks"`
+	Bytes    int64 `json:"bytes"`
+	Hosts    int   `json:"hosts,omitempty"`
+	Segments int   `json:"segments,omitempty"`
+	MinTime  int64 `json:"minTime,omitempty"`
+	MaxTime  int64 `json:"maxTime,omitempty"`
+}
+
+// =====================================================================================
+//       Struct:  SaveError
+//  Description:  Save部分失败时返回，ErrorMap为失败的数据块在输入中的下标 -> 错误，
+//                不在其中的数据块已经保存成功
+// =====================================================================================
+type SaveError struct {
+	ErrorMap map[int]error
+}
+
+func (e *SaveError) Error() string {
+	var first = -1
+	for i := range e.ErrorMap {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	if first < 0 {
+		return "no block failed"
+	}
+	return fmt.Sprintf("%d blocks failed, first error: %s", len(e.ErrorMap), e.ErrorMap[first].Error())
+}
+
+func (e *SaveError) add(index int, err error) {
+	if e.ErrorMap == nil {
+		e.ErrorMap = make(map[int]error)
+	}
+	e.ErrorMap[index] = err
+}
+
+// 没有失败时返回nil，避免返回值为nil的*SaveError
+func (e *SaveError) result() error {
+	if len(e.ErrorMap) == 0 {
+		return nil
+	}
+	return e
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  SaveErrorMap
+//  Description:  Save返回的错误中每个失败的数据块，不是*SaveError时count个数据块全部失败
+// =====================================================================================
+*/
+func SaveErrorMap(err error, count int) map[int]error {
+	if err == nil {
+		return nil
+	}
+	if saveErr, ok := err.(*SaveError); ok {
+		return saveErr.ErrorMap
+	}
+	var errorMap = make(map[int]error, count)
+	for i := 0; i < count; i++ {
+		errorMap[i] = err
+	}
+	return errorMap
+}
+
+// =====================================================================================
+//       Struct:  EngineFactory
+//  Description:
+// =====================================================================================
+type EngineFactory struct {
+	Name string
+}
+
+func (factory *EngineFactory) Create(conf *Conf) (Engine, error) {
+	switch factory.Name {
+	case MongoEngineName:
+		return NewMongoEngine(conf)
+	case LocalEngineName:
+		return NewLocalEngine(conf)
+	default:
+		return nil, fmt.Errorf("engine[%s] not supported", factory.Name)
+	}
+}
+
+type valueItem struct {
+	timestamp uint32
+	value     []byte
+}
+
+// =====================================================================================
+//       Struct:  RangeBuilder
+//  Description:  将按照时间顺序读出的数据块拼接为InfoRange，key按照第一次出现的顺序输出
+// =====================================================================================
+type RangeBuilder struct {
+	result   *core.InfoRange
+	keyList  []string
+	valueMap map[string][]valueItem
+}
+
+func NewRangeBuilder() *RangeBuilder {
+	return &RangeBuilder{valueMap: make(map[string][]valueItem)}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  SetHeader
+//  Description:  使用第一个数据块的header、count与step
+// =====================================================================================
+*/
+func (b *RangeBuilder) SetHeader(service string, hid int32, host string, count, step uint32) {
+	if b.result != nil {
+		return
+	}
+	b.result = &core.InfoRange{
+		Header: &core.Header{
+			Service: service,
+			Hid:     hid,
+			Host:    host,
+		},
+		Count: count,
+		Step:  step,
+	}
+}
+
+func (b *RangeBuilder) Add(key string, timestamp uint32, value []byte) {
+	var list, ok = b.valueMap[key]
+	if !ok {
+		b.keyList = append(b.keyList, key)
+	}
+	b.valueMap[key] = append(list, valueItem{timestamp: timestamp, value: value})
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Build
+//  Description:  Data的格式：key个数，每个key：key长度、key、数据块个数，
+//                每个数据块：timestamp、长度、压缩后的数据，均为大端uint32
+//                没有数据块时返回nil
+// =====================================================================================
+*/
+func (b *RangeBuilder) Build() *core.InfoRange {
+	if b.result == nil {
+		return nil
+	}
+
+	bytesBuffer := bytes.NewBuffer([]byte{})
+	// write key list size
+	binary.Write(bytesBuffer, binary.BigEndian, uint32(len(b.keyList)))
+	for _, k := range b.keyList {
+		var v = b.valueMap[k]
+		// write key size
+		binary.Write(bytesBuffer, binary.BigEndian, uint32(len(k)))
+		// write key
+		bytesBuffer.WriteString(k)
+		// write data size
+		binary.Write(bytesBuffer, binary.BigEndian, uint32(len(v)))
+		for _, it := range v {
+			binary.Write(bytesBuffer, binary.BigEndian, it.timestamp)
+			binary.Write(bytesBuffer, binary.BigEndian, uint32(len(it.value)))
+			bytesBuffer.Write(it.value)
+		}
+	}
+	b.result.Data = bytesBuffer.Bytes()
+	return b.result
+}
+
+// =====================================================================================
+//       Struct:  Block
+//  Description:  InfoRange.Data中的一个数据块
+// =====================================================================================
+type Block struct {
+	Timestamp uint32
+	Value     []byte
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ParseRangeData
+//  Description:  解析Build生成的Data，返回key列表(按照Data中的顺序)以及每个key的数据块
+//                Value引用data中的内容，不做拷贝
+// =====================================================================================
+*/
+func ParseRangeData(data []byte) ([]string, map[string][]Block, error) {
+	var keyList []string
+	var blockMap = make(map[string][]Block)
+	var r = &recordReader{buf: data}
+	var n = r.uint32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		var key = string(r.next(int(r.uint32())))
+		var count = r.uint32()
+		for j := uint32(0); j < count && r.err == nil; j++ {
+			var timestamp = r.uint32()
+			var value = r.next(int(r.uint32()))
+			blockMap[key] = append(blockMap[key], Block{Timestamp: timestamp, Value: value})
+		}
+		keyList = append(keyList, key)
+	}
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("parse range data error: %s", r.err.Error())
+	}
+	if r.pos != len(data) {
+		return nil, nil, fmt.Errorf("parse range data error: %d bytes left", len(data)-r.pos)
+	}
+	return keyList, blockMap, nil
+}
@@ -0,0 +1,747 @@
+/*
+// =====================================================================================
+//
+//       Filename:  localEngine.go
+//
+//    Description:  内嵌的文件存储引擎，不依赖外部数据库
+//                  目录结构：<dir>/<service>/<segment开始时间>.seg，
+//                  每个segment保存开始时间在[start, start+interval)内的数据块，按照写入顺序追加，
+//                  每条记录：长度(uint32)、crc32(uint32)、记录内容，记录内容为：
+//                  hid(int32)、timestamp、count、step(uint32)、host长度(uint16)、host、
+//                  item个数(uint32)，每个item：key长度(uint16)、key、value长度(uint32)、value
+//                  启动时扫描所有segment在内存中建立每个host的索引，末尾不完整的记录会被截断
+//
+//        Version:  1.0
+//        Created:  10/20/2026 02:25:44 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"inspector/proto/core"
+
+	"github.com/golang/glog"
+)
+
+const (
+	segmentSuffix          = ".seg"
+	recordHeaderSize       = 8
+	defaultSegmentInterval = 3600 // second
+)
+
+// =====================================================================================
+//       Struct:  hostKey
+//  Description:  segment内索引的key
+// =====================================================================================
+type hostKey struct {
+	hid  int32
+	host string
+}
+
+// =====================================================================================
+//       Struct:  blockRef
+//  Description:  数据块在segment文件中的位置，offset指向记录头
+// =====================================================================================
+type blockRef struct {
+	timestamp uint32
+	offset    int64
+	length    uint32
+}
+
+// =====================================================================================
+//       Struct:  segment
+//  Description:  一个segment文件，index中每个host的数据块按照timestamp排序，
+//                同一个timestamp重复写入时以最后一次为准
+// =====================================================================================
+type segment struct {
+	start   int64 // unix秒，按照interval对齐
+	path    string
+	file    *os.File // 最近的segment保持打开用于追加，nil表示已经关闭
+	size    int64
+	blocks  int64
+	index   map[hostKey][]blockRef
+	minTime int64 // 数据块开始时间的范围，unix秒
+	maxTime int64
+}
+
+// =====================================================================================
+//       Struct:  localService
+//  Description:  step为最近写入的数据块的step，用于将查询的虚拟时间转换为segment的时间
+// =====================================================================================
+type localService struct {
+	step        uint32
+	segmentList []*segment // 按照start排序
+}
+
+// =====================================================================================
+//       Struct:  LocalEngine
+//  Description:  写入时持有写锁，查询只读取文件，持有读锁
+// =====================================================================================
+type LocalEngine struct {
+	dir        string
+	interval   int64
+	lock       sync.RWMutex
+	serviceMap map[string]*localService
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  NewLocalEngine
+//  Description:  创建数据目录并加载已有的segment
+// =====================================================================================
+*/
+func NewLocalEngine(conf *Conf) (*LocalEngine, error) {
+	glog.V(1).Infoln("[Trace][NewLocalEngine] start")
+
+	if len(conf.Dir) == 0 {
+		return nil, errors.New("data directory of local engine is not configured")
+	}
+	var e = &LocalEngine{
+		dir:        conf.Dir,
+		interval:   int64(conf.SegmentInterval),
+		serviceMap: make(map[string]*localService),
+	}
+	if e.interval <= 0 {
+		e.interval = defaultSegmentInterval
+	}
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+
+	glog.V(1).Infof("[Trace][NewLocalEngine] success: dir[%s] services[%d]", e.dir, len(e.serviceMap))
+	return e, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  load
+//  Description:  扫描数据目录，每个子目录为一个service，文件名不是segment的跳过
+// =====================================================================================
+*/
+func (e *LocalEngine) load() error {
+	var dirList, err = ioutil.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirList {
+		if !dir.IsDir() {
+			continue
+		}
+		var fileList []os.FileInfo
+		if fileList, err = ioutil.ReadDir(filepath.Join(e.dir, dir.Name())); err != nil {
+			return err
+		}
+		var svc = &localService{}
+		for _, file := range fileList {
+			var name = file.Name()
+			if file.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+				continue
+			}
+			var start int64
+			if start, err = strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64); err != nil {
+				glog.Warningf("skip segment[%s] of service[%s]: invalid name", name, dir.Name())
+				continue
+			}
+			var seg = newSegment(filepath.Join(e.dir, dir.Name(), name), start)
+			var step uint32
+			if step, err = seg.load(); err != nil {
+				return fmt.Errorf("load segment[%s] error: %s", seg.path, err.Error())
+			}
+			if step != 0 {
+				svc.step = step
+			}
+			svc.segmentList = append(svc.segmentList, seg)
+		}
+		sort.Slice(svc.segmentList, func(i, j int) bool {
+			return svc.segmentList[i].start < svc.segmentList[j].start
+		})
+		e.serviceMap[dir.Name()] = svc
+	}
+	return nil
+}
+
+func (e *LocalEngine) Name() string {
+	return LocalEngineName
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Save
+//  Description:  追加到数据块开始时间所在的segment，写完后sync，sync失败时
+//                写入该segment的数据块均视为失败。每个service只保持最近两个segment打开
+// =====================================================================================
+*/
+func (e *LocalEngine) Save(input []*core.Info) error {
+	glog.V(1).Infof("[Trace][LocalEngine.Save] called: info count[%d] ", len(input))
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var saveErr = new(SaveError)
+	var touched = make(map[*segment][]int)
+	var touchedService = make(map[string]*localService)
+	for i, it := range input {
+		var service = it.GetHeader().GetService()
+		if err := checkServiceName(service); err != nil {
+			saveErr.add(i, err)
+			continue
+		}
+		if it.Step == 0 {
+			saveErr.add(i, fmt.Errorf("step of service[%s] host[%s] is 0", service, it.GetHeader().GetHost()))
+			continue
+		}
+		var svc = e.getService(service, true)
+		var blockTime = int64(it.Timestamp) * int64(it.Step)
+		var seg, err = e.getSegment(service, svc, blockTime-blockTime%e.interval)
+		if err != nil {
+			saveErr.add(i, err)
+			continue
+		}
+		if err = seg.append(it); err != nil {
+			saveErr.add(i, fmt.Errorf("store service[%s] to segment[%s] error: %s", service, seg.path, err.Error()))
+			continue
+		}
+		svc.step = it.Step
+		touched[seg] = append(touched[seg], i)
+		touchedService[service] = svc
+	}
+
+	for seg, indexList := range touched {
+		if err := seg.file.Sync(); err != nil {
+			for _, i := range indexList {
+				saveErr.add(i, fmt.Errorf("sync segment[%s] error: %s", seg.path, err.Error()))
+			}
+		}
+	}
+	for _, svc := range touchedService {
+		e.closeIdle(svc)
+	}
+	return saveErr.result()
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Query
+//  Description:  按照service的step找到覆盖[begin, end]的segment，按照时间顺序读取数据块
+// =====================================================================================
+*/
+func (e *LocalEngine) Query(input *core.Query, begin, end uint32) (*core.InfoRange, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var service = input.GetHeader().GetService()
+	var svc = e.getService(service, false)
+	if svc == nil || svc.step == 0 || begin > end {
+		return nil, nil
+	}
+	var key = hostKey{hid: input.Header.Hid, host: input.Header.Host}
+	var keySet map[string]bool
+	if len(input.KeyList) != 0 {
+		keySet = make(map[string]bool, len(input.KeyList))
+		for _, it := range input.KeyList {
+			keySet[it] = true
+		}
+	}
+
+	var builder = NewRangeBuilder()
+	var timeBegin = int64(begin) * int64(svc.step)
+	var timeEnd = int64(end) * int64(svc.step)
+	for _, seg := range svc.segmentList {
+		if seg.start > timeEnd || seg.start+e.interval <= timeBegin {
+			continue
+		}
+		var refList = seg.index[key]
+		var i = sort.Search(len(refList), func(i int) bool { return refList[i].timestamp >= begin })
+		if i == len(refList) || refList[i].timestamp > end {
+			continue
+		}
+		if err := seg.read(refList[i:], end, func(body []byte) error {
+			return decodeRecord(body, service, builder, keySet)
+		}); err != nil {
+			return nil, fmt.Errorf("read segment[%s] error: %s", seg.path, err.Error())
+		}
+	}
+	return builder.Build(), nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  SetRetention
+//  Description:  本地文件没有自动过期，由调用方定期Delete
+// =====================================================================================
+*/
+func (e *LocalEngine) SetRetention(service string, retention time.Duration) error {
+	return checkServiceName(service)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Delete
+//  Description:  删除结束时间不晚于before的整个segment
+// =====================================================================================
+*/
+func (e *LocalEngine) Delete(service string, before time.Time) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var serviceList = []string{service}
+	if len(service) == 0 {
+		serviceList = serviceList[:0]
+		for it := range e.serviceMap {
+			serviceList = append(serviceList, it)
+		}
+	}
+	for _, name := range serviceList {
+		var svc = e.serviceMap[name]
+		if svc == nil {
+			continue
+		}
+		var remain = svc.segmentList[:0]
+		var removed int
+		for i, seg := range svc.segmentList {
+			if seg.start+e.interval > before.Unix() {
+				remain = append(remain, seg)
+				continue
+			}
+			if err := seg.remove(); err != nil {
+				// 保留没有删除的segment
+				remain = append(remain, svc.segmentList[i:]...)
+				svc.segmentList = remain
+				return fmt.Errorf("delete segment[%s] error: %s", seg.path, err.Error())
+			}
+			removed++
+		}
+		svc.segmentList = remain
+		glog.V(2).Infof("[Trace][LocalEngine.Delete] service[%s] before[%v]: %d segments removed", name, before, removed)
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Stats
+//  Description:
+// =====================================================================================
+*/
+func (e *LocalEngine) Stats() (*Stats, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var stats = &Stats{Engine: LocalEngineName, Services: make(map[string]*ServiceStats)}
+	for name, svc := range e.serviceMap {
+		var ss = &ServiceStats{Segments: len(svc.segmentList)}
+		var hostSet = make(map[hostKey]struct{})
+		for _, seg := range svc.segmentList {
+			ss.Blocks += seg.blocks
+			ss.Bytes += seg.size
+			for key := range seg.index {
+				hostSet[key] = struct{}{}
+			}
+			if seg.blocks == 0 {
+				continue
+			}
+			// 第一个有数据的segment
+			if ss.Blocks == seg.blocks || seg.minTime < ss.MinTime {
+				ss.MinTime = seg.minTime
+			}
+			if seg.maxTime > ss.MaxTime {
+				ss.MaxTime = seg.maxTime
+			}
+		}
+		ss.Hosts = len(hostSet)
+		stats.Services[name] = ss
+	}
+	return stats, nil
+}
+
+func (e *LocalEngine) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var err error
+	for _, svc := range e.serviceMap {
+		for _, seg := range svc.segmentList {
+			if closeErr := seg.close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return err
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  getService
+//  Description:  create为true时不存在则创建，调用者需要持有写锁
+// =====================================================================================
+*/
+func (e *LocalEngine) getService(service string, create bool) *localService {
+	var svc = e.serviceMap[service]
+	if svc == nil && create {
+		svc = &localService{}
+		e.serviceMap[service] = svc
+	}
+	return svc
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  getSegment
+//  Description:  找到开始时间为start的segment，不存在则创建
+// =====================================================================================
+*/
+func (e *LocalEngine) getSegment(service string, svc *localService, start int64) (*segment, error) {
+	var list = svc.segmentList
+	var i = sort.Search(len(list), func(i int) bool { return list[i].start >= start })
+	if i < len(list) && list[i].start == start {
+		return list[i], nil
+	}
+
+	var dir = filepath.Join(e.dir, service)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var seg = newSegment(filepath.Join(dir, strconv.FormatInt(start, 10)+segmentSuffix), start)
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = seg
+	svc.segmentList = list
+	return seg, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  closeIdle
+//  Description:  数据块基本按照时间顺序写入，只保持最近两个segment的文件打开
+// =====================================================================================
+*/
+func (e *LocalEngine) closeIdle(svc *localService) {
+	var n = len(svc.segmentList)
+	if n == 0 {
+		return
+	}
+	var last = svc.segmentList[n-1].start
+	for _, seg := range svc.segmentList[:n-1] {
+		if seg.start < last-e.interval {
+			if err := seg.close(); err != nil {
+				glog.Warningf("close segment[%s] error: %s", seg.path, err.Error())
+			}
+		}
+	}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  checkServiceName
+//  Description:  service名称作为目录名
+// =====================================================================================
+*/
+func checkServiceName(service string) error {
+	if len(service) == 0 || service == "." || service == ".." || strings.ContainsAny(service, "/\\") {
+		return fmt.Errorf("service[%s] is invalid", service)
+	}
+	return nil
+}
+
+func newSegment(path string, start int64) *segment {
+	return &segment{
+		start: start,
+		path:  path,
+		index: make(map[hostKey][]blockRef),
+	}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  load
+//  Description:  扫描segment文件建立索引，返回最后一个数据块的step
+//                长度越界或者crc不一致的记录及其之后的内容被截断
+// =====================================================================================
+*/
+func (seg *segment) load() (uint32, error) {
+	var data, err = ioutil.ReadFile(seg.path)
+	if err != nil {
+		return 0, err
+	}
+	var step uint32
+	var offset int64
+	for offset < int64(len(data)) {
+		var body, ok = checkRecord(data[offset:])
+		if !ok {
+			break
+		}
+		var r = &recordReader{buf: body}
+		var hid, timestamp, _, blockStep, host = r.header()
+		if r.err != nil {
+			break
+		}
+		seg.addRef(hostKey{hid: hid, host: host}, blockRef{
+			timestamp: timestamp,
+			offset:    offset,
+			length:    uint32(recordHeaderSize + len(body)),
+		}, int64(timestamp)*int64(blockStep))
+		step = blockStep
+		offset += int64(recordHeaderSize + len(body))
+	}
+	if offset < int64(len(data)) {
+		glog.Warningf("segment[%s] is truncated from %d to %d", seg.path, len(data), offset)
+		if err = os.Truncate(seg.path, offset); err != nil {
+			return 0, err
+		}
+	}
+	seg.size = offset
+	return step, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  addRef
+//  Description:  按照timestamp插入索引，timestamp相同时覆盖
+// =====================================================================================
+*/
+func (seg *segment) addRef(key hostKey, ref blockRef, blockTime int64) {
+	var list = seg.index[key]
+	var i = sort.Search(len(list), func(i int) bool { return list[i].timestamp >= ref.timestamp })
+	if i < len(list) && list[i].timestamp == ref.timestamp {
+		list[i] = ref
+		return
+	}
+	list = append(list, blockRef{})
+	copy(list[i+1:], list[i:])
+	list[i] = ref
+	seg.index[key] = list
+
+	if seg.blocks == 0 || blockTime < seg.minTime {
+		seg.minTime = blockTime
+	}
+	if blockTime > seg.maxTime {
+		seg.maxTime = blockTime
+	}
+	seg.blocks++
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  append
+//  Description:  写入失败时截断到写入前的位置
+// =====================================================================================
+*/
+func (seg *segment) append(info *core.Info) error {
+	if seg.file == nil {
+		var file, err = os.OpenFile(seg.path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		seg.file = file
+	}
+
+	var record = encodeRecord(info)
+	if _, err := seg.file.WriteAt(record, seg.size); err != nil {
+		seg.file.Truncate(seg.size)
+		return err
+	}
+	seg.addRef(hostKey{hid: info.Header.Hid, host: info.Header.Host}, blockRef{
+		timestamp: info.Timestamp,
+		offset:    seg.size,
+		length:    uint32(len(record)),
+	}, int64(info.Timestamp)*int64(info.Step))
+	seg.size += int64(len(record))
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  read
+//  Description:  依次读取refList中timestamp不大于end的记录，文件已经关闭时临时打开
+// =====================================================================================
+*/
+func (seg *segment) read(refList []blockRef, end uint32, handler func(body []byte) error) error {
+	var file = seg.file
+	if file == nil {
+		var err error
+		if file, err = os.Open(seg.path); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	for _, ref := range refList {
+		if ref.timestamp > end {
+			break
+		}
+		// handler会引用记录中的数据，每条记录单独分配
+		var buf = make([]byte, ref.length)
+		if _, err := file.ReadAt(buf, ref.offset); err != nil {
+			return err
+		}
+		var body, ok = checkRecord(buf)
+		if !ok {
+			return fmt.Errorf("record at offset[%d] is corrupted", ref.offset)
+		}
+		if err := handler(body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (seg *segment) close() error {
+	if seg.file == nil {
+		return nil
+	}
+	var err = seg.file.Close()
+	seg.file = nil
+	return err
+}
+
+func (seg *segment) remove() error {
+	seg.close()
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  encodeRecord
+//  Description:  记录头与记录内容
+// =====================================================================================
+*/
+func encodeRecord(info *core.Info) []byte {
+	bytesBuffer := bytes.NewBuffer(make([]byte, recordHeaderSize))
+	binary.Write(bytesBuffer, binary.BigEndian, info.Header.Hid)
+	binary.Write(bytesBuffer, binary.BigEndian, info.Timestamp)
+	binary.Write(bytesBuffer, binary.BigEndian, info.Count)
+	binary.Write(bytesBuffer, binary.BigEndian, info.Step)
+	binary.Write(bytesBuffer, binary.BigEndian, uint16(len(info.Header.Host)))
+	bytesBuffer.WriteString(info.Header.Host)
+	binary.Write(bytesBuffer, binary.BigEndian, uint32(len(info.Items)))
+	for _, it := range info.Items {
+		binary.Write(bytesBuffer, binary.BigEndian, uint16(len(it.Key)))
+		bytesBuffer.WriteString(it.Key)
+		binary.Write(bytesBuffer, binary.BigEndian, uint32(len(it.Value)))
+		bytesBuffer.Write(it.Value)
+	}
+
+	var record = bytesBuffer.Bytes()
+	var body = record[recordHeaderSize:]
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(body))
+	return record
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  checkRecord
+//  Description:  检查data开头的记录是否完整，返回记录内容
+// =====================================================================================
+*/
+func checkRecord(data []byte) ([]byte, bool) {
+	if len(data) < recordHeaderSize {
+		return nil, false
+	}
+	var length = binary.BigEndian.Uint32(data[0:4])
+	if uint64(len(data)-recordHeaderSize) < uint64(length) {
+		return nil, false
+	}
+	var body = data[recordHeaderSize : recordHeaderSize+int(length)]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, false
+	}
+	return body, true
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  decodeRecord
+//  Description:  将记录加入builder，keySet为nil时加入所有key
+// =====================================================================================
+*/
+func decodeRecord(body []byte, service string, builder *RangeBuilder, keySet map[string]bool) error {
+	var r = &recordReader{buf: body}
+	var hid, timestamp, count, step, host = r.header()
+	var n = r.uint32()
+	if r.err != nil {
+		return r.err
+	}
+	builder.SetHeader(service, hid, host, count, step)
+	for i := uint32(0); i < n; i++ {
+		var key = string(r.next(int(r.uint16())))
+		var value = r.next(int(r.uint32()))
+		if r.err != nil {
+			return r.err
+		}
+		if keySet == nil || keySet[key] {
+			builder.Add(key, timestamp, value)
+		}
+	}
+	return nil
+}
+
+// =====================================================================================
+//       Struct:  recordReader
+//  Description:  按照大端读取记录内容，越界后err不为空，之后的读取均返回零值
+// =====================================================================================
+type recordReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *recordReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	var data = r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return data
+}
+
+func (r *recordReader) uint16() uint16 {
+	if data := r.next(2); data != nil {
+		return binary.BigEndian.Uint16(data)
+	}
+	return 0
+}
+
+func (r *recordReader) uint32() uint32 {
+	if data := r.next(4); data != nil {
+		return binary.BigEndian.Uint32(data)
+	}
+	return 0
+}
+
+func (r *recordReader) header() (hid int32, timestamp, count, step uint32, host string) {
+	hid = int32(r.uint32())
+	timestamp = r.uint32()
+	count = r.uint32()
+	step = r.uint32()
+	host = string(r.next(int(r.uint16())))
+	return
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"inspector/proto/core"
+)
+
+// 解析InfoRange.Data，返回key -> "timestamp:value"列表
+func parseRangeData(data []byte) map[string][]string {
+	var _, blockMap, err = ParseRangeData(data)
+	if err != nil {
+		return nil
+	}
+	var result = make(map[string][]string)
+	for key, blockList := range blockMap {
+		for _, it := range blockList {
+			result[key] = append(result[key], fmt.Sprintf("%d:%s", it.Timestamp, it.Value))
+		}
+	}
+	return result
+}
+
+func newTestInfo(service string, hid int32, host string, timestamp uint32, items ...string) *core.Info {
+	var info = &core.Info{
+		Header:    &core.Header{Service: service, Hid: hid, Host: host},
+		Timestamp: timestamp,
+		Count:     60,
+		Step:      1,
+	}
+	for i := 0; i+1 < len(items); i += 2 {
+		info.Items = append(info.Items, &core.KVPair{Key: items[i], Value: []byte(items[i+1])})
+	}
+	return info
+}
+
+func TestLocalEngine(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	var dir, err = ioutil.TempDir("", "local_engine")
+	check(err == nil, "create temp dir")
+	defer os.RemoveAll(dir)
+
+	var conf = &Conf{Dir: dir, SegmentInterval: 120}
+	var e Engine
+	e, err = (&EngineFactory{Name: LocalEngineName}).Create(conf)
+	check(err == nil, "create local engine")
+	check(e.Name() == LocalEngineName, "engine name")
+
+	// 每个数据块60秒，每个segment两个数据块
+	err = e.Save([]*core.Info{
+		newTestInfo("redis", 1, "h1", 0, "a", "a0", "b", "b0"),
+		newTestInfo("redis", 2, "h2", 0, "a", "x0"),
+		newTestInfo("redis", 1, "h1", 60, "a", "a60", "b", "b60"),
+		newTestInfo("redis", 1, "h1", 180, "a", "a180", "b", "b180"),
+	})
+	check(err == nil, fmt.Sprintf("save error: %v", err))
+	// 乱序写入以及重复写入，以最后一次为准
+	err = e.Save([]*core.Info{
+		newTestInfo("redis", 1, "h1", 120, "a", "a120", "b", "b120"),
+		newTestInfo("redis", 1, "h1", 60, "a", "a60'", "b", "b60'"),
+	})
+	check(err == nil, fmt.Sprintf("save error: %v", err))
+	// 部分失败时返回每个失败的数据块
+	var zeroStep = newTestInfo("mysql", 1, "h1", 60, "a", "a60")
+	zeroStep.Step = 0
+	err = e.Save([]*core.Info{
+		newTestInfo("../x", 1, "h1", 0, "a", "a0"),
+		newTestInfo("mysql", 1, "h1", 0, "a", "a0"),
+		zeroStep,
+	})
+	var errorMap = SaveErrorMap(err, 3)
+	check(len(errorMap) == 2 && errorMap[0] != nil && errorMap[2] != nil, fmt.Sprintf("partial failure: %v", err))
+	check(len(SaveErrorMap(errors.New("timeout"), 3)) == 3 && SaveErrorMap(nil, 3) == nil, "save error map")
+
+	var query = &core.Query{Header: &core.Header{Service: "redis", Hid: 1, Host: "h1"}}
+	var checkQuery = func(e Engine, begin, end uint32, keyList []string, expect string) {
+		query.KeyList = keyList
+		var res, err = e.Query(query, begin, end)
+		check(err == nil, fmt.Sprintf("query error: %v", err))
+		if len(expect) == 0 {
+			check(res == nil, fmt.Sprintf("query [%d, %d] should be empty", begin, end))
+			return
+		}
+		check(res != nil, fmt.Sprintf("query [%d, %d] is empty", begin, end))
+		check(res.Header.Host == "h1" && res.Header.Hid == 1 && res.Count == 60 && res.Step == 1, "query header")
+		var data = fmt.Sprint(parseRangeData(res.Data))
+		check(data == expect, fmt.Sprintf("query [%d, %d] keys%v: %s != %s", begin, end, keyList, data, expect))
+	}
+	checkQuery(e, 0, 1000, nil, "map[a:[0:a0 60:a60' 120:a120 180:a180] b:[0:b0 60:b60' 120:b120 180:b180]]")
+	checkQuery(e, 60, 120, []string{"b"}, "map[b:[60:b60' 120:b120]]")
+	checkQuery(e, 61, 119, nil, "")
+	checkQuery(e, 1000, 2000, nil, "")
+	query.Header.Host = "h3"
+	checkQuery(e, 0, 1000, nil, "")
+	query.Header.Host = "h1"
+
+	var stats *Stats
+	stats, err = e.Stats()
+	check(err == nil, "stats")
+	var ss = stats.Services["redis"]
+	check(ss != nil && ss.Blocks == 5 && ss.Hosts == 2 && ss.Segments == 2, fmt.Sprintf("stats: %+v", ss))
+	check(ss.MinTime == 0 && ss.MaxTime == 180, fmt.Sprintf("stats time: %+v", ss))
+
+	// 重新打开后索引不变，末尾不完整的记录被截断
+	check(e.Close() == nil, "close")
+	var path = filepath.Join(dir, "redis", "120"+segmentSuffix)
+	var info, _ = os.Stat(path)
+	var file, _ = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	var record = encodeRecord(newTestInfo("redis", 1, "h1", 200, "a", "a200"))
+	file.Write(record[:len(record)-1])
+	file.Close()
+
+	e, err = NewLocalEngine(conf)
+	check(err == nil, fmt.Sprintf("reopen error: %v", err))
+	checkQuery(e, 0, 1000, []string{"a"}, "map[a:[0:a0 60:a60' 120:a120 180:a180]]")
+	var truncated, _ = os.Stat(path)
+	check(truncated.Size() == info.Size(), "segment should be truncated")
+
+	check(e.Save([]*core.Info{newTestInfo("redis", 1, "h1", 240, "a", "a240")}) == nil, "save after reopen")
+	checkQuery(e, 170, 1000, []string{"a"}, "map[a:[180:a180 240:a240]]")
+
+	// 只删除整个segment都在before之前的
+	check(e.Delete("", time.Unix(200, 0)) == nil, "delete")
+	checkQuery(e, 0, 1000, []string{"a"}, "map[a:[120:a120 180:a180 240:a240]]")
+	_, err = os.Stat(filepath.Join(dir, "redis", "0"+segmentSuffix))
+	check(os.IsNotExist(err), "segment should be removed")
+	check(e.Delete("redis", time.Unix(1000, 0)) == nil, "delete service")
+	checkQuery(e, 0, 1000, nil, "")
+	check(e.Close() == nil, "close")
+}
+
+func TestCheckRecord(t *testing.T) {
+	var record = encodeRecord(newTestInfo("redis", -5, "host", 7, "k", "v"))
+	var body, ok = checkRecord(record)
+	if !ok {
+		t.Fatal("record should be valid")
+	}
+	var r = &recordReader{buf: body}
+	var hid, timestamp, count, step, host = r.header()
+	if hid != -5 || timestamp != 7 || count != 60 || step != 1 || host != "host" || r.uint32() != 1 {
+		t.Fatalf("decode header error: %d %d %d %d %s", hid, timestamp, count, step, host)
+	}
+
+	var corrupted = bytes.NewBuffer(nil)
+	corrupted.Write(record)
+	corrupted.Bytes()[len(record)-1] ^= 0xff
+	if _, ok = checkRecord(corrupted.Bytes()); ok {
+		t.Fatal("crc should mismatch")
+	}
+	var huge = make([]byte, recordHeaderSize)
+	binary.BigEndian.PutUint32(huge, 1<<31)
+	if _, ok = checkRecord(huge); ok {
+		t.Fatal("length should be out of range")
+	}
+}
@@ -0,0 +1,415 @@
+/*
+// =====================================================================================
+//
+//       Filename:  mongoEngine.go
+//
+//    Description:  mongodb存储引擎，每个service一个collection，每个数据块一个文档：
+//                  i: hid(按位反转)，h: host，t: timestamp，c: "count:step"，
+//                  e: 数据块开始的时间，d: key -> 压缩后的数据
+//
+//        Version:  1.0
+//        Created:  10/20/2026 10:40:17 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"inspector/client"
+	"inspector/proto/core"
+	"inspector/util"
+	"inspector/util/pool"
+
+	"github.com/golang/glog"
+	"github.com/vinllen/mgo"
+	"github.com/vinllen/mgo/bson"
+)
+
+// =====================================================================================
+//       Struct:  MongoEngine
+//  Description:  写入使用多个session并发，查询使用单独的session
+// =====================================================================================
+type MongoEngine struct {
+	db               string
+	querySession     *mgo.Session
+	storeSessionList []*mgo.Session
+	storePool        *pool.GoroutinePool
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  NewMongoEngine
+//  Description:  建立写入的session列表、写入的goroutine pool以及查询的session
+// =====================================================================================
+*/
+func NewMongoEngine(conf *Conf) (*MongoEngine, error) {
+	glog.V(1).Infoln("[Trace][NewMongoEngine] start")
+
+	if conf.SessionCount <= 0 {
+		return nil, fmt.Errorf("mongo session count[%d] is invalid", conf.SessionCount)
+	}
+	var e = &MongoEngine{db: conf.DB}
+
+	// split connection string
+	var connList = strings.Split(conf.Address, ",")
+
+	// init store session list
+	for i := 0; i < conf.SessionCount; i++ {
+		session, err := e.connect(connList[i%len(connList)], conf, conf.WriteTimeout)
+		if err != nil {
+			return nil, err
+		}
+		e.storeSessionList = append(e.storeSessionList, session)
+	}
+	glog.V(1).Infoln("[Trace][NewMongoEngine] create store session list success")
+
+	// init store pool
+	e.storePool = new(pool.GoroutinePool)
+	e.storePool.Init()
+	go e.storePool.Run()
+	glog.V(1).Infoln("[Trace][NewMongoEngine] create store pool success")
+
+	// init query session
+	var err error
+	if e.querySession, err = e.connect(conf.Address, conf, conf.ReadTimeout); err != nil {
+		return nil, err
+	}
+	glog.V(1).Infoln("[Trace][NewMongoEngine] create query session success")
+
+	return e, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  connect
+//  Description:
+// =====================================================================================
+*/
+func (e *MongoEngine) connect(address string, conf *Conf, timeout int) (*mgo.Session, error) {
+	newClient, err := client.NewMongoClient().
+		ConnectString(address).
+		Username(conf.Username).
+		Password(conf.Password).
+		UseDB(conf.DB).
+		SetOpt(map[string]interface{}{
+			"SafeMode":        "majority",
+			"ConsistencyMode": "Monotonic",
+			"Timeout":         time.Duration(timeout) * time.Second,
+		}).
+		EstablishConnect()
+	if err != nil {
+		return nil, fmt.Errorf("new mongo client error: %s", err.Error())
+	}
+
+	session := newClient.GetSession()
+	if session == nil || reflect.ValueOf(session).IsNil() {
+		return nil, errors.New("mongo session is nil")
+	}
+	mongoSession, ok := session.(*mgo.Session)
+	if !ok {
+		return nil, errors.New("get mongo session error")
+	}
+	return mongoSession, nil
+}
+
+func (e *MongoEngine) Name() string {
+	return MongoEngineName
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Save
+//  Description:  按照service分组，每组拆分为多个批次通过pool并发写入，
+//                只返回写入失败的数据块
+// =====================================================================================
+*/
+func (e *MongoEngine) Save(input []*core.Info) error {
+	glog.V(1).Infof("[Trace][MongoEngine.Save] called: info count[%d] ", len(input))
+
+	type batchResult struct {
+		indexList []int         // 批次中的数据块在input中的下标
+		errorMap  map[int]error // 批次内的下标 -> 错误
+	}
+	// 无序写入，一个文档失败不影响批次中的其他文档，只有失败的文档需要重新发送
+	var doSendMongo = func(index int, service string, iflist []interface{}, indexList []int, resultChan chan *batchResult) func() {
+		return func() {
+			var bulk = e.storeSessionList[index].
+				DB(e.db).
+				C(service).
+				Bulk()
+			bulk.Unordered()
+			bulk.Insert(iflist...)
+			var result = &batchResult{indexList: indexList}
+			if _, err := bulk.Run(); err != nil {
+				glog.Errorf("store service[%s] to mongodb error: %s", service, err.Error())
+				result.errorMap = make(map[int]error)
+				var bulkErr, ok = err.(*mgo.BulkError)
+				if ok && len(bulkErr.Cases()) > 0 {
+					for _, it := range bulkErr.Cases() {
+						if it.Index < 0 || it.Index >= len(indexList) {
+							// 无法确定失败的文档，整个批次视为失败
+							ok = false
+							break
+						}
+						result.errorMap[it.Index] = it.Err
+					}
+				}
+				if len(result.errorMap) == 0 || !ok {
+					for i := range indexList {
+						result.errorMap[i] = err
+					}
+				}
+			}
+			resultChan <- result
+		}
+	}
+
+	var msgListMap map[string][]bson.M = make(map[string][]bson.M)
+	var indexListMap map[string][]int = make(map[string][]int)
+
+	for i, it := range input {
+		// check service
+		var service = it.GetHeader().GetService()
+
+		// new msg
+		var msg bson.M = bson.M{}
+
+		// build msg header
+		msg["i"] = util.Int32Reverse(it.Header.Hid)
+		msg["h"] = it.Header.Host
+		msg["t"] = it.Timestamp
+		msg["c"] = fmt.Sprintf("%d:%d", it.Count, it.Step)
+		msg["e"] = time.Unix(int64(it.Timestamp*it.Step), 0)
+
+		// build msg body
+		var body = make(map[string][]byte)
+		for _, it := range it.Items {
+			body[it.Key] = it.Value
+		}
+		msg["d"] = body
+
+		msgListMap[service] = append(msgListMap[service], msg)
+		indexListMap[service] = append(indexListMap[service], i)
+	}
+
+	// save to mongodb
+	var sessionCount = len(e.storeSessionList)
+	var resultChan = make(chan *batchResult, sessionCount*2)
+	var batchCount int = 0
+	var iflist = make([]interface{}, 0)
+	for service, msgList := range msgListMap {
+		var indexList = indexListMap[service]
+		var begin int
+		var batch int = len(msgList) / sessionCount
+		if batch < 10 { // 10是拍脑袋写的，给一个最小批次，为了避免batch太小导致模0错误
+			batch = 10
+		}
+		for i, it := range msgList {
+			iflist = append(iflist, it)
+			if i != 0 && i%batch == 0 {
+				e.storePool.AsyncRun(doSendMongo((i/batch-1)%sessionCount, service, iflist, indexList[begin:i+1], resultChan))
+				batchCount++
+				iflist = make([]interface{}, 0)
+				begin = i + 1
+			}
+		}
+		// send last batch
+		if len(iflist) != 0 {
+			e.storePool.AsyncRun(doSendMongo(0, service, iflist, indexList[begin:], resultChan))
+			batchCount++
+			iflist = make([]interface{}, 0)
+		}
+	}
+
+	// wait for all AsyncRun return
+	var saveErr = new(SaveError)
+	for i := 0; i < batchCount; i++ {
+		var result = <-resultChan
+		for i, err := range result.errorMap {
+			saveErr.add(result.indexList[i], err)
+		}
+	}
+
+	return saveErr.result()
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Query
+//  Description:
+// =====================================================================================
+*/
+func (e *MongoEngine) Query(input *core.Query, begin, end uint32) (*core.InfoRange, error) {
+	var condition = bson.M{
+		"i": util.Int32Reverse(input.Header.Hid),
+		"h": input.Header.Host,
+		"t": bson.M{
+			"$gte": begin,
+			"$lte": end,
+		},
+	}
+	var selector = bson.M{"i": 1, "h": 1, "t": 1, "c": 1}
+	if len(input.KeyList) == 0 {
+		selector["d"] = 1
+	} else {
+		for _, it := range input.KeyList {
+			selector["d."+it] = 1
+		}
+	}
+
+	var session = e.querySession.Copy()
+	defer session.Close()
+	var iter = session.DB(e.db).C(input.Header.Service).
+		Find(condition).Select(selector).Sort("+t").Iter()
+
+	var builder = NewRangeBuilder()
+	var row = bson.M{}
+	for iter.Next(&row) {
+		// 由于mongo存储int32，取出后变成int，所以这里对i值的类型做多重判断
+		var hid int32
+		switch v := row["i"].(type) {
+		case int:
+			hid = int32(v)
+		case int32:
+			hid = v
+		}
+		// count是联合类型，结构为："count:step"，需要对string进行拆解
+		var count, step uint32
+		fmt.Sscanf(row["c"].(string), "%d:%d", &count, &step)
+		builder.SetHeader(input.Header.Service, hid, row["h"].(string), count, step)
+
+		var timestamp = uint32(row["t"].(int))
+		if data, ok := row["d"].(bson.M); ok {
+			for k, v := range data {
+				builder.Add(k, timestamp, v.([]byte))
+			}
+		}
+		row = bson.M{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return builder.Build(), nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Delete
+//  Description:  按照数据块开始的时间删除
+// =====================================================================================
+*/
+func (e *MongoEngine) Delete(service string, before time.Time) error {
+	var session = e.storeSessionList[0].Copy()
+	defer session.Close()
+
+	var serviceList = []string{service}
+	if len(service) == 0 {
+		var err error
+		if serviceList, err = session.DB(e.db).CollectionNames(); err != nil {
+			return err
+		}
+	}
+	for _, it := range serviceList {
+		var info, err = session.DB(e.db).C(it).RemoveAll(bson.M{"e": bson.M{"$lt": before}})
+		if err != nil {
+			return fmt.Errorf("delete service[%s] before[%v] error: %s", it, before, err.Error())
+		}
+		glog.V(2).Infof("[Trace][MongoEngine.Delete] service[%s] before[%v]: %d removed", it, before, info.Removed)
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  SetRetention
+//  Description:  通过数据块开始时间e上的TTL索引删除，索引不存在时创建，
+//                保留时间变化时通过collMod修改，0表示删除TTL索引
+// =====================================================================================
+*/
+func (e *MongoEngine) SetRetention(service string, retention time.Duration) error {
+	var session = e.storeSessionList[0].Copy()
+	defer session.Close()
+
+	var collection = session.DB(e.db).C(service)
+	// collection不存在时索引列表为空
+	var indexList, _ = collection.Indexes()
+	var ttl *mgo.Index
+	for i, it := range indexList {
+		if len(it.Key) == 1 && it.Key[0] == "e" {
+			ttl = &indexList[i]
+		}
+	}
+
+	var err error
+	switch {
+	case retention <= 0:
+		if ttl != nil {
+			err = collection.DropIndexName(ttl.Name)
+		}
+	case ttl == nil:
+		err = collection.EnsureIndex(mgo.Index{Key: []string{"e"}, ExpireAfter: retention, Background: true})
+	case ttl.ExpireAfter != retention:
+		err = session.DB(e.db).Run(bson.D{
+			{Name: "collMod", Value: service},
+			{Name: "index", Value: bson.M{
+				"keyPattern":         bson.M{"e": 1},
+				"expireAfterSeconds": int64(retention / time.Second),
+			}},
+		}, nil)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("set retention[%v] of service[%s] error: %s", retention, service, err.Error())
+	}
+	glog.Infof("set retention of service[%s] to %v", service, retention)
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Stats
+//  Description:  通过collStats统计每个collection的文档个数与数据大小
+// =====================================================================================
+*/
+func (e *MongoEngine) Stats() (*Stats, error) {
+	var session = e.querySession.Copy()
+	defer session.Close()
+
+	var serviceList, err = session.DB(e.db).CollectionNames()
+	if err != nil {
+		return nil, err
+	}
+	var stats = &Stats{Engine: MongoEngineName, Services: make(map[string]*ServiceStats)}
+	for _, it := range serviceList {
+		if strings.HasPrefix(it, "system.") {
+			continue
+		}
+		var result struct {
+			Count int64 `bson:"count"`
+			Size  int64 `bson:"size"`
+		}
+		if err = session.DB(e.db).Run(bson.D{{Name: "collStats", Value: it}}, &result); err != nil {
+			return nil, fmt.Errorf("collStats of service[%s] error: %s", it, err.Error())
+		}
+		stats.Services[it] = &ServiceStats{Blocks: result.Count, Bytes: result.Size}
+	}
+	return stats, nil
+}
+
+func (e *MongoEngine) Close() error {
+	e.querySession.Close()
+	for _, it := range e.storeSessionList {
+		it.Close()
+	}
+	return nil
+}
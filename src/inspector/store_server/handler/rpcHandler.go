@@ -17,7 +17,6 @@ package handler
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"time"
@@ -25,11 +24,10 @@ import (
 	"inspector/proto/core"
 	"inspector/proto/store"
 	"inspector/store_server/configure"
-	"inspector/util"
+	"inspector/store_server/engine"
+	"inspector/util/grpc2"
 
 	"github.com/golang/glog"
-	"github.com/vinllen/mgo"
-	"github.com/vinllen/mgo/bson"
 )
 
 // =====================================================================================
@@ -48,95 +46,17 @@ type storeModel struct {
 
 /*
 // ===  FUNCTION  ======================================================================
-//         Name:  doStoreToMongo
+//         Name:  doStoreToEngine
 //  Description:
 // =====================================================================================
 */
-func (h *RpcHandler) doStoreToMongo(input []*core.Info) error {
-	glog.V(1).Infof("[Trace][doStoreToMongo] called: info count[%d] ", len(input))
-
-	var doSendMongo = func(index int, service string, iflist []interface{}, errChan chan error) func() {
-		return func() {
-			var err = configure.Options.MongoStoreSessionList[index].
-				DB(configure.Options.StoreServerDB).
-				C(service).
-				Insert(iflist...)
-			if err != nil {
-				glog.Errorf("store service[%s] to mongodb error: %s", service, err.Error())
-				errChan <- err
-			}
-			errChan <- nil
-		}
-	}
-
-	var msgListMap map[string][]bson.M = make(map[string][]bson.M)
-
-	for _, it := range input {
-		// check service
-		var service = it.GetHeader().GetService()
-
-		// get msgList
-		var msgList []bson.M = nil
-		var ok bool
-		if msgList, ok = msgListMap[service]; !ok {
-			msgList = make([]bson.M, 0)
-		}
-
-		// new msg
-		var msg bson.M = bson.M{}
-
-		// build msg header
-		msg["i"] = util.Int32Reverse(it.Header.Hid)
-		msg["h"] = it.Header.Host
-		msg["t"] = it.Timestamp
-		msg["c"] = fmt.Sprintf("%d:%d", it.Count, it.Step)
-		msg["e"] = time.Unix(int64(it.Timestamp*it.Step), 0)
-
-		// build msg body
-		var body = make(map[string][]byte)
-		for _, it := range it.Items {
-			body[it.Key] = it.Value
-			// fmt.Printf("debug store each key: hid[%v] host[%v] timestamp[%v] cs[%v] key[%v] value[%v]\n",
-			// 	msg["i"], msg["h"], msg["t"], msg["c"], it.Key, it.Value)
-		}
-		msg["d"] = body
-
-		msgList = append(msgList, msg)
-		msgListMap[service] = msgList
-	}
-
-	// save to mongodb
-	var errChan chan error = make(chan error, configure.Options.MongoStoreSessionListCount*2)
-	var errLen int = 0
-	var iflist = make([]interface{}, 0)
-	for service, msgList := range msgListMap {
-		var batch int = len(msgList) / configure.Options.MongoStoreSessionListCount
-		if batch < 10 { // 10是拍脑袋写的，给一个最小批次，为了避免batch太小导致模0错误
-			batch = 10
-		}
-		for i, it := range msgList {
-			iflist = append(iflist, it)
-			if i != 0 && i%batch == 0 {
-				configure.Options.MongoStorePool.AsyncRun(doSendMongo((i/batch-1)%configure.Options.MongoStoreSessionListCount, service, iflist, errChan))
-				errLen++
-				iflist = make([]interface{}, 0)
-			}
-		}
-		// send last batch
-		if len(iflist) != 0 {
-			configure.Options.MongoStorePool.AsyncRun(doSendMongo(0, service, iflist, errChan))
-			errLen++
-		}
-	}
-
-	// wait for all AsyncRun return
-	for i := 0; i < errLen; i++ {
-		if err := <-errChan; err != nil {
-			return err
-		}
-	}
+func (h *RpcHandler) doStoreToEngine(input []*core.Info) error {
+	glog.V(1).Infof("[Trace][doStoreToEngine] called: info count[%d] ", len(input))
 
-	return nil
+	var err = configure.Options.StorageEngine.Save(input)
+	// 写入失败的部分在聚合时读不到，不影响结果
+	configure.Options.Rollup.Observe(input)
+	return err
 }
 
 /*
@@ -148,6 +68,13 @@ func (h *RpcHandler) doStoreToMongo(input []*core.Info) error {
 func (h *RpcHandler) doStoreToCache(input []*core.Info) error {
 	glog.V(1).Infof("[Trace][doStoreToCache] called: info count[%d] ", len(input))
 
+	// 写入cache之前记录，cache会修改Step
+	if configure.Options.Journal != nil {
+		if err := configure.Options.Journal.Append(input); err != nil {
+			glog.Errorln("store to journal error: ", err)
+		}
+	}
+
 	for _, it := range input {
 		var err = configure.Options.TimeCache.Set(it)
 		if err != nil {
@@ -158,92 +85,6 @@ func (h *RpcHandler) doStoreToCache(input []*core.Info) error {
 	return nil
 }
 
-type valueItem struct {
-	timestamp uint32
-	value     []byte
-}
-
-/*
-// ===  FUNCTION  ======================================================================
-//         Name:  query2infoRange
-//  Description:
-// =====================================================================================
-*/
-func (h *RpcHandler) query2infoRange(input *core.Query, query *mgo.Query) *core.InfoRange {
-	var result *core.InfoRange = &core.InfoRange{}
-	var valueMap map[string][]valueItem = make(map[string][]valueItem)
-
-	var iter = query.Iter()
-
-	// save first values
-	row := bson.M{}
-	if iter.Next(row) == true {
-		// write header
-		result.Header = &core.Header{
-			Service: input.Header.Service,
-			// bug: mongo use type(int32) as type(int)
-			// Hid:     int32(row["i"].(int)),
-			Host: row["h"].(string),
-		}
-		// 由于mongo存储int32，取出后变成int，所以这里对i值的类型做多重判断
-		switch row["i"].(type) {
-		case int:
-			result.Header.Hid = int32(row["i"].(int))
-		case int32:
-			result.Header.Hid = row["i"].(int32)
-		}
-		// count是联合类型，结构为："count:step"，需要对string进行拆解
-		fmt.Sscanf(row["c"].(string), "%d:%d", &result.Count, &result.Step)
-
-		// save first value
-		for k, v := range row["d"].(bson.M) {
-			valueMap[k] = make([]valueItem, 0)
-			valueMap[k] = append(valueMap[k], valueItem{
-				timestamp: uint32(row["t"].(int)),
-				value:     v.([]byte),
-			})
-		}
-
-		// save other values
-		row = bson.M{}
-		for iter.Next(row) {
-			// save values temporay
-			for k, v := range row["d"].(bson.M) {
-				valueMap[k] = append(valueMap[k], valueItem{
-					timestamp: uint32(row["t"].(int)),
-					value:     v.([]byte),
-				})
-			}
-		}
-
-	} else {
-		return nil
-	}
-
-	// write to infoRange
-	bytesBuffer := bytes.NewBuffer([]byte{})
-	// write key list size
-	binary.Write(bytesBuffer, binary.BigEndian, uint32(len(valueMap)))
-	for k, v := range valueMap {
-		// write key size
-		binary.Write(bytesBuffer, binary.BigEndian, uint32(len(k)))
-		// write key
-		bytesBuffer.WriteString(k)
-		// write data size
-		binary.Write(bytesBuffer, binary.BigEndian, uint32(len(v)))
-		for _, it := range v {
-			binary.Write(bytesBuffer, binary.BigEndian, it.timestamp)
-			binary.Write(bytesBuffer, binary.BigEndian, uint32(len(it.value)))
-			bytesBuffer.Write(it.value)
-			// fmt.Printf("debug query mongo: key[%v] timestamp[%v] value[%v]\n",
-			// 	k, it.timestamp, it.value)
-		}
-	}
-	result.Data = bytesBuffer.Bytes()
-
-	return result
-}
-
 /*
 // ===  FUNCTION  ======================================================================
 //         Name:  doQuery
@@ -254,49 +95,49 @@ func (h *RpcHandler) doQuery(input *core.Query) ([]*core.InfoRange, error) {
 	glog.V(1).Infof("[Trace][doQuery] called: info[%v] ", input)
 
 	var res []*core.InfoRange
+	var rollupRes *core.InfoRange
 	var cacheRes []*core.InfoRange
-	var mongoRes []*core.InfoRange
+	var engineRes []*core.InfoRange
 
 	var timeBegin uint32
 	var timeEnd uint32
 
 	var err error
-
-	// findInMongo closure
-	var selector = bson.M{"i": 1, "h": 1, "t": 1, "c": 1}
-	var findInMongo = func(keyList []string, start, end uint32) {
-		var condition = bson.M{
-			"i": util.Int32Reverse(input.Header.Hid),
-			"h": input.Header.Host,
-			"t": bson.M{
-				"$gte": start,
-				"$lte": end,
-			},
-		}
-		for _, it := range keyList {
-			selector["d."+it] = 1
-		}
-		var query = configure.Options.MongoQuerySession.DB(configure.Options.StoreServerDB).C(input.Header.Service).
-			Find(condition).Select(selector).Sort("+t")
-		if infoRange := h.query2infoRange(input, query); infoRange != nil {
-			mongoRes = append(mongoRes, infoRange)
+	var queryErr error
+
+	// findInEngine closure
+	var storage = configure.Options.StorageEngine
+	var findInEngine = func(start, end uint32) {
+		var infoRange *core.InfoRange
+		if infoRange, queryErr = storage.Query(input, start, end); queryErr != nil {
+			glog.Errorf("query host[%s] from %s error: %s", input.Header.Host, storage.Name(), queryErr.Error())
+		} else if infoRange != nil {
+			engineRes = append(engineRes, infoRange)
 		}
-		h.timeTick(fmt.Sprintf("doQuery[%v] from mongo", input.Header.Host))
-		// for _, it := range mongoRes {
-		// 	fmt.Println("debug query from db data: ", start, end, it.GetHeader(), util.ShowData(it.GetData()))
-		// }
+		h.timeTick(fmt.Sprintf("doQuery[%v] from %s", input.Header.Host, storage.Name()))
 	}
 
-	if len(input.KeyList) == 0 {
-		selector["d"] = 1
-	} else {
-		buff := bytes.NewBuffer([]byte{})
-		for _, it := range input.KeyList {
-			buff.Reset()
-			buff.WriteString("d.")
-			buff.WriteString(it)
-			selector[buff.String()] = 1
+	// 范围超出原始数据的保留时间或者精度要求不高时，先从聚合层级中查询，
+	// 还没有聚合的部分继续从cache与存储引擎中查询原始数据
+	var rawBegin uint32
+	rollupRes, rawBegin, err = configure.Options.Rollup.Query(input, time.Now())
+	if err != nil {
+		glog.Errorf("query host[%s] from rollup error: %s", input.Header.Host, err.Error())
+	}
+	h.timeTick(fmt.Sprintf("doQuery[%v] from rollup", input.Header.Host))
+	if rollupRes != nil {
+		res = append(res, rollupRes)
+	}
+	if rawBegin > input.TimeEnd {
+		if res == nil {
+			return nil, errors.New("data not exist")
 		}
+		return res, nil
+	}
+	if rawBegin > input.TimeBegin {
+		var rawInput = *input
+		rawInput.TimeBegin = rawBegin
+		input = &rawInput
 	}
 
 	cacheRes, timeBegin, timeEnd, err = configure.Options.TimeCache.Get(input)
@@ -306,28 +147,24 @@ func (h *RpcHandler) doQuery(input *core.Query) ([]*core.InfoRange, error) {
 	// for _, it := range cacheRes {
 	// 	fmt.Println("debug query from cache data: ", timeBegin, timeEnd, it.GetHeader(), util.ShowData(it.GetData()))
 	// }
-	// findInMongo(input.GetKeyList(), input.TimeBegin, input.TimeEnd)
-	// mongoRes = nil
 
 	if err != nil {
-		// get all data from mongo store
-		// fmt.Println("debug: get all data from mongo store")
-		glog.V(3).Infof("[Debug][doQuery] get all data from mongo store")
-		findInMongo(input.GetKeyList(), input.TimeBegin, input.TimeEnd)
+		// get all data from storage engine
+		glog.V(3).Infof("[Debug][doQuery] get all data from storage engine")
+		findInEngine(input.TimeBegin, input.TimeEnd)
 
 	} else {
-		// get miss data from mongo store
+		// get miss data from storage engine
 		if timeBegin > input.TimeBegin {
-			// fmt.Println("debug: get previous miss data from mongo store")
-			glog.V(3).Infof("[Debug][doQuery] get previous miss data from mongo store")
-			findInMongo(input.GetKeyList(), input.TimeBegin, timeBegin-1)
+			glog.V(3).Infof("[Debug][doQuery] get previous miss data from storage engine")
+			findInEngine(input.TimeBegin, timeBegin-1)
 		}
 
 		// 一般最近一段时间的数据，不可能在cache里没有
 		// 这里为了解决数据以外丢失的情况，例如刚刚重启
 		// if timeEnd < input.TimeEnd {
-		// 	fmt.Println("debug query tail data in mongo")
-		// 	findInMongo(input.GetKeyList(), timeEnd, input.TimeEnd)
+		// 	fmt.Println("debug query tail data in storage engine")
+		// 	findInEngine(timeEnd, input.TimeEnd)
 		// }
 		_ = timeEnd
 	}
@@ -335,12 +172,15 @@ func (h *RpcHandler) doQuery(input *core.Query) ([]*core.InfoRange, error) {
 	if cacheRes != nil {
 		res = append(res, cacheRes...)
 	}
-	if mongoRes != nil {
-		res = append(res, mongoRes...)
+	if engineRes != nil {
+		res = append(res, engineRes...)
 	}
-	glog.V(3).Infof("[Debug][doQuery] cacheResSize[%v], mongoResSize[%v]", len(cacheRes), len(mongoRes))
+	glog.V(3).Infof("[Debug][doQuery] cacheResSize[%v], engineResSize[%v]", len(cacheRes), len(engineRes))
 
 	if res == nil {
+		if queryErr != nil {
+			return nil, queryErr
+		}
 		return nil, errors.New("data not exist")
 	}
 
@@ -366,14 +206,38 @@ func (h *RpcHandler) Save(ctx context.Context, req *store.StoreSaveRequest) (*st
 	var infoList = req.InfoList
 
 	h.timeReset()
-	if err = h.doStoreToMongo(infoList); err != nil {
+	err = h.doStoreToEngine(infoList)
+	h.timeTick(fmt.Sprintf("store[%d] to %s", len(infoList), configure.Options.StorageEngine.Name()))
+
+	// 每个数据块的结果，只有失败的部分需要collector重新发送
+	var errorMap = engine.SaveErrorMap(err, len(infoList))
+	var savedList = make([]*core.Info, 0, len(infoList))
+	for i, it := range infoList {
+		var item = &core.ResponseItem{
+			Header:    it.Header,
+			Timestamp: it.Timestamp,
+		}
+		if itemErr, ok := errorMap[i]; ok {
+			item.Error = &core.Error{Errno: 255, Errmsg: itemErr.Error()}
+			res.FailureList = append(res.FailureList, item)
+		} else {
+			item.Error = &core.Error{Errno: 0, Errmsg: "OK"}
+			res.SuccessList = append(res.SuccessList, item)
+			savedList = append(savedList, it)
+		}
+	}
+	if len(res.FailureList) == 0 {
+		res.Error.Errno = 0
+		res.Error.Errmsg = "OK"
+	} else {
+		glog.Errorf("store %d of %d blocks error: %s", len(res.FailureList), len(infoList), err.Error())
 		res.Error.Errno = 255
 		res.Error.Errmsg = err.Error()
 	}
-	h.timeTick(fmt.Sprintf("store[%d] to mongo", len(infoList)))
 
-	h.doStoreToCache(infoList)
-	h.timeTick(fmt.Sprintf("store[%d] to cache", len(infoList)))
+	// 失败的数据块不写入cache，collector重新发送时再写入，避免cache中的数据在store中不存在
+	h.doStoreToCache(savedList)
+	h.timeTick(fmt.Sprintf("store[%d] to cache", len(savedList)))
 
 	if glog.V(2) {
 		var durationAll, durationList = h.getTimeConsumeResult()
@@ -399,7 +263,8 @@ func (h *RpcHandler) Save(ctx context.Context, req *store.StoreSaveRequest) (*st
 // =====================================================================================
 */
 func (h *RpcHandler) Query(ctx context.Context, req *store.StoreQueryRequest) (*store.StoreQueryResponse, error) {
-	glog.V(1).Infof("[Trace][RPC StoreQuery] called: request[%v] ", req)
+	var traceID, traceDebug = grpc2.TraceFromContext(ctx)
+	glog.V(1).Infof("[Trace][RPC StoreQuery] called: trace[%s] request[%v] ", traceID, req)
 
 	res := &store.StoreQueryResponse{
 		Error:       new(core.Error),
@@ -427,18 +292,30 @@ func (h *RpcHandler) Query(ctx context.Context, req *store.StoreQueryRequest) (*
 		// h.timeTick(fmt.Sprintf("doQuery[%v]", host))
 	}
 
-	if glog.V(2) {
+	// getTimeConsumeResult只能调用一次，api_server需要时通过trailer返回cache与存储引擎的耗时
+	if bool(glog.V(2)) || traceDebug {
 		bytesBuffer := bytes.NewBuffer([]byte{})
 		var durationAll, durationList = h.getTimeConsumeResult()
+		if traceDebug {
+			var steps = make([]grpc2.TraceStep, 0, len(durationList))
+			for _, it := range durationList {
+				steps = append(steps, grpc2.TraceStep{Name: it.name, Duration: it.duration})
+			}
+			if err := grpc2.SetTraceTiming(ctx, steps); err != nil {
+				glog.Warningf("set trace[%s] timing error: %s", traceID, err.Error())
+			}
+		}
 
-		bytesBuffer.WriteString("[Perf][Query]: ")
+		bytesBuffer.WriteString(fmt.Sprintf("[Perf][Query]: trace[%s] ", traceID))
 		for _, it := range durationList {
 			bytesBuffer.WriteString(
 				fmt.Sprintf("step[%v](%v) time duration[%v]|",
 					it.name, it.step, it.duration))
 		}
 		bytesBuffer.WriteString(fmt.Sprintf("all time duration[%v]\n", durationAll))
-		glog.Infof(bytesBuffer.String())
+		if glog.V(2) {
+			glog.Infof(bytesBuffer.String())
+		}
 	}
 
 	if len(res.FailureList) == 0 {
@@ -19,22 +19,22 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"reflect"
 	"strings"
 	"time"
 
 	"inspector/cache"
-	"inspector/client"
 	"inspector/config"
 	"inspector/heartbeat"
 	"inspector/proto/store"
 	"inspector/store_server/configure"
+	"inspector/store_server/engine"
 	"inspector/store_server/handler"
+	"inspector/store_server/journal"
+	"inspector/store_server/retention"
+	"inspector/store_server/rollup"
 	"inspector/util"
-	"inspector/util/pool"
 
 	"github.com/golang/glog"
-	"github.com/vinllen/mgo"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -111,88 +111,133 @@ func StartHeartBeat() error {
 
 /*
 // ===  FUNCTION  ======================================================================
-//         Name:  InitPersistentStorage
-//  Description:
+//         Name:  InitStorageEngine
+//  Description:  创建持久化存储引擎
 // =====================================================================================
 */
-func InitPersistentStorage() client.ClientInterface {
-	glog.V(1).Infoln("[Trace][InitPersistentStorage] start")
-
-	// split connection string
-	var connList = strings.Split(configure.Options.StoreServerAddress, ",")
-
-	// init store session list
-	for i := 0; i < configure.Options.MongoStoreSessionListCount; i++ {
-		// establish connection
-		newClient, err := client.NewMongoClient().
-			ConnectString(connList[i%len(connList)]).
-			Username(configure.Options.StoreServerUsername).
-			Password(configure.Options.StoreServerPassword).
-			UseDB(configure.Options.StoreServerDB).
-			SetOpt(map[string]interface{}{
-				"SafeMode":        "majority",
-				"ConsistencyMode": "Monotonic",
-				"Timeout":         time.Duration(configure.Options.StoreWriteTimeout) * time.Second,
-			}).
-			EstablishConnect()
-
-		if err != nil {
-			glog.Errorf("new mongo client error: ", err)
-			return nil
-		}
+func InitStorageEngine() error {
+	glog.V(1).Infoln("[Trace][InitStorageEngine] start")
 
-		session := newClient.GetSession()
-		if session == nil || reflect.ValueOf(session).IsNil() {
-			glog.Errorf("mongo session is nil: ", err)
-			return nil
-		}
-		if mongoSession, ok := session.(*mgo.Session); !ok {
-			glog.Errorf("get mongo session error: ", err)
-			return nil
-		} else {
-			configure.Options.MongoStoreSessionList = append(configure.Options.MongoStoreSessionList, mongoSession)
+	var factory = engine.EngineFactory{Name: configure.Options.StorageEngineName}
+	var storage, err = factory.Create(&engine.Conf{
+		Address:         configure.Options.StoreServerAddress,
+		Username:        configure.Options.StoreServerUsername,
+		Password:        configure.Options.StoreServerPassword,
+		DB:              configure.Options.StoreServerDB,
+		SessionCount:    configure.Options.MongoStoreSessionListCount,
+		ReadTimeout:     configure.Options.StoreReadTimeout,
+		WriteTimeout:    configure.Options.StoreWriteTimeout,
+		Dir:             configure.Options.LocalDataDir,
+		SegmentInterval: configure.Options.LocalSegmentInterval,
+	})
+	if err != nil {
+		var errStr = fmt.Sprintf("create storage engine[%s] error: %s", configure.Options.StorageEngineName, err.Error())
+		glog.Error(errStr)
+		return errors.New(errStr)
+	}
+	configure.Options.StorageEngine = storage
+
+	glog.V(1).Infof("[Trace][InitStorageEngine] engine[%s] success", storage.Name())
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  InitRollup
+//  Description:  启动聚合层级，原始数据与各个层级的过期数据也由rollup按照保留时间删除
+// =====================================================================================
+*/
+func InitRollup() error {
+	glog.V(1).Infoln("[Trace][InitRollup] start")
+
+	var tierList []*rollup.Tier
+	for _, it := range []struct {
+		name      string
+		interval  uint32
+		retention int
+	}{
+		{"1m", 60, configure.Options.Rollup1mRetention},
+		{"1h", 3600, configure.Options.Rollup1hRetention},
+	} {
+		if it.retention < 0 {
+			continue
 		}
+		tierList = append(tierList, &rollup.Tier{
+			Name:      it.name,
+			Interval:  it.interval,
+			Retention: time.Duration(it.retention) * time.Hour,
+		})
 	}
-	glog.V(1).Infoln("[Trace][InitPersistentStorage] create store session list success")
-
-	// init store pool
-	configure.Options.MongoStorePool = new(pool.GoroutinePool)
-	configure.Options.MongoStorePool.Init()
-	go configure.Options.MongoStorePool.Run()
-	glog.V(1).Infoln("[Trace][InitPersistentStorage] create store pool success")
-
-	// init query session list
-	newClient, err := client.NewMongoClient().
-		ConnectString(configure.Options.StoreServerAddress).
-		Username(configure.Options.StoreServerUsername).
-		Password(configure.Options.StoreServerPassword).
-		UseDB(configure.Options.StoreServerDB).
-		SetOpt(map[string]interface{}{
-			"SafeMode":        "majority",
-			"ConsistencyMode": "Monotonic",
-			"Timeout":         time.Duration(configure.Options.StoreReadTimeout) * time.Second,
-		}).
-		EstablishConnect()
 
+	var rawRetention = time.Duration(configure.Options.DataRetention) * time.Hour
+	var r, err = rollup.New(configure.Options.StorageEngine, tierList, rawRetention)
 	if err != nil {
-		glog.Errorf("new mongo client error: ", err)
-		return nil
+		var errStr = fmt.Sprintf("create rollup error: %s", err.Error())
+		glog.Error(errStr)
+		return errors.New(errStr)
 	}
+	r.Start()
+	configure.Options.Rollup = r
 
-	session := newClient.GetSession()
-	if session == nil || reflect.ValueOf(session).IsNil() {
-		glog.Errorf("mongo session is nil: ", err)
-		return nil
+	glog.V(1).Infof("[Trace][InitRollup] tier count[%d] success", len(tierList))
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  StartRetentionManager
+//  Description:  按照meta中每个service声明的保留策略调整rollup、TTL索引与cache
+// =====================================================================================
+*/
+func StartRetentionManager() error {
+	glog.V(1).Infoln("[Trace][StartRetentionManager] start")
+
+	var m = retention.NewManager(configure.Options.ConfigServer, configure.Options.StorageEngine,
+		configure.Options.Rollup, configure.Options.TimeCache)
+	if err := m.Start(); err != nil {
+		var errStr = fmt.Sprintf("start retention manager error: %s", err.Error())
+		glog.Error(errStr)
+		return errors.New(errStr)
 	}
-	if mongoSession, ok := session.(*mgo.Session); !ok {
-		glog.Errorf("get mongo session error: ", err)
+
+	glog.V(1).Infoln("[Trace][StartRetentionManager] success")
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  InitJournal
+//  Description:  回放cache的日志预热cache，需要在service的reserve生效之后、开始接收数据之前调用
+// =====================================================================================
+*/
+func InitJournal() error {
+	if len(configure.Options.JournalDir) == 0 {
 		return nil
-	} else {
-		configure.Options.MongoQuerySession = mongoSession
 	}
-	glog.V(1).Infoln("[Trace][InitPersistentStorage] create query session list success")
+	glog.V(1).Infoln("[Trace][InitJournal] start")
 
-	return newClient
+	var tc = configure.Options.TimeCache
+	var j, err = journal.New(configure.Options.JournalDir, tc.GetReserve)
+	if err != nil {
+		var errStr = fmt.Sprintf("create journal error: %s", err.Error())
+		glog.Error(errStr)
+		return errors.New(errStr)
+	}
+	var begin = time.Now()
+	var count int
+	if count, err = j.Replay(tc.Set, begin); err != nil {
+		// 回放失败只影响cache的命中
+		glog.Errorf("replay journal error: %s", err.Error())
+	}
+	glog.Infof("replay %d blocks from journal, time used[%v]", count, time.Since(begin))
+	if configure.Options.JournalCheckpoint <= 0 {
+		configure.Options.JournalCheckpoint = 300
+	}
+	j.Start(time.Duration(configure.Options.JournalCheckpoint) * time.Second)
+	configure.Options.Journal = j
+
+	glog.V(1).Infoln("[Trace][InitJournal] success")
+	return nil
 }
 
 /*
@@ -218,11 +263,18 @@ func StartRPCServer() error {
 		return errors.New(errStr)
 	}
 
-	// new mongo client for persistent store
-	if configure.Options.StorageClient = InitPersistentStorage(); configure.Options.StorageClient == nil {
-		var errStr = "initialize persistent storage error"
-		glog.Error(errStr)
-		return errors.New(errStr)
+	// storage engine for persistent store
+	if err = InitStorageEngine(); err != nil {
+		return err
+	}
+	if err = InitRollup(); err != nil {
+		return err
+	}
+	if err = StartRetentionManager(); err != nil {
+		return err
+	}
+	if err = InitJournal(); err != nil {
+		return err
 	}
 
 	s := grpc.NewServer(grpc.MaxRecvMsgSize(64 * 1024 * 1024))
@@ -0,0 +1,350 @@
+/*
+// =====================================================================================
+//
+//       Filename:  journal.go
+//
+//    Description:  TimeCache的日志，只用于重启后预热cache，避免cache重新填满之前
+//                  所有查询都落到存储引擎。数据块在存储引擎保存成功之后才写入，
+//                  不是存储引擎的预写日志，不能用来恢复没有落盘的数据。
+//                  目录结构：<dir>/<序号>.journal，按照序号顺序追加，每次checkpoint切换新文件，
+//                  每条记录：长度(uint32)、crc32(uint32)、core.Info序列化后的内容
+//                  checkpoint删除所有数据块都已经超出cache保留时间的文件，回放的时间
+//                  不超过保留时间加上一个checkpoint间隔。写入后不sync，进程重启不丢失数据，
+//                  机器宕机时可能丢失最近的部分，只影响cache的命中
+//
+//        Version:  1.0
+//        Created:  10/28/2026 11:05:42 AM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"inspector/proto/core"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	journalSuffix    = ".journal"
+	recordHeaderSize = 8
+)
+
+// 返回service在cache中保留的虚拟时间
+type ReserveFunc func(service string) uint32
+
+// =====================================================================================
+//       Struct:  segment
+//  Description:  一个journal文件，expire为其中数据块全部超出cache保留时间的时刻，unix秒
+// =====================================================================================
+type segment struct {
+	seq    uint64
+	path   string
+	size   int64
+	expire int64
+}
+
+// =====================================================================================
+//       Struct:  Journal
+//  Description:  segmentList按照序号排序，只有最后一个segment打开用于追加
+// =====================================================================================
+type Journal struct {
+	dir         string
+	reserveOf   ReserveFunc
+	lock        sync.Mutex
+	segmentList []*segment
+	file        *os.File // 最后一个segment，nil表示下次写入时创建新文件
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  New
+//  Description:  创建目录并列出已有的journal文件，需要先Replay再Append
+// =====================================================================================
+*/
+func New(dir string, reserveOf ReserveFunc) (*Journal, error) {
+	glog.V(1).Infoln("[Trace][journal.New] start")
+
+	if len(dir) == 0 {
+		return nil, errors.New("journal directory is not configured")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var fileList, err = ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var j = &Journal{dir: dir, reserveOf: reserveOf}
+	for _, file := range fileList {
+		var name = file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, journalSuffix) {
+			continue
+		}
+		var seq uint64
+		if seq, err = strconv.ParseUint(strings.TrimSuffix(name, journalSuffix), 10, 64); err != nil {
+			glog.Warningf("skip journal[%s]: invalid name", name)
+			continue
+		}
+		j.segmentList = append(j.segmentList, &segment{
+			seq:  seq,
+			path: filepath.Join(dir, name),
+			size: file.Size(),
+		})
+	}
+	sort.Slice(j.segmentList, func(a, b int) bool {
+		return j.segmentList[a].seq < j.segmentList[b].seq
+	})
+
+	glog.V(1).Infof("[Trace][journal.New] success: dir[%s] segments[%d]", dir, len(j.segmentList))
+	return j, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Replay
+//  Description:  按照写入顺序将没有超出保留时间的数据块交给handler，返回回放的个数，
+//                不完整或者crc不一致的记录及其之后的内容跳过。回放后删除过期的文件
+// =====================================================================================
+*/
+func (j *Journal) Replay(handler func(info *core.Info) error, now time.Time) (int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	var count int
+	for _, seg := range j.segmentList {
+		var data, err = ioutil.ReadFile(seg.path)
+		if err != nil {
+			return count, err
+		}
+		var offset int
+		for offset < len(data) {
+			var body, ok = checkRecord(data[offset:])
+			if !ok {
+				glog.Warningf("journal[%s] is broken at %d of %d, skip the rest", seg.path, offset, len(data))
+				break
+			}
+			offset += recordHeaderSize + len(body)
+
+			var info = new(core.Info)
+			if err = proto.Unmarshal(body, info); err != nil || info.Header == nil {
+				glog.Warningf("journal[%s] has an invalid record before %d, skip it", seg.path, offset)
+				continue
+			}
+			var expire = j.expireOf(info)
+			if expire > seg.expire {
+				seg.expire = expire
+			}
+			if expire < now.Unix() {
+				continue
+			}
+			if err = handler(info); err != nil {
+				glog.Warningf("replay journal[%s] host[%s] timestamp[%d] error: %s",
+					seg.path, info.Header.Host, info.Timestamp, err.Error())
+				continue
+			}
+			count++
+		}
+	}
+
+	return count, j.checkpoint(now)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Append
+//  Description:  需要在TimeCache.Set之前调用，Set会修改info的Step
+// =====================================================================================
+*/
+func (j *Journal) Append(infoList []*core.Info) error {
+	var bytesBuffer bytes.Buffer
+	var expire int64
+	for _, it := range infoList {
+		var body, err = proto.Marshal(it)
+		if err != nil {
+			return err
+		}
+		var header [recordHeaderSize]byte
+		binary.BigEndian.PutUint32(header[0:4], uint32(len(body)))
+		binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(body))
+		bytesBuffer.Write(header[:])
+		bytesBuffer.Write(body)
+		if v := j.expireOf(it); v > expire {
+			expire = v
+		}
+	}
+	if bytesBuffer.Len() == 0 {
+		return nil
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		if err := j.create(); err != nil {
+			return err
+		}
+	}
+	var seg = j.segmentList[len(j.segmentList)-1]
+	if _, err := j.file.WriteAt(bytesBuffer.Bytes(), seg.size); err != nil {
+		// 截断到写入前的位置，避免之后的记录在回放时被跳过
+		j.file.Truncate(seg.size)
+		return fmt.Errorf("write journal[%s] error: %s", seg.path, err.Error())
+	}
+	seg.size += int64(bytesBuffer.Len())
+	if expire > seg.expire {
+		seg.expire = expire
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Checkpoint
+//  Description:  切换到新的文件，删除已经全部过期的文件
+// =====================================================================================
+*/
+func (j *Journal) Checkpoint(now time.Time) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.checkpoint(now)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Start
+//  Description:  每隔interval做一次checkpoint
+// =====================================================================================
+*/
+func (j *Journal) Start(interval time.Duration) {
+	go func() {
+		for now := range time.Tick(interval) {
+			if err := j.Checkpoint(now); err != nil {
+				glog.Errorf("journal checkpoint error: %s", err.Error())
+			}
+		}
+	}()
+}
+
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.closeFile()
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  checkpoint
+//  Description:  调用者需要持有锁，删除失败的文件保留到下次checkpoint
+// =====================================================================================
+*/
+func (j *Journal) checkpoint(now time.Time) error {
+	if err := j.closeFile(); err != nil {
+		return err
+	}
+
+	var remain = j.segmentList[:0]
+	var errList []string
+	for _, seg := range j.segmentList {
+		if seg.expire >= now.Unix() {
+			remain = append(remain, seg)
+			continue
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			errList = append(errList, err.Error())
+			remain = append(remain, seg)
+			continue
+		}
+		glog.V(2).Infof("[Trace][Journal.checkpoint] journal[%s] removed", seg.path)
+	}
+	j.segmentList = remain
+	if len(errList) > 0 {
+		return fmt.Errorf("%s", strings.Join(errList, "; "))
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  create
+//  Description:  创建序号加一的文件用于追加
+// =====================================================================================
+*/
+func (j *Journal) create() error {
+	var seq uint64 = 1
+	if n := len(j.segmentList); n > 0 {
+		seq = j.segmentList[n-1].seq + 1
+	}
+	var path = filepath.Join(j.dir, fmt.Sprintf("%020d%s", seq, journalSuffix))
+	var file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.segmentList = append(j.segmentList, &segment{seq: seq, path: path})
+	return nil
+}
+
+func (j *Journal) closeFile() error {
+	if j.file == nil {
+		return nil
+	}
+	var err = j.file.Close()
+	j.file = nil
+	return err
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  expireOf
+//  Description:  数据块的最后一个点超出cache保留时间的时刻，Timestamp与保留时间为虚拟时间
+// =====================================================================================
+*/
+func (j *Journal) expireOf(info *core.Info) int64 {
+	var step = int64(info.Step)
+	if step == 0 {
+		step = 1
+	}
+	var reserve = j.reserveOf(info.GetHeader().GetService())
+	return (int64(info.Timestamp) + int64(info.Count) + int64(reserve)) * step
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  checkRecord
+//  Description:  检查data开头的记录是否完整，返回记录内容
+// =====================================================================================
+*/
+func checkRecord(data []byte) ([]byte, bool) {
+	if len(data) < recordHeaderSize {
+		return nil, false
+	}
+	var length = binary.BigEndian.Uint32(data[0:4])
+	if uint64(len(data)-recordHeaderSize) < uint64(length) {
+		return nil, false
+	}
+	var body = data[recordHeaderSize : recordHeaderSize+int(length)]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, false
+	}
+	return body, true
+}
@@ -0,0 +1,94 @@
+package journal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"inspector/cache"
+	"inspector/proto/core"
+)
+
+func TestJournal(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+	var newInfo = func(host string, timestamp uint32) *core.Info {
+		return &core.Info{
+			Header:    &core.Header{Service: "redis", Hid: 1, Host: host},
+			Timestamp: timestamp,
+			Count:     60,
+			Step:      1,
+			Items:     []*core.KVPair{{Key: "a", Value: []byte{1, 2, 3}}},
+		}
+	}
+	var journalCount = func(dir string) int {
+		var list, _ = filepath.Glob(filepath.Join(dir, "*"+journalSuffix))
+		return len(list)
+	}
+
+	var dir, err = ioutil.TempDir("", "journal")
+	check(err == nil, "create temp dir")
+	defer os.RemoveAll(dir)
+
+	var tc = cache.NewTimeCache(1, 3600)
+	var j *Journal
+	j, err = New(dir, tc.GetReserve)
+	check(err == nil, fmt.Sprintf("new journal error: %v", err))
+	var count int
+	count, err = j.Replay(tc.Set, time.Unix(0, 0))
+	check(err == nil && count == 0, "replay empty journal")
+
+	// checkpoint后切换文件，没有过期的文件保留
+	check(j.Append([]*core.Info{newInfo("h0", 1000)}) == nil, "append")
+	check(j.Checkpoint(time.Unix(3000, 0)) == nil && journalCount(dir) == 1, "checkpoint should keep the journal")
+	check(j.Append([]*core.Info{newInfo("h1", 7200), newInfo("h1", 7260)}) == nil, "append")
+	check(journalCount(dir) == 2, fmt.Sprint("journal count: ", journalCount(dir)))
+	check(j.Close() == nil, "close")
+
+	// 模拟写入一半时退出
+	var file *os.File
+	file, err = os.OpenFile(j.segmentList[1].path, os.O_WRONLY|os.O_APPEND, 0644)
+	check(err == nil, "open journal")
+	file.Write([]byte{0, 0, 0, 100, 1, 2})
+	file.Close()
+
+	// 重启后回放到新的cache，过期的数据块跳过，全部过期的文件删除
+	var restarted = cache.NewTimeCache(1, 3600)
+	j, err = New(dir, restarted.GetReserve)
+	check(err == nil && len(j.segmentList) == 2, "reopen journal")
+	count, err = j.Replay(restarted.Set, time.Unix(10000, 0))
+	check(err == nil && count == 2, fmt.Sprintf("replay: %d %v", count, err))
+	check(journalCount(dir) == 1, fmt.Sprint("expired journal should be removed: ", journalCount(dir)))
+
+	var query = &core.Query{
+		Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+		KeyList:   []string{"a"},
+		TimeBegin: 7200,
+		TimeEnd:   7319,
+	}
+	var begin uint32
+	_, begin, _, err = restarted.Get(query)
+	check(err == nil && begin == 7200, fmt.Sprintf("get from cache: %d %v", begin, err))
+	query.Header.Host = "h0"
+	_, _, _, err = restarted.Get(query)
+	check(err != nil, "expired block should not be replayed")
+
+	// 回放之后写入新的文件，不会接在不完整的记录之后
+	check(j.Append([]*core.Info{newInfo("h1", 7320)}) == nil, "append")
+	check(journalCount(dir) == 2 && j.segmentList[1].seq == 3, fmt.Sprint("journal count: ", journalCount(dir)))
+	check(j.Close() == nil, "close")
+
+	// 全部过期后删除
+	j, err = New(dir, restarted.GetReserve)
+	check(err == nil, "reopen journal")
+	count, err = j.Replay(restarted.Set, time.Unix(20000, 0))
+	check(err == nil && count == 0 && journalCount(dir) == 0, fmt.Sprintf("replay: %d %v %d", count, err, journalCount(dir)))
+}
@@ -18,6 +18,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"inspector/store_server/configure"
@@ -42,6 +43,22 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "inspector store is running")
 }
 
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  statsHandler
+//  Description:  存储引擎的统计
+// =====================================================================================
+*/
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	var stats, err = configure.Options.StorageEngine.Stats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
 /*
 // ===  FUNCTION  ======================================================================
 //         Name:  main
@@ -71,10 +88,19 @@ func main() {
 	flag.IntVar(&configure.Options.ServicePort, "port", 6200, "port of store server")
 	flag.IntVar(&configure.Options.SystemProfile, "profiling_port", 9200, "http profiling port")
 	flag.IntVar(&configure.Options.CacheConcurrence, "concurrence", 1, "concurrence of cache")
-	flag.IntVar(&configure.Options.CacheDataReserve, "reserve", 3600, "data reserve")
+	flag.IntVar(&configure.Options.CacheDataReserve, "reserve", 3600, "data reserve of cache, overwritten by \"reserve\" in meta of service")
+	flag.StringVar(&configure.Options.JournalDir, "journal_dir", "", "journal directory of cache, replayed to warm up the cache after restart, empty means disabled")
+	flag.IntVar(&configure.Options.JournalCheckpoint, "journal_checkpoint", 300, "interval(second) of switching journal file and removing the expired ones")
 
 	flag.IntVar(&configure.Options.MongoStoreSessionListCount, "session_count", 10, "mongo session count")
 
+	flag.StringVar(&configure.Options.StorageEngineName, "engine", "mongo", "storage engine: mongo or local")
+	flag.StringVar(&configure.Options.LocalDataDir, "local_dir", "data", "data directory of local engine")
+	flag.IntVar(&configure.Options.LocalSegmentInterval, "local_segment", 3600, "time span(second) of each segment file of local engine")
+	flag.IntVar(&configure.Options.DataRetention, "retention", 24, "hours to keep raw data, 0 means never delete, overwritten by \"retention\" in meta of service")
+	flag.IntVar(&configure.Options.Rollup1mRetention, "rollup_1m_retention", 24*30, "hours to keep 1-minute rollups, 0 means never delete, negative disables the tier")
+	flag.IntVar(&configure.Options.Rollup1hRetention, "rollup_1h_retention", 24*365, "hours to keep 1-hour rollups, 0 means never delete, negative disables the tier")
+
 	flag.IntVar(&configure.Options.MonitorPort, "monitor_port", 9096, "monitor port of store server")
 
 	var version bool
@@ -114,6 +140,7 @@ func main() {
 
 	// http server
 	http.HandleFunc("/", indexHandler)
+	http.HandleFunc("/stats", statsHandler)
 	http.ListenAndServe(fmt.Sprintf(":%d", configure.Options.MonitorPort), nil)
 }
 
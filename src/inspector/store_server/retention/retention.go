@@ -0,0 +1,254 @@
+/*
+// =====================================================================================
+//
+//       Filename:  retention.go
+//
+//    Description:  按照service的meta文档中声明的保留策略，调整rollup的清理、
+//                  存储引擎的TTL索引以及TimeCache的保留时间，meta变化时自动调整：
+//                  "retention" : { "raw" : 24, "1m" : 720, "1h" : 8760 }  单位小时，0表示不删除
+//                  "reserve" : 3600                                       TimeCache保留的时间，单位秒
+//                  没有声明的部分使用启动参数的全局配置
+//
+//        Version:  1.0
+//        Created:  10/26/2026 04:37:09 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package retention
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"inspector/cache"
+	"inspector/config"
+	"inspector/store_server/engine"
+	"inspector/store_server/rollup"
+	"inspector/util"
+
+	"github.com/golang/glog"
+)
+
+const (
+	RetentionKey = "retention"
+	ReserveKey   = "reserve"
+)
+
+// =====================================================================================
+//       Struct:  Policy
+//  Description:  meta中声明的保留策略，Reserve为TimeCache保留的虚拟时间，0表示没有声明
+// =====================================================================================
+type Policy struct {
+	Retention rollup.Retention
+	Reserve   uint32
+}
+
+func (p *Policy) retention() rollup.Retention {
+	if p == nil {
+		return nil
+	}
+	return p.Retention
+}
+
+func (p *Policy) reserve() uint32 {
+	if p == nil {
+		return 0
+	}
+	return p.Reserve
+}
+
+func (p *Policy) String() string {
+	if p == nil {
+		return "default"
+	}
+	return fmt.Sprintf("retention%v reserve[%d]", p.Retention, p.Reserve)
+}
+
+func (p *Policy) equal(q *Policy) bool {
+	if p.reserve() != q.reserve() || len(p.retention()) != len(q.retention()) {
+		return false
+	}
+	for name, v := range p.retention() {
+		if w, ok := q.retention()[name]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+// =====================================================================================
+//       Struct:  Manager
+//  Description:  policyMap为已经生效的保留策略，只有声明过保留时间的数据才会调整TTL索引，
+//                没有声明的service保持create_index.js创建的索引不变
+// =====================================================================================
+type Manager struct {
+	cs        config.ConfigInterface
+	storage   engine.Engine
+	rollup    *rollup.Rollup
+	cache     *cache.TimeCache
+	nameList  []string // rollup.RawRetention以及启用的层级
+	lock      sync.Mutex
+	policyMap map[string]*Policy
+}
+
+func NewManager(cs config.ConfigInterface, storage engine.Engine, r *rollup.Rollup, tc *cache.TimeCache) *Manager {
+	var m = &Manager{
+		cs:        cs,
+		storage:   storage,
+		rollup:    r,
+		cache:     tc,
+		nameList:  []string{rollup.RawRetention},
+		policyMap: make(map[string]*Policy),
+	}
+	for _, it := range r.TierList() {
+		m.nameList = append(m.nameList, it.Name)
+	}
+	return m
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Start
+//  Description:  先按照当前的meta调整一次，之后通过watcher监听meta的变化
+// =====================================================================================
+*/
+func (m *Manager) Start() error {
+	m.reconcile(config.NODEALL)
+
+	var watcher = &config.Watcher{
+		Event:   config.NODEALL,
+		Handler: m.reconcile,
+	}
+	if err := m.cs.RegisterGlobalWatcher(util.MetaCollection, "", watcher); err != nil {
+		return fmt.Errorf("register meta watcher error: %s", err.Error())
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  reconcile
+//  Description:  对比每个service的保留策略与已经生效的策略，不同时重新调整，
+//                meta中删除的service恢复全局配置
+// =====================================================================================
+*/
+func (m *Manager) reconcile(event config.WatcheEvent) error {
+	glog.V(1).Infof("[Trace][Manager.reconcile] called: event[%v]", event)
+
+	var serviceList, err = m.cs.GetKeyList(util.MetaCollection)
+	if err != nil {
+		glog.Errorf("get service list from meta error: %s", err.Error())
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var serviceMap = make(map[string]bool, len(serviceList))
+	for _, service := range serviceList {
+		serviceMap[service] = true
+		if err := m.apply(service, m.load(service)); err != nil {
+			glog.Errorf("apply retention policy of service[%s] error: %s", service, err.Error())
+		}
+	}
+	for service := range m.policyMap {
+		if serviceMap[service] {
+			continue
+		}
+		if err := m.apply(service, nil); err != nil {
+			glog.Errorf("reset retention policy of service[%s] error: %s", service, err.Error())
+		}
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  load
+//  Description:  读取meta中的保留策略，没有声明时返回nil，非法的值忽略
+// =====================================================================================
+*/
+func (m *Manager) load(service string) *Policy {
+	var policy = new(Policy)
+	for _, name := range m.nameList {
+		var hours, err = m.cs.GetInt(util.MetaCollection, service, RetentionKey, name)
+		if err != nil {
+			continue
+		}
+		if hours < 0 {
+			glog.Warningf("retention[%s] of service[%s] is invalid: %d", name, service, hours)
+			continue
+		}
+		if policy.Retention == nil {
+			policy.Retention = make(rollup.Retention)
+		}
+		policy.Retention[name] = time.Duration(hours) * time.Hour
+	}
+
+	if reserve, err := m.cs.GetInt(util.MetaCollection, service, ReserveKey); err == nil {
+		// TimeCache使用虚拟时间，按照采集间隔换算
+		var interval, err = m.cs.GetInt(util.MetaCollection, service, "interval")
+		if err != nil || interval <= 0 {
+			interval = 1
+		}
+		if reserve > 0 {
+			policy.Reserve = uint32((reserve + interval - 1) / interval)
+		} else {
+			glog.Warningf("reserve of service[%s] is invalid: %d", service, reserve)
+		}
+	}
+
+	if policy.Retention == nil && policy.Reserve == 0 {
+		return nil
+	}
+	return policy
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  apply
+//  Description:  policy为nil表示恢复全局配置。新旧策略中声明过的数据按照生效的保留时间
+//                调整TTL索引，失败时不记录，下次meta变化时重试
+// =====================================================================================
+*/
+func (m *Manager) apply(service string, policy *Policy) error {
+	var old = m.policyMap[service]
+	if old.equal(policy) {
+		return nil
+	}
+	glog.Infof("retention policy of service[%s] changed: %v -> %v", service, old, policy)
+
+	m.rollup.SetRetention(service, policy.retention())
+	m.cache.SetReserve(service, policy.reserve())
+
+	var errList []string
+	for _, name := range m.nameList {
+		var _, inOld = old.retention()[name]
+		var _, inNew = policy.retention()[name]
+		if !inOld && !inNew {
+			continue
+		}
+		var retention, _ = m.rollup.RetentionOf(service, name)
+		var target = service
+		if name != rollup.RawRetention {
+			target = service + rollup.TierSeparator + name
+		}
+		if err := m.storage.SetRetention(target, retention); err != nil {
+			errList = append(errList, err.Error())
+		}
+	}
+	if len(errList) > 0 {
+		delete(m.policyMap, service)
+		return fmt.Errorf("%s", strings.Join(errList, "; "))
+	}
+
+	if policy == nil {
+		delete(m.policyMap, service)
+	} else {
+		m.policyMap[service] = policy
+	}
+	return nil
+}
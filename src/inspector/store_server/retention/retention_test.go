@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"inspector/cache"
+	"inspector/config"
+	"inspector/store_server/engine"
+	"inspector/store_server/rollup"
+)
+
+// 只记录SetRetention的引擎
+type fakeEngine struct {
+	engine.Engine
+	retentionMap map[string]time.Duration
+}
+
+func (e *fakeEngine) SetRetention(service string, retention time.Duration) error {
+	e.retentionMap[service] = retention
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	var dir, err = ioutil.TempDir("", "retention")
+	check(err == nil, "create temp dir")
+	defer os.RemoveAll(dir)
+	var filename = filepath.Join(dir, "meta.cfg")
+	var writeMeta = func(content string) {
+		check(ioutil.WriteFile(filename, []byte("[meta]\n"+content), 0644) == nil, "write meta")
+	}
+	writeMeta(`redis = {"count":60, "interval":1, "retention":{"raw":48, "1h":0, "5m":1}, "reserve":600}
+mysql = {"count":60, "interval":5, "retention":{"1m":-1}, "reserve":600}
+mongo = {"count":60, "interval":1}
+`)
+	var cs config.ConfigInterface
+	cs, err = (&config.ConfigFactory{Name: config.LocalConfigName}).Create(filename, "", "", "", 0)
+	check(err == nil, fmt.Sprintf("create config error: %v", err))
+
+	var day = 24 * time.Hour
+	var storage = &fakeEngine{retentionMap: make(map[string]time.Duration)}
+	var r *rollup.Rollup
+	r, err = rollup.New(storage, []*rollup.Tier{
+		{Name: "1m", Interval: 60, Retention: 30 * day},
+		{Name: "1h", Interval: 3600, Retention: 365 * day},
+	}, day)
+	check(err == nil, "new rollup")
+	var tc = cache.NewTimeCache(1, 3600)
+	var m = NewManager(cs, storage, r, tc)
+
+	// 没有启用的层级以及非法的值忽略，没有声明的service不调整
+	check(m.reconcile(config.NODEALL) == nil, "reconcile")
+	check(fmt.Sprint(storage.retentionMap) == "map[redis:48h0m0s redis@1h:0s]", fmt.Sprint("ttl: ", storage.retentionMap))
+	var retention, _ = r.RetentionOf("redis", rollup.RawRetention)
+	check(retention == 2*day, fmt.Sprint("raw retention: ", retention))
+	retention, _ = r.RetentionOf("redis", "1m")
+	check(retention == 30*day, fmt.Sprint("1m retention: ", retention))
+	check(tc.GetReserve("redis") == 600 && tc.GetReserve("mysql") == 120 && tc.GetReserve("mongo") == 3600,
+		fmt.Sprint("reserve: ", tc.GetReserve("redis"), tc.GetReserve("mysql"), tc.GetReserve("mongo")))
+
+	// 没有变化时不重复调整
+	storage.retentionMap = make(map[string]time.Duration)
+	check(m.reconcile(config.NODEALL) == nil, "reconcile")
+	check(len(storage.retentionMap) == 0, fmt.Sprint("ttl should not change: ", storage.retentionMap))
+
+	// 删除声明后恢复全局配置，meta中删除的service同样恢复
+	writeMeta(`redis = {"count":60, "interval":1, "retention":{"1m":24}}
+mongo = {"count":60, "interval":1}
+`)
+	check(cs.EstablishConnect() == nil, "reload meta")
+	check(m.reconcile(config.NODEALL) == nil, "reconcile")
+	check(fmt.Sprint(storage.retentionMap) == "map[redis:24h0m0s redis@1h:8760h0m0s redis@1m:24h0m0s]",
+		fmt.Sprint("ttl: ", storage.retentionMap))
+	retention, _ = r.RetentionOf("redis", rollup.RawRetention)
+	check(retention == day, fmt.Sprint("raw retention: ", retention))
+	check(tc.GetReserve("redis") == 3600 && tc.GetReserve("mysql") == 3600, "reserve should be reset")
+	check(len(m.policyMap) == 1 && m.policyMap["redis"] != nil, fmt.Sprint("policy: ", m.policyMap))
+}
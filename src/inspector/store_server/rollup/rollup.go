@@ -0,0 +1,811 @@
+/*
+// =====================================================================================
+//
+//       Filename:  rollup.go
+//
+//    Description:  将原始数据块按小时聚合为分钟、小时等粗粒度的min/max/avg/last数据，
+//                  每个层级单独保存为"service@层级"，key为"key#聚合方式"，
+//                  层级与原始数据分别按照各自的保留时间删除
+//
+//        Version:  1.0
+//        Created:  10/24/2026 02:15:41 PM
+//       Compiler:  go1.10.3
+//
+// =====================================================================================
+*/
+
+package rollup
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"inspector/compress"
+	"inspector/proto/core"
+	"inspector/store_server/engine"
+	"inspector/util"
+
+	"github.com/golang/glog"
+)
+
+const (
+	TierSeparator = "@"
+	KeySeparator  = "#"
+
+	AggregateMin  = "min"
+	AggregateMax  = "max"
+	AggregateAvg  = "avg"
+	AggregateLast = "last"
+
+	hourSeconds     = 3600
+	processDelay    = 5 * time.Minute // 整点之后等待迟到的数据块
+	recoverWindow   = 24 * time.Hour  // 重启之后最多补做的聚合
+	cleanupInterval = time.Hour
+)
+
+var AggregateList = []string{AggregateMin, AggregateMax, AggregateAvg, AggregateLast}
+
+// 原始数据在Retention中的名称
+const RawRetention = "raw"
+
+// =====================================================================================
+//         Type:  Retention
+//  Description:  单个service的保留时间，key为RawRetention或者层级名称，0表示不删除，
+//                没有的key使用全局配置
+// =====================================================================================
+type Retention map[string]time.Duration
+
+// =====================================================================================
+//       Struct:  Tier
+//  Description:  聚合层级，Interval需要能整除3600，Retention为0表示不删除
+// =====================================================================================
+type Tier struct {
+	Name      string
+	Interval  uint32 // second
+	Retention time.Duration
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  TierService
+//  Description:  层级数据在存储引擎中的service名称
+// =====================================================================================
+*/
+func TierService(service string, tier *Tier) string {
+	return service + TierSeparator + tier.Name
+}
+
+type hostKey struct {
+	hid  int32
+	host string
+}
+
+// =====================================================================================
+//       Struct:  serviceState
+//  Description:  service的采集间隔以及等待聚合的小时，hostSet为启动之后写入过的host，
+//                recover为需要从存储引擎中找回重启前没有完成的聚合的host
+// =====================================================================================
+type serviceState struct {
+	step    uint32
+	count   uint32
+	hostSet map[hostKey]bool
+	pending map[int64]map[hostKey]bool // 整点(unix秒) -> host
+	recover map[hostKey]int64          // host -> 启动之后第一个数据块所在的整点
+}
+
+// =====================================================================================
+//       Struct:  Rollup
+//  Description:  写入时通过Observe记录需要聚合的小时，整点之后定期从存储引擎读出
+//                原始数据进行聚合；查询时根据范围与精度选择层级
+// =====================================================================================
+type Rollup struct {
+	storage      engine.Engine
+	tierList     []*Tier // 按照Interval从小到大
+	rawRetention time.Duration
+	lock         sync.Mutex
+	serviceMap   map[string]*serviceState
+	retentionMap map[string]Retention // service -> meta中声明的保留时间
+	lastCleanup  time.Time
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  New
+//  Description:  rawRetention为原始数据的保留时间，0表示不删除
+// =====================================================================================
+*/
+func New(storage engine.Engine, tierList []*Tier, rawRetention time.Duration) (*Rollup, error) {
+	if rawRetention < 0 {
+		rawRetention = 0
+	}
+	var r = &Rollup{
+		storage:      storage,
+		rawRetention: rawRetention,
+		serviceMap:   make(map[string]*serviceState),
+		retentionMap: make(map[string]Retention),
+	}
+	var nameMap = make(map[string]bool)
+	for _, it := range tierList {
+		if len(it.Name) == 0 || it.Name == RawRetention || strings.Contains(it.Name, TierSeparator) || nameMap[it.Name] {
+			return nil, fmt.Errorf("rollup tier name[%s] is invalid", it.Name)
+		}
+		if it.Interval == 0 || hourSeconds%it.Interval != 0 {
+			return nil, fmt.Errorf("interval[%d] of rollup tier[%s] should divide %d", it.Interval, it.Name, hourSeconds)
+		}
+		if it.Retention < 0 {
+			return nil, fmt.Errorf("retention[%v] of rollup tier[%s] is invalid", it.Retention, it.Name)
+		}
+		nameMap[it.Name] = true
+		r.tierList = append(r.tierList, it)
+	}
+	sort.Slice(r.tierList, func(i, j int) bool {
+		return r.tierList[i].Interval < r.tierList[j].Interval
+	})
+	return r, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Start
+//  Description:  每分钟聚合已经结束的小时，每小时删除一次过期数据
+// =====================================================================================
+*/
+func (r *Rollup) Start() {
+	go func() {
+		for now := range time.Tick(time.Minute) {
+			r.process(now)
+			if now.Sub(r.lastCleanup) >= cleanupInterval {
+				r.cleanup(now)
+				r.lastCleanup = now
+			}
+		}
+	}()
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Observe
+//  Description:  记录写入的数据块所在的小时，跨越整点的数据块两个小时都需要聚合
+//                启动之后第一次出现的host，由process从存储引擎中找回之前没有聚合的小时
+// =====================================================================================
+*/
+func (r *Rollup) Observe(infoList []*core.Info) {
+	if len(r.tierList) == 0 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, it := range infoList {
+		var service = it.GetHeader().GetService()
+		if it.Step == 0 || it.Count == 0 || strings.Contains(service, TierSeparator) {
+			continue
+		}
+		var state = r.getState(service)
+		state.step = it.Step
+		state.count = it.Count
+
+		var key = hostKey{hid: it.Header.Hid, host: it.Header.Host}
+		var begin = int64(it.Timestamp) * int64(it.Step)
+		var end = begin + int64(it.Count)*int64(it.Step) - 1
+		if !state.hostSet[key] {
+			state.hostSet[key] = true
+			state.recover[key] = begin - begin%hourSeconds
+		}
+		state.addPending(begin-begin%hourSeconds, key)
+		state.addPending(end-end%hourSeconds, key)
+	}
+}
+
+func (r *Rollup) getState(service string) *serviceState {
+	var state, ok = r.serviceMap[service]
+	if !ok {
+		state = &serviceState{
+			hostSet: make(map[hostKey]bool),
+			pending: make(map[int64]map[hostKey]bool),
+			recover: make(map[hostKey]int64),
+		}
+		r.serviceMap[service] = state
+	}
+	return state
+}
+
+func (s *serviceState) addPending(hour int64, key hostKey) {
+	if hour < 0 {
+		return
+	}
+	var hostSet, ok = s.pending[hour]
+	if !ok {
+		hostSet = make(map[hostKey]bool)
+		s.pending[hour] = hostSet
+	}
+	hostSet[key] = true
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  process
+//  Description:  聚合所有已经结束的小时，失败的下次重试
+// =====================================================================================
+*/
+func (r *Rollup) process(now time.Time) {
+	type task struct {
+		service     string
+		step, count uint32
+		hour        int64
+		key         hostKey
+	}
+
+	// 先找回重启前没有完成的小时
+	var recoverList []task
+	r.lock.Lock()
+	for service, state := range r.serviceMap {
+		for key, hour := range state.recover {
+			recoverList = append(recoverList, task{service, state.step, state.count, hour, key})
+		}
+		state.recover = make(map[hostKey]int64)
+	}
+	r.lock.Unlock()
+	for _, it := range recoverList {
+		var hourList, err = r.recoverHours(it.service, it.step, it.hour, it.key)
+		r.lock.Lock()
+		var state = r.getState(it.service)
+		if err != nil {
+			glog.Errorf("recover rollup of service[%s] hid[%d] host[%s] error: %s",
+				it.service, it.key.hid, it.key.host, err.Error())
+			state.recover[it.key] = it.hour
+		}
+		for _, hour := range hourList {
+			state.addPending(hour, it.key)
+		}
+		r.lock.Unlock()
+	}
+
+	var taskList []task
+	r.lock.Lock()
+	for service, state := range r.serviceMap {
+		for hour, hostSet := range state.pending {
+			if time.Unix(hour+hourSeconds, 0).Add(processDelay).After(now) {
+				continue
+			}
+			for key := range hostSet {
+				taskList = append(taskList, task{service, state.step, state.count, hour, key})
+			}
+			delete(state.pending, hour)
+		}
+	}
+	r.lock.Unlock()
+
+	for _, it := range taskList {
+		if err := r.rollupHour(it.service, it.step, it.count, it.hour, it.key); err != nil {
+			glog.Errorf("rollup service[%s] hid[%d] host[%s] hour[%d] error: %s",
+				it.service, it.key.hid, it.key.host, it.hour, err.Error())
+			r.lock.Lock()
+			r.getState(it.service).addPending(it.hour, it.key)
+			r.lock.Unlock()
+		}
+	}
+	if len(taskList) > 0 {
+		glog.V(2).Infof("[Trace][Rollup.process] %d tasks done", len(taskList))
+	}
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  recoverHours
+//  Description:  从最细的层级中找到host最后一个已经聚合的小时，返回之后到hour之前
+//                需要补做的小时，最多recoverWindow。没有层级数据时只补做前一个小时
+// =====================================================================================
+*/
+func (r *Rollup) recoverHours(service string, step uint32, hour int64, key hostKey) ([]int64, error) {
+	if step == 0 || hourSeconds%step != 0 {
+		return nil, nil
+	}
+	var tier *Tier
+	for _, it := range r.tierList {
+		if it.Interval > step && it.Interval%step == 0 {
+			tier = it
+			break
+		}
+	}
+	if tier == nil {
+		return nil, nil
+	}
+
+	var begin = hour - int64(recoverWindow/time.Second)
+	if begin < 0 {
+		begin = 0
+	}
+	var query = &core.Query{Header: &core.Header{Service: TierService(service, tier), Hid: key.hid, Host: key.host}}
+	var res, err = r.storage.Query(query, uint32(begin/int64(step)), uint32(hour/int64(step))-1)
+	if err != nil {
+		return nil, err
+	}
+	var last = hour - 2*hourSeconds
+	if res != nil {
+		var _, blockMap, err = engine.ParseRangeData(res.Data)
+		if err != nil {
+			return nil, err
+		}
+		last = begin - hourSeconds
+		for _, blockList := range blockMap {
+			for _, block := range blockList {
+				if t := int64(block.Timestamp) * int64(res.Step); t > last {
+					last = t
+				}
+			}
+		}
+	}
+
+	var hourList []int64
+	for it := last + hourSeconds; it < hour; it += hourSeconds {
+		hourList = append(hourList, it)
+	}
+	glog.V(2).Infof("[Trace][Rollup.recoverHours] service[%s] host[%s] recover %d hours",
+		service, key.host, len(hourList))
+	return hourList, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  rollupHour
+//  Description:  读出一个host一个小时的原始数据，聚合后写入每个比采集间隔粗的层级。
+//                层级数据块与原始数据使用相同的虚拟时间：Timestamp为整点，Step为采集间隔，
+//                Count为一个小时的点数，value为这个小时内按照层级间隔聚合的结果
+// =====================================================================================
+*/
+func (r *Rollup) rollupHour(service string, step, count uint32, hour int64, key hostKey) error {
+	if step == 0 || hourSeconds%step != 0 {
+		glog.V(2).Infof("[Trace][Rollup.rollupHour] service[%s] step[%d] can't be aligned to hour", service, step)
+		return nil
+	}
+	var points = hourSeconds / step
+	var hourBegin = uint32(hour / int64(step))
+	// 开始于整点之前的数据块也可能包含这个小时的数据
+	var begin uint32
+	if hourBegin+1 > count {
+		begin = hourBegin + 1 - count
+	}
+
+	var query = &core.Query{Header: &core.Header{Service: service, Hid: key.hid, Host: key.host}}
+	var res, err = r.storage.Query(query, begin, hourBegin+points-1)
+	if err != nil || res == nil {
+		return err
+	}
+	var keyList []string
+	var blockMap map[string][]engine.Block
+	if keyList, blockMap, err = engine.ParseRangeData(res.Data); err != nil {
+		return err
+	}
+
+	var infoMap = make(map[string]*core.Info)
+	for _, k := range keyList {
+		var values = make([]int64, points)
+		for i := range values {
+			values[i] = util.NullData
+		}
+		for _, block := range blockMap[k] {
+			var data, err = compress.Decompress(block.Value, nil)
+			if err != nil {
+				glog.Errorf("rollup service[%s] key[%s] decompress error: %s", service, k, err.Error())
+				continue
+			}
+			for j, v := range data {
+				var index = int64(block.Timestamp) + int64(j) - int64(hourBegin)
+				if index >= 0 && index < int64(points) {
+					values[index] = v
+				}
+			}
+		}
+
+		for _, tier := range r.tierList {
+			if tier.Interval <= step || tier.Interval%step != 0 {
+				continue
+			}
+			for agg, result := range aggregate(values, int(tier.Interval/step)) {
+				var value []byte
+				if value, err = compressValues(result); err != nil {
+					return err
+				} else if value == nil {
+					continue
+				}
+				var info, ok = infoMap[tier.Name]
+				if !ok {
+					info = &core.Info{
+						Header:    &core.Header{Service: TierService(service, tier), Hid: key.hid, Host: key.host},
+						Timestamp: hourBegin,
+						Count:     points,
+						Step:      step,
+					}
+					infoMap[tier.Name] = info
+				}
+				info.Items = append(info.Items, &core.KVPair{Key: k + KeySeparator + agg, Value: value})
+			}
+		}
+	}
+	if len(infoMap) == 0 {
+		return nil
+	}
+
+	var infoList = make([]*core.Info, 0, len(infoMap))
+	for _, it := range infoMap {
+		infoList = append(infoList, it)
+	}
+	return r.storage.Save(infoList)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  aggregate
+//  Description:  每width个点聚合为一个点，忽略空值，全部为空时结果为空值，avg四舍五入
+// =====================================================================================
+*/
+func aggregate(values []int64, width int) map[string][]int64 {
+	var n = len(values) / width
+	var result = make(map[string][]int64, len(AggregateList))
+	for _, agg := range AggregateList {
+		result[agg] = make([]int64, n)
+	}
+	for i := 0; i < n; i++ {
+		var min, max, last = util.NullData, util.NullData, util.NullData
+		var sum float64
+		var cnt int
+		for _, v := range values[i*width : (i+1)*width] {
+			if v == util.NullData {
+				continue
+			}
+			if cnt == 0 || v < min {
+				min = v
+			}
+			if cnt == 0 || v > max {
+				max = v
+			}
+			last = v
+			sum += float64(v)
+			cnt++
+		}
+		var avg = util.NullData
+		if cnt > 0 {
+			avg = int64(math.Round(sum / float64(cnt)))
+			// 浮点误差可能使平均值略微超出[min, max]
+			if avg < min {
+				avg = min
+			} else if avg > max {
+				avg = max
+			}
+		}
+		result[AggregateMin][i] = min
+		result[AggregateMax][i] = max
+		result[AggregateAvg][i] = avg
+		result[AggregateLast][i] = last
+	}
+	return result
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  compressValues
+//  Description:  与collector相同：全部相同时使用SameDigitCompress，否则使用DiffCompress
+//                全部为空时返回nil
+// =====================================================================================
+*/
+func compressValues(data []int64) ([]byte, error) {
+	var same = true
+	var empty = true
+	var gcd int64
+	for _, it := range data {
+		if it == util.NullData || it != data[0] {
+			same = false
+		}
+		if it != util.NullData {
+			empty = false
+			gcd = util.GCD(gcd, it)
+		}
+	}
+	if empty {
+		return nil, nil
+	}
+	if same {
+		return compress.Compress(compress.SameDigitCompress, len(data), data[0])
+	}
+	return compress.Compress(compress.DiffCompress, gcd, data)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Query
+//  Description:  范围超出原始数据的保留时间或者请求的Step不小于层级间隔时，从层级中查询。
+//                层级的每个点展开为原始精度的SameDigit数据块，InfoRange的Count为层级间隔
+//                对应的点数，api_server不需要区分；返回的rawBegin之后的部分还没有聚合，
+//                需要查询原始数据，不使用层级时rawBegin为TimeBegin。rawBegin为第一个
+//                缺失的小时，之后的层级数据不返回
+// =====================================================================================
+*/
+func (r *Rollup) Query(input *core.Query, now time.Time) (*core.InfoRange, uint32, error) {
+	var rawBegin = input.TimeBegin
+	var service = input.GetHeader().GetService()
+	if len(r.tierList) == 0 || input.TimeBegin > input.TimeEnd || strings.Contains(service, TierSeparator) {
+		return nil, rawBegin, nil
+	}
+
+	var step = r.stepOf(input)
+	var tier = r.selectTier(service, step, input.TimeBegin, input.Step, now)
+	if tier == nil {
+		return nil, rawBegin, nil
+	}
+
+	var agg = AggregateAvg
+	for _, it := range AggregateList {
+		if input.Aggregate == it {
+			agg = it
+		}
+	}
+	var suffix = KeySeparator + agg
+	var tierQuery = &core.Query{
+		Header: &core.Header{Service: TierService(service, tier), Hid: input.Header.Hid, Host: input.Header.Host},
+	}
+	for _, it := range input.KeyList {
+		tierQuery.KeyList = append(tierQuery.KeyList, it+suffix)
+	}
+	// 层级数据块从整点开始，向前取到TimeBegin所在的整点
+	var beginHour = int64(input.TimeBegin) * int64(step)
+	beginHour -= beginHour % hourSeconds
+	var res, err = r.storage.Query(tierQuery, uint32(beginHour/int64(step)), input.TimeEnd)
+	if err != nil || res == nil {
+		return nil, rawBegin, err
+	}
+	if res.Step == 0 || tier.Interval%res.Step != 0 || hourSeconds%res.Step != 0 {
+		return nil, rawBegin, fmt.Errorf("step[%d] of rollup tier[%s] is invalid", res.Step, tier.Name)
+	}
+	var keyList []string
+	var blockMap map[string][]engine.Block
+	if keyList, blockMap, err = engine.ParseRangeData(res.Data); err != nil {
+		return nil, rawBegin, err
+	}
+
+	var width = tier.Interval / res.Step
+	var hourPoints = hourSeconds / res.Step
+	// 层级中已有的小时，从第一个缺失的小时开始查询原始数据，避免聚合失败或者还没有聚合的小时
+	// 出现空洞。超出原始数据保留时间的小时已经无法补上，跳过
+	var hourSet = make(map[uint32]bool)
+	var tierEnd uint32
+	for _, k := range keyList {
+		if !strings.HasSuffix(k, suffix) {
+			continue
+		}
+		for _, block := range blockMap[k] {
+			hourSet[block.Timestamp] = true
+			if end := block.Timestamp + hourPoints; end > tierEnd {
+				tierEnd = end
+			}
+		}
+	}
+	var rawRetention, _ = r.RetentionOf(service, RawRetention)
+	var rawFloor int64
+	if rawRetention > 0 {
+		rawFloor = now.Add(-rawRetention).Unix() / int64(res.Step)
+	}
+	for hour := uint32(beginHour / int64(res.Step)); hour < tierEnd; hour += hourPoints {
+		if !hourSet[hour] && int64(hour+hourPoints) > rawFloor {
+			tierEnd = hour
+			break
+		}
+	}
+	if tierEnd > rawBegin {
+		rawBegin = tierEnd
+	}
+
+	var builder = engine.NewRangeBuilder()
+	builder.SetHeader(service, res.Header.Hid, res.Header.Host, width, res.Step)
+	for _, k := range keyList {
+		if !strings.HasSuffix(k, suffix) {
+			continue
+		}
+		var key = strings.TrimSuffix(k, suffix)
+		for _, block := range blockMap[k] {
+			if block.Timestamp >= rawBegin {
+				continue
+			}
+			var data, err = compress.Decompress(block.Value, nil)
+			if err != nil {
+				glog.Errorf("query rollup tier[%s] key[%s] decompress error: %s", tier.Name, k, err.Error())
+				continue
+			}
+			for j, v := range data {
+				var timestamp = block.Timestamp + uint32(j)*width
+				if v == util.NullData || timestamp+width <= input.TimeBegin || timestamp > input.TimeEnd {
+					continue
+				}
+				var value []byte
+				if value, err = compress.Compress(compress.SameDigitCompress, int(width), v); err != nil {
+					return nil, input.TimeBegin, err
+				}
+				builder.Add(key, timestamp, value)
+			}
+		}
+	}
+	if rawBegin > input.TimeEnd {
+		rawBegin = input.TimeEnd + 1
+	}
+	glog.V(2).Infof("[Trace][Rollup.Query] service[%s] host[%s] use tier[%s], raw begin[%d]",
+		service, input.Header.Host, tier.Name, rawBegin)
+	return builder.Build(), rawBegin, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  stepOf
+//  Description:  service的采集间隔，重启后还没有写入的service从最粗的层级中获取
+// =====================================================================================
+*/
+func (r *Rollup) stepOf(input *core.Query) uint32 {
+	var service = input.Header.Service
+	r.lock.Lock()
+	var state, ok = r.serviceMap[service]
+	r.lock.Unlock()
+	if ok && state.step != 0 {
+		return state.step
+	}
+
+	var tier = r.tierList[len(r.tierList)-1]
+	var query = &core.Query{
+		Header: &core.Header{Service: TierService(service, tier), Hid: input.Header.Hid, Host: input.Header.Host},
+	}
+	if len(input.KeyList) > 0 {
+		query.KeyList = []string{input.KeyList[0] + KeySeparator + AggregateAvg}
+	}
+	// 采集间隔不小于1秒，TimeBegin所在的整点不会早于TimeBegin-3600
+	var begin uint32
+	if input.TimeBegin > hourSeconds {
+		begin = input.TimeBegin - hourSeconds
+	}
+	var res, err = r.storage.Query(query, begin, input.TimeEnd)
+	if err != nil || res == nil {
+		return 0
+	}
+	r.lock.Lock()
+	state = r.getState(service)
+	if state.step == 0 {
+		state.step = res.Step
+	}
+	r.lock.Unlock()
+	return res.Step
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  selectTier
+//  Description:  begin为虚拟时间，resolution为请求的精度(秒)，返回nil时使用原始数据
+//                1. 间隔不超过resolution且保留时间覆盖begin的最粗层级
+//                2. 原始数据覆盖begin时使用原始数据
+//                3. 保留时间覆盖begin的最细层级
+//                4. 都无法覆盖时使用保留时间最长的
+// =====================================================================================
+*/
+func (r *Rollup) selectTier(service string, step, begin, resolution uint32, now time.Time) *Tier {
+	if step == 0 {
+		return nil
+	}
+	var beginTime = int64(begin) * int64(step)
+	var covers = func(retention time.Duration) bool {
+		return retention == 0 || beginTime >= now.Add(-retention).Unix()
+	}
+
+	var candidateList []*Tier
+	var retentionList []time.Duration
+	for _, it := range r.tierList {
+		if it.Interval > step && it.Interval%step == 0 {
+			var retention, _ = r.RetentionOf(service, it.Name)
+			candidateList = append(candidateList, it)
+			retentionList = append(retentionList, retention)
+		}
+	}
+	for i := len(candidateList) - 1; i >= 0; i-- {
+		if candidateList[i].Interval <= resolution && covers(retentionList[i]) {
+			return candidateList[i]
+		}
+	}
+	var rawRetention, _ = r.RetentionOf(service, RawRetention)
+	if covers(rawRetention) {
+		return nil
+	}
+	var longest = -1
+	for i := range candidateList {
+		if covers(retentionList[i]) {
+			return candidateList[i]
+		}
+		if longest < 0 || retentionList[i] > retentionList[longest] {
+			longest = i
+		}
+	}
+	if longest >= 0 && retentionList[longest] > rawRetention {
+		return candidateList[longest]
+	}
+	return nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  SetRetention
+//  Description:  设置service在meta中声明的保留时间，覆盖全局配置，retention为空时恢复全局配置
+// =====================================================================================
+*/
+func (r *Rollup) SetRetention(service string, retention Retention) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(retention) == 0 {
+		delete(r.retentionMap, service)
+		return
+	}
+	r.retentionMap[service] = retention
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  RetentionOf
+//  Description:  name为RawRetention或者层级名称，返回service生效的保留时间，
+//                层级没有启用时返回false
+// =====================================================================================
+*/
+func (r *Rollup) RetentionOf(service, name string) (time.Duration, bool) {
+	var retention = r.rawRetention
+	if name != RawRetention {
+		var tier = r.tierByName(name)
+		if tier == nil {
+			return 0, false
+		}
+		retention = tier.Retention
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if v, ok := r.retentionMap[service][name]; ok {
+		retention = v
+	}
+	return retention, true
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  cleanup
+//  Description:  按照保留时间删除原始数据以及各个层级的数据，未启用的层级不删除
+// =====================================================================================
+*/
+func (r *Rollup) cleanup(now time.Time) {
+	var stats, err = r.storage.Stats()
+	if err != nil {
+		glog.Errorf("get stats of storage engine error: %s", err.Error())
+		return
+	}
+	for service := range stats.Services {
+		var base, name = service, RawRetention
+		if i := strings.LastIndex(service, TierSeparator); i >= 0 {
+			base, name = service[:i], service[i+len(TierSeparator):]
+		}
+		var retention, ok = r.RetentionOf(base, name)
+		if !ok || retention <= 0 {
+			continue
+		}
+		if err = r.storage.Delete(service, now.Add(-retention)); err != nil {
+			glog.Errorf("delete expired data of service[%s] error: %s", service, err.Error())
+		}
+	}
+}
+
+// 启用的层级，按照Interval从小到大，调用方不能修改
+func (r *Rollup) TierList() []*Tier {
+	return r.tierList
+}
+
+func (r *Rollup) tierByName(name string) *Tier {
+	for _, it := range r.tierList {
+		if it.Name == name {
+			return it
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package rollup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"inspector/compress"
+	"inspector/proto/core"
+	"inspector/store_server/engine"
+	"inspector/util"
+)
+
+// 解析InfoRange.Data，返回key -> 解压后按顺序拼接的数据
+func decodeRange(res *core.InfoRange) map[string][]int64 {
+	var _, blockMap, err = engine.ParseRangeData(res.Data)
+	if err != nil {
+		return nil
+	}
+	var result = make(map[string][]int64)
+	for key, blockList := range blockMap {
+		for _, it := range blockList {
+			result[key], _ = compress.Decompress(it.Value, result[key])
+		}
+	}
+	return result
+}
+
+func TestAggregate(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	var null = util.NullData
+	var result = aggregate([]int64{1, 4, null, 2, null, null, -3, 8, 5}, 3)
+	check(fmt.Sprint(result[AggregateMin]) == fmt.Sprint([]int64{1, 2, -3}), fmt.Sprint("min: ", result[AggregateMin]))
+	check(fmt.Sprint(result[AggregateMax]) == fmt.Sprint([]int64{4, 2, 8}), fmt.Sprint("max: ", result[AggregateMax]))
+	check(fmt.Sprint(result[AggregateAvg]) == fmt.Sprint([]int64{3, 2, 3}), fmt.Sprint("avg: ", result[AggregateAvg]))
+	check(fmt.Sprint(result[AggregateLast]) == fmt.Sprint([]int64{4, 2, 5}), fmt.Sprint("last: ", result[AggregateLast]))
+
+	result = aggregate([]int64{null, null, 7, 7}, 2)
+	check(result[AggregateAvg][0] == null && result[AggregateLast][1] == 7, fmt.Sprint("null: ", result))
+
+	var value, err = compressValues([]int64{null, null})
+	check(err == nil && value == nil, "all null should be skipped")
+	value, err = compressValues([]int64{6, null, -9})
+	var data, _ = compress.Decompress(value, nil)
+	check(err == nil && fmt.Sprint(data) == fmt.Sprint([]int64{6, null, -9}), fmt.Sprint("compress: ", data))
+}
+
+func TestRollup(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	_, err := New(nil, []*Tier{{Name: "7s", Interval: 7}}, 0)
+	check(err != nil, "interval should divide an hour")
+	_, err = New(nil, []*Tier{{Name: "1m", Interval: 60}, {Name: "1m", Interval: 300}}, 0)
+	check(err != nil, "duplicated tier name")
+
+	var dir string
+	dir, err = ioutil.TempDir("", "rollup")
+	check(err == nil, "create temp dir")
+	defer os.RemoveAll(dir)
+	var storage engine.Engine
+	storage, err = engine.NewLocalEngine(&engine.Conf{Dir: dir})
+	check(err == nil, fmt.Sprintf("create local engine error: %v", err))
+	defer storage.Close()
+
+	var day = 24 * time.Hour
+	var r *Rollup
+	r, err = New(storage, []*Tier{
+		{Name: "1h", Interval: 3600, Retention: 365 * day},
+		{Name: "1m", Interval: 60, Retention: 30 * day},
+	}, day)
+	check(err == nil && r.tierList[0].Name == "1m", "new rollup")
+
+	// 一个小时的数据，每秒的值为距离整点的秒数，第一个数据块为空
+	const hour = 7200
+	var infoList []*core.Info
+	for i := 1; i < 60; i++ {
+		var values = make([]int64, 60)
+		for j := range values {
+			values[j] = int64(i*60 + j)
+		}
+		var value, _ = compressValues(values)
+		infoList = append(infoList, &core.Info{
+			Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+			Timestamp: uint32(hour + i*60),
+			Count:     60,
+			Step:      1,
+			Items:     []*core.KVPair{{Key: "a", Value: value}},
+		})
+	}
+	check(storage.Save(infoList) == nil, "save raw data")
+	r.Observe(infoList)
+
+	// 整点之后等待迟到的数据
+	r.process(time.Unix(hour+3600, 0))
+	var res *core.InfoRange
+	res, err = storage.Query(&core.Query{Header: &core.Header{Service: "redis@1m", Hid: 1, Host: "h1"}}, 0, hour*2)
+	check(err == nil && res == nil, "hour should not be rolled up before the delay")
+
+	var now = time.Unix(hour+3600, 0).Add(processDelay)
+	r.process(now)
+	res, err = storage.Query(&core.Query{Header: &core.Header{Service: "redis@1m", Hid: 1, Host: "h1"}}, 0, hour*2)
+	check(err == nil && res != nil, fmt.Sprintf("query 1m tier error: %v", err))
+	check(res.Count == 3600 && res.Step == 1, fmt.Sprintf("1m tier header: %v", res))
+	var data = decodeRange(res)
+	check(len(data["a#avg"]) == 60 && data["a#avg"][0] == util.NullData, fmt.Sprint("1m avg: ", data["a#avg"]))
+	check(data["a#avg"][1] == 90 && data["a#min"][2] == 120 && data["a#max"][2] == 179 && data["a#last"][59] == 3599,
+		fmt.Sprint("1m data: ", data))
+
+	res, err = storage.Query(&core.Query{Header: &core.Header{Service: "redis@1h", Hid: 1, Host: "h1"}}, 0, hour*2)
+	check(err == nil && res != nil, fmt.Sprintf("query 1h tier error: %v", err))
+	data = decodeRange(res)
+	check(fmt.Sprint(data) == "map[a#avg:[1830] a#last:[3599] a#max:[3599] a#min:[60]]", fmt.Sprint("1h data: ", data))
+
+	// 请求的精度不小于1分钟时使用1m层级，展开为原始精度，之后的部分查询原始数据
+	var query = &core.Query{
+		Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+		KeyList:   []string{"a"},
+		TimeBegin: hour + 90,
+		TimeEnd:   hour + 7200,
+		Step:      300,
+		Aggregate: "max",
+	}
+	var rawBegin uint32
+	res, rawBegin, err = r.Query(query, now)
+	check(err == nil && res != nil && rawBegin == hour+3600, fmt.Sprintf("query rollup: %v %d", err, rawBegin))
+	check(res.Header.Service == "redis" && res.Count == 60 && res.Step == 1, fmt.Sprintf("rollup header: %v", res))
+	var _, blockMap, _ = engine.ParseRangeData(res.Data)
+	check(len(blockMap["a"]) == 59 && blockMap["a"][0].Timestamp == hour+60, fmt.Sprint("rollup blocks: ", len(blockMap["a"])))
+	data = decodeRange(res)
+	check(len(data["a"]) == 59*60 && data["a"][0] == 119 && data["a"][60] == 179, fmt.Sprint("rollup data: ", len(data["a"])))
+
+	// 精度小于层级间隔且在原始数据的保留时间内时不使用层级
+	query.Step = 30
+	res, rawBegin, err = r.Query(query, now)
+	check(err == nil && res == nil && rawBegin == query.TimeBegin, "query raw data")
+
+	// 超出原始数据的保留时间后，使用能覆盖的最细的层级
+	var tier = r.selectTier("redis", 1, hour, 0, time.Unix(hour, 0).Add(2*day))
+	check(tier != nil && tier.Name == "1m", fmt.Sprint("select tier: ", tier))
+	tier = r.selectTier("redis", 1, hour, 0, time.Unix(hour, 0).Add(31*day))
+	check(tier != nil && tier.Name == "1h", fmt.Sprint("select tier: ", tier))
+	tier = r.selectTier("redis", 1, hour, 0, time.Unix(hour, 0).Add(400*day))
+	check(tier != nil && tier.Name == "1h", fmt.Sprint("select tier: ", tier))
+	tier = r.selectTier("redis", 1, hour, 7200, time.Unix(hour, 0))
+	check(tier != nil && tier.Name == "1h", fmt.Sprint("select tier: ", tier))
+	tier = r.selectTier("redis", 60, hour/60, 60, time.Unix(hour, 0))
+	check(tier == nil, fmt.Sprint("tier interval should be larger than step: ", tier))
+
+	// meta中声明的保留时间覆盖全局配置
+	r.SetRetention("redis", Retention{RawRetention: 3 * day, "1h": day})
+	tier = r.selectTier("redis", 1, hour, 0, time.Unix(hour, 0).Add(2*day))
+	check(tier == nil, fmt.Sprint("raw data should cover the range: ", tier))
+	var retention, ok = r.RetentionOf("redis", "1m")
+	check(ok && retention == 30*day, fmt.Sprint("1m retention: ", retention))
+	retention, ok = r.RetentionOf("redis", "1h")
+	check(ok && retention == day, fmt.Sprint("1h retention: ", retention))
+	_, ok = r.RetentionOf("redis", "5m")
+	check(!ok, "5m tier is not enabled")
+	r.SetRetention("redis", Retention{"1h": day})
+
+	// 重启后从层级数据中获取采集间隔
+	var restarted *Rollup
+	restarted, err = New(storage, r.tierList, day)
+	check(err == nil, "new rollup")
+	query.Step = 60
+	res, _, err = restarted.Query(query, time.Unix(hour, 0).Add(2*day))
+	check(err == nil && res != nil && res.Count == 60, fmt.Sprint("query after restart: ", err))
+
+	// 原始数据与各个层级按照各自的保留时间删除
+	r.cleanup(time.Unix(hour, 0).Add(2 * day))
+	query.TimeBegin, query.TimeEnd = 0, hour*2
+	res, err = storage.Query(query, 0, hour*2)
+	check(err == nil && res == nil, "raw data should be deleted")
+	res, err = storage.Query(&core.Query{Header: &core.Header{Service: "redis@1m", Hid: 1, Host: "h1"}}, 0, hour*2)
+	check(err == nil && res != nil, "1m tier should be kept")
+	res, err = storage.Query(&core.Query{Header: &core.Header{Service: "redis@1h", Hid: 1, Host: "h1"}}, 0, hour*2)
+	check(err == nil && res == nil, "1h tier should be deleted by the retention of service")
+}
+
+// 记录层级数据块的存储引擎
+type recordEngine struct {
+	engine.Engine
+	saved []string
+}
+
+func (e *recordEngine) Save(infoList []*core.Info) error {
+	for _, it := range infoList {
+		e.saved = append(e.saved, fmt.Sprintf("%s:%d", it.Header.Service, it.Timestamp))
+	}
+	return e.Engine.Save(infoList)
+}
+
+func TestRecover(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	var dir, err = ioutil.TempDir("", "rollup")
+	check(err == nil, "create temp dir")
+	defer os.RemoveAll(dir)
+	var local engine.Engine
+	local, err = engine.NewLocalEngine(&engine.Conf{Dir: dir})
+	check(err == nil, fmt.Sprintf("create local engine error: %v", err))
+	defer local.Close()
+
+	// 三个小时的原始数据，每个小时一个数据块
+	var value, _ = compressValues(make([]int64, 3600))
+	for _, hour := range []uint32{7200, 10800, 14400} {
+		check(local.Save([]*core.Info{{
+			Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+			Timestamp: hour,
+			Count:     3600,
+			Step:      1,
+			Items:     []*core.KVPair{{Key: "a", Value: value}},
+		}}) == nil, "save raw data")
+	}
+
+	// 重启前只完成了第一个小时的聚合
+	var tierList = []*Tier{{Name: "1m", Interval: 60}}
+	var r *Rollup
+	r, err = New(local, tierList, 0)
+	check(err == nil, "new rollup")
+	check(r.rollupHour("redis", 1, 3600, 7200, hostKey{hid: 1, host: "h1"}) == nil, "rollup hour")
+
+	// 重启后第一次写入时，补做之前没有完成的小时，已经完成的不再重复
+	var storage = &recordEngine{Engine: local}
+	r, err = New(storage, tierList, 0)
+	check(err == nil, "new rollup")
+	r.Observe([]*core.Info{{
+		Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+		Timestamp: 14400,
+		Count:     3600,
+		Step:      1,
+	}})
+	r.process(time.Unix(18000, 0).Add(processDelay))
+	sort.Strings(storage.saved)
+	check(fmt.Sprint(storage.saved) == "[redis@1m:10800 redis@1m:14400]", fmt.Sprint("saved: ", storage.saved))
+}
+
+func TestQueryGap(t *testing.T) {
+	var check = func(statement bool, msg string) {
+		if !statement {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line[%d]: %s", line, msg)
+		}
+	}
+
+	var dir, err = ioutil.TempDir("", "rollup")
+	check(err == nil, "create temp dir")
+	defer os.RemoveAll(dir)
+	var storage engine.Engine
+	storage, err = engine.NewLocalEngine(&engine.Conf{Dir: dir})
+	check(err == nil, fmt.Sprintf("create local engine error: %v", err))
+	defer storage.Close()
+
+	var value, _ = compressValues(make([]int64, 3600))
+	for _, hour := range []uint32{7200, 10800, 14400} {
+		check(storage.Save([]*core.Info{{
+			Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+			Timestamp: hour,
+			Count:     3600,
+			Step:      1,
+			Items:     []*core.KVPair{{Key: "a", Value: value}},
+		}}) == nil, "save raw data")
+	}
+
+	// 中间的小时聚合失败
+	var day = 24 * time.Hour
+	var r *Rollup
+	r, err = New(storage, []*Tier{{Name: "1m", Interval: 60}}, day)
+	check(err == nil, "new rollup")
+	check(r.rollupHour("redis", 1, 3600, 7200, hostKey{hid: 1, host: "h1"}) == nil, "rollup hour")
+	check(r.rollupHour("redis", 1, 3600, 14400, hostKey{hid: 1, host: "h1"}) == nil, "rollup hour")
+
+	// 从第一个缺失的小时开始查询原始数据，之后的层级数据不返回
+	var query = &core.Query{
+		Header:    &core.Header{Service: "redis", Hid: 1, Host: "h1"},
+		KeyList:   []string{"a"},
+		TimeBegin: 7200,
+		TimeEnd:   17999,
+		Step:      300,
+	}
+	var res *core.InfoRange
+	var rawBegin uint32
+	res, rawBegin, err = r.Query(query, time.Unix(18000, 0).Add(processDelay))
+	check(err == nil && res != nil && rawBegin == 10800, fmt.Sprintf("query rollup: %v %d", err, rawBegin))
+	var _, blockMap, _ = engine.ParseRangeData(res.Data)
+	check(len(blockMap["a"]) == 60 && blockMap["a"][59].Timestamp == 10740, fmt.Sprint("rollup blocks: ", len(blockMap["a"])))
+
+	// 缺失的小时已经超出原始数据的保留时间时跳过
+	res, rawBegin, err = r.Query(query, time.Unix(18000, 0).Add(2*day))
+	check(err == nil && res != nil && rawBegin == 18000, fmt.Sprintf("query rollup: %v %d", err, rawBegin))
+	_, blockMap, _ = engine.ParseRangeData(res.Data)
+	check(len(blockMap["a"]) == 120, fmt.Sprint("rollup blocks: ", len(blockMap["a"])))
+}
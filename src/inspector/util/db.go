@@ -27,12 +27,16 @@ const (
 	MetaCollection           = "meta"
 	TaskListCollection       = "taskList"
 	TaskDistributeCollection = "taskDistribute"
+	PushListCollection       = "pushList"   // 通过remote_write推送数据的实例，格式与taskList相同
+	RecordRuleCollection     = "recordRule" // recording rule，key为规则名称
+	AlertRuleCollection      = "alertRule"  // 告警规则，key为规则名称
 
 	TaskDistributeName = "distribute"
 	Md5Name            = string(InnerLeadingMark) + "key_md5"
 	CommandName        = "cmds"
 	IntervalName       = "interval"
 	CountName          = "count"
+	PrecisionName      = "precision"
 	MetaSelectorName   = "selector"
 	MetaTargetName     = "target"
 	MetaBaseName       = "base"
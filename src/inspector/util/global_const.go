@@ -1,6 +1,11 @@
 package util
 
+// 数值以定点数int64的形式保存，真实值 = 存储值 / 10^precision
+// 采集与存储使用service在meta中配置的precision，没有配置时为0，即只保存整数部分
+// api_server计算与展示统一转换为FloatPrecision精度
 const (
-	FloatPrecision = 0
-	FloatMultiple  = 1
+	FloatPrecision = 3
+	FloatMultiple  = 1000
+
+	MaxPrecision = 9 // precision的上限，避免int64溢出
 )
@@ -0,0 +1,90 @@
+// trace id and timing breakdown passed through grpc metadata
+package grpc2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// trace id of the request, set by api_server and logged by every server
+	TraceIDKey = "inspector-trace-id"
+	// set when the client wants the timing breakdown in the trailer
+	TraceDebugKey = "inspector-trace-debug"
+	// timing breakdown returned in the trailer, each value is "name=nanoseconds"
+	TraceTimingKey = "inspector-trace-timing"
+)
+
+// one step of the timing breakdown on the server side
+type TraceStep struct {
+	Name     string
+	Duration time.Duration
+}
+
+// NewTraceID returns 8 random bytes as a 16-character hex id
+func NewTraceID() string {
+	var buf = make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// WithTrace attaches the trace id to the outgoing context of the client
+func WithTrace(ctx context.Context, id string, debug bool) context.Context {
+	if len(id) == 0 {
+		return ctx
+	}
+	var kv = []string{TraceIDKey, id}
+	if debug {
+		kv = append(kv, TraceDebugKey, "1")
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// TraceFromContext returns the trace id and whether the client wants the timing breakdown
+func TraceFromContext(ctx context.Context) (string, bool) {
+	var md, ok = metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	var id string
+	if values := md.Get(TraceIDKey); len(values) > 0 {
+		id = values[0]
+	}
+	return id, len(md.Get(TraceDebugKey)) > 0
+}
+
+// SetTraceTiming returns the timing breakdown to the client in the trailer
+func SetTraceTiming(ctx context.Context, steps []TraceStep) error {
+	var values = make([]string, 0, len(steps))
+	for _, it := range steps {
+		values = append(values, fmt.Sprintf("%s=%d", it.Name, it.Duration.Nanoseconds()))
+	}
+	return grpc.SetTrailer(ctx, metadata.MD{TraceTimingKey: values})
+}
+
+// ParseTraceTiming parses the timing breakdown from the trailer, invalid values are skipped
+func ParseTraceTiming(md metadata.MD) []TraceStep {
+	var steps []TraceStep
+	for _, it := range md.Get(TraceTimingKey) {
+		var pos = strings.LastIndex(it, "=")
+		if pos < 0 {
+			continue
+		}
+		var n, err = strconv.ParseInt(it[pos+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		steps = append(steps, TraceStep{Name: it[:pos], Duration: time.Duration(n)})
+	}
+	return steps
+}
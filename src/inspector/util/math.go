@@ -16,6 +16,8 @@ package util
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 )
 
 const (
@@ -147,4 +149,140 @@ func IntReverse(n int) int {
 */
 func Int32Reverse(n int32) int32 {
 	return int32(IntReverse(int(n)))
-}
\ No newline at end of file
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  PrecisionMultiple
+//  Description:  返回10^precision，precision超出[0, MaxPrecision]时按边界处理
+// =====================================================================================
+*/
+func PrecisionMultiple(precision int) int64 {
+	if precision < 0 {
+		precision = 0
+	}
+	if precision > MaxPrecision {
+		precision = MaxPrecision
+	}
+	var multiple int64 = 1
+	for i := 0; i < precision; i++ {
+		multiple *= 10
+	}
+	return multiple
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Float2Fixed
+//  Description:  将浮点数转换为定点数，四舍五入到1/multiple
+// =====================================================================================
+*/
+func Float2Fixed(v float64, multiple int64) (int64, error) {
+	var x = math.Round(v * float64(multiple))
+	if math.IsNaN(x) || x >= float64(NullData) || x <= float64(INT64_MIN) {
+		return 0, fmt.Errorf("value[%v] out of range with multiple[%d]", v, multiple)
+	}
+	return int64(x), nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ParseFixedValue
+//  Description:  将采集到的数值转换为定点数，bool转换为0或1
+// =====================================================================================
+*/
+func ParseFixedValue(input interface{}, multiple int64) (ret int64, err error) {
+	switch v := input.(type) {
+	case int:
+		ret, err = Int2Fixed(int64(v), multiple)
+	case uint:
+		ret, err = Uint2Fixed(uint64(v), multiple)
+	case int32:
+		ret, err = Int2Fixed(int64(v), multiple)
+	case uint32:
+		ret, err = Int2Fixed(int64(v), multiple)
+	case bool:
+		if v == true {
+			ret = multiple
+		} else {
+			ret = 0
+		}
+	case int64:
+		ret, err = Int2Fixed(v, multiple)
+	case uint64:
+		ret, err = Uint2Fixed(v, multiple)
+	case float64:
+		ret, err = Float2Fixed(v, multiple)
+	case float32:
+		ret, err = Float2Fixed(float64(v), multiple)
+	default:
+		err = fmt.Errorf("unknown type[%v]", reflect.TypeOf(v))
+	}
+
+	return ret, err
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Int2Fixed
+//  Description:  将整数转换为定点数，与Float2Fixed一样，结果不能落在NullData上，
+//                也不能小于等于INT64_MIN
+// =====================================================================================
+*/
+func Int2Fixed(v int64, multiple int64) (int64, error) {
+	if multiple > 0 && (v > (NullData-1)/multiple || v < (INT64_MIN+1)/multiple) {
+		return 0, fmt.Errorf("value[%d] out of range with multiple[%d]", v, multiple)
+	}
+	return v * multiple, nil
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  Uint2Fixed
+//  Description:  无符号整数超过INT64_MAX时直接认为越界
+// =====================================================================================
+*/
+func Uint2Fixed(v uint64, multiple int64) (int64, error) {
+	if v > uint64(INT64_MAX) {
+		return 0, fmt.Errorf("value[%d] out of range with multiple[%d]", v, multiple)
+	}
+	return Int2Fixed(int64(v), multiple)
+}
+
+/*
+// ===  FUNCTION  ======================================================================
+//         Name:  ConvertPrecision
+//  Description:  原地将定点数从from精度转换为to精度，空值保持不变
+//                降低精度时四舍五入，提高精度时越界的点置为空值
+// =====================================================================================
+*/
+func ConvertPrecision(data []int64, from, to int) []int64 {
+	if from == to {
+		return data
+	}
+	if from < to {
+		var multiple = PrecisionMultiple(to - from)
+		for i, it := range data {
+			if it == NullData {
+				continue
+			}
+			var err error
+			if data[i], err = Int2Fixed(it, multiple); err != nil {
+				data[i] = NullData
+			}
+		}
+		return data
+	}
+	var multiple = PrecisionMultiple(from - to)
+	for i, it := range data {
+		if it == NullData {
+			continue
+		}
+		if it >= 0 {
+			data[i] = (it + multiple/2) / multiple
+		} else {
+			data[i] = (it - multiple/2) / multiple
+		}
+	}
+	return data
+}
@@ -16,6 +16,7 @@ package util
 
 import (
 	"fmt"
+	"math"
 	"runtime"
 	"testing"
 
@@ -168,6 +169,55 @@ func TestMath(t *testing.T) {
 	var r32 = Int32Reverse(123)
 	check(r32 == 321, "test")
 
+	// 定点数
+	check(PrecisionMultiple(0) == 1 && PrecisionMultiple(3) == 1000, "test")
+	check(PrecisionMultiple(-1) == 1 && PrecisionMultiple(100) == 1000000000, "test")
+
+	var x, err = ParseFixedValue(1.2345, 1000)
+	check(err == nil && x == 1235, "test")
+	x, err = ParseFixedValue(float32(-0.5), 10)
+	check(err == nil && x == -5, "test")
+	x, err = ParseFixedValue(7.9, 1)
+	check(err == nil && x == 8, "test")
+	x, err = ParseFixedValue(int32(12), 100)
+	check(err == nil && x == 1200, "test")
+	x, err = ParseFixedValue(true, 1000)
+	check(err == nil && x == 1000, "test")
+	_, err = ParseFixedValue(math.NaN(), 1000)
+	check(err != nil, "test")
+	_, err = ParseFixedValue(1e18, 1000)
+	check(err != nil, "test")
+	_, err = ParseFixedValue("1", 1000)
+	check(err != nil, "test")
+
+	// 整数溢出
+	x, err = ParseFixedValue(int64(9223372036854775), 1000)
+	check(err == nil && x == 9223372036854775000, "test")
+	_, err = ParseFixedValue(int64(9223372036854776), 1000)
+	check(err != nil, "test")
+	_, err = ParseFixedValue(int64(-9223372036854776), 1000)
+	check(err != nil, "test")
+	_, err = ParseFixedValue(uint64(1)<<63, 1)
+	check(err != nil, "test")
+	_, err = ParseFixedValue(UINT64_MAX, 1000)
+	check(err != nil, "test")
+	_, err = ParseFixedValue(INT64_MAX, 1)
+	check(err != nil, "test")
+	x, err = ParseFixedValue(uint64(INT64_MAX-1), 1)
+	check(err == nil && x == INT64_MAX-1, "test")
+	x, err = ParseFixedValue(UINT32_MAX, 1000)
+	check(err == nil && x == 4294967295000, "test")
+
+	var data = []int64{1, -25, NullData, 1250}
+	ConvertPrecision(data, 0, 2)
+	check(data[0] == 100 && data[1] == -2500 && data[2] == NullData && data[3] == 125000, "test")
+	ConvertPrecision(data, 3, 1)
+	check(data[0] == 1 && data[1] == -25 && data[2] == NullData && data[3] == 1250, "test")
+
+	data = []int64{9223372036854775, 9223372036854776, -9223372036854776, 7}
+	ConvertPrecision(data, 0, 3)
+	check(data[0] == 9223372036854775000 && data[1] == NullData && data[2] == NullData && data[3] == 7000, "test")
+
 	check(true, "test")
 }
 